@@ -0,0 +1,135 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// allowList is the set of devices that may announce and be looked up, read
+// from a file with one device ID per line. Empty lines and lines starting
+// with # are ignored. The file is reloaded when it changes; if it can't be
+// read or parsed, the previous list stays in effect.
+type allowList struct {
+	path string
+
+	mut     sync.RWMutex
+	devices map[protocol.DeviceID]struct{}
+	modTime time.Time
+	size    int64
+}
+
+func newAllowList(path string) (*allowList, error) {
+	a := &allowList{path: path}
+	if _, err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// allowed returns whether the device is on the list. Everyone is allowed
+// when there is no list.
+func (a *allowList) allowed(id *protocol.DeviceID) bool {
+	if a == nil {
+		return true
+	}
+	a.mut.RLock()
+	_, ok := a.devices[*id]
+	a.mut.RUnlock()
+	return ok
+}
+
+func (a *allowList) Serve(ctx context.Context) error {
+	t := time.NewTicker(allowListReloadInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if changed, err := a.reload(); err != nil {
+				log.Println("Error reloading allow list:", err)
+				allowListReloadsTotal.WithLabelValues("error").Inc()
+			} else if changed {
+				allowListReloadsTotal.WithLabelValues("success").Inc()
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// reload reads the file if it changed since the last time, and returns
+// whether it did.
+func (a *allowList) reload() (bool, error) {
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		return false, err
+	}
+	a.mut.RLock()
+	unchanged := a.devices != nil && fi.ModTime().Equal(a.modTime) && fi.Size() == a.size
+	a.mut.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	fd, err := os.Open(a.path)
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+	devices, err := parseAllowList(fd)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", a.path, err)
+	}
+
+	a.mut.Lock()
+	a.devices = devices
+	a.modTime = fi.ModTime()
+	a.size = fi.Size()
+	a.mut.Unlock()
+
+	allowListDevices.Set(float64(len(devices)))
+	log.Printf("Loaded allow list with %d devices", len(devices))
+	return true, nil
+}
+
+func parseAllowList(r io.Reader) (map[protocol.DeviceID]struct{}, error) {
+	devices := make(map[protocol.DeviceID]struct{})
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		id, err := protocol.DeviceIDFromString(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		devices[id] = struct{}{}
+	}
+	return devices, sc.Err()
+}
+
+// hasBearerToken returns whether the request carries the given token in its
+// Authorization header.
+func hasBearerToken(req *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -45,6 +45,8 @@ type apiSrv struct {
 	db             database
 	listener       net.Listener
 	repl           replicator // optional
+	allowList      *allowList // optional
+	queryToken     string     // optional
 	useHTTP        bool
 	compression    bool
 	gzipWriters    sync.Pool
@@ -66,12 +68,14 @@ type contextKey int
 
 const idKey contextKey = iota
 
-func newAPISrv(addr string, cert tls.Certificate, db database, repl replicator, useHTTP, compression bool) *apiSrv {
+func newAPISrv(addr string, cert tls.Certificate, db database, repl replicator, allowList *allowList, queryToken string, useHTTP, compression bool) *apiSrv {
 	return &apiSrv{
 		addr:        addr,
 		cert:        cert,
 		db:          db,
 		repl:        repl,
+		allowList:   allowList,
+		queryToken:  queryToken,
 		useHTTP:     useHTTP,
 		compression: compression,
 		seenTracker: &retryAfterTracker{
@@ -195,6 +199,17 @@ func (s *apiSrv) handler(w http.ResponseWriter, req *http.Request) {
 func (s *apiSrv) handleGET(w http.ResponseWriter, req *http.Request) {
 	reqID := req.Context().Value(idKey).(requestID)
 
+	if s.queryToken != "" && !hasBearerToken(req, s.queryToken) {
+		if debug {
+			log.Println(reqID, "missing or bad token")
+		}
+		lookupRequestsTotal.WithLabelValues("unauthorized").Inc()
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	deviceID, err := protocol.DeviceIDFromString(req.URL.Query().Get("device"))
 	if err != nil {
 		if debug {
@@ -206,6 +221,18 @@ func (s *apiSrv) handleGET(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if !s.allowList.allowed(&deviceID) {
+		// Answer as if we'd never seen the device, so as not to reveal
+		// what's on the list.
+		if debug {
+			log.Println(reqID, "lookup of device not on allow list:", deviceID)
+		}
+		lookupRequestsTotal.WithLabelValues("not_allowed").Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(notFoundRetryUnknownMaxSeconds))
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
 	rec, err := s.db.get(&deviceID)
 	if err != nil {
 		// some sort of internal error
@@ -281,6 +308,16 @@ func (s *apiSrv) handlePOST(remoteAddr *net.TCPAddr, w http.ResponseWriter, req
 
 	deviceID := protocol.NewDeviceID(rawCert)
 
+	if !s.allowList.allowed(&deviceID) {
+		if debug {
+			log.Println(reqID, "announcement from device not on allow list:", deviceID)
+		}
+		announceRequestsTotal.WithLabelValues("not_allowed").Inc()
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	addresses := fixupAddresses(remoteAddr, ann.Addresses)
 	if len(addresses) == 0 {
 		if debug {
@@ -18,6 +18,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/tlsutil"
@@ -111,22 +112,10 @@ func BenchmarkAPIRequests(b *testing.B) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go db.Serve(ctx)
-	api := newAPISrv("127.0.0.1:0", tls.Certificate{}, db, nil, true, true)
+	api := newAPISrv("127.0.0.1:0", tls.Certificate{}, db, nil, nil, "", true, true)
 	srv := httptest.NewServer(http.HandlerFunc(api.handler))
 
-	kf := b.TempDir() + "/cert"
-	crt, err := tlsutil.NewCertificate(kf+".crt", kf+".key", "localhost", 7)
-	if err != nil {
-		b.Fatal(err)
-	}
-	certBs, err := os.ReadFile(kf + ".crt")
-	if err != nil {
-		b.Fatal(err)
-	}
-	certBs = regexp.MustCompile(`---[^\n]+---\n`).ReplaceAll(certBs, nil)
-	certString := string(strings.ReplaceAll(string(certBs), "\n", " "))
-
-	devID := protocol.NewDeviceID(crt.Certificate[0])
+	devID, certString := newTestCertHeader(b)
 	devIDString := devID.String()
 
 	b.Run("Announce", func(b *testing.B) {
@@ -181,3 +170,117 @@ func BenchmarkAPIRequests(b *testing.B) {
 		}
 	})
 }
+
+// newTestCertHeader returns a new device ID and its certificate, formatted
+// as Traefik passes it on.
+func newTestCertHeader(tb testing.TB) (protocol.DeviceID, string) {
+	tb.Helper()
+	kf := tb.TempDir() + "/cert"
+	crt, err := tlsutil.NewCertificate(kf+".crt", kf+".key", "localhost", 7)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	certBs, err := os.ReadFile(kf + ".crt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	certBs = regexp.MustCompile(`---[^\n]+---\n`).ReplaceAll(certBs, nil)
+	certString := string(strings.ReplaceAll(string(certBs), "\n", " "))
+	return protocol.NewDeviceID(crt.Certificate[0]), certString
+}
+
+func TestAccessControl(t *testing.T) {
+	db := newInMemoryStore(t.TempDir(), 0, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Serve(ctx)
+
+	allowedID, allowedCert := newTestCertHeader(t)
+	deniedID, deniedCert := newTestCertHeader(t)
+
+	listFile := t.TempDir() + "/allow"
+	if err := os.WriteFile(listFile, []byte("# our devices\n\n"+allowedID.String()+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	al, err := newAllowList(listFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := newAPISrv("127.0.0.1:0", tls.Certificate{}, db, nil, al, "s3cret", true, false)
+	srv := httptest.NewServer(http.HandlerFunc(api.handler))
+	defer srv.Close()
+
+	announce := func(id protocol.DeviceID, cert string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v2/?device="+id.String(), strings.NewReader(`{"addresses":["tcp://192.0.2.42:22000"]}`))
+		req.Header.Set("X-Forwarded-Tls-Client-Cert", cert)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	lookup := func(id protocol.DeviceID, token string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v2/?device="+id.String(), nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := announce(allowedID, allowedCert); code != http.StatusNoContent {
+		t.Errorf("announce from allowed device: got %d", code)
+	}
+	if code := announce(deniedID, deniedCert); code != http.StatusForbidden {
+		t.Errorf("announce from other device: got %d", code)
+	}
+	if code := lookup(allowedID, "s3cret"); code != http.StatusOK {
+		t.Errorf("lookup of allowed device: got %d", code)
+	}
+	if code := lookup(allowedID, ""); code != http.StatusUnauthorized {
+		t.Errorf("lookup without token: got %d", code)
+	}
+	if code := lookup(allowedID, "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("lookup with wrong token: got %d", code)
+	}
+	if code := lookup(deniedID, "s3cret"); code != http.StatusNotFound {
+		t.Errorf("lookup of other device: got %d", code)
+	}
+
+	// Swap the devices on the list; the change is picked up on reload.
+	if err := os.WriteFile(listFile, []byte(deniedID.String()+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(listFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := al.reload(); err != nil || !changed {
+		t.Fatalf("reload: %v, %v", changed, err)
+	}
+	if code := announce(allowedID, allowedCert); code != http.StatusForbidden {
+		t.Errorf("announce from removed device: got %d", code)
+	}
+	if code := announce(deniedID, deniedCert); code != http.StatusNoContent {
+		t.Errorf("announce from added device: got %d", code)
+	}
+
+	// A broken list leaves the previous one in effect.
+	if err := os.WriteFile(listFile, []byte("banana\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.reload(); err == nil {
+		t.Error("expected an error reloading a broken list")
+	}
+	if !al.allowed(&deniedID) {
+		t.Error("previous list should stay in effect")
+	}
+}
@@ -113,7 +113,9 @@ func (s *inMemoryStore) merge(key *protocol.DeviceID, addrs []*discosrv.Database
 	}
 
 	if oldRec, ok := s.m.Load(*key); ok {
-		newRec = merge(oldRec, newRec)
+		// Merge into a copy, as the stored record may be read concurrently
+		// by replication and lookups.
+		newRec = merge(proto.Clone(oldRec).(*discosrv.DatabaseRecord), newRec)
 	}
 	s.m.Store(*key, newRec)
 
@@ -135,7 +137,10 @@ func (s *inMemoryStore) get(key *protocol.DeviceID) (*discosrv.DatabaseRecord, e
 		return &discosrv.DatabaseRecord{}, nil
 	}
 
-	rec.Addresses = expire(rec.Addresses, s.clock.Now())
+	rec = &discosrv.DatabaseRecord{
+		Addresses: expire(slices.Clone(rec.Addresses), s.clock.Now()),
+		Seen:      rec.Seen,
+	}
 	databaseOperations.WithLabelValues(dbOpGet, dbResSuccess).Inc()
 	return rec, nil
 }
@@ -53,6 +53,9 @@ const (
 
 	// Size of the replication outbox channel
 	replicationOutboxSize = 10000
+
+	// How often to check the allow list file for changes
+	allowListReloadInterval = 10 * time.Second
 )
 
 var debug = false
@@ -74,6 +77,12 @@ type CLI struct {
 	DBS3AccessKeyID string `name:"db-s3-access-key-id" group:"Database (S3 backup)" hidden:"true" help:"S3 access key ID for database" env:"DISCOVERY_DB_S3_ACCESS_KEY_ID"`
 	DBS3SecretKey   string `name:"db-s3-secret-key" group:"Database (S3 backup)" hidden:"true" help:"S3 secret key for database" env:"DISCOVERY_DB_S3_SECRET_KEY"`
 
+	AllowList  string `group:"Access control" help:"File with the device IDs allowed to announce and be looked up, one per line; reloaded on change" env:"DISCOVERY_ALLOW_LIST"`
+	QueryToken string `group:"Access control" help:"Bearer token required for lookups" env:"DISCOVERY_QUERY_TOKEN"`
+
+	ReplicationListen string   `group:"Peer replication" help:"Listen address for replication peers" env:"DISCOVERY_REPLICATION_LISTEN"`
+	ReplicationPeers  []string `group:"Peer replication" help:"Replication peers, as DEVICEID@host:port" env:"DISCOVERY_REPLICATION_PEERS"`
+
 	AMQPAddress string `group:"AMQP replication" hidden:"true" help:"Address to AMQP broker" env:"DISCOVERY_AMQP_ADDRESS"`
 
 	Debug   bool `short:"d" help:"Print debug output" env:"DISCOVERY_DEBUG"`
@@ -94,8 +103,22 @@ func main() {
 
 	buildInfo.WithLabelValues(build.Version, runtime.Version(), build.User, build.Date.UTC().Format("2006-01-02T15:04:05Z")).Set(1)
 
+	var peers []replicationPeer
+	for _, p := range cli.ReplicationPeers {
+		peer, err := parseReplicationPeer(p)
+		if err != nil {
+			log.Fatalln("Bad replication peer:", err)
+		}
+		peers = append(peers, peer)
+	}
+	usePeerReplication := cli.ReplicationListen != "" || len(peers) > 0
+	if usePeerReplication && cli.AMQPAddress != "" {
+		log.Fatalln("Peer replication and AMQP replication are mutually exclusive")
+	}
+
+	// The certificate identifies us to clients, and to replication peers.
 	var cert tls.Certificate
-	if !cli.HTTP {
+	if !cli.HTTP || usePeerReplication {
 		var err error
 		cert, err = tls.LoadX509KeyPair(cli.Cert, cli.Key)
 		if os.IsNotExist(err) {
@@ -131,17 +154,33 @@ func main() {
 	db := newInMemoryStore(cli.DBDir, cli.DBFlushInterval, s3c)
 	main.Add(db)
 
-	// If we have an AMQP broker for replication, start that
+	// If we have an AMQP broker or peers for replication, start that
 	var repl replicator
 	if cli.AMQPAddress != "" {
 		clientID := rand.String(10)
 		kr := newAMQPReplicator(cli.AMQPAddress, clientID, db)
 		main.Add(kr)
 		repl = kr
+	} else if usePeerReplication {
+		pr := newPeerReplicator(cli.ReplicationListen, peers, cert, db)
+		main.Add(pr)
+		repl = pr
+	}
+
+	// If we have an allow list, only the devices on it may announce and be
+	// looked up.
+	var al *allowList
+	if cli.AllowList != "" {
+		var err error
+		al, err = newAllowList(cli.AllowList)
+		if err != nil {
+			log.Fatalln("Failed to load allow list:", err)
+		}
+		main.Add(al)
 	}
 
 	// Start the main API server.
-	qs := newAPISrv(cli.Listen, cert, db, repl, cli.HTTP, cli.Compression)
+	qs := newAPISrv(cli.Listen, cert, db, repl, al, cli.QueryToken, cli.HTTP, cli.Compression)
 	main.Add(qs)
 
 	// If we have a metrics port configured, start a metrics handler.
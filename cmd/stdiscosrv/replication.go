@@ -0,0 +1,447 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thejerf/suture/v4"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syncthing/syncthing/internal/gen/discosrv"
+	"github.com/syncthing/syncthing/internal/protoutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Peer replication lets discovery servers keep each other up to date
+// without a message broker. Each server connects to each of its peers over
+// HTTP/2 with mutual TLS, the peers being identified by the device IDs of
+// their certificates, and requests a stream of records from it. The stream
+// starts with everything the peer has in its database, so that we catch up
+// on whatever we missed while disconnected, and continues with the
+// announcements the peer receives. Records are framed as in the database
+// file: a four byte length followed by a ReplicationRecord.
+
+const (
+	replicationPath = "/replication"
+
+	// Largest record we accept; a record is one device's addresses.
+	replicationMaxRecordSize = 1 << 20
+	// How long the stream may be quiet before we check the connection
+	// with a ping, and how long to wait for the answer.
+	replicationReadIdleTimeout = 30 * time.Second
+	replicationPingTimeout     = 15 * time.Second
+)
+
+// replicationPeer is another discovery server we replicate with, given as
+// DEVICEID@host:port.
+type replicationPeer struct {
+	id   protocol.DeviceID
+	addr string
+}
+
+func parseReplicationPeer(s string) (replicationPeer, error) {
+	idStr, addr, ok := strings.Cut(s, "@")
+	if !ok || addr == "" {
+		return replicationPeer{}, fmt.Errorf("replication peer %q: expected DEVICEID@host:port", s)
+	}
+	id, err := protocol.DeviceIDFromString(idStr)
+	if err != nil {
+		return replicationPeer{}, fmt.Errorf("replication peer %q: %w", s, err)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return replicationPeer{}, fmt.Errorf("replication peer %q: %w", s, err)
+	}
+	return replicationPeer{id: id, addr: addr}, nil
+}
+
+type peerReplicator struct {
+	suture.Service
+	server *replicationServer
+}
+
+func newPeerReplicator(listen string, peers []replicationPeer, cert tls.Certificate, db *inMemoryStore) *peerReplicator {
+	svc := suture.New("peerReplicator", suture.Spec{PassThroughPanics: true})
+
+	server := newReplicationServer(listen, peers, cert, db)
+	if listen != "" {
+		svc.Add(server)
+	}
+	for _, peer := range peers {
+		svc.Add(newReplicationClient(peer, cert, db))
+	}
+
+	return &peerReplicator{
+		Service: svc,
+		server:  server,
+	}
+}
+
+func (r *peerReplicator) send(key *protocol.DeviceID, ps []*discosrv.DatabaseAddress, seen int64) {
+	r.server.send(key, ps, seen)
+}
+
+// replicationServer streams records to the peers connected to it.
+type replicationServer struct {
+	listen string
+	cert   tls.Certificate
+	db     *inMemoryStore
+	peers  map[protocol.DeviceID]struct{}
+
+	mut  sync.Mutex
+	subs map[*replicationSub]struct{}
+}
+
+// replicationSub is the outbox for one connected peer. The peer is dropped
+// when it doesn't keep up, so that it reconnects and catches up instead.
+type replicationSub struct {
+	outbox  chan *discosrv.ReplicationRecord
+	dropped chan struct{}
+}
+
+func newReplicationServer(listen string, peers []replicationPeer, cert tls.Certificate, db *inMemoryStore) *replicationServer {
+	s := &replicationServer{
+		listen: listen,
+		cert:   cert,
+		db:     db,
+		peers:  make(map[protocol.DeviceID]struct{}, len(peers)),
+		subs:   make(map[*replicationSub]struct{}),
+	}
+	for _, peer := range peers {
+		s.peers[peer.id] = struct{}{}
+	}
+	return s
+}
+
+func (s *replicationServer) tlsConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{s.cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS13,
+		NextProtos:   []string{"h2"},
+	}
+}
+
+func (s *replicationServer) Serve(ctx context.Context) error {
+	listener, err := tls.Listen("tcp", s.listen, s.tlsConfig())
+	if err != nil {
+		log.Println("Replication listen:", err)
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(replicationPath, s.handle)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadTimeout,
+		MaxHeaderBytes:    httpMaxHeaderBytes,
+	}
+	if !debug {
+		srv.ErrorLog = log.New(io.Discard, "", 0)
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	err = srv.Serve(listener)
+	if ctx.Err() != nil {
+		return nil
+	}
+	log.Println("Replication serve:", err)
+	return err
+}
+
+func (s *replicationServer) String() string {
+	return fmt.Sprintf("replicationServer(%q)", s.listen)
+}
+
+func (s *replicationServer) handle(w http.ResponseWriter, req *http.Request) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	id := protocol.NewDeviceID(req.TLS.PeerCertificates[0].Raw)
+	if _, ok := s.peers[id]; !ok {
+		log.Println("Replication connection from unknown peer", id)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming Unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before catching up, so that nothing falls between the two.
+	// Records might be sent twice, which merging takes care of.
+	sub := s.subscribe()
+	defer s.unsubscribe(sub)
+
+	log.Println("Replication peer", id, "connected from", req.RemoteAddr)
+	defer log.Println("Replication peer", id, "disconnected")
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	bw := bufio.NewWriter(w)
+
+	n, err := s.catchUp(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Println("Replication catch-up to", id, err)
+		return
+	}
+	flusher.Flush()
+	if debug {
+		log.Println("Replication caught up", id, "with", n, "records")
+	}
+
+	var buf []byte
+	for {
+		select {
+		case rec := <-sub.outbox:
+			buf, err = writeReplicationRecord(bw, buf, rec)
+			if err != nil {
+				replicationSendsTotal.WithLabelValues("error").Inc()
+				log.Println("Replication send to", id, err)
+				return
+			}
+			replicationSendsTotal.WithLabelValues("success").Inc()
+			if len(sub.outbox) > 0 {
+				// More to come; send them all at once.
+				continue
+			}
+			if err := bw.Flush(); err != nil {
+				log.Println("Replication send to", id, err)
+				return
+			}
+			flusher.Flush()
+
+		case <-sub.dropped:
+			log.Println("Replication peer", id, "can't keep up; disconnecting")
+			return
+
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// catchUp writes all current records to w.
+func (s *replicationServer) catchUp(w io.Writer) (int, error) {
+	var buf []byte
+	var err error
+	now := s.db.clock.Now().UnixNano()
+	i, n := 0, 0
+	s.db.m.Range(func(key protocol.DeviceID, value *discosrv.DatabaseRecord) bool {
+		if i%1000 == 0 {
+			runtime.Gosched()
+		}
+		i++
+
+		var addrs []*discosrv.DatabaseAddress
+		for _, addr := range value.Addresses {
+			if addr.Expires >= now {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			// Nothing the peer could make use of.
+			return true
+		}
+		buf, err = writeReplicationRecord(w, buf, &discosrv.ReplicationRecord{
+			Key:       key[:],
+			Addresses: addrs,
+			Seen:      value.Seen,
+		})
+		n++
+		return err == nil
+	})
+	return n, err
+}
+
+func (s *replicationServer) subscribe() *replicationSub {
+	sub := &replicationSub{
+		outbox:  make(chan *discosrv.ReplicationRecord, replicationOutboxSize),
+		dropped: make(chan struct{}),
+	}
+	s.mut.Lock()
+	s.subs[sub] = struct{}{}
+	s.mut.Unlock()
+	return sub
+}
+
+func (s *replicationServer) unsubscribe(sub *replicationSub) {
+	s.mut.Lock()
+	delete(s.subs, sub)
+	s.mut.Unlock()
+}
+
+func (s *replicationServer) send(key *protocol.DeviceID, ps []*discosrv.DatabaseAddress, seen int64) {
+	rec := &discosrv.ReplicationRecord{
+		Key:       key[:],
+		Addresses: ps,
+		Seen:      seen,
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for sub := range s.subs {
+		// The send should never block. A peer that falls behind is
+		// disconnected, and catches up when it reconnects.
+		select {
+		case sub.outbox <- rec:
+		default:
+			replicationSendsTotal.WithLabelValues("drop").Inc()
+			close(sub.dropped)
+			delete(s.subs, sub)
+		}
+	}
+}
+
+// replicationClient receives records from a peer and merges them into the
+// database.
+type replicationClient struct {
+	peer   replicationPeer
+	client *http.Client
+	db     database
+}
+
+func newReplicationClient(peer replicationPeer, cert tls.Certificate, db database) *replicationClient {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+			// The peer is verified by its device ID, not the usual
+			// certificate chain.
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: verifyPeerDeviceID(peer.id),
+		},
+		ForceAttemptHTTP2: true,
+	}
+	if h2, err := http2.ConfigureTransports(tr); err == nil {
+		h2.ReadIdleTimeout = replicationReadIdleTimeout
+		h2.PingTimeout = replicationPingTimeout
+	}
+	return &replicationClient{
+		peer:   peer,
+		client: &http.Client{Transport: tr},
+		db:     db,
+	}
+}
+
+func (c *replicationClient) Serve(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+c.peer.addr+replicationPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("replication connect: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("replication connect: %s", resp.Status)
+	}
+	log.Println("Replicating from", c.peer.id, "at", c.peer.addr)
+
+	br := bufio.NewReader(resp.Body)
+	var buf []byte
+	for {
+		var rec discosrv.ReplicationRecord
+		buf, err = readReplicationRecord(br, buf, &rec)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			replicationRecvsTotal.WithLabelValues("error").Inc()
+			return fmt.Errorf("replication receive: %w", err)
+		}
+		id, err := protocol.DeviceIDFromBytes(rec.Key)
+		if err != nil {
+			log.Println("Replication device ID:", err)
+			replicationRecvsTotal.WithLabelValues("error").Inc()
+			continue
+		}
+		if err := c.db.merge(&id, rec.Addresses, rec.Seen); err != nil {
+			return fmt.Errorf("replication database merge: %w", err)
+		}
+		replicationRecvsTotal.WithLabelValues("success").Inc()
+	}
+}
+
+func (c *replicationClient) String() string {
+	return fmt.Sprintf("replicationClient(%s@%s)", c.peer.id.Short(), c.peer.addr)
+}
+
+func verifyPeerDeviceID(id protocol.DeviceID) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate")
+		}
+		if got := protocol.NewDeviceID(rawCerts[0]); got != id {
+			return fmt.Errorf("unexpected device ID %s", got)
+		}
+		return nil
+	}
+}
+
+// writeReplicationRecord writes the length prefixed record to w, using and
+// returning buf for the marshalled data.
+func writeReplicationRecord(w io.Writer, buf []byte, rec *discosrv.ReplicationRecord) ([]byte, error) {
+	size := proto.Size(rec)
+	if size+4 > len(buf) {
+		buf = make([]byte, size+4)
+	}
+	n, err := protoutil.MarshalTo(buf[4:], rec)
+	if err != nil {
+		return buf, err
+	}
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	_, err = w.Write(buf[:n+4])
+	return buf, err
+}
+
+// readReplicationRecord reads a length prefixed record from r, using and
+// returning buf for the marshalled data.
+func readReplicationRecord(r io.Reader, buf []byte, rec *discosrv.ReplicationRecord) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return buf, err
+	}
+	if n > replicationMaxRecordSize {
+		return buf, fmt.Errorf("record size %d too large", n)
+	}
+	if int(n) > len(buf) {
+		buf = make([]byte, n)
+	}
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return buf, err
+	}
+	return buf, proto.Unmarshal(buf[:n], rec)
+}
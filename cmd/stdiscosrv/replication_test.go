@@ -0,0 +1,192 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syncthing/syncthing/internal/gen/discosrv"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestParseReplicationPeer(t *testing.T) {
+	id := protocol.NewDeviceID([]byte{1, 2, 3})
+	peer, err := parseReplicationPeer(id.String() + "@192.0.2.42:19200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.id != id || peer.addr != "192.0.2.42:19200" {
+		t.Errorf("got %v", peer)
+	}
+
+	for _, bad := range []string{"", id.String(), id.String() + "@", "banana@192.0.2.42:19200", id.String() + "@192.0.2.42"} {
+		if _, err := parseReplicationPeer(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestReplicationRecordFraming(t *testing.T) {
+	recs := []*discosrv.ReplicationRecord{
+		{Key: []byte{1, 2, 3}, Seen: 42, Addresses: []*discosrv.DatabaseAddress{{Address: "tcp://192.0.2.42:22000", Expires: 43}}},
+		{Key: []byte{4, 5, 6}, Seen: 44},
+	}
+	var buf bytes.Buffer
+	var wbuf, rbuf []byte
+	var err error
+	for _, rec := range recs {
+		if wbuf, err = writeReplicationRecord(&buf, wbuf, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rec := range recs {
+		var got discosrv.ReplicationRecord
+		if rbuf, err = readReplicationRecord(&buf, rbuf, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(&got, rec) {
+			t.Errorf("got %v, expected %v", &got, rec)
+		}
+	}
+}
+
+type testReplicationInstance struct {
+	id     protocol.DeviceID
+	cert   tls.Certificate
+	db     *inMemoryStore
+	server *replicationServer
+	ts     *httptest.Server
+}
+
+func newTestReplicationInstance(t *testing.T) *testReplicationInstance {
+	t.Helper()
+	cert, err := tlsutil.NewCertificateInMemory("stdiscosrv", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := &testReplicationInstance{
+		id:   protocol.NewDeviceID(cert.Certificate[0]),
+		cert: cert,
+		db:   newInMemoryStore(t.TempDir(), 0, nil),
+	}
+	inst.server = newReplicationServer("", nil, cert, inst.db)
+	inst.ts = httptest.NewUnstartedServer(http.HandlerFunc(inst.server.handle))
+	inst.ts.TLS = inst.server.tlsConfig()
+	inst.ts.EnableHTTP2 = true
+	inst.ts.StartTLS()
+	t.Cleanup(inst.ts.Close)
+	return inst
+}
+
+// replicateFrom starts replicating from the other instance, until the
+// returned function is called.
+func (inst *testReplicationInstance) replicateFrom(other *testReplicationInstance) func() {
+	other.server.peers[inst.id] = struct{}{}
+	client := newReplicationClient(replicationPeer{id: other.id, addr: other.ts.Listener.Addr().String()}, inst.cert, inst.db)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			_ = client.Serve(ctx)
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (inst *testReplicationInstance) announce(t *testing.T, id protocol.DeviceID, addr string) {
+	t.Helper()
+	addrs := []*discosrv.DatabaseAddress{{Address: addr, Expires: time.Now().Add(time.Hour).UnixNano()}}
+	seen := time.Now().UnixNano()
+	inst.server.send(&id, addrs, seen)
+	if err := inst.db.merge(&id, addrs, seen); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (inst *testReplicationInstance) waitFor(t *testing.T, id protocol.DeviceID, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		rec, err := inst.db.get(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range rec.Addresses {
+			if a.Address == addr {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s never got %s for %s", inst.id.Short(), addr, id.Short())
+}
+
+func TestPeerReplication(t *testing.T) {
+	a := newTestReplicationInstance(t)
+	b := newTestReplicationInstance(t)
+
+	dev1 := protocol.NewDeviceID([]byte{1})
+	dev2 := protocol.NewDeviceID([]byte{2})
+	dev3 := protocol.NewDeviceID([]byte{3})
+
+	// Announced before the peers connect, so replicated by catching up.
+	a.announce(t, dev1, "tcp://192.0.2.1:22000")
+
+	stopA := a.replicateFrom(b)
+	defer stopA()
+	stopB := b.replicateFrom(a)
+
+	b.waitFor(t, dev1, "tcp://192.0.2.1:22000")
+
+	// Announced while connected, so replicated as it happens, both ways.
+	a.announce(t, dev2, "tcp://192.0.2.2:22000")
+	b.announce(t, dev2, "tcp://192.0.2.22:22000")
+	b.waitFor(t, dev2, "tcp://192.0.2.2:22000")
+	a.waitFor(t, dev2, "tcp://192.0.2.22:22000")
+
+	// Announced while b is disconnected, so replicated when it reconnects.
+	stopB()
+	a.announce(t, dev3, "tcp://192.0.2.3:22000")
+	stopB = b.replicateFrom(a)
+	defer stopB()
+	b.waitFor(t, dev3, "tcp://192.0.2.3:22000")
+}
+
+func TestPeerReplicationUnknownPeer(t *testing.T) {
+	a := newTestReplicationInstance(t)
+	b := newTestReplicationInstance(t)
+
+	// b isn't one of a's peers.
+	client := newReplicationClient(replicationPeer{id: a.id, addr: a.ts.Listener.Addr().String()}, b.cert, b.db)
+	if err := client.Serve(context.Background()); err == nil {
+		t.Error("expected an error replicating from a server we're not a peer of")
+	}
+
+	// a isn't who b expects at the address.
+	a.server.peers[b.id] = struct{}{}
+	client = newReplicationClient(replicationPeer{id: b.id, addr: a.ts.Listener.Addr().String()}, b.cert, b.db)
+	if err := client.Serve(context.Background()); err == nil {
+		t.Error("expected an error replicating from the wrong server")
+	}
+}
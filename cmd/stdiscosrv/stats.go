@@ -50,6 +50,21 @@ var (
 			Help:      "Number of announcement requests.",
 		}, []string{"result"})
 
+	allowListDevices = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "syncthing",
+			Subsystem: "discovery",
+			Name:      "allow_list_devices",
+			Help:      "Number of devices on the allow list.",
+		})
+	allowListReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "syncthing",
+			Subsystem: "discovery",
+			Name:      "allow_list_reloads_total",
+			Help:      "Number of allow list reloads.",
+		}, []string{"result"})
+
 	replicationSendsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "syncthing",
@@ -135,6 +150,7 @@ func init() {
 	prometheus.MustRegister(buildInfo,
 		apiRequestsTotal, apiRequestsSeconds,
 		lookupRequestsTotal, announceRequestsTotal,
+		allowListDevices, allowListReloadsTotal,
 		replicationSendsTotal, replicationRecvsTotal,
 		databaseKeys, databaseStatisticsSeconds,
 		databaseOperations, databaseOperationSeconds,
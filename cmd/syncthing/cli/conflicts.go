@@ -0,0 +1,59 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/syncthing/syncthing/lib/model"
+)
+
+type conflictsCommand struct {
+	List    conflictsListCommand    `cmd:"" help:"List the conflict copies in a folder"`
+	Resolve conflictsResolveCommand `cmd:"" help:"Resolve a conflict by keeping the original, the conflict copy, or both"`
+}
+
+type conflictsListCommand struct {
+	FolderID string `arg:""`
+}
+
+type conflictsResolveCommand struct {
+	FolderID   string `arg:""`
+	Name       string `arg:"" help:"Name of the conflict copy, relative to the folder root"`
+	Resolution string `arg:"" enum:"keep-original,keep-conflict,keep-both" help:"What to keep (keep-original, keep-conflict, keep-both)"`
+}
+
+var conflictResolutions = map[string]model.ConflictResolution{
+	"keep-original": model.ConflictKeepOriginal,
+	"keep-conflict": model.ConflictKeepConflict,
+	"keep-both":     model.ConflictKeepBoth,
+}
+
+func (c *conflictsListCommand) Run(ctx Context) error {
+	query := make(url.Values)
+	query.Set("folder", c.FolderID)
+	return indexDumpOutput("folder/conflicts?"+query.Encode(), ctx.clientFactory)
+}
+
+func (c *conflictsResolveCommand) Run(ctx Context) error {
+	client, err := ctx.clientFactory.getClient()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"name":       c.Name,
+		"resolution": conflictResolutions[c.Resolution],
+	})
+	if err != nil {
+		return err
+	}
+	query := make(url.Values)
+	query.Set("folder", c.FolderID)
+	_, err = client.Post("folder/conflicts?"+query.Encode(), string(body))
+	return err
+}
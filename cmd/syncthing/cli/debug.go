@@ -42,4 +42,5 @@ type debugCommand struct {
 	File    fileCommand    `cmd:"" help:"Show information about a file (or directory/symlink)"`
 	Profile profileCommand `cmd:"" help:"Save a profile to help figuring out what Syncthing does"`
 	Index   indexCommand   `cmd:"" help:"Show information about the index (database)"`
+	Audit   auditCommand   `cmd:"" help:"Work with audit logs"`
 }
@@ -11,10 +11,11 @@ import (
 )
 
 type indexCommand struct {
-	Dump     struct{} `cmd:"" help:"Print the entire db"`
-	DumpSize struct{} `cmd:"" help:"Print the db size of different categories of information"`
-	Check    struct{} `cmd:"" help:"Check the database for inconsistencies"`
-	Account  struct{} `cmd:"" help:"Print key and value size statistics per key type"`
+	Dump     struct{}            `cmd:"" help:"Print the entire db"`
+	DumpSize struct{}            `cmd:"" help:"Print the db size of different categories of information"`
+	Check    struct{}            `cmd:"" help:"Check the database for inconsistencies"`
+	Account  struct{}            `cmd:"" help:"Print key and value size statistics per key type"`
+	Migrate  indexMigrateCommand `cmd:"" help:"Convert the database to another backend (Syncthing must not be running)"`
 }
 
 func (*indexCommand) Run(kongCtx *kong.Context) error {
@@ -0,0 +1,91 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/locations"
+)
+
+type indexMigrateCommand struct {
+	Backend string `arg:"" enum:"leveldb,sqlite" help:"Backend to convert to (leveldb, sqlite)"`
+}
+
+func (c *indexMigrateCommand) Run() error {
+	var target config.DatabaseBackend
+	if err := target.UnmarshalText([]byte(c.Backend)); err != nil {
+		return err
+	}
+	to := backend.Kind(target)
+
+	location := locations.Get(locations.Database)
+	from, ok := backend.DetectKind(location)
+	if !ok {
+		return fmt.Errorf("no database found at %s", location)
+	}
+	if from == to {
+		return fmt.Errorf("database is already using the %v backend", to)
+	}
+
+	backup := location + "." + from.String() + "-backup"
+	if _, err := os.Lstat(backup); err == nil {
+		return fmt.Errorf("%s already exists; remove it first", backup)
+	}
+
+	// The new database is built next to the old one and only moved into
+	// place once complete, so an interrupted migration leaves the old
+	// database untouched.
+	tmp := location + ".migrating"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := migrateDB(tmp, location, to); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	if err := os.Rename(location, backup); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, location); err != nil {
+		// Put the old database back
+		return errors.Join(err, os.Rename(backup, location))
+	}
+
+	fmt.Printf("Converted the database from %v to %v.\n", from, to)
+	fmt.Printf("The old database was kept in %s and can be removed once everything works as expected.\n", backup)
+	fmt.Printf("Set the databaseBackend option to %q to use the %v backend for any new database as well.\n", to, to)
+	return nil
+}
+
+func migrateDB(dstPath, srcPath string, to backend.Kind) error {
+	src, err := backend.OpenRO(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := backend.Open(dstPath, to, backend.TuningAuto)
+	if err != nil {
+		return err
+	}
+	if err := backend.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Compact(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -28,6 +28,7 @@ type CLI struct {
 	Debug      debugCommand     `cmd:"" help:"Debug command group"`
 	Operations operationCommand `cmd:"" help:"Operation command group"`
 	Errors     errorsCommand    `cmd:"" help:"Error command group"`
+	Conflicts  conflictsCommand `cmd:"" help:"Conflict command group"`
 	Config     configCommand    `cmd:"" help:"Configuration modification command group" passthrough:""`
 	Stdin      stdinCommand     `cmd:"" name:"-" help:"Read commands from stdin"`
 }
@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"net/url"
 	"path/filepath"
 
 	"github.com/alecthomas/kong"
@@ -21,16 +22,21 @@ type folderOverrideCommand struct {
 	FolderID string `arg:""`
 }
 
+type folderConfirmDeletionsCommand struct {
+	FolderID string `arg:""`
+}
+
 type defaultIgnoresCommand struct {
 	Path string `arg:""`
 }
 
 type operationCommand struct {
-	Restart        struct{}              `cmd:"" help:"Restart syncthing"`
-	Shutdown       struct{}              `cmd:"" help:"Shutdown syncthing"`
-	Upgrade        struct{}              `cmd:"" help:"Upgrade syncthing (if a newer version is available)"`
-	FolderOverride folderOverrideCommand `cmd:"" help:"Override changes on folder (remote for sendonly, local for receiveonly). WARNING: Destructive - deletes/changes your data"`
-	DefaultIgnores defaultIgnoresCommand `cmd:"" help:"Set the default ignores (config) from a file"`
+	Restart                struct{}                      `cmd:"" help:"Restart syncthing"`
+	Shutdown               struct{}                      `cmd:"" help:"Shutdown syncthing"`
+	Upgrade                struct{}                      `cmd:"" help:"Upgrade syncthing (if a newer version is available)"`
+	FolderOverride         folderOverrideCommand         `cmd:"" help:"Override changes on folder (remote for sendonly, local for receiveonly). WARNING: Destructive - deletes/changes your data"`
+	FolderConfirmDeletions folderConfirmDeletionsCommand `cmd:"" help:"Carry out the deletions held on folder for exceeding its threshold. WARNING: Destructive - deletes your data"`
+	DefaultIgnores         defaultIgnoresCommand         `cmd:"" help:"Set the default ignores (config) from a file"`
 }
 
 func (*operationCommand) Run(ctx Context, kongCtx *kong.Context) error {
@@ -81,6 +87,12 @@ func (f *folderOverrideCommand) Run(ctx Context) error {
 	return fmt.Errorf("Folder %q not found", rid)
 }
 
+func (f *folderConfirmDeletionsCommand) Run(ctx Context) error {
+	query := make(url.Values)
+	query.Set("folder", f.FolderID)
+	return emptyPost("db/confirmdeletions?"+query.Encode(), ctx.clientFactory)
+}
+
 func (d *defaultIgnoresCommand) Run(ctx Context) error {
 	client, err := ctx.clientFactory.getClient()
 	if err != nil {
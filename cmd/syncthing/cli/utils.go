@@ -134,7 +134,7 @@ func prettyPrintResponse(response *http.Response) error {
 }
 
 func getDB() (backend.Backend, error) {
-	return backend.OpenLevelDBRO(locations.Get(locations.Database))
+	return backend.OpenRO(locations.Get(locations.Database))
 }
 
 func nulString(bs []byte) string {
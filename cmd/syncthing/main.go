@@ -139,30 +139,34 @@ var entrypoint struct {
 // serveOptions are the options for the `syncthing serve` command.
 type serveOptions struct {
 	cmdutil.CommonOptions
-	AllowNewerConfig bool   `help:"Allow loading newer than current config version"`
-	Audit            bool   `help:"Write events to audit file"`
-	AuditFile        string `name:"auditfile" placeholder:"PATH" help:"Specify audit file (use \"-\" for stdout, \"--\" for stderr)"`
-	BrowserOnly      bool   `help:"Open GUI in browser"`
-	DataDir          string `name:"data" placeholder:"PATH" env:"STDATADIR" help:"Set data directory (database and logs)"`
-	DeviceID         bool   `help:"Show the device ID"`
-	GenerateDir      string `name:"generate" placeholder:"PATH" help:"Generate key and config in specified dir, then exit"` // DEPRECATED: replaced by subcommand!
-	GUIAddress       string `name:"gui-address" placeholder:"URL" help:"Override GUI address (e.g. \"http://192.0.2.42:8443\")"`
-	GUIAPIKey        string `name:"gui-apikey" placeholder:"API-KEY" help:"Override GUI API key"`
-	LogFile          string `name:"logfile" default:"${logFile}" placeholder:"PATH" help:"Log file name (see below)"`
-	LogFlags         int    `name:"logflags" default:"${logFlags}" placeholder:"BITS" help:"Select information in log line prefix (see below)"`
-	LogMaxFiles      int    `placeholder:"N" default:"${logMaxFiles}" name:"log-max-old-files" help:"Number of old files to keep (zero to keep only current)"`
-	LogMaxSize       int    `placeholder:"BYTES" default:"${logMaxSize}" help:"Maximum size of any file (zero to disable log rotation)"`
-	NoBrowser        bool   `help:"Do not start browser"`
-	NoRestart        bool   `env:"STNORESTART" help:"Do not restart Syncthing when exiting due to API/GUI command, upgrade, or crash"`
-	NoUpgrade        bool   `env:"STNOUPGRADE" help:"Disable automatic upgrades"`
-	Paths            bool   `help:"Show configuration paths"`
-	Paused           bool   `help:"Start with all devices and folders paused"`
-	Unpaused         bool   `help:"Start with all devices and folders unpaused"`
-	Upgrade          bool   `help:"Perform upgrade"`
-	UpgradeCheck     bool   `help:"Check for available upgrade"`
-	UpgradeTo        string `placeholder:"URL" help:"Force upgrade directly from specified URL"`
-	Verbose          bool   `help:"Print verbose log output"`
-	Version          bool   `help:"Show version"`
+	AllowNewerConfig bool          `help:"Allow loading newer than current config version"`
+	Audit            bool          `help:"Write events to audit file"`
+	AuditFile        string        `name:"auditfile" placeholder:"PATH" help:"Specify audit file (use \"-\" for stdout, \"--\" for stderr)"`
+	AuditEvents      string        `placeholder:"TYPES" help:"Comma separated event types to audit (default all)"`
+	AuditMaxAge      time.Duration `placeholder:"DURATION" help:"Maximum time to write to any audit file (zero to disable rotation by age)"`
+	AuditMaxFiles    int           `placeholder:"N" name:"audit-max-old-files" help:"Number of old audit files to keep (zero to keep all)"`
+	AuditMaxSize     int64         `placeholder:"BYTES" help:"Maximum size of any audit file (zero to disable rotation by size)"`
+	BrowserOnly      bool          `help:"Open GUI in browser"`
+	DataDir          string        `name:"data" placeholder:"PATH" env:"STDATADIR" help:"Set data directory (database and logs)"`
+	DeviceID         bool          `help:"Show the device ID"`
+	GenerateDir      string        `name:"generate" placeholder:"PATH" help:"Generate key and config in specified dir, then exit"` // DEPRECATED: replaced by subcommand!
+	GUIAddress       string        `name:"gui-address" placeholder:"URL" help:"Override GUI address (e.g. \"http://192.0.2.42:8443\")"`
+	GUIAPIKey        string        `name:"gui-apikey" placeholder:"API-KEY" help:"Override GUI API key"`
+	LogFile          string        `name:"logfile" default:"${logFile}" placeholder:"PATH" help:"Log file name (see below)"`
+	LogFlags         int           `name:"logflags" default:"${logFlags}" placeholder:"BITS" help:"Select information in log line prefix (see below)"`
+	LogMaxFiles      int           `placeholder:"N" default:"${logMaxFiles}" name:"log-max-old-files" help:"Number of old files to keep (zero to keep only current)"`
+	LogMaxSize       int           `placeholder:"BYTES" default:"${logMaxSize}" help:"Maximum size of any file (zero to disable log rotation)"`
+	NoBrowser        bool          `help:"Do not start browser"`
+	NoRestart        bool          `env:"STNORESTART" help:"Do not restart Syncthing when exiting due to API/GUI command, upgrade, or crash"`
+	NoUpgrade        bool          `env:"STNOUPGRADE" help:"Disable automatic upgrades"`
+	Paths            bool          `help:"Show configuration paths"`
+	Paused           bool          `help:"Start with all devices and folders paused"`
+	Unpaused         bool          `help:"Start with all devices and folders unpaused"`
+	Upgrade          bool          `help:"Perform upgrade"`
+	UpgradeCheck     bool          `help:"Check for available upgrade"`
+	UpgradeTo        string        `placeholder:"URL" help:"Force upgrade directly from specified URL"`
+	Verbose          bool          `help:"Print verbose log output"`
+	Version          bool          `help:"Show version"`
 
 	// Debug options below
 	DebugDBIndirectGCInterval time.Duration `env:"STGCINDIRECTEVERY" help:"Database indirection GC interval"`
@@ -376,9 +380,11 @@ func (options serveOptions) Run() error {
 	if options.Upgrade {
 		release, err := checkUpgrade()
 		if err == nil {
-			// Use leveldb database locks to protect against concurrent upgrades
+			// Use database locks to protect against concurrent upgrades
 			var ldb backend.Backend
-			ldb, err = syncthing.OpenDBBackend(locations.Get(locations.Database), config.TuningAuto)
+			dbFile := locations.Get(locations.Database)
+			kind, _ := backend.DetectKind(dbFile)
+			ldb, err = syncthing.OpenDBBackend(dbFile, config.TuningAuto, config.DatabaseBackend(kind))
 			if err != nil {
 				err = upgradeViaRest()
 			} else {
@@ -581,7 +587,7 @@ func syncthingMain(options serveOptions) {
 	}
 
 	dbFile := locations.Get(locations.Database)
-	ldb, err := syncthing.OpenDBBackend(dbFile, cfgWrapper.Options().DatabaseTuning)
+	ldb, err := syncthing.OpenDBBackend(dbFile, cfgWrapper.Options().DatabaseTuning, cfgWrapper.Options().DatabaseBackend)
 	if err != nil {
 		l.Warnln("Error opening database:", err)
 		os.Exit(1)
@@ -625,7 +631,8 @@ func syncthingMain(options serveOptions) {
 		DBIndirectGCInterval: options.DebugDBIndirectGCInterval,
 	}
 	if options.Audit {
-		appOpts.AuditWriter = auditWriter(options.AuditFile)
+		appOpts.AuditWriter = auditWriter(options)
+		appOpts.AuditEvents = auditEvents(options.AuditEvents)
 	}
 	if dur, err := time.ParseDuration(os.Getenv("STRECHECKDBEVERY")); err == nil {
 		appOpts.DBRecheckInterval = dur
@@ -717,12 +724,13 @@ func loadOrDefaultConfig() (config.Wrapper, error) {
 	return cfg, err
 }
 
-func auditWriter(auditFile string) io.Writer {
+func auditWriter(options serveOptions) io.Writer {
 	var fd io.Writer
 	var err error
 	var auditDest string
 	var auditFlags int
 
+	auditFile := options.AuditFile
 	if auditFile == "-" {
 		fd = os.Stdout
 		auditDest = "stdout"
@@ -732,11 +740,11 @@ func auditWriter(auditFile string) io.Writer {
 	} else {
 		if auditFile == "" {
 			auditFile = locations.GetTimestamped(locations.AuditLog)
-			auditFlags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			auditFlags = os.O_EXCL
 		} else {
-			auditFlags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			auditFlags = os.O_APPEND
 		}
-		fd, err = os.OpenFile(auditFile, auditFlags, 0o600)
+		fd, err = syncthing.OpenAuditFile(auditFile, auditFlags, options.AuditMaxSize, options.AuditMaxAge, options.AuditMaxFiles)
 		if err != nil {
 			l.Warnln("Audit:", err)
 			os.Exit(svcutil.ExitError.AsInt())
@@ -749,6 +757,25 @@ func auditWriter(auditFile string) io.Writer {
 	return fd
 }
 
+// auditEvents returns the mask for the comma separated event types, or zero
+// for all events.
+func auditEvents(types string) events.EventType {
+	var mask events.EventType
+	for _, name := range strings.Split(types, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ev := events.UnmarshalEventType(name)
+		if ev == 0 {
+			l.Warnln("Audit: unknown event type", name)
+			os.Exit(svcutil.ExitError.AsInt())
+		}
+		mask |= ev
+	}
+	return mask
+}
+
 func resetDB() error {
 	return os.RemoveAll(locations.Get(locations.Database))
 }
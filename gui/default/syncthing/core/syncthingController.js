@@ -579,7 +579,7 @@ angular.module('syncthing.core')
             // This function should match IsAuthEnabled() in guiconfiguration.go
             var guiCfg = $scope.config && $scope.config.gui;
             if (guiCfg) {
-                return guiCfg.authMode === 'ldap' || (guiCfg.user && guiCfg.password);
+                return guiCfg.authMode === 'ldap' || (guiCfg.user && guiCfg.password) || (guiCfg.users && guiCfg.users.length > 0);
             }
             return false;
         };
@@ -1016,7 +1016,7 @@ angular.module('syncthing.core')
             if (status === 'idle' || status === 'localadditions') {
                 return 'success';
             }
-            if (status == 'paused') {
+            if (status == 'paused' || status === 'scheduled-idle') {
                 return 'default';
             }
             if (status === 'syncing' || status === 'sync-preparing' || status === 'scanning' || status === 'cleaning') {
@@ -1231,6 +1231,8 @@ angular.module('syncthing.core')
                     return 'fa-pause';
                 case 'scanning':
                     return 'fa-search';
+                case 'scheduled-idle':
+                    return 'fa-clock';
                 case 'stopped':
                     return 'fa-stop';
                 case 'syncing':
@@ -1264,6 +1266,8 @@ angular.module('syncthing.core')
                     return $translate.instant('Waiting to Scan');
                 case 'scanning':
                     return $translate.instant('Scanning');
+                case 'scheduled-idle':
+                    return $translate.instant('Waiting for Sync Window');
                 case 'stopped':
                     return $translate.instant('Stopped');
                 case 'sync-preparing':
@@ -1384,6 +1388,7 @@ angular.module('syncthing.core')
             if (conn.type.indexOf('relay') === 0) type = "relay";
             else if (conn.type.indexOf('quic') === 0) type = "quic";
             else if (conn.type.indexOf('tcp') === 0) type = "tcp";
+            else if (conn.type.indexOf('wss') === 0) type = "wss";
             else return type;
 
             if (conn.isLocal) type += "lan";
@@ -1405,6 +1410,10 @@ angular.module('syncthing.core')
                     return $translate.instant('TCP WAN');
                 case "tcplan":
                     return $translate.instant('TCP LAN');
+                case "wsswan":
+                    return $translate.instant('WebSocket WAN');
+                case "wsslan":
+                    return $translate.instant('WebSocket LAN');
                 default:
                     return $translate.instant('Disconnected');
             }
@@ -1414,9 +1423,11 @@ angular.module('syncthing.core')
             switch (type) {
             case "tcplan":
             case "quiclan":
+            case "wsslan":
                 return "reception-4";
             case "tcpwan":
             case "quicwan":
+            case "wsswan":
                 return "reception-3";
             case "relaylan":
                 return "reception-2";
@@ -1440,6 +1451,10 @@ angular.module('syncthing.core')
                     return $translate.instant('Using a direct TCP connection over WAN');
                 case "tcplan":
                     return $translate.instant('Using a direct TCP connection over LAN');
+                case "wsswan":
+                    return $translate.instant('Using a WebSocket connection over WAN');
+                case "wsslan":
+                    return $translate.instant('Using a WebSocket connection over LAN');
                 default:
                     return $translate.instant('Unknown');
             }
@@ -2367,6 +2382,7 @@ angular.module('syncthing.core')
                 $scope.currentFolder._guiVersioning.trashcanClean = +currentVersioning.params.cleanoutDays;
                 break;
             case "simple":
+            case "dedup":
                 $scope.currentFolder._guiVersioning.simpleKeep = +currentVersioning.params.keep;
                 $scope.currentFolder._guiVersioning.trashcanClean = +currentVersioning.params.cleanoutDays;
                 break;
@@ -2552,6 +2568,7 @@ angular.module('syncthing.core')
                 folderCfg.versioning.params.cleanoutDays = '' + folderCfg._guiVersioning.trashcanClean;
                 break;
             case "simple":
+            case "dedup":
                 folderCfg.versioning.params.keep = '' + folderCfg._guiVersioning.simpleKeep,
                 folderCfg.versioning.params.cleanoutDays = '' + folderCfg._guiVersioning.trashcanClean;
                 break;
@@ -66,6 +66,7 @@ const (
 	DiskEventMask         = events.LocalChangeDetected | events.RemoteChangeDetected
 	EventSubBufferSize    = 1000
 	defaultEventTimeout   = time.Minute
+	eventStreamKeepalive  = 15 * time.Second
 	httpsCertLifetimeDays = 820
 )
 
@@ -92,6 +93,7 @@ type service struct {
 	listenerAddr         net.Addr
 	exitChan             chan *svcutil.FatalErr
 	miscDB               *db.NamespacedKV
+	apiTokenUsage        *apiTokenUsage
 
 	guiErrors logger.Recorder
 	systemLog logger.Recorder
@@ -129,6 +131,7 @@ func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonNam
 		startedOnce:          make(chan struct{}),
 		exitChan:             make(chan *svcutil.FatalErr, 1),
 		miscDB:               miscDB,
+		apiTokenUsage:        newAPITokenUsage("apiTokensLastUsed", miscDB),
 	}
 }
 
@@ -254,13 +257,16 @@ func (s *service) Serve(ctx context.Context) error {
 	restMux.HandlerFunc(http.MethodGet, "/rest/db/need", s.getDBNeed)                         // folder [perpage] [page]
 	restMux.HandlerFunc(http.MethodGet, "/rest/db/remoteneed", s.getDBRemoteNeed)             // device folder [perpage] [page]
 	restMux.HandlerFunc(http.MethodGet, "/rest/db/localchanged", s.getDBLocalChanged)         // folder [perpage] [page]
+	restMux.HandlerFunc(http.MethodGet, "/rest/db/preview", s.getDBPreview)                   // folder
 	restMux.HandlerFunc(http.MethodGet, "/rest/db/status", s.getDBStatus)                     // folder
 	restMux.HandlerFunc(http.MethodGet, "/rest/db/browse", s.getDBBrowse)                     // folder [prefix] [dirsonly] [levels]
 	restMux.HandlerFunc(http.MethodGet, "/rest/folder/versions", s.getFolderVersions)         // folder
 	restMux.HandlerFunc(http.MethodGet, "/rest/folder/errors", s.getFolderErrors)             // folder [perpage] [page]
+	restMux.HandlerFunc(http.MethodGet, "/rest/folder/conflicts", s.getFolderConflicts)       // folder
 	restMux.HandlerFunc(http.MethodGet, "/rest/folder/pullerrors", s.getFolderErrors)         // folder (deprecated)
 	restMux.HandlerFunc(http.MethodGet, "/rest/events", s.getIndexEvents)                     // [since] [limit] [timeout] [events]
 	restMux.HandlerFunc(http.MethodGet, "/rest/events/disk", s.getDiskEvents)                 // [since] [limit] [timeout]
+	restMux.HandlerFunc(http.MethodGet, "/rest/events/stream", s.getEventStream)              // [since] [events]
 	restMux.HandlerFunc(http.MethodGet, "/rest/noauth/health", s.getHealth)                   // -
 	restMux.HandlerFunc(http.MethodGet, "/rest/stats/device", s.getDeviceStats)               // -
 	restMux.HandlerFunc(http.MethodGet, "/rest/stats/folder", s.getFolderStats)               // -
@@ -283,11 +289,15 @@ func (s *service) Serve(ctx context.Context) error {
 
 	// The POST handlers
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/prio", s.postDBPrio)                          // folder file
+	restMux.HandlerFunc(http.MethodPost, "/rest/db/fetch", s.postDBFetch)                        // folder file
+	restMux.HandlerFunc(http.MethodPost, "/rest/db/preview", s.postDBPreview)                    // folder plan
+	restMux.HandlerFunc(http.MethodPost, "/rest/db/confirmdeletions", s.postDBConfirmDeletions)  // folder
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/ignores", s.postDBIgnores)                    // folder
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/override", s.postDBOverride)                  // folder
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/revert", s.postDBRevert)                      // folder
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/scan", s.postDBScan)                          // folder [sub...] [delay]
 	restMux.HandlerFunc(http.MethodPost, "/rest/folder/versions", s.postFolderVersionsRestore)   // folder <body>
+	restMux.HandlerFunc(http.MethodPost, "/rest/folder/conflicts", s.postFolderConflictResolve)  // folder <body>
 	restMux.HandlerFunc(http.MethodPost, "/rest/system/error", s.postSystemError)                // <body>
 	restMux.HandlerFunc(http.MethodPost, "/rest/system/error/clear", s.postSystemErrorClear)     // -
 	restMux.HandlerFunc(http.MethodPost, "/rest/system/ping", s.restPing)                        // -
@@ -306,9 +316,10 @@ func (s *service) Serve(ctx context.Context) error {
 	// Config endpoints
 
 	configBuilder := &configMuxBuilder{
-		Router: restMux,
-		id:     s.id,
-		cfg:    s.cfg,
+		Router:        restMux,
+		id:            s.id,
+		cfg:           s.cfg,
+		apiTokenUsage: s.apiTokenUsage,
 	}
 
 	configBuilder.registerConfig("/rest/config")
@@ -324,6 +335,7 @@ func (s *service) Serve(ctx context.Context) error {
 	configBuilder.registerOptions("/rest/config/options")
 	configBuilder.registerLDAP("/rest/config/ldap")
 	configBuilder.registerGUI("/rest/config/gui")
+	configBuilder.registerAPITokens("/rest/config/gui/tokens")
 
 	// Deprecated config endpoints
 	configBuilder.registerConfigDeprecated("/rest/system/config") // POST instead of PUT
@@ -344,7 +356,7 @@ func (s *service) Serve(ctx context.Context) error {
 
 	// The main routing handler
 	mux := http.NewServeMux()
-	mux.Handle("/rest/", noCacheRestMux)
+	mux.Handle("/rest/", roleMiddleware(noCacheRestMux))
 	mux.HandleFunc("/qr/", s.getQR)
 
 	// Serve compiled in assets unless an asset directory was set (for development)
@@ -358,10 +370,16 @@ func (s *service) Serve(ctx context.Context) error {
 	mux.Handle("/metrics", promHttpHandler)
 
 	guiCfg := s.cfg.GUI()
+	apiKeys := &apiKeys{guiCfg: guiCfg, usage: s.apiTokenUsage}
+
+	// Serve the global state of the folders over WebDAV, if enabled
+	if guiCfg.WebDAVEnabled {
+		mux.Handle(webdavPrefix+"/", s.webdavHandler(guiCfg, apiKeys))
+	}
 
 	// Wrap everything in CSRF protection. The /rest prefix should be
 	// protected, other requests will grant cookies.
-	var handler http.Handler = newCsrfManager(s.id.Short().String(), "/rest", guiCfg, mux, s.miscDB)
+	var handler http.Handler = newCsrfManager(s.id.Short().String(), "/rest", apiKeys, mux, s.miscDB)
 
 	// Add our version and ID as a header to responses
 	handler = withDetailsMiddleware(s.id, handler)
@@ -369,7 +387,7 @@ func (s *service) Serve(ctx context.Context) error {
 	// Wrap everything in basic auth, if user/password is set.
 	if guiCfg.IsAuthEnabled() {
 		tokenCookieManager := newTokenCookieManager(s.id.Short().String(), guiCfg, s.evLogger, s.miscDB)
-		authMW := newBasicAuthAndSessionMiddleware(tokenCookieManager, guiCfg, s.cfg.LDAP(), handler, s.evLogger)
+		authMW := newBasicAuthAndSessionMiddleware(tokenCookieManager, apiKeys, guiCfg, s.cfg.LDAP(), handler, s.evLogger)
 		handler = authMW
 
 		restMux.Handler(http.MethodPost, "/rest/noauth/auth/password", http.HandlerFunc(authMW.passwordAuthHandler))
@@ -483,9 +501,20 @@ func (*service) VerifyConfiguration(_, to config.Configuration) error {
 func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 	// No action required when this changes, so mask the fact that it changed at all.
 	from.GUI.Debugging = to.GUI.Debugging
+	// Nor when an unset list of users, tokens or group roles becomes an
+	// empty one.
+	if len(from.GUI.Users) == 0 && len(to.GUI.Users) == 0 {
+		from.GUI.Users = to.GUI.Users
+	}
+	if len(from.GUI.APITokens) == 0 && len(to.GUI.APITokens) == 0 {
+		from.GUI.APITokens = to.GUI.APITokens
+	}
+	if len(from.LDAP.GroupRoles) == 0 && len(to.LDAP.GroupRoles) == 0 {
+		from.LDAP.GroupRoles = to.LDAP.GroupRoles
+	}
 
-	if to.GUI == from.GUI {
-		// No GUI changes, we're done here.
+	if reflect.DeepEqual(to.GUI, from.GUI) && reflect.DeepEqual(to.LDAP, from.LDAP) {
+		// No GUI or LDAP changes, we're done here.
 		return true
 	}
 
@@ -923,7 +952,13 @@ func (s *service) getDBLocalChanged(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *service) getSystemConnections(w http.ResponseWriter, _ *http.Request) {
-	sendJSON(w, s.model.ConnectionStats())
+	res := map[string]interface{}{
+		"bandwidthLimits": s.connectionsService.BandwidthLimits(),
+	}
+	for k, v := range s.model.ConnectionStats() {
+		res[k] = v
+	}
+	sendJSON(w, res)
 }
 
 func (s *service) getDeviceStats(w http.ResponseWriter, _ *http.Request) {
@@ -935,12 +970,17 @@ func (s *service) getDeviceStats(w http.ResponseWriter, _ *http.Request) {
 	sendJSON(w, stats)
 }
 
-func (s *service) getFolderStats(w http.ResponseWriter, _ *http.Request) {
+func (s *service) getFolderStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := s.model.FolderStatistics()
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	for folder := range stats {
+		if !canAccessFolder(r.Context(), folder) {
+			delete(stats, folder)
+		}
+	}
 	sendJSON(w, stats)
 }
 
@@ -1435,7 +1475,7 @@ func (*service) getEvents(w http.ResponseWriter, r *http.Request, eventSub event
 	f.Flush()
 
 	// If there are no events available return an empty slice, as this gets serialized as `[]`
-	evs := eventSub.Since(since, []events.Event{}, timeout)
+	evs := filterEvents(eventSub.Since(since, []events.Event{}, timeout), eventFilterForUser(r.Context()))
 	if 0 < limit && limit < len(evs) {
 		evs = evs[len(evs)-limit:]
 	}
@@ -1443,6 +1483,97 @@ func (*service) getEvents(w http.ResponseWriter, r *http.Request, eventSub event
 	sendJSON(w, evs)
 }
 
+// getEventStream sends events as Server-Sent Events as they happen. Each
+// event is sent as a message with the event ID as the message ID, so that
+// a reconnecting client continues where it left off. When events were lost,
+// because the client fell too far behind, a "lost" message is sent before
+// the following event.
+func (s *service) getEventStream(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	sub := s.getEventSub(s.getEventMask(qs.Get("events")))
+	since, _ := strconv.Atoi(qs.Get("since"))
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		since = lastID
+	}
+	streamEvents(r.Context(), w, sub, since, eventStreamKeepalive, eventFilterForUser(r.Context()))
+}
+
+// filterEvents returns the events for which visible returns true, or all
+// of them if it's nil.
+func filterEvents(evs []events.Event, visible func(*events.Event) bool) []events.Event {
+	if visible == nil {
+		return evs
+	}
+	filtered := evs[:0]
+	for i := range evs {
+		if visible(&evs[i]) {
+			filtered = append(filtered, evs[i])
+		}
+	}
+	return filtered
+}
+
+type eventsLost struct {
+	LastID int `json:"lastID"` // the last event received before the loss
+	NextID int `json:"nextID"` // the first event received after it
+	Lost   int `json:"lost"`
+}
+
+func streamEvents(ctx context.Context, w http.ResponseWriter, eventSub events.BufferedSubscription, since int, keepalive time.Duration, visible func(*events.Event) bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	f := w.(http.Flusher)
+	f.Flush()
+
+	var evs []events.Event
+	for ctx.Err() == nil {
+		evs = eventSub.Since(since, evs[:0], keepalive)
+		if len(evs) == 0 {
+			// A comment, which clients ignore, to keep the connection
+			// alive and notice when the client has gone away.
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			f.Flush()
+			continue
+		}
+
+		for _, ev := range evs {
+			if since > 0 && ev.SubscriptionID > since+1 {
+				lost := eventsLost{LastID: since, NextID: ev.SubscriptionID, Lost: ev.SubscriptionID - since - 1}
+				if err := writeServerSentEvent(w, "lost", 0, lost); err != nil {
+					return
+				}
+			}
+			since = ev.SubscriptionID
+			if visible != nil && !visible(&ev) {
+				continue
+			}
+			if err := writeServerSentEvent(w, "", ev.SubscriptionID, ev); err != nil {
+				return
+			}
+		}
+		f.Flush()
+	}
+}
+
+func writeServerSentEvent(w io.Writer, event string, id int, data interface{}) error {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	if id > 0 {
+		fmt.Fprintf(&buf, "id: %d\n", id)
+	}
+	fmt.Fprintf(&buf, "data: %s\n\n", bs)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
 func (*service) getEventMask(evs string) events.EventType {
 	eventMask := DefaultEventMask
 	if evs != "" {
@@ -1615,6 +1746,22 @@ func (s *service) postDBScan(w http.ResponseWriter, r *http.Request) {
 		if err == nil {
 			s.model.DelayScan(folder, time.Duration(next)*time.Second)
 		}
+	} else if user, ok := guiUserFromContext(r.Context()); ok && len(user.Folders) > 0 {
+		// Only the folders the user sees.
+		errors := make(map[string]error)
+		for _, folder := range user.Folders {
+			if _, ok := s.cfg.Folder(folder); !ok {
+				continue
+			}
+			if err := s.model.ScanFolder(folder); err != nil {
+				errors[folder] = err
+			}
+		}
+		if len(errors) > 0 {
+			http.Error(w, "Error scanning folders", http.StatusInternalServerError)
+			sendJSON(w, errors)
+			return
+		}
 	} else {
 		errors := s.model.ScanFolders()
 		if len(errors) > 0 {
@@ -1633,6 +1780,59 @@ func (s *service) postDBPrio(w http.ResponseWriter, r *http.Request) {
 	s.getDBNeed(w, r)
 }
 
+func (s *service) postDBFetch(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+	file := qs.Get("file")
+	err := s.model.FetchFile(folder, file)
+	switch {
+	case errors.Is(err, model.ErrFetchNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, model.ErrFetchNotSelective):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.getDBNeed(w, r)
+}
+
+func (s *service) getDBPreview(w http.ResponseWriter, r *http.Request) {
+	folder := r.URL.Query().Get("folder")
+	plan, err := s.model.PullPreview(folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, plan)
+}
+
+func (s *service) postDBPreview(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	err := s.model.ApprovePull(qs.Get("folder"), qs.Get("plan"))
+	if errors.Is(err, model.ErrPullPlanChanged) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *service) postDBConfirmDeletions(w http.ResponseWriter, r *http.Request) {
+	err := s.model.ConfirmDeletions(r.URL.Query().Get("folder"))
+	switch {
+	case isFolderNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, model.ErrNoHeldDeletions):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (*service) getHealth(w http.ResponseWriter, _ *http.Request) {
 	sendJSON(w, map[string]string{"status": "OK"})
 }
@@ -1714,6 +1914,44 @@ func (s *service) postFolderVersionsRestore(w http.ResponseWriter, r *http.Reque
 	sendJSON(w, errorStringMap(ferr))
 }
 
+func (s *service) getFolderConflicts(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	conflicts, err := s.model.FolderConflicts(qs.Get("folder"))
+	switch {
+	case isFolderNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, conflicts)
+}
+
+func (s *service) postFolderConflictResolve(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var req struct {
+		Name       string                   `json:"name"`
+		Resolution model.ConflictResolution `json:"resolution"`
+	}
+	if err := unmarshalTo(r.Body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := s.model.ResolveConflict(qs.Get("folder"), req.Name, req.Resolution)
+	switch {
+	case isFolderNotFound(err), fs.IsNotExist(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, model.ErrNotAConflict), errors.Is(err, model.ErrUnknownConflictResolution),
+		errors.Is(err, model.ErrConflictEncrypted):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (s *service) getFolderErrors(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
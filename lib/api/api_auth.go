@@ -80,15 +80,17 @@ func isNoAuthPath(path string) bool {
 
 type basicAuthAndSessionMiddleware struct {
 	tokenCookieManager *tokenCookieManager
+	apiKeys            *apiKeys
 	guiCfg             config.GUIConfiguration
 	ldapCfg            config.LDAPConfiguration
 	next               http.Handler
 	evLogger           events.Logger
 }
 
-func newBasicAuthAndSessionMiddleware(tokenCookieManager *tokenCookieManager, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, next http.Handler, evLogger events.Logger) *basicAuthAndSessionMiddleware {
+func newBasicAuthAndSessionMiddleware(tokenCookieManager *tokenCookieManager, apiKeys *apiKeys, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, next http.Handler, evLogger events.Logger) *basicAuthAndSessionMiddleware {
 	return &basicAuthAndSessionMiddleware{
 		tokenCookieManager: tokenCookieManager,
+		apiKeys:            apiKeys,
 		guiCfg:             guiCfg,
 		ldapCfg:            ldapCfg,
 		next:               next,
@@ -97,20 +99,26 @@ func newBasicAuthAndSessionMiddleware(tokenCookieManager *tokenCookieManager, gu
 }
 
 func (m *basicAuthAndSessionMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if hasValidAPIKeyHeader(r, m.guiCfg) {
-		m.next.ServeHTTP(w, r)
+	if user, valid, allowed := m.apiKeys.check(r); valid {
+		if !allowed {
+			forbidden(w)
+			return
+		}
+		m.next.ServeHTTP(w, withGUIUser(r, user))
 		return
 	}
 
-	if m.tokenCookieManager.hasValidSession(r) {
-		m.next.ServeHTTP(w, r)
-		return
+	if user, ok := m.tokenCookieManager.sessionUser(r); ok {
+		if user, ok := m.currentUser(user); ok {
+			m.next.ServeHTTP(w, withGUIUser(r, user))
+			return
+		}
 	}
 
 	// Fall back to Basic auth if provided
-	if username, ok := attemptBasicAuth(r, m.guiCfg, m.ldapCfg, m.evLogger); ok {
-		m.tokenCookieManager.createSession(username, false, w, r)
-		m.next.ServeHTTP(w, r)
+	if user, ok := attemptBasicAuth(r, m.guiCfg, m.ldapCfg, m.evLogger); ok {
+		m.tokenCookieManager.createSession(user, false, w, r)
+		m.next.ServeHTTP(w, withGUIUser(r, user))
 		return
 	}
 
@@ -130,6 +138,28 @@ func (m *basicAuthAndSessionMiddleware) ServeHTTP(w http.ResponseWriter, r *http
 	forbidden(w)
 }
 
+// currentUser resolves the user a session was created for against the
+// current configuration, so that changed roles take effect and removed
+// users lose access. LDAP users keep the role they logged in with.
+func (m *basicAuthAndSessionMiddleware) currentUser(user config.GUIUser) (config.GUIUser, bool) {
+	if user.Name == "" {
+		// A session from before users were recorded, which was for an
+		// admin, as there were no other roles.
+		return adminUser(m.guiCfg.User), len(m.guiCfg.Users) == 0 && len(m.ldapCfg.GroupRoles) == 0
+	}
+	if configured, ok := m.guiCfg.UserByName(user.Name); ok {
+		configured.Password = ""
+		return configured, true
+	}
+	if m.guiCfg.AuthMode == config.AuthModeLDAP {
+		return user, true
+	}
+	if user.Name == m.guiCfg.User {
+		return adminUser(user.Name), true
+	}
+	return config.GUIUser{}, false
+}
+
 func (m *basicAuthAndSessionMiddleware) passwordAuthHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Username     string
@@ -142,8 +172,8 @@ func (m *basicAuthAndSessionMiddleware) passwordAuthHandler(w http.ResponseWrite
 		return
 	}
 
-	if auth(req.Username, req.Password, m.guiCfg, m.ldapCfg) {
-		m.tokenCookieManager.createSession(req.Username, req.StayLoggedIn, w, r)
+	if user, ok := auth(req.Username, req.Password, m.guiCfg, m.ldapCfg); ok {
+		m.tokenCookieManager.createSession(user, req.StayLoggedIn, w, r)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -153,27 +183,27 @@ func (m *basicAuthAndSessionMiddleware) passwordAuthHandler(w http.ResponseWrite
 	forbidden(w)
 }
 
-func attemptBasicAuth(r *http.Request, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, evLogger events.Logger) (string, bool) {
+func attemptBasicAuth(r *http.Request, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, evLogger events.Logger) (config.GUIUser, bool) {
 	username, password, ok := r.BasicAuth()
 	if !ok {
-		return "", false
+		return config.GUIUser{}, false
 	}
 
 	l.Debugln("Sessionless HTTP request with authentication; this is expensive.")
 
-	if auth(username, password, guiCfg, ldapCfg) {
-		return username, true
+	if user, ok := auth(username, password, guiCfg, ldapCfg); ok {
+		return user, true
 	}
 
 	usernameFromIso := string(iso88591ToUTF8([]byte(username)))
 	passwordFromIso := string(iso88591ToUTF8([]byte(password)))
-	if auth(usernameFromIso, passwordFromIso, guiCfg, ldapCfg) {
-		return usernameFromIso, true
+	if user, ok := auth(usernameFromIso, passwordFromIso, guiCfg, ldapCfg); ok {
+		return user, true
 	}
 
 	emitLoginAttempt(false, username, r.RemoteAddr, evLogger)
 	antiBruteForceSleep()
-	return "", false
+	return config.GUIUser{}, false
 }
 
 func (m *basicAuthAndSessionMiddleware) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -181,19 +211,32 @@ func (m *basicAuthAndSessionMiddleware) handleLogout(w http.ResponseWriter, r *h
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func auth(username string, password string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration) bool {
+// auth returns the user with the given credentials. The additional users
+// in the GUI configuration take precedence over LDAP and the static user.
+func auth(username string, password string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration) (config.GUIUser, bool) {
+	if user, ok := guiCfg.UserByName(username); ok {
+		if user.CompareHashedPassword(password) != nil {
+			return config.GUIUser{}, false
+		}
+		user.Password = ""
+		return user, true
+	}
 	if guiCfg.AuthMode == config.AuthModeLDAP {
 		return authLDAP(username, password, ldapCfg)
-	} else {
-		return authStatic(username, password, guiCfg)
 	}
+	if authStatic(username, password, guiCfg) {
+		return adminUser(username), true
+	}
+	return config.GUIUser{}, false
 }
 
 func authStatic(username string, password string, guiCfg config.GUIConfiguration) bool {
 	return guiCfg.CompareHashedPassword(password) == nil && username == guiCfg.User
 }
 
-func authLDAP(username string, password string, cfg config.LDAPConfiguration) bool {
+// authLDAP returns the LDAP user with the given credentials, with the role
+// given by its groups.
+func authLDAP(username string, password string, cfg config.LDAPConfiguration) (config.GUIUser, bool) {
 	address := cfg.Address
 	hostname, _, err := net.SplitHostPort(address)
 	if err != nil {
@@ -211,14 +254,14 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 
 	if err != nil {
 		l.Warnln("LDAP Dial:", err)
-		return false
+		return config.GUIUser{}, false
 	}
 
 	if cfg.Transport == config.LDAPTransportStartTLS {
 		err = connection.StartTLS(&tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify})
 		if err != nil {
 			l.Warnln("LDAP Start TLS:", err)
-			return false
+			return config.GUIUser{}, false
 		}
 	}
 
@@ -228,17 +271,31 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 	err = connection.Bind(bindDN, password)
 	if err != nil {
 		l.Warnln("LDAP Bind:", err)
-		return false
+		return config.GUIUser{}, false
 	}
 
 	if cfg.SearchFilter == "" && cfg.SearchBaseDN == "" {
-		// We're done here.
-		return true
+		if len(cfg.GroupRoles) == 0 {
+			// We're done here.
+			return cfg.UserForGroups(username, nil)
+		}
+		// Look up the groups of the user we bound as.
+		searchReq := ldap.NewSearchRequest(bindDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 60, false, "(objectClass=*)", []string{"memberOf"}, nil)
+		res, err := connection.Search(searchReq)
+		if err != nil {
+			l.Warnln("LDAP Search:", err)
+			return config.GUIUser{}, false
+		}
+		if len(res.Entries) != 1 {
+			l.Infof("Wrong number of LDAP search results, %d != 1", len(res.Entries))
+			return config.GUIUser{}, false
+		}
+		return ldapUserForGroups(username, res.Entries[0], cfg)
 	}
 
 	if cfg.SearchFilter == "" || cfg.SearchBaseDN == "" {
 		l.Warnln("LDAP configuration: both searchFilter and searchBaseDN must be set, or neither.")
-		return false
+		return config.GUIUser{}, false
 	}
 
 	// If a search filter and search base is set we do an LDAP search for
@@ -248,19 +305,27 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 	searchString := formatOptionalPercentS(cfg.SearchFilter, escapeForLDAPFilter(username))
 	const sizeLimit = 2  // we search for up to two users -- we only want to match one, so getting any number >1 is a failure.
 	const timeLimit = 60 // Search for up to a minute...
-	searchReq := ldap.NewSearchRequest(cfg.SearchBaseDN, ldap.ScopeWholeSubtree, ldap.DerefFindingBaseObj, sizeLimit, timeLimit, false, searchString, nil, nil)
+	searchReq := ldap.NewSearchRequest(cfg.SearchBaseDN, ldap.ScopeWholeSubtree, ldap.DerefFindingBaseObj, sizeLimit, timeLimit, false, searchString, []string{"memberOf"}, nil)
 
 	res, err := connection.Search(searchReq)
 	if err != nil {
 		l.Warnln("LDAP Search:", err)
-		return false
+		return config.GUIUser{}, false
 	}
 	if len(res.Entries) != 1 {
 		l.Infof("Wrong number of LDAP search results, %d != 1", len(res.Entries))
-		return false
+		return config.GUIUser{}, false
 	}
 
-	return true
+	return ldapUserForGroups(username, res.Entries[0], cfg)
+}
+
+func ldapUserForGroups(username string, entry *ldap.Entry, cfg config.LDAPConfiguration) (config.GUIUser, bool) {
+	user, ok := cfg.UserForGroups(username, entry.GetAttributeValues("memberOf"))
+	if !ok {
+		l.Infof("LDAP user %q is not in any group with a role", username)
+	}
+	return user, ok
 }
 
 // escapeForLDAPFilter escapes a value that will be used in a filter clause
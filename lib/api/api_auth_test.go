@@ -7,6 +7,7 @@
 package api
 
 import (
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -50,6 +51,86 @@ func TestStaticAuthPasswordFail(t *testing.T) {
 	}
 }
 
+func TestAuthUsers(t *testing.T) {
+	t.Parallel()
+
+	cfg := guiCfg.Copy()
+	viewer := config.GUIUser{Name: "viewer", Role: config.GUIRoleReadOnly, Folders: []string{"default"}}
+	if err := viewer.SetPassword("secret"); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Users = []config.GUIUser{viewer}
+
+	user, ok := auth("viewer", "secret", cfg, config.LDAPConfiguration{})
+	if !ok {
+		t.Fatal("should pass auth")
+	}
+	if user.Role != config.GUIRoleReadOnly || !user.CanAccessFolder("default") || user.CanAccessFolder("other") || user.Password != "" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if _, ok := auth("viewer", "pass", cfg, config.LDAPConfiguration{}); ok {
+		t.Error("should fail auth")
+	}
+	if user, ok := auth("user", "pass", cfg, config.LDAPConfiguration{}); !ok || user.Role != config.GUIRoleAdmin {
+		t.Errorf("static user should be an admin, got %+v, %v", user, ok)
+	}
+}
+
+func TestAPIKeysCheck(t *testing.T) {
+	t.Parallel()
+
+	mdb, _ := db.NewLowlevel(backend.OpenMemory(), events.NoopLogger)
+	usage := newAPITokenUsage("testTokensLastUsed", db.NewNamespacedKV(mdb, "test"))
+	clock := &mockClock{now: time.Now()}
+	usage.timeNow = clock.Now
+
+	cfg := config.GUIConfiguration{
+		APIKey: "abc123",
+		APITokens: []config.APIToken{
+			{Name: "reader", Token: config.HashAPIToken("reader-token"), Scopes: []string{"GET /rest/db/*"}},
+			{Name: "expired", Token: config.HashAPIToken("expired-token"), Expires: time.Now().Add(-time.Hour)},
+		},
+	}
+	keys := &apiKeys{guiCfg: cfg, usage: usage}
+
+	cases := []struct {
+		method, path, header, key string
+		valid, allowed            bool
+	}{
+		{"POST", "/rest/system/restart", "X-API-Key", "abc123", true, true},
+		{"GET", "/rest/db/status", "X-API-Key", "reader-token", true, true},
+		{"GET", "/rest/db/status", "Authorization", "Bearer reader-token", true, true},
+		{"POST", "/rest/db/scan", "X-API-Key", "reader-token", true, false},
+		{"GET", "/rest/db/status", "X-API-Key", "expired-token", false, false},
+		{"GET", "/rest/db/status", "X-API-Key", "other", false, false},
+		{"GET", "/rest/db/status", "", "", false, false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.header != "" {
+			r.Header.Set(tc.header, tc.key)
+		}
+		user, valid, allowed := keys.check(r)
+		if valid != tc.valid || allowed != tc.allowed {
+			t.Errorf("%s %s with %s %q: got %v, %v, expected %v, %v", tc.method, tc.path, tc.header, tc.key, valid, allowed, tc.valid, tc.allowed)
+		}
+		if valid && user.CanChangeConfig() != (tc.key == "abc123") {
+			t.Errorf("%s %q: only the API key should change the configuration, got %+v", tc.header, tc.key, user)
+		}
+	}
+
+	if last := usage.LastUsed("reader"); !last.Equal(clock.now.Truncate(time.Second)) {
+		t.Errorf("reader token last used at %v, expected %v", last, clock.now)
+	}
+	if last := usage.LastUsed("expired"); !last.IsZero() {
+		t.Errorf("expired token shouldn't have been used, got %v", last)
+	}
+	usage.forget("reader")
+	if last := usage.LastUsed("reader"); !last.IsZero() {
+		t.Errorf("forgotten token shouldn't have a last use, got %v", last)
+	}
+}
+
 func TestFormatOptionalPercentS(t *testing.T) {
 	t.Parallel()
 
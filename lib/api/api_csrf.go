@@ -20,34 +20,35 @@ const (
 )
 
 type csrfManager struct {
-	unique          string
-	prefix          string
-	apiKeyValidator apiKeyValidator
-	next            http.Handler
-	tokens          *tokenManager
-}
-
-type apiKeyValidator interface {
-	IsValidAPIKey(key string) bool
+	unique  string
+	prefix  string
+	apiKeys *apiKeys
+	next    http.Handler
+	tokens  *tokenManager
 }
 
 // Check for CSRF token on /rest/ URLs. If a correct one is not given, reject
 // the request with 403. For / and /index.html, set a new CSRF cookie if none
 // is currently set.
-func newCsrfManager(unique string, prefix string, apiKeyValidator apiKeyValidator, next http.Handler, miscDB *db.NamespacedKV) *csrfManager {
+func newCsrfManager(unique string, prefix string, apiKeys *apiKeys, next http.Handler, miscDB *db.NamespacedKV) *csrfManager {
 	m := &csrfManager{
-		unique:          unique,
-		prefix:          prefix,
-		apiKeyValidator: apiKeyValidator,
-		next:            next,
-		tokens:          newTokenManager("csrfTokens", miscDB, maxCSRFTokenLifetime, maxActiveCSRFTokens),
+		unique:  unique,
+		prefix:  prefix,
+		apiKeys: apiKeys,
+		next:    next,
+		tokens:  newTokenManager("csrfTokens", miscDB, maxCSRFTokenLifetime, maxActiveCSRFTokens),
 	}
 	return m
 }
 
 func (m *csrfManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Allow requests carrying a valid API key
-	if hasValidAPIKeyHeader(r, m.apiKeyValidator) {
+	// Allow requests carrying a valid API key, or a token that allows
+	// the request
+	if _, valid, allowed := m.apiKeys.check(r); valid {
+		if !allowed {
+			forbidden(w)
+			return
+		}
 		// Set the access-control-allow-origin header for CORS requests
 		// since a valid API key has been provided
 		w.Header().Add("Access-Control-Allow-Origin", "*")
@@ -94,14 +95,3 @@ func (m *csrfManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	m.next.ServeHTTP(w, r)
 }
-
-func hasValidAPIKeyHeader(r *http.Request, validator apiKeyValidator) bool {
-	if key := r.Header.Get("X-API-Key"); validator.IsValidAPIKey(key) {
-		return true
-	}
-	if auth := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(auth), "bearer ") {
-		bearerToken := auth[len("bearer "):]
-		return validator.IsValidAPIKey(bearerToken)
-	}
-	return false
-}
@@ -0,0 +1,345 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/discover"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/model"
+)
+
+type guiUserKey struct{}
+
+func adminUser(name string) config.GUIUser {
+	return config.GUIUser{Name: name, Role: config.GUIRoleAdmin}
+}
+
+// tokenUser returns who requests with the named API token act as: an
+// operator, so that tokens may act on folders and devices within their
+// scopes, but never change the configuration or see its secrets.
+func tokenUser(name string) config.GUIUser {
+	return config.GUIUser{Name: "token:" + name, Role: config.GUIRoleOperator}
+}
+
+// withGUIUser returns the request with the authenticated user in its
+// context.
+func withGUIUser(r *http.Request, user config.GUIUser) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), guiUserKey{}, user))
+}
+
+// guiUserFromContext returns the authenticated user. There is none when
+// authentication is disabled, or for paths that don't require it.
+func guiUserFromContext(ctx context.Context) (config.GUIUser, bool) {
+	user, ok := ctx.Value(guiUserKey{}).(config.GUIUser)
+	return user, ok
+}
+
+// canAccessFolder returns true if the authenticated user, if any, sees the
+// folder.
+func canAccessFolder(ctx context.Context, folder string) bool {
+	user, ok := guiUserFromContext(ctx)
+	return !ok || user.CanAccessFolder(folder)
+}
+
+// roleMiddleware rejects REST requests that the role of the authenticated
+// user doesn't allow, or that concern folders the user doesn't see.
+func roleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := guiUserFromContext(r.Context())
+		if !ok || isNoAuthPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if user.Role < requiredRole(r) || isConfigChange(r) && !user.CanChangeConfig() {
+			l.Debugf("Denying %s %s to %s user %q", r.Method, r.URL.Path, user.Role, user.Name)
+			forbidden(w)
+			return
+		}
+		for _, folder := range requestedFolders(r) {
+			if !user.CanAccessFolder(folder) {
+				l.Debugf("Denying %s %s to user %q without access to folder %q", r.Method, r.URL.Path, user.Name, folder)
+				forbidden(w)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiredRole returns the lowest role that may make the request. Looking
+// is read-only, except for debugging and browsing the file system; acting
+// on folders and devices takes an operator; and changing the configuration
+// or the process takes an admin.
+func requiredRole(r *http.Request) config.GUIRole {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/rest/debug/"),
+		r.URL.Path == "/rest/system/browse":
+		return config.GUIRoleAdmin
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+		return config.GUIRoleReadOnly
+	case isConfigChange(r):
+		return config.GUIRoleAdmin
+	}
+	switch r.URL.Path {
+	case "/rest/system/restart", "/rest/system/shutdown", "/rest/system/reset",
+		"/rest/system/upgrade", "/rest/system/debug":
+		return config.GUIRoleAdmin
+	}
+	return config.GUIRoleOperator
+}
+
+func isConfigChange(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		return false
+	}
+	path := r.URL.Path
+	return path == "/rest/config" || strings.HasPrefix(path, "/rest/config/") || path == "/rest/system/config"
+}
+
+// requestedFolders returns the folders the request is about, either as
+// query parameters or in the path of the folder configuration.
+func requestedFolders(r *http.Request) []string {
+	folders := r.URL.Query()["folder"]
+	if id, ok := strings.CutPrefix(r.URL.Path, "/rest/config/folders/"); ok && id != "" {
+		folders = append(folders, id)
+	}
+	return folders
+}
+
+// canSeeSecrets returns true if the authenticated user, if any, may see the
+// secrets in the configuration, which only those who may change it do.
+func canSeeSecrets(ctx context.Context) bool {
+	user, ok := guiUserFromContext(ctx)
+	return !ok || user.CanChangeConfig()
+}
+
+// filterConfigForUser returns the configuration as the authenticated user
+// may see it: only with the folders they see, and without secrets unless
+// they may change the configuration.
+func filterConfigForUser(ctx context.Context, cfg config.Configuration) config.Configuration {
+	if canSeeSecrets(ctx) {
+		return cfg
+	}
+	cfg.Folders = filterFoldersForUser(ctx, cfg.Folders)
+	cfg.Defaults.Folder = redactFolderSecrets(cfg.Defaults.Folder)
+	webhooks := make([]config.WebhookConfiguration, len(cfg.Webhooks))
+	for i, hook := range cfg.Webhooks {
+		hook.URL = fs.RedactURI(hook.URL)
+		hook.Secret = ""
+		webhooks[i] = hook
+	}
+	cfg.Webhooks = webhooks
+	cfg.Options = redactOptionsSecrets(cfg.Options)
+	cfg.GUI = redactGUISecrets(cfg.GUI)
+	return cfg
+}
+
+// filterFoldersForUser returns the folders the authenticated user sees, as
+// they may see them.
+func filterFoldersForUser(ctx context.Context, folders []config.FolderConfiguration) []config.FolderConfiguration {
+	visible := make([]config.FolderConfiguration, 0, len(folders))
+	for _, folder := range folders {
+		if canAccessFolder(ctx, folder.ID) {
+			visible = append(visible, filterFolderForUser(ctx, folder))
+		}
+	}
+	return visible
+}
+
+// filterFolderForUser returns the folder without secrets, unless the user
+// may change the configuration.
+func filterFolderForUser(ctx context.Context, folder config.FolderConfiguration) config.FolderConfiguration {
+	if canSeeSecrets(ctx) {
+		return folder
+	}
+	return redactFolderSecrets(folder)
+}
+
+// redactFolderSecrets takes the encryption passwords, and the credentials in
+// the path of remote filesystems, out of the folder.
+func redactFolderSecrets(folder config.FolderConfiguration) config.FolderConfiguration {
+	folder.LocalEncryptionPassword = ""
+	devices := make([]config.FolderDeviceConfiguration, len(folder.Devices))
+	for i, dev := range folder.Devices {
+		dev.EncryptionPassword = ""
+		devices[i] = dev
+	}
+	folder.Devices = devices
+	switch folder.FilesystemType {
+	case config.FilesystemTypeS3, config.FilesystemTypeSFTP:
+		folder.Path = fs.RedactURI(folder.Path)
+	}
+	return folder
+}
+
+// filterOptionsForUser takes the tokens for private discovery servers out
+// of the options, unless the user may change the configuration.
+func filterOptionsForUser(ctx context.Context, opts config.OptionsConfiguration) config.OptionsConfiguration {
+	if canSeeSecrets(ctx) {
+		return opts
+	}
+	return redactOptionsSecrets(opts)
+}
+
+func redactOptionsSecrets(opts config.OptionsConfiguration) config.OptionsConfiguration {
+	servers := make([]string, len(opts.RawGlobalAnnServers))
+	for i, srv := range opts.RawGlobalAnnServers {
+		servers[i] = discover.RedactServerToken(srv)
+	}
+	opts.RawGlobalAnnServers = servers
+	return opts
+}
+
+func filterGUIConfigForUser(ctx context.Context, gui config.GUIConfiguration) config.GUIConfiguration {
+	if canSeeSecrets(ctx) {
+		return gui
+	}
+	return redactGUISecrets(gui)
+}
+
+func redactGUISecrets(gui config.GUIConfiguration) config.GUIConfiguration {
+	gui.Password = ""
+	gui.APIKey = ""
+	users := make([]config.GUIUser, len(gui.Users))
+	for i, u := range gui.Users {
+		u.Password = ""
+		users[i] = u
+	}
+	gui.Users = users
+	tokens := make([]config.APIToken, len(gui.APITokens))
+	for i, t := range gui.APITokens {
+		t.Token = ""
+		tokens[i] = t
+	}
+	gui.APITokens = tokens
+	return gui
+}
+
+// eventFilterForUser returns a function that tells if an event is for the
+// authenticated user to see, or nil if they see all events. Events about
+// folders they don't see are left out, or have those folders taken out,
+// changed configurations aren't sent along, and events that might tell
+// about other users or unseen folders are left out altogether. The data of
+// events is shared between subscribers, so it is replaced, never changed.
+func eventFilterForUser(ctx context.Context) func(*events.Event) bool {
+	user, ok := guiUserFromContext(ctx)
+	if !ok || user.CanChangeConfig() {
+		return nil
+	}
+	return func(ev *events.Event) bool {
+		switch ev.Type {
+		case events.ConfigSaved:
+			ev.Data = nil
+			return true
+
+		case events.Starting, events.StartupComplete, events.DeviceDiscovered,
+			events.DeviceConnected, events.DeviceDisconnected, events.DeviceRejected,
+			events.PendingDevicesChanged, events.DevicePaused, events.DeviceResumed,
+			events.ClusterConfigReceived, events.ListenAddressesChanged,
+			events.ConnectionUpgraded:
+			// Not about folders.
+			return true
+
+		case events.LocalChangeDetected, events.RemoteChangeDetected,
+			events.LocalIndexUpdated, events.RemoteIndexUpdated, events.ItemStarted,
+			events.ItemFinished, events.StateChanged, events.FolderRejected,
+			events.RemoteDownloadProgress, events.FolderSummary,
+			events.FolderCompletion, events.FolderErrors, events.FolderScanProgress,
+			events.FolderWatchStateChanged:
+			folder, ok := eventDataString(ev.Data, "folder")
+			return ok && user.CanAccessFolder(folder)
+
+		case events.FolderPaused, events.FolderResumed:
+			folder, ok := eventDataString(ev.Data, "id")
+			return ok && user.CanAccessFolder(folder)
+
+		case events.DownloadProgress:
+			progress, ok := ev.Data.(map[string]map[string]*model.PullerProgress)
+			if !ok {
+				return false
+			}
+			visible := make(map[string]map[string]*model.PullerProgress, len(progress))
+			for folder, files := range progress {
+				if user.CanAccessFolder(folder) {
+					visible[folder] = files
+				}
+			}
+			ev.Data = visible
+			return true
+
+		case events.PendingFoldersChanged:
+			return filterPendingFoldersEvent(ev, user)
+
+		default:
+			// Login attempts are about other users, failures may be about
+			// any folder, and there is no telling for anything else.
+			return false
+		}
+	}
+}
+
+func eventDataString(data interface{}, key string) (string, bool) {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		s, ok := data[key].(string)
+		return s, ok
+	case map[string]string:
+		s, ok := data[key]
+		return s, ok
+	}
+	return "", false
+}
+
+// filterPendingFoldersEvent takes the folders the user doesn't see out of
+// the added and removed pending folders, leaving out the event if none
+// remain.
+func filterPendingFoldersEvent(ev *events.Event, user config.GUIUser) bool {
+	data, ok := ev.Data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	visible := make(map[string]interface{}, len(data))
+	for key, list := range data {
+		switch list := list.(type) {
+		case []model.UpdatedPendingFolder:
+			var folders []model.UpdatedPendingFolder
+			for _, folder := range list {
+				if user.CanAccessFolder(folder.FolderID) {
+					folders = append(folders, folder)
+				}
+			}
+			if len(folders) > 0 {
+				visible[key] = folders
+			}
+		case []map[string]string:
+			var folders []map[string]string
+			for _, folder := range list {
+				if user.CanAccessFolder(folder["folderID"]) {
+					folders = append(folders, folder)
+				}
+			}
+			if len(folders) > 0 {
+				visible[key] = folders
+			}
+		default:
+			return false
+		}
+	}
+	if len(visible) == 0 {
+		return false
+	}
+	ev.Data = visible
+	return true
+}
@@ -7,10 +7,12 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -248,6 +250,12 @@ func TestAPIServiceRequests(t *testing.T) {
 			Type:   "application/json",
 			Prefix: "{",
 		},
+		{
+			URL:    "/rest/db/preview?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "{",
+		},
 		{
 			URL:    "/rest/db/status?folder=default",
 			Code:   200,
@@ -267,6 +275,14 @@ func TestAPIServiceRequests(t *testing.T) {
 			Prefix: "",
 		},
 
+		// /rest/folder
+		{
+			URL:    "/rest/folder/conflicts?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "null",
+		},
+
 		// /rest/stats
 		{
 			URL:    "/rest/stats/device",
@@ -325,7 +341,7 @@ func TestAPIServiceRequests(t *testing.T) {
 			URL:    "/rest/system/connections",
 			Code:   200,
 			Type:   "application/json",
-			Prefix: "null",
+			Prefix: "{",
 		},
 		{
 			URL:    "/rest/system/discovery",
@@ -1516,6 +1532,72 @@ func TestEventMasks(t *testing.T) {
 	}
 }
 
+func TestEventStream(t *testing.T) {
+	t.Parallel()
+
+	evLogger := events.NewLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evLogger.Serve(ctx)
+
+	// A buffer of four events, so that we lose some of the ten we log.
+	sub := events.NewBufferedSubscription(evLogger.Subscribe(events.AllEvents), 4)
+	for i := 0; i < 10; i++ {
+		evLogger.Log(events.StateChanged, i)
+	}
+	for {
+		if evs := sub.Since(0, nil, time.Second); len(evs) > 0 && evs[len(evs)-1].SubscriptionID == 10 {
+			break
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		streamEvents(r.Context(), w, sub, since, 10*time.Millisecond, nil)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?since=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatal("unexpected content type", ct)
+	}
+
+	// We expect to be told that events 3 to 6 were lost, then get
+	// events 7 to 10, then keepalives.
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == ": keepalive" {
+			break
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	expected := []string{
+		"event: lost",
+		`data: {"lastID":2,"nextID":7,"lost":4}`,
+	}
+	for id := 7; id <= 10; id++ {
+		expected = append(expected, fmt.Sprintf("id: %d", id))
+		expected = append(expected, fmt.Sprintf(`data: {"id":%d,`, id))
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected stream:\n%s", strings.Join(lines, "\n"))
+	}
+	for i := range expected {
+		if !strings.HasPrefix(lines[i], expected[i]) {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
 func TestBrowse(t *testing.T) {
 	t.Parallel()
 
@@ -1738,6 +1820,70 @@ func TestConfigChanges(t *testing.T) {
 	}
 }
 
+func TestPullControlErrorStatus(t *testing.T) {
+	m := new(modelmocks.Model)
+	s := &service{cfg: newMockedConfig(), model: m}
+
+	cases := []struct {
+		handler http.HandlerFunc
+		setErr  func(error)
+		err     error
+		status  int
+	}{
+		{s.postDBFetch, func(err error) { m.FetchFileReturns(err) }, model.ErrFetchNotFound, http.StatusNotFound},
+		{s.postDBFetch, func(err error) { m.FetchFileReturns(err) }, model.ErrFetchNotSelective, http.StatusBadRequest},
+		{s.postDBConfirmDeletions, func(err error) { m.ConfirmDeletionsReturns(err) }, model.ErrFolderMissing, http.StatusNotFound},
+		{s.postDBConfirmDeletions, func(err error) { m.ConfirmDeletionsReturns(err) }, model.ErrNoHeldDeletions, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		tc.setErr(tc.err)
+		rec := httptest.NewRecorder()
+		tc.handler(rec, httptest.NewRequest(http.MethodPost, "/?folder=default&file=foo", nil))
+		if rec.Code != tc.status {
+			t.Errorf("%v: status %d, expected %d", tc.err, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestConflictErrorStatus(t *testing.T) {
+	m := new(modelmocks.Model)
+	s := &service{cfg: newMockedConfig(), model: m}
+
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{model.ErrFolderMissing, http.StatusNotFound},
+		{errors.New("boom"), http.StatusInternalServerError},
+	} {
+		m.FolderConflictsReturns(nil, tc.err)
+		rec := httptest.NewRecorder()
+		s.getFolderConflicts(rec, httptest.NewRequest(http.MethodGet, "/?folder=default", nil))
+		if rec.Code != tc.status {
+			t.Errorf("listing with %v: status %d, expected %d", tc.err, rec.Code, tc.status)
+		}
+	}
+
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{model.ErrFolderPaused, http.StatusNotFound},
+		{fmt.Errorf("lstat foo: %w", fs.ErrNotExist), http.StatusNotFound},
+		{model.ErrNotAConflict, http.StatusBadRequest},
+		{model.ErrUnknownConflictResolution, http.StatusBadRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	} {
+		m.ResolveConflictReturns(tc.err)
+		rec := httptest.NewRecorder()
+		body := strings.NewReader(`{"name": "foo", "resolution": "keepBoth"}`)
+		s.postFolderConflictResolve(rec, httptest.NewRequest(http.MethodPost, "/?folder=default", body))
+		if rec.Code != tc.status {
+			t.Errorf("resolving with %v: status %d, expected %d", tc.err, rec.Code, tc.status)
+		}
+	}
+}
+
 func TestSanitizedHostname(t *testing.T) {
 	cases := []struct {
 		in, out string
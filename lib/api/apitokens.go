@@ -0,0 +1,123 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// apiKeys checks requests for the API key or one of the API tokens.
+type apiKeys struct {
+	guiCfg config.GUIConfiguration
+	usage  *apiTokenUsage
+}
+
+// check returns whether the request carries a valid API key or token, and
+// if so, who it acts as and whether that allows the request.
+func (k *apiKeys) check(r *http.Request) (user config.GUIUser, valid, allowed bool) {
+	for _, key := range requestAPIKeys(r) {
+		if k.guiCfg.IsValidAPIKey(key) {
+			return adminUser(""), true, true
+		}
+		if token, ok := k.guiCfg.APIToken(key, time.Now()); ok {
+			if k.usage != nil {
+				k.usage.used(token.Name)
+			}
+			return tokenUser(token.Name), true, token.Allows(r.Method, r.URL.Path)
+		}
+	}
+	return config.GUIUser{}, false, false
+}
+
+// requestAPIKeys returns the keys given in the X-API-Key header and as a
+// bearer token.
+func requestAPIKeys(r *http.Request) []string {
+	var keys []string
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		keys = append(keys, key)
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+		keys = append(keys, auth[len("bearer "):])
+	}
+	return keys
+}
+
+// apiTokenUsage keeps track of when API tokens were last used. As this
+// changes with every request, it's kept in the database instead of the
+// configuration, and saved after a second of inactivity.
+type apiTokenUsage struct {
+	key    string
+	miscDB *db.NamespacedKV
+
+	timeNow func() time.Time // can be overridden for testing
+
+	mut       sync.Mutex
+	lastUsed  map[string]time.Time
+	saveTimer *time.Timer
+}
+
+func newAPITokenUsage(key string, miscDB *db.NamespacedKV) *apiTokenUsage {
+	lastUsed := make(map[string]time.Time)
+	if bs, ok, _ := miscDB.Bytes(key); ok {
+		_ = json.Unmarshal(bs, &lastUsed) // best effort
+	}
+	return &apiTokenUsage{
+		key:      key,
+		miscDB:   miscDB,
+		timeNow:  time.Now,
+		mut:      sync.NewMutex(),
+		lastUsed: lastUsed,
+	}
+}
+
+// LastUsed returns when the named token was last used, or the zero time.
+func (u *apiTokenUsage) LastUsed(name string) time.Time {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	return u.lastUsed[name]
+}
+
+func (u *apiTokenUsage) used(name string) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.lastUsed[name] = u.timeNow().Truncate(time.Second)
+	u.saveLocked()
+}
+
+// forget drops the usage of a removed token.
+func (u *apiTokenUsage) forget(name string) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	delete(u.lastUsed, name)
+	u.saveLocked()
+}
+
+func (u *apiTokenUsage) saveLocked() {
+	// Postpone saving until one second of inactivity.
+	if u.saveTimer == nil {
+		u.saveTimer = time.AfterFunc(time.Second, u.scheduledSave)
+	} else {
+		u.saveTimer.Reset(time.Second)
+	}
+}
+
+func (u *apiTokenUsage) scheduledSave() {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	u.saveTimer = nil
+
+	bs, _ := json.Marshal(u.lastUsed) // can't fail
+	_ = u.miscDB.PutBytes(u.key, bs)  // can fail, but what are we going to do?
+}
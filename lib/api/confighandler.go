@@ -10,23 +10,27 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/structutil"
 )
 
 type configMuxBuilder struct {
 	*httprouter.Router
-	id  protocol.DeviceID
-	cfg config.Wrapper
+	id            protocol.DeviceID
+	cfg           config.Wrapper
+	apiTokenUsage *apiTokenUsage
 }
 
 func (c *configMuxBuilder) registerConfig(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.RawCopy())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, filterConfigForUser(r.Context(), c.cfg.RawCopy()))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -35,8 +39,8 @@ func (c *configMuxBuilder) registerConfig(path string) {
 }
 
 func (c *configMuxBuilder) registerConfigDeprecated(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.RawCopy())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, filterConfigForUser(r.Context(), c.cfg.RawCopy()))
 	})
 
 	c.HandlerFunc(http.MethodPost, path, func(w http.ResponseWriter, r *http.Request) {
@@ -57,8 +61,8 @@ func (c *configMuxBuilder) registerConfigRequiresRestart(path string) {
 }
 
 func (c *configMuxBuilder) registerFolders(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.FolderList())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, filterFoldersForUser(r.Context(), c.cfg.FolderList()))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -127,13 +131,13 @@ func (c *configMuxBuilder) registerDevices(path string) {
 }
 
 func (c *configMuxBuilder) registerFolder(path string) {
-	c.Handle(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) {
+	c.Handle(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		folder, ok := c.cfg.Folder(p.ByName("id"))
 		if !ok {
 			http.Error(w, "No folder with given ID", http.StatusNotFound)
 			return
 		}
-		sendJSON(w, folder)
+		sendJSON(w, filterFolderForUser(r.Context(), folder))
 	})
 
 	c.Handle(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -206,8 +210,8 @@ func (c *configMuxBuilder) registerDevice(path string) {
 }
 
 func (c *configMuxBuilder) registerDefaultFolder(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.DefaultFolder())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, filterFolderForUser(r.Context(), c.cfg.DefaultFolder()))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -260,8 +264,8 @@ func (c *configMuxBuilder) registerDefaultIgnores(path string) {
 }
 
 func (c *configMuxBuilder) registerOptions(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.Options())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, filterOptionsForUser(r.Context(), c.cfg.Options()))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -292,8 +296,8 @@ func (c *configMuxBuilder) registerLDAP(path string) {
 }
 
 func (c *configMuxBuilder) registerGUI(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.GUI())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, filterGUIConfigForUser(r.Context(), c.cfg.GUI()))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -307,6 +311,101 @@ func (c *configMuxBuilder) registerGUI(path string) {
 	})
 }
 
+// apiTokenInfo is an API token as listed, without the token itself.
+type apiTokenInfo struct {
+	Name     string    `json:"name"`
+	Expires  time.Time `json:"expires"`
+	Scopes   []string  `json:"scopes"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+func (c *configMuxBuilder) registerAPITokens(path string) {
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
+		tokens := make([]apiTokenInfo, 0)
+		for _, token := range c.cfg.GUI().APITokens {
+			tokens = append(tokens, apiTokenInfo{
+				Name:     token.Name,
+				Expires:  token.Expires,
+				Scopes:   token.Scopes,
+				LastUsed: c.apiTokenUsage.LastUsed(token.Name),
+			})
+		}
+		sendJSON(w, tokens)
+	})
+
+	// Creating a token returns it, which is the only time it's available.
+	c.HandlerFunc(http.MethodPost, path, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name    string    `json:"name"`
+			Expires time.Time `json:"expires"`
+			Scopes  []string  `json:"scopes"`
+		}
+		if err := unmarshalTo(r.Body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			http.Error(w, "Token name must be set", http.StatusBadRequest)
+			return
+		}
+		token := rand.String(32)
+		exists := false
+		waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+			for _, t := range cfg.GUI.APITokens {
+				if t.Name == req.Name {
+					exists = true
+					return
+				}
+			}
+			cfg.GUI.APITokens = append(cfg.GUI.APITokens, config.APIToken{
+				Name:    req.Name,
+				Token:   config.HashAPIToken(token),
+				Expires: req.Expires,
+				Scopes:  req.Scopes,
+			})
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, "A token with that name already exists", http.StatusConflict)
+			return
+		}
+		waiter.Wait()
+		if err := c.cfg.Save(); err != nil {
+			l.Warnln("Saving config:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, map[string]string{"name": req.Name, "token": token})
+	})
+
+	c.Handle(http.MethodDelete, path+"/:name", func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) {
+		name := p.ByName("name")
+		found := false
+		waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+			cfg.GUI.APITokens = slices.DeleteFunc(cfg.GUI.APITokens, func(t config.APIToken) bool {
+				if t.Name == name {
+					found = true
+					return true
+				}
+				return false
+			})
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "No token with given name", http.StatusNotFound)
+			return
+		}
+		c.apiTokenUsage.forget(name)
+		c.finish(w, waiter)
+	})
+}
+
 func (c *configMuxBuilder) adjustConfig(w http.ResponseWriter, r *http.Request) {
 	to, err := config.ReadJSON(r.Body, c.id)
 	r.Body.Close()
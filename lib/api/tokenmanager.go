@@ -7,6 +7,7 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"slices"
 	"strings"
@@ -32,6 +33,7 @@ type tokenManager struct {
 
 	mut       sync.Mutex
 	tokens    *apiproto.TokenSet
+	owners    map[string]string // token -> owner, for tokens created with one
 	saveTimer *time.Timer
 }
 
@@ -42,6 +44,10 @@ func newTokenManager(key string, miscDB *db.NamespacedKV, lifetime time.Duration
 	if bs, ok, _ := miscDB.Bytes(key); ok {
 		_ = proto.Unmarshal(bs, tokens) // best effort
 	}
+	owners := make(map[string]string)
+	if bs, ok, _ := miscDB.Bytes(key + "Owners"); ok {
+		_ = json.Unmarshal(bs, &owners) // best effort
+	}
 	return &tokenManager{
 		key:      key,
 		miscDB:   miscDB,
@@ -50,6 +56,7 @@ func newTokenManager(key string, miscDB *db.NamespacedKV, lifetime time.Duration
 		timeNow:  time.Now,
 		mut:      sync.NewMutex(),
 		tokens:   tokens,
+		owners:   owners,
 	}
 }
 
@@ -76,23 +83,41 @@ func (m *tokenManager) Check(token string) bool {
 
 // New creates a new token and returns it.
 func (m *tokenManager) New() string {
+	return m.NewOwned("")
+}
+
+// NewOwned creates a new token recording who it belongs to, and returns it.
+func (m *tokenManager) NewOwned(owner string) string {
 	token := rand.String(randomTokenLength)
 
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
 	m.tokens.Tokens[token] = m.timeNow().Add(m.lifetime).UnixNano()
+	if owner != "" {
+		m.owners[token] = owner
+	}
 	m.saveLocked()
 
 	return token
 }
 
+// Owner returns who the token belongs to, if it was created with an owner.
+func (m *tokenManager) Owner(token string) (string, bool) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	owner, ok := m.owners[token]
+	return owner, ok
+}
+
 // Delete removes a token.
 func (m *tokenManager) Delete(token string) {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
 	delete(m.tokens.Tokens, token)
+	delete(m.owners, token)
 	m.saveLocked()
 }
 
@@ -125,6 +150,12 @@ func (m *tokenManager) saveLocked() {
 		}
 	}
 
+	for token := range m.owners {
+		if _, ok := m.tokens.Tokens[token]; !ok {
+			delete(m.owners, token)
+		}
+	}
+
 	// Postpone saving until one second of inactivity.
 	if m.saveTimer == nil {
 		m.saveTimer = time.AfterFunc(time.Second, m.scheduledSave)
@@ -141,6 +172,8 @@ func (m *tokenManager) scheduledSave() {
 
 	bs, _ := proto.Marshal(m.tokens) // can't fail
 	_ = m.miscDB.PutBytes(m.key, bs) // can fail, but what are we going to do?
+	bs, _ = json.Marshal(m.owners)
+	_ = m.miscDB.PutBytes(m.key+"Owners", bs)
 }
 
 type tokenCookieManager struct {
@@ -161,8 +194,10 @@ func newTokenCookieManager(shortID string, guiCfg config.GUIConfiguration, evLog
 	}
 }
 
-func (m *tokenCookieManager) createSession(username string, persistent bool, w http.ResponseWriter, r *http.Request) {
-	sessionid := m.tokens.New()
+func (m *tokenCookieManager) createSession(user config.GUIUser, persistent bool, w http.ResponseWriter, r *http.Request) {
+	user.Password = ""
+	owner, _ := json.Marshal(user) // can't fail
+	sessionid := m.tokens.NewOwned(string(owner))
 
 	// Best effort detection of whether the connection is HTTPS --
 	// either directly to us, or as used by the client towards a reverse
@@ -188,10 +223,12 @@ func (m *tokenCookieManager) createSession(username string, persistent bool, w h
 		Path:   "/",
 	})
 
-	emitLoginAttempt(true, username, r.RemoteAddr, m.evLogger)
+	emitLoginAttempt(true, user.Name, r.RemoteAddr, m.evLogger)
 }
 
-func (m *tokenCookieManager) hasValidSession(r *http.Request) bool {
+// sessionUser returns the user the session was created for. Sessions
+// created before users were recorded return an empty user.
+func (m *tokenCookieManager) sessionUser(r *http.Request) (config.GUIUser, bool) {
 	for _, cookie := range r.Cookies() {
 		// We iterate here since there may, historically, be multiple
 		// cookies with the same name but different path. Any "old" ones
@@ -199,11 +236,17 @@ func (m *tokenCookieManager) hasValidSession(r *http.Request) bool {
 		// later removed on logout or when timing out.
 		if cookie.Name == m.cookieName {
 			if m.tokens.Check(cookie.Value) {
-				return true
+				var user config.GUIUser
+				if owner, ok := m.tokens.Owner(cookie.Value); ok {
+					if err := json.Unmarshal([]byte(owner), &user); err != nil {
+						continue
+					}
+				}
+				return user, true
 			}
 		}
 	}
-	return false
+	return config.GUIUser{}, false
 }
 
 func (m *tokenCookieManager) destroySession(w http.ResponseWriter, r *http.Request) {
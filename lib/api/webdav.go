@@ -0,0 +1,396 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// webdavPrefix is where the global state of the folders is served over
+// WebDAV, with a directory per folder.
+const webdavPrefix = "/webdav"
+
+var (
+	errWebDAVIsDir       = errors.New("is a directory")
+	errWebDAVUnavailable = errors.New("block is not available from any device")
+)
+
+// webdavHandler serves the global state of the folders read-only over
+// WebDAV. Files are streamed from the local copy, if it's up to date, and
+// otherwise from the devices that have them. Requests must always be
+// authenticated; without a GUI user, that means with the API key or a token
+// whose scopes allow it.
+func (s *service) webdavHandler(guiCfg config.GUIConfiguration, apiKeys *apiKeys) http.Handler {
+	dav := &webdav.Handler{
+		Prefix:     webdavPrefix,
+		FileSystem: &globalFS{cfg: s.cfg, model: s.model},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				l.Debugf("WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, valid, allowed := apiKeys.check(r); valid && !allowed || !guiCfg.IsAuthEnabled() && !valid {
+			forbidden(w)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+			dav.ServeHTTP(w, r)
+		default:
+			http.Error(w, "WebDAV access is read-only", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// globalFS is a read-only webdav.FileSystem of the global state of the
+// folders. Paused and receive-encrypted folders are left out, as are those
+// the authenticated user doesn't see.
+type globalFS struct {
+	cfg   config.Wrapper
+	model model.Model
+}
+
+func (*globalFS) Mkdir(context.Context, string, os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (*globalFS) RemoveAll(context.Context, string) error {
+	return os.ErrPermission
+}
+
+func (*globalFS) Rename(context.Context, string, string) error {
+	return os.ErrPermission
+}
+
+func (g *globalFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	_, info, err := g.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (g *globalFS) OpenFile(ctx context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, os.ErrPermission
+	}
+	fcfg, info, err := g.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &globalDir{ctx: ctx, fs: g, folder: fcfg.ID, prefix: info.file.Name, info: info}, nil
+	}
+	return &globalFile{ctx: ctx, fs: g, fcfg: fcfg, info: info, block: -1}, nil
+}
+
+// lookup returns the folder and info for the given slash separated name,
+// which starts with the folder ID.
+func (g *globalFS) lookup(ctx context.Context, name string) (config.FolderConfiguration, *globalFileInfo, error) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return config.FolderConfiguration{}, &globalFileInfo{name: "/", dir: true}, nil
+	}
+
+	id, rest, _ := strings.Cut(name, "/")
+	fcfg, ok := g.folder(ctx, id)
+	if !ok {
+		return config.FolderConfiguration{}, nil, os.ErrNotExist
+	}
+	if rest == "" {
+		return fcfg, &globalFileInfo{name: id, dir: true}, nil
+	}
+
+	file, ok, err := g.model.CurrentGlobalFile(fcfg.ID, filepath.FromSlash(rest))
+	if errors.Is(err, model.ErrFolderMissing) {
+		return config.FolderConfiguration{}, nil, os.ErrNotExist
+	} else if err != nil {
+		return config.FolderConfiguration{}, nil, err
+	}
+	if !ok || file.IsDeleted() || file.IsInvalid() || file.IsSymlink() {
+		return config.FolderConfiguration{}, nil, os.ErrNotExist
+	}
+	return fcfg, newGlobalFileInfo(path.Base(rest), file), nil
+}
+
+func (g *globalFS) folder(ctx context.Context, id string) (config.FolderConfiguration, bool) {
+	fcfg, ok := g.cfg.Folder(id)
+	if !ok || fcfg.Paused || fcfg.Type == config.FolderTypeReceiveEncrypted || !canAccessFolder(ctx, id) {
+		return config.FolderConfiguration{}, false
+	}
+	return fcfg, true
+}
+
+type globalFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	file    protocol.FileInfo // not set for the root and folder directories
+}
+
+func newGlobalFileInfo(name string, file protocol.FileInfo) *globalFileInfo {
+	return &globalFileInfo{
+		name:    name,
+		size:    file.FileSize(),
+		modTime: file.ModTime(),
+		dir:     file.IsDirectory(),
+		file:    file,
+	}
+}
+
+func (i *globalFileInfo) Name() string       { return i.name }
+func (i *globalFileInfo) Size() int64        { return i.size }
+func (i *globalFileInfo) ModTime() time.Time { return i.modTime }
+func (i *globalFileInfo) IsDir() bool        { return i.dir }
+func (*globalFileInfo) Sys() interface{}     { return nil }
+
+func (i *globalFileInfo) Mode() iofs.FileMode {
+	if i.dir {
+		return iofs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// ContentType implements webdav.ContentTyper, so that listing a directory
+// doesn't read from every file in it to sniff the type.
+func (i *globalFileInfo) ContentType(context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(i.name)); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
+
+type globalDir struct {
+	ctx     context.Context
+	fs      *globalFS
+	folder  string // empty for the root
+	prefix  string
+	info    *globalFileInfo
+	entries []os.FileInfo
+	listed  bool
+}
+
+func (*globalDir) Read([]byte) (int, error) {
+	return 0, errWebDAVIsDir
+}
+
+func (*globalDir) Write([]byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (*globalDir) Seek(int64, int) (int64, error) {
+	return 0, errWebDAVIsDir
+}
+
+func (*globalDir) Close() error {
+	return nil
+}
+
+func (d *globalDir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *globalDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		if err := d.list(); err != nil {
+			return nil, err
+		}
+		d.listed = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+func (d *globalDir) list() error {
+	if d.folder == "" {
+		ids := make([]string, 0)
+		for id := range d.fs.cfg.Folders() {
+			if _, ok := d.fs.folder(d.ctx, id); ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			d.entries = append(d.entries, &globalFileInfo{name: id, dir: true})
+		}
+		return nil
+	}
+
+	tree, err := d.fs.model.GlobalDirectoryTree(d.folder, d.prefix, 0, false)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree {
+		if entry.Type != protocol.FileInfoTypeFile && entry.Type != protocol.FileInfoTypeDirectory {
+			continue
+		}
+		d.entries = append(d.entries, &globalFileInfo{
+			name:    entry.Name,
+			size:    entry.Size,
+			modTime: entry.ModTime,
+			dir:     entry.Type == protocol.FileInfoTypeDirectory,
+		})
+	}
+	return nil
+}
+
+// A globalFile reads the blocks of a file in the global state as they are
+// needed, from the local copy if it's up to date or else from the devices
+// that have it.
+type globalFile struct {
+	ctx    context.Context
+	fs     *globalFS
+	fcfg   config.FolderConfiguration
+	info   *globalFileInfo
+	offset int64
+
+	local        fs.File // the local copy, if it has the global version
+	localChecked bool
+
+	block int // index of the block in buf
+	buf   []byte
+}
+
+func (*globalFile) Write([]byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *globalFile) Close() error {
+	if f.local != nil {
+		return f.local.Close()
+	}
+	return nil
+}
+
+func (f *globalFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (*globalFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+func (f *globalFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *globalFile) Read(p []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	blockSize := int64(f.info.file.BlockSize())
+	idx := int(f.offset / blockSize)
+	if idx != f.block {
+		buf, err := f.readBlock(idx)
+		if err != nil {
+			return 0, err
+		}
+		f.block, f.buf = idx, buf
+	}
+	n := copy(p, f.buf[f.offset-int64(idx)*blockSize:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *globalFile) readBlock(idx int) ([]byte, error) {
+	file := f.info.file
+	block := file.Blocks[idx]
+
+	if local := f.localFile(); local != nil {
+		buf := make([]byte, block.Size)
+		if _, err := local.ReadAt(buf, block.Offset); err == nil && scanner.Validate(buf, block.Hash, block.WeakHash) {
+			return buf, nil
+		}
+		// The local copy changed since it was last scanned; fall back to
+		// the other devices.
+	}
+
+	avail, err := f.fs.model.Availability(f.fcfg.ID, file, block)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range avail {
+		buf, err := f.fs.model.RequestGlobal(f.ctx, a.ID, f.fcfg.ID, file.Name, idx, block.Offset, block.Size, block.Hash, block.WeakHash, a.FromTemporary)
+		if err != nil {
+			l.Debugf("WebDAV: requesting block %d of %s from %s: %v", idx, file.Name, a.ID.Short(), err)
+			continue
+		}
+		if !scanner.Validate(buf, block.Hash, block.WeakHash) {
+			l.Debugf("WebDAV: block %d of %s from %s has the wrong hash", idx, file.Name, a.ID.Short())
+			continue
+		}
+		return buf, nil
+	}
+	return nil, errWebDAVUnavailable
+}
+
+// localFile returns the local copy of the file, if it exists and has the
+// same contents as the global version.
+func (f *globalFile) localFile() fs.File {
+	if f.localChecked {
+		return f.local
+	}
+	f.localChecked = true
+
+	cur, ok, err := f.fs.model.CurrentFolderFile(f.fcfg.ID, f.info.file.Name)
+	if err != nil || !ok || cur.IsDeleted() || cur.IsInvalid() || !cur.BlocksEqual(f.info.file) {
+		return nil
+	}
+	fd, err := f.fs.model.OpenLocalFile(f.fcfg.ID, f.info.file.Name)
+	if err != nil {
+		l.Debugln("WebDAV: opening local copy:", err)
+		return nil
+	}
+	f.local = fd
+	return fd
+}
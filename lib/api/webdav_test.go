@@ -0,0 +1,171 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/model"
+	modelmocks "github.com/syncthing/syncthing/lib/model/mocks"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestWebDAVGlobalFile(t *testing.T) {
+	// A file of three blocks, available only from a remote device.
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3*protocol.MinBlockSize/16)
+	file := protocol.FileInfo{
+		Name:         "dir/file.txt",
+		Type:         protocol.FileInfoTypeFile,
+		Size:         int64(len(data)),
+		RawBlockSize: protocol.MinBlockSize,
+	}
+	for off := 0; off < len(data); off += protocol.MinBlockSize {
+		hash := sha256.Sum256(data[off : off+protocol.MinBlockSize])
+		file.Blocks = append(file.Blocks, protocol.BlockInfo{Hash: hash[:], Offset: int64(off), Size: protocol.MinBlockSize})
+	}
+
+	cfg := newMockedConfig()
+	cfg.FolderReturns(config.FolderConfiguration{ID: "default"}, true)
+	m := new(modelmocks.Model)
+	m.CurrentGlobalFileReturns(file, true, nil)
+	m.AvailabilityReturns([]model.Availability{{ID: protocol.LocalDeviceID}}, nil)
+	m.RequestGlobalCalls(func(_ context.Context, _ protocol.DeviceID, _, _ string, _ int, offset int64, size int, _ []byte, _ uint32, _ bool) ([]byte, error) {
+		return data[offset : offset+int64(size)], nil
+	})
+
+	g := &globalFS{cfg: cfg, model: m}
+	if _, err := g.OpenFile(context.Background(), "/default/dir/file.txt", os.O_RDWR, 0); !errors.Is(err, os.ErrPermission) {
+		t.Error("expected permission error on writing, got", err)
+	}
+
+	fd, err := g.OpenFile(context.Background(), "/default/dir/file.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	info, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "file.txt" || info.Size() != file.Size || info.IsDir() {
+		t.Error("unexpected file info", info.Name(), info.Size(), info.IsDir())
+	}
+
+	// Start in the middle of a block, as for a range request
+	start := int64(protocol.MinBlockSize + 100)
+	if _, err := fd.Seek(start, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	read, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data[start:]) {
+		t.Error("read data doesn't match")
+	}
+	if n := m.RequestGlobalCallCount(); n != 2 {
+		t.Errorf("requested %d blocks, expected 2", n)
+	}
+}
+
+func TestWebDAVLocalFile(t *testing.T) {
+	data := []byte("local contents")
+	hash := sha256.Sum256(data)
+	file := protocol.FileInfo{
+		Name:         "file.txt",
+		Type:         protocol.FileInfoTypeFile,
+		Size:         int64(len(data)),
+		RawBlockSize: protocol.MinBlockSize,
+		Blocks:       []protocol.BlockInfo{{Hash: hash[:], Size: len(data)}},
+	}
+	ffs := fs.NewFilesystem(fs.FilesystemTypeFake, t.Name()+"?content=true")
+	fd, err := ffs.Create(file.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	cfg := newMockedConfig()
+	cfg.FolderReturns(config.FolderConfiguration{ID: "default"}, true)
+	m := new(modelmocks.Model)
+	m.CurrentGlobalFileReturns(file, true, nil)
+	m.CurrentFolderFileReturns(file, true, nil)
+	m.OpenLocalFileCalls(func(_, name string) (fs.File, error) {
+		return ffs.Open(name)
+	})
+
+	g := &globalFS{cfg: cfg, model: m}
+	wfd, err := g.OpenFile(context.Background(), "/default/file.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wfd.Close()
+	read, err := io.ReadAll(wfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Errorf("read %q, expected %q", read, data)
+	}
+	if n := m.OpenLocalFileCallCount(); n != 1 {
+		t.Errorf("opened the local file %d times, expected 1", n)
+	}
+	if n := m.RequestGlobalCallCount(); n != 0 {
+		t.Errorf("requested %d blocks, expected none", n)
+	}
+}
+
+func TestWebDAVReadOnlyAndAuthenticated(t *testing.T) {
+	cfg := newMockedConfig()
+	s := &service{cfg: cfg, model: new(modelmocks.Model)}
+	guiCfg := config.GUIConfiguration{
+		APIKey:        "abc123",
+		WebDAVEnabled: true,
+		APITokens: []config.APIToken{
+			{Name: "dav", Token: config.HashAPIToken("dav-token"), Scopes: []string{webdavPrefix + "/*"}},
+			{Name: "rest", Token: config.HashAPIToken("rest-token"), Scopes: []string{"/rest/*"}},
+		},
+	}
+	h := s.webdavHandler(guiCfg, &apiKeys{guiCfg: guiCfg})
+
+	cases := []struct {
+		method string
+		apiKey string
+		status int
+	}{
+		{"PROPFIND", "", http.StatusForbidden},
+		{"PROPFIND", "abc123", http.StatusMultiStatus},
+		{"PROPFIND", "dav-token", http.StatusMultiStatus},
+		{"PROPFIND", "rest-token", http.StatusForbidden},
+		{http.MethodPut, "abc123", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "abc123", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, webdavPrefix+"/", nil)
+		if tc.apiKey != "" {
+			req.Header.Set("X-API-Key", tc.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s with API key %q: status %d, expected %d", tc.method, tc.apiKey, rec.Code, tc.status)
+		}
+	}
+}
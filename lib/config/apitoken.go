@@ -0,0 +1,83 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// An APIToken is a named API key, optionally expiring and limited to some
+// requests. Each scope is a path, optionally preceded by a method, such as
+// "GET /rest/db/status"; a path ending in "*" covers everything below it.
+// Without scopes, the token allows everything an operator may do; unlike
+// the API key, tokens never change the configuration or see its secrets.
+//
+// Only a hash of the token is kept. A plaintext token set in the
+// configuration is hashed when the configuration is loaded.
+type APIToken struct {
+	Name    string    `json:"name" xml:"name,attr"`
+	Token   string    `json:"token" xml:"token"`
+	Expires time.Time `json:"expires" xml:"expires"`
+	Scopes  []string  `json:"scopes" xml:"scope"`
+}
+
+// matches a hashed API token
+var apiTokenHashExpr = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// HashAPIToken returns the hash of the token, as stored in the
+// configuration.
+func HashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// Matches returns true if the given plaintext token is this one.
+func (t APIToken) Matches(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(HashAPIToken(token)), []byte(t.Token)) == 1
+}
+
+// Expired returns true if the token has expired at the given time.
+func (t APIToken) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// Allows returns true if the token's scopes cover the given request.
+func (t APIToken) Allows(method, path string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range t.Scopes {
+		scopeMethod, scopePath, ok := strings.Cut(strings.TrimSpace(scope), " ")
+		if !ok {
+			scopeMethod, scopePath = "", scopeMethod
+		} else if !strings.EqualFold(scopeMethod, method) {
+			continue
+		}
+		scopePath = strings.TrimSpace(scopePath)
+		if prefix, ok := strings.CutSuffix(scopePath, "*"); ok && strings.HasPrefix(path, prefix) || scopePath == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (t APIToken) Copy() APIToken {
+	t.Scopes = slices.Clone(t.Scopes)
+	return t
+}
+
+func (t *APIToken) prepare() {
+	if t.Token != "" && !apiTokenHashExpr.MatchString(t.Token) {
+		t.Token = HashAPIToken(t.Token)
+	}
+}
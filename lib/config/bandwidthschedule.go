@@ -0,0 +1,71 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"time"
+)
+
+// A BandwidthScheduleEntry replaces the static rate limits with its own
+// during a time of day on some days of the week. Days is a comma separated
+// list of days and day ranges ("mon-fri,sun"), empty meaning every day.
+// Start and End are local times of day ("09:00"); an End before Start
+// means the period extends past midnight, into the following day, and
+// equal times cover the whole day. As for the static limits, zero means
+// unlimited.
+type BandwidthScheduleEntry struct {
+	Name        string `json:"name" xml:"name,attr"`
+	Days        string `json:"days" xml:"days,attr"`
+	Start       string `json:"start" xml:"start,attr"`
+	End         string `json:"end" xml:"end,attr"`
+	MaxSendKbps int    `json:"maxSendKbps" xml:"maxSendKbps,attr"`
+	MaxRecvKbps int    `json:"maxRecvKbps" xml:"maxRecvKbps,attr"`
+}
+
+// Validate returns an error if the entry can't be parsed.
+func (e BandwidthScheduleEntry) Validate() error {
+	_, err := parseTimeWindow(e.Days, e.Start, e.End)
+	return err
+}
+
+// ActiveAt returns true if the entry is in effect at the given time.
+// Invalid entries are never in effect.
+func (e BandwidthScheduleEntry) ActiveAt(t time.Time) bool {
+	w, err := parseTimeWindow(e.Days, e.Start, e.End)
+	if err != nil {
+		return false
+	}
+	return w.contains(t)
+}
+
+// ActiveBandwidthScheduleEntry returns the first entry that is in effect at
+// the given time, if any.
+func ActiveBandwidthScheduleEntry(entries []BandwidthScheduleEntry, t time.Time) (BandwidthScheduleEntry, bool) {
+	for _, e := range entries {
+		if e.ActiveAt(t) {
+			return e, true
+		}
+	}
+	return BandwidthScheduleEntry{}, false
+}
+
+// validBandwidthSchedule returns the entries that are valid, warning about
+// the others.
+func validBandwidthSchedule(entries []BandwidthScheduleEntry, context string) []BandwidthScheduleEntry {
+	if len(entries) == 0 {
+		return entries
+	}
+	valid := make([]BandwidthScheduleEntry, 0, len(entries))
+	for _, e := range entries {
+		if err := e.Validate(); err != nil {
+			l.Warnf("Removing invalid bandwidth schedule entry %q for %s: %v", e.Name, context, err)
+			continue
+		}
+		valid = append(valid, e)
+	}
+	return valid
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandwidthScheduleEntryActiveAt(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(day int, hhmm string) time.Time {
+		tod, err := time.Parse("15:04", hhmm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2024, 1, day, tod.Hour(), tod.Minute(), 0, 0, time.Local)
+	}
+
+	office := BandwidthScheduleEntry{Days: "mon-fri", Start: "09:00", End: "17:00"}
+	night := BandwidthScheduleEntry{Days: "fri,sat", Start: "22:00", End: "06:00"}
+	weekend := BandwidthScheduleEntry{Days: "sat-sun"}
+	wrapping := BandwidthScheduleEntry{Days: "sat-mon", Start: "12:00", End: "13:00"}
+
+	cases := []struct {
+		entry  BandwidthScheduleEntry
+		t      time.Time
+		active bool
+	}{
+		{office, at(1, "08:59"), false},
+		{office, at(1, "09:00"), true},
+		{office, at(5, "16:59"), true},
+		{office, at(5, "17:00"), false},
+		{office, at(6, "12:00"), false},
+		{night, at(5, "21:59"), false},
+		{night, at(5, "22:00"), true},
+		{night, at(6, "05:59"), true},
+		{night, at(7, "05:59"), true},
+		{night, at(7, "22:00"), false},
+		{night, at(8, "05:59"), false},
+		{weekend, at(6, "00:00"), true},
+		{weekend, at(7, "23:59"), true},
+		{weekend, at(8, "00:00"), false},
+		{wrapping, at(1, "12:30"), true},
+		{wrapping, at(2, "12:30"), false},
+		{wrapping, at(7, "12:30"), true},
+	}
+	for i, tc := range cases {
+		if active := tc.entry.ActiveAt(tc.t); active != tc.active {
+			t.Errorf("%d: %+v at %v: expected active=%v", i, tc.entry, tc.t, tc.active)
+		}
+	}
+}
+
+func TestBandwidthScheduleEntryValidate(t *testing.T) {
+	valid := []BandwidthScheduleEntry{
+		{},
+		{Days: "Mon, Wed-Fri", Start: "09:30", End: "17:45"},
+		{Days: "sun", End: "01:00"},
+	}
+	for _, e := range valid {
+		if err := e.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error: %v", e, err)
+		}
+	}
+
+	invalid := []BandwidthScheduleEntry{
+		{Days: "monday"},
+		{Days: "mon-"},
+		{Start: "9"},
+		{End: "25:00"},
+	}
+	for _, e := range invalid {
+		if err := e.Validate(); err == nil {
+			t.Errorf("%+v: expected error", e)
+		}
+		if e.ActiveAt(time.Now()) {
+			t.Errorf("%+v: invalid entry should never be active", e)
+		}
+	}
+}
+
+func TestInvalidBandwidthScheduleRemoved(t *testing.T) {
+	cfg := New(device1)
+	cfg.Options.BandwidthSchedule = []BandwidthScheduleEntry{
+		{Name: "good", Days: "mon-fri", Start: "09:00", End: "17:00", MaxSendKbps: 100},
+		{Name: "bad", Days: "someday"},
+	}
+	cfg.prepare(device1)
+
+	if len(cfg.Options.BandwidthSchedule) != 1 || cfg.Options.BandwidthSchedule[0].Name != "good" {
+		t.Error("unexpected schedule after prepare:", cfg.Options.BandwidthSchedule)
+	}
+}
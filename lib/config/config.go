@@ -40,6 +40,9 @@ var (
 	DefaultTCPPort = 22000
 	// DefaultQUICPort defines default QUIC port used if the URI does not specify one, for example quic://0.0.0.0
 	DefaultQUICPort = 22000
+	// DefaultWSSPort defines default port used for WebSocket connections if
+	// the URI does not specify one, for example wss://0.0.0.0
+	DefaultWSSPort = 443
 	// DefaultListenAddresses should be substituted when the configuration
 	// contains <listenAddress>default</listenAddress>. This is done by the
 	// "consumer" of the configuration as we don't want these saved to the
@@ -100,15 +103,16 @@ var (
 )
 
 type Configuration struct {
-	Version                  int                   `json:"version" xml:"version,attr"`
-	Folders                  []FolderConfiguration `json:"folders" xml:"folder"`
-	Devices                  []DeviceConfiguration `json:"devices" xml:"device"`
-	GUI                      GUIConfiguration      `json:"gui" xml:"gui"`
-	LDAP                     LDAPConfiguration     `json:"ldap" xml:"ldap"`
-	Options                  OptionsConfiguration  `json:"options" xml:"options"`
-	IgnoredDevices           []ObservedDevice      `json:"remoteIgnoredDevices" xml:"remoteIgnoredDevice"`
-	DeprecatedPendingDevices []ObservedDevice      `json:"-" xml:"pendingDevice,omitempty"` // Deprecated: Do not use.
-	Defaults                 Defaults              `json:"defaults" xml:"defaults"`
+	Version                  int                    `json:"version" xml:"version,attr"`
+	Folders                  []FolderConfiguration  `json:"folders" xml:"folder"`
+	Devices                  []DeviceConfiguration  `json:"devices" xml:"device"`
+	GUI                      GUIConfiguration       `json:"gui" xml:"gui"`
+	LDAP                     LDAPConfiguration      `json:"ldap" xml:"ldap"`
+	Options                  OptionsConfiguration   `json:"options" xml:"options"`
+	IgnoredDevices           []ObservedDevice       `json:"remoteIgnoredDevices" xml:"remoteIgnoredDevice"`
+	DeprecatedPendingDevices []ObservedDevice       `json:"-" xml:"pendingDevice,omitempty"` // Deprecated: Do not use.
+	Defaults                 Defaults               `json:"defaults" xml:"defaults"`
+	Webhooks                 []WebhookConfiguration `json:"webhooks" xml:"webhook"`
 }
 
 type Defaults struct {
@@ -261,6 +265,11 @@ func (cfg Configuration) Copy() Configuration {
 	newCfg.IgnoredDevices = make([]ObservedDevice, len(cfg.IgnoredDevices))
 	copy(newCfg.IgnoredDevices, cfg.IgnoredDevices)
 
+	newCfg.Webhooks = make([]WebhookConfiguration, len(cfg.Webhooks))
+	for i := range newCfg.Webhooks {
+		newCfg.Webhooks[i] = cfg.Webhooks[i].Copy()
+	}
+
 	return newCfg
 }
 
@@ -293,6 +302,8 @@ func (cfg *Configuration) prepare(myID protocol.DeviceID) error {
 
 	cfg.Defaults.prepare(myID, existingDevices)
 
+	cfg.prepareWebhooks()
+
 	cfg.removeDeprecatedProtocols()
 
 	structutil.FillNilExceptDeprecated(cfg)
@@ -17,9 +17,11 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 	"golang.org/x/crypto/bcrypt"
@@ -65,6 +67,7 @@ func TestDefaultValues(t *testing.T) {
 			ReconnectIntervalS:        60,
 			RelaysEnabled:             true,
 			RelayReconnectIntervalM:   10,
+			HolePunchingEnabled:       true,
 			StartBrowser:              true,
 			NATEnabled:                true,
 			NATLeaseM:                 60,
@@ -81,6 +84,7 @@ func TestDefaultValues(t *testing.T) {
 			URPostInsecurely:          false,
 			ReleasesURL:               "https://upgrades.syncthing.net/meta.json",
 			AlwaysLocalNets:           []string{},
+			BandwidthSchedule:         []BandwidthScheduleEntry{},
 			OverwriteRemoteDevNames:   false,
 			TempIndexMinBlocks:        10,
 			UnackedNotificationIDs:    []string{"authenticationUserAndPassword"},
@@ -96,6 +100,8 @@ func TestDefaultValues(t *testing.T) {
 			ConnectionPriorityQUICLAN: 20,
 			ConnectionPriorityTCPWAN:  30,
 			ConnectionPriorityQUICWAN: 40,
+			ConnectionPriorityWSSLAN:  25,
+			ConnectionPriorityWSSWAN:  45,
 			ConnectionPriorityRelay:   50,
 		},
 		Defaults: Defaults{
@@ -117,6 +123,8 @@ func TestDefaultValues(t *testing.T) {
 				MaxConflicts:         10,
 				WeakHashThresholdPct: 25,
 				MarkerName:           ".stfolder",
+				SyncWindows:          []SyncWindow{},
+				SelectiveSyncInclude: []string{},
 				MaxConcurrentWrites:  2,
 				XattrFilter: XattrFilter{
 					Entries:            []XattrFilterEntry{},
@@ -125,16 +133,18 @@ func TestDefaultValues(t *testing.T) {
 				},
 			},
 			Device: DeviceConfiguration{
-				Addresses:       []string{"dynamic"},
-				AllowedNetworks: []string{},
-				Compression:     CompressionMetadata,
-				IgnoredFolders:  []ObservedFolder{},
+				Addresses:         []string{"dynamic"},
+				AllowedNetworks:   []string{},
+				Compression:       CompressionMetadata,
+				IgnoredFolders:    []ObservedFolder{},
+				BandwidthSchedule: []BandwidthScheduleEntry{},
 			},
 			Ignores: Ignores{
 				Lines: []string{},
 			},
 		},
 		IgnoredDevices: []ObservedDevice{},
+		Webhooks:       []WebhookConfiguration{},
 	}
 	expected.Devices = []DeviceConfiguration{expected.Defaults.Device.Copy()}
 	expected.Devices[0].DeviceID = device1
@@ -192,6 +202,8 @@ func TestDeviceConfig(t *testing.T) {
 				},
 				WeakHashThresholdPct: 25,
 				MarkerName:           DefaultMarkerName,
+				SyncWindows:          []SyncWindow{},
+				SelectiveSyncInclude: []string{},
 				JunctionsAsDirs:      true,
 				MaxConcurrentWrites:  maxConcurrentWritesDefault,
 				XattrFilter: XattrFilter{
@@ -202,20 +214,22 @@ func TestDeviceConfig(t *testing.T) {
 
 		expectedDevices := []DeviceConfiguration{
 			{
-				DeviceID:        device1,
-				Name:            "node one",
-				Addresses:       []string{"tcp://a"},
-				Compression:     CompressionMetadata,
-				AllowedNetworks: []string{},
-				IgnoredFolders:  []ObservedFolder{},
+				DeviceID:          device1,
+				Name:              "node one",
+				Addresses:         []string{"tcp://a"},
+				Compression:       CompressionMetadata,
+				AllowedNetworks:   []string{},
+				IgnoredFolders:    []ObservedFolder{},
+				BandwidthSchedule: []BandwidthScheduleEntry{},
 			},
 			{
-				DeviceID:        device4,
-				Name:            "node two",
-				Addresses:       []string{"tcp://b"},
-				Compression:     CompressionMetadata,
-				AllowedNetworks: []string{},
-				IgnoredFolders:  []ObservedFolder{},
+				DeviceID:          device4,
+				Name:              "node two",
+				Addresses:         []string{"tcp://b"},
+				Compression:       CompressionMetadata,
+				AllowedNetworks:   []string{},
+				IgnoredFolders:    []ObservedFolder{},
+				BandwidthSchedule: []BandwidthScheduleEntry{},
 			},
 		}
 		expectedDeviceIDs := []protocol.DeviceID{device1, device4}
@@ -262,6 +276,7 @@ func TestOverriddenValues(t *testing.T) {
 		ReconnectIntervalS:        6000,
 		RelaysEnabled:             false,
 		RelayReconnectIntervalM:   20,
+		HolePunchingEnabled:       false,
 		StartBrowser:              false,
 		NATEnabled:                false,
 		NATLeaseM:                 90,
@@ -280,6 +295,7 @@ func TestOverriddenValues(t *testing.T) {
 		URPostInsecurely:          true,
 		ReleasesURL:               "https://localhost/releases",
 		AlwaysLocalNets:           []string{},
+		BandwidthSchedule:         []BandwidthScheduleEntry{},
 		OverwriteRemoteDevNames:   true,
 		TempIndexMinBlocks:        100,
 		UnackedNotificationIDs:    []string{"asdfasdf"},
@@ -294,6 +310,8 @@ func TestOverriddenValues(t *testing.T) {
 		ConnectionPriorityQUICLAN: 45,
 		ConnectionPriorityTCPWAN:  50,
 		ConnectionPriorityQUICWAN: 55,
+		ConnectionPriorityWSSLAN:  47,
+		ConnectionPriorityWSSWAN:  57,
 		ConnectionPriorityRelay:   9000,
 	}
 	expectedPath := "/media/syncthing"
@@ -323,30 +341,34 @@ func TestDeviceAddressesDynamic(t *testing.T) {
 	name, _ := os.Hostname()
 	expected := map[protocol.DeviceID]DeviceConfiguration{
 		device1: {
-			DeviceID:        device1,
-			Addresses:       []string{"dynamic"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device1,
+			Addresses:         []string{"dynamic"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device2: {
-			DeviceID:        device2,
-			Addresses:       []string{"dynamic"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device2,
+			Addresses:         []string{"dynamic"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device3: {
-			DeviceID:        device3,
-			Addresses:       []string{"dynamic"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device3,
+			Addresses:         []string{"dynamic"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device4: {
-			DeviceID:        device4,
-			Name:            name, // Set when auto created
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device4,
+			Name:              name, // Set when auto created
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 	}
 
@@ -366,33 +388,37 @@ func TestDeviceCompression(t *testing.T) {
 	name, _ := os.Hostname()
 	expected := map[protocol.DeviceID]DeviceConfiguration{
 		device1: {
-			DeviceID:        device1,
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device1,
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device2: {
-			DeviceID:        device2,
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device2,
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device3: {
-			DeviceID:        device3,
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionNever,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device3,
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionNever,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device4: {
-			DeviceID:        device4,
-			Name:            name, // Set when auto created
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device4,
+			Name:              name, // Set when auto created
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 	}
 
@@ -412,30 +438,34 @@ func TestDeviceAddressesStatic(t *testing.T) {
 	name, _ := os.Hostname()
 	expected := map[protocol.DeviceID]DeviceConfiguration{
 		device1: {
-			DeviceID:        device1,
-			Addresses:       []string{"tcp://192.0.2.1", "tcp://192.0.2.2"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device1,
+			Addresses:         []string{"tcp://192.0.2.1", "tcp://192.0.2.2"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device2: {
-			DeviceID:        device2,
-			Addresses:       []string{"tcp://192.0.2.3:6070", "tcp://[2001:db8::42]:4242"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device2,
+			Addresses:         []string{"tcp://192.0.2.3:6070", "tcp://[2001:db8::42]:4242"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device3: {
-			DeviceID:        device3,
-			Addresses:       []string{"tcp://[2001:db8::44]:4444", "tcp://192.0.2.4:6090"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device3,
+			Addresses:         []string{"tcp://[2001:db8::44]:4444", "tcp://192.0.2.4:6090"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 		device4: {
-			DeviceID:        device4,
-			Name:            name, // Set when auto created
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device4,
+			Name:              name, // Set when auto created
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			BandwidthSchedule: []BandwidthScheduleEntry{},
 		},
 	}
 
@@ -558,6 +588,7 @@ func TestFolderCheckPath(t *testing.T) {
 		cfg := FolderConfiguration{
 			FilesystemType: FilesystemTypeFake,
 			MarkerName:     DefaultMarkerName,
+			SyncWindows:    []SyncWindow{},
 		}
 
 		if err := cfg.checkFilesystemPath(tmpFs, testcase.path); testcase.err != err {
@@ -776,6 +807,110 @@ func TestGUIConfigURL(t *testing.T) {
 	}
 }
 
+func TestGUIUserPasswordHashedOnPrepare(t *testing.T) {
+	c := GUIConfiguration{Users: []GUIUser{{Name: "user", Password: "pass"}}}
+	c.prepare()
+	if c.Users[0].Password == "pass" {
+		t.Error("Password wasn't hashed")
+	}
+	if err := c.Users[0].CompareHashedPassword("pass"); err != nil {
+		t.Errorf("No match on same password: %v", err)
+	}
+	hash := c.Users[0].Password
+	c.prepare()
+	if c.Users[0].Password != hash {
+		t.Error("Hashed password was hashed again")
+	}
+}
+
+func TestAPITokenHashedOnPrepare(t *testing.T) {
+	c := GUIConfiguration{APITokens: []APIToken{{Name: "backup", Token: "s3cret"}}}
+	c.prepare()
+	if c.APITokens[0].Token != HashAPIToken("s3cret") {
+		t.Errorf("Token wasn't hashed: %q", c.APITokens[0].Token)
+	}
+	c.prepare()
+	if c.APITokens[0].Token != HashAPIToken("s3cret") {
+		t.Error("Hashed token was hashed again")
+	}
+
+	now := time.Now()
+	if _, ok := c.APIToken("s3cret", now); !ok {
+		t.Error("Token should be valid")
+	}
+	if _, ok := c.APIToken(HashAPIToken("s3cret"), now); ok {
+		t.Error("The hash shouldn't be valid as a token")
+	}
+	c.APITokens[0].Expires = now
+	if _, ok := c.APIToken("s3cret", now); ok {
+		t.Error("Expired token should be invalid")
+	}
+}
+
+func TestAPITokenAllows(t *testing.T) {
+	token := APIToken{Scopes: []string{"GET /rest/db/*", "/rest/system/ping"}}
+	cases := []struct {
+		method, path string
+		allowed      bool
+	}{
+		{"GET", "/rest/db/status", true},
+		{"get", "/rest/db/browse", true},
+		{"POST", "/rest/db/scan", false},
+		{"GET", "/rest/dbx", false},
+		{"GET", "/rest/system/ping", true},
+		{"POST", "/rest/system/ping", true},
+		{"POST", "/rest/system/restart", false},
+	}
+	for _, tc := range cases {
+		if allowed := token.Allows(tc.method, tc.path); allowed != tc.allowed {
+			t.Errorf("%s %s: allowed %v, expected %v", tc.method, tc.path, allowed, tc.allowed)
+		}
+	}
+	if !(APIToken{}).Allows("POST", "/rest/system/restart") {
+		t.Error("Token without scopes should allow everything")
+	}
+}
+
+func TestGUIRoleUnmarshal(t *testing.T) {
+	for _, role := range []GUIRole{GUIRoleReadOnly, GUIRoleOperator, GUIRoleAdmin} {
+		bs, _ := role.MarshalText()
+		var got GUIRole
+		if err := got.UnmarshalText(bs); err != nil || got != role {
+			t.Errorf("Expected %v, got %v, %v", role, got, err)
+		}
+	}
+	for _, bad := range []string{"", "Admin", "root"} {
+		var got GUIRole
+		if err := got.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for role %q, got %v", bad, got)
+		}
+	}
+}
+
+func TestLDAPUserForGroups(t *testing.T) {
+	var c LDAPConfiguration
+	if user, ok := c.UserForGroups("user", nil); !ok || user.Role != GUIRoleAdmin {
+		t.Errorf("Without group roles, expected an admin, got %+v, %v", user, ok)
+	}
+
+	c.GroupRoles = []LDAPGroupRole{
+		{Group: "cn=ops,dc=example", Role: GUIRoleOperator, Folders: []string{"a"}},
+		{Group: "cn=viewers,dc=example", Role: GUIRoleReadOnly, Folders: []string{"b"}},
+		{Group: "cn=auditors,dc=example", Role: GUIRoleReadOnly},
+	}
+	if _, ok := c.UserForGroups("user", []string{"cn=others,dc=example"}); ok {
+		t.Error("User without a matching group should be rejected")
+	}
+	user, ok := c.UserForGroups("user", []string{"CN=Ops,dc=example", "cn=viewers,dc=example"})
+	if !ok || user.Role != GUIRoleOperator || !slices.Equal(user.Folders, []string{"a", "b"}) {
+		t.Errorf("Expected an operator for a and b, got %+v, %v", user, ok)
+	}
+	user, ok = c.UserForGroups("user", []string{"cn=viewers,dc=example", "cn=auditors,dc=example"})
+	if !ok || user.Role != GUIRoleReadOnly || !user.CanAccessFolder("c") {
+		t.Errorf("Expected a read-only user for all folders, got %+v, %v", user, ok)
+	}
+}
+
 func TestGUIPasswordHash(t *testing.T) {
 	var c GUIConfiguration
 
@@ -1466,6 +1601,42 @@ func TestReceiveEncryptedFolderFixed(t *testing.T) {
 	}
 }
 
+func TestLocalEncryptionPassword(t *testing.T) {
+	dir := t.TempDir()
+	fcfg := FolderConfiguration{
+		ID:                      "foo",
+		Path:                    dir,
+		FilesystemType:          FilesystemTypeBasic,
+		LocalEncryptionPassword: "secret",
+	}
+
+	if err := fs.WriteFile(fcfg.Filesystem(nil), "plain", []byte("plain text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "plain")); !os.IsNotExist(err) {
+		t.Error("expected no plain text name on disk, got", err)
+	}
+	fd, err := fcfg.Filesystem(nil).Open("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "plain text" {
+		t.Errorf("unexpected contents %q", bs)
+	}
+
+	// Receive encrypted folders are left alone, the data is encrypted
+	// already.
+	fcfg.Type = FolderTypeReceiveEncrypted
+	if _, err := fcfg.Filesystem(nil).Lstat("plain"); !fs.IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+}
+
 func TestXattrFilter(t *testing.T) {
 	cases := []struct {
 		in     []string
@@ -1672,3 +1843,20 @@ func TestCopyMatching(t *testing.T) {
 		t.Error("NoCopy")
 	}
 }
+
+func TestInvalidWebhooksRemoved(t *testing.T) {
+	cfg := New(device1)
+	cfg.Webhooks = []WebhookConfiguration{
+		{URL: "https://example.com/hook", Events: []string{"FolderCompletion"}},
+		{URL: "ftp://example.com/hook"},
+		{URL: "https://example.com/other", Events: []string{"NoSuchEvent"}},
+	}
+	cfg.prepare(device1)
+
+	if len(cfg.Webhooks) != 1 || cfg.Webhooks[0].URL != "https://example.com/hook" {
+		t.Error("unexpected webhooks after prepare:", cfg.Webhooks)
+	}
+	if mask := cfg.Webhooks[0].EventMask(events.AllEvents); mask != events.FolderCompletion {
+		t.Errorf("unexpected event mask %v", mask)
+	}
+}
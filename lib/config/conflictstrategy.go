@@ -0,0 +1,63 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// ConflictStrategy decides what happens to the local file when it's in
+// conflict with a remote change that is about to replace it.
+type ConflictStrategy int32
+
+const (
+	// The local file is kept as a conflict copy, up to MaxConflicts of
+	// them.
+	ConflictStrategyCopy ConflictStrategy = 0
+	// The file with the newest modification time wins, no conflict copy
+	// is made.
+	ConflictStrategyNewest ConflictStrategy = 1
+	// The change made by ConflictWinnerDevice wins, no conflict copy is
+	// made. Conflicts between other devices are handled as with
+	// ConflictStrategyCopy.
+	ConflictStrategyDevice ConflictStrategy = 2
+	// The remote change wins and the local file is archived by the
+	// folder's versioner instead of kept as a conflict copy. Without a
+	// versioner this is the same as ConflictStrategyCopy.
+	ConflictStrategyVersion ConflictStrategy = 3
+)
+
+func (s ConflictStrategy) String() string {
+	switch s {
+	case ConflictStrategyCopy:
+		return "copy"
+	case ConflictStrategyNewest:
+		return "newest"
+	case ConflictStrategyDevice:
+		return "device"
+	case ConflictStrategyVersion:
+		return "version"
+	default:
+		return "unknown"
+	}
+}
+
+func (s ConflictStrategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ConflictStrategy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "copy":
+		*s = ConflictStrategyCopy
+	case "newest":
+		*s = ConflictStrategyNewest
+	case "device":
+		*s = ConflictStrategyDevice
+	case "version":
+		*s = ConflictStrategyVersion
+	default:
+		*s = ConflictStrategyCopy
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+type DatabaseBackend int32
+
+const (
+	DatabaseBackendLevelDB DatabaseBackend = 0
+	DatabaseBackendSQLite  DatabaseBackend = 1
+)
+
+func (b DatabaseBackend) String() string {
+	switch b {
+	case DatabaseBackendLevelDB:
+		return "leveldb"
+	case DatabaseBackendSQLite:
+		return "sqlite"
+	default:
+		return "unknown"
+	}
+}
+
+func (b DatabaseBackend) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *DatabaseBackend) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "leveldb":
+		*b = DatabaseBackendLevelDB
+	case "sqlite":
+		*b = DatabaseBackendSQLite
+	default:
+		*b = DatabaseBackendLevelDB
+	}
+	return nil
+}
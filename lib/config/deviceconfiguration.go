@@ -16,25 +16,26 @@ import (
 const defaultNumConnections = 1 // number of connections to use by default; may change in the future.
 
 type DeviceConfiguration struct {
-	DeviceID                 protocol.DeviceID `json:"deviceID" xml:"id,attr" nodefault:"true"`
-	Name                     string            `json:"name" xml:"name,attr,omitempty"`
-	Addresses                []string          `json:"addresses" xml:"address,omitempty"`
-	Compression              Compression       `json:"compression" xml:"compression,attr"`
-	CertName                 string            `json:"certName" xml:"certName,attr,omitempty"`
-	Introducer               bool              `json:"introducer" xml:"introducer,attr"`
-	SkipIntroductionRemovals bool              `json:"skipIntroductionRemovals" xml:"skipIntroductionRemovals,attr"`
-	IntroducedBy             protocol.DeviceID `json:"introducedBy" xml:"introducedBy,attr" nodefault:"true"`
-	Paused                   bool              `json:"paused" xml:"paused"`
-	AllowedNetworks          []string          `json:"allowedNetworks" xml:"allowedNetwork,omitempty"`
-	AutoAcceptFolders        bool              `json:"autoAcceptFolders" xml:"autoAcceptFolders"`
-	MaxSendKbps              int               `json:"maxSendKbps" xml:"maxSendKbps"`
-	MaxRecvKbps              int               `json:"maxRecvKbps" xml:"maxRecvKbps"`
-	IgnoredFolders           []ObservedFolder  `json:"ignoredFolders" xml:"ignoredFolder"`
-	DeprecatedPendingFolders []ObservedFolder  `json:"-" xml:"pendingFolder,omitempty"` // Deprecated: Do not use.
-	MaxRequestKiB            int               `json:"maxRequestKiB" xml:"maxRequestKiB"`
-	Untrusted                bool              `json:"untrusted" xml:"untrusted"`
-	RemoteGUIPort            int               `json:"remoteGUIPort" xml:"remoteGUIPort"`
-	RawNumConnections        int               `json:"numConnections" xml:"numConnections"`
+	DeviceID                 protocol.DeviceID        `json:"deviceID" xml:"id,attr" nodefault:"true"`
+	Name                     string                   `json:"name" xml:"name,attr,omitempty"`
+	Addresses                []string                 `json:"addresses" xml:"address,omitempty"`
+	Compression              Compression              `json:"compression" xml:"compression,attr"`
+	CertName                 string                   `json:"certName" xml:"certName,attr,omitempty"`
+	Introducer               bool                     `json:"introducer" xml:"introducer,attr"`
+	SkipIntroductionRemovals bool                     `json:"skipIntroductionRemovals" xml:"skipIntroductionRemovals,attr"`
+	IntroducedBy             protocol.DeviceID        `json:"introducedBy" xml:"introducedBy,attr" nodefault:"true"`
+	Paused                   bool                     `json:"paused" xml:"paused"`
+	AllowedNetworks          []string                 `json:"allowedNetworks" xml:"allowedNetwork,omitempty"`
+	AutoAcceptFolders        bool                     `json:"autoAcceptFolders" xml:"autoAcceptFolders"`
+	MaxSendKbps              int                      `json:"maxSendKbps" xml:"maxSendKbps"`
+	MaxRecvKbps              int                      `json:"maxRecvKbps" xml:"maxRecvKbps"`
+	BandwidthSchedule        []BandwidthScheduleEntry `json:"bandwidthSchedule" xml:"bandwidthSchedule"`
+	IgnoredFolders           []ObservedFolder         `json:"ignoredFolders" xml:"ignoredFolder"`
+	DeprecatedPendingFolders []ObservedFolder         `json:"-" xml:"pendingFolder,omitempty"` // Deprecated: Do not use.
+	MaxRequestKiB            int                      `json:"maxRequestKiB" xml:"maxRequestKiB"`
+	Untrusted                bool                     `json:"untrusted" xml:"untrusted"`
+	RemoteGUIPort            int                      `json:"remoteGUIPort" xml:"remoteGUIPort"`
+	RawNumConnections        int                      `json:"numConnections" xml:"numConnections"`
 }
 
 func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
@@ -45,6 +46,8 @@ func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
 	copy(c.AllowedNetworks, cfg.AllowedNetworks)
 	c.IgnoredFolders = make([]ObservedFolder, len(cfg.IgnoredFolders))
 	copy(c.IgnoredFolders, cfg.IgnoredFolders)
+	c.BandwidthSchedule = make([]BandwidthScheduleEntry, len(cfg.BandwidthSchedule))
+	copy(c.BandwidthSchedule, cfg.BandwidthSchedule)
 	return c
 }
 
@@ -61,6 +64,8 @@ func (cfg *DeviceConfiguration) prepare(sharedFolders []string) {
 
 	cfg.IgnoredFolders = sortedObservedFolderSlice(ignoredFolders)
 
+	cfg.BandwidthSchedule = validBandwidthSchedule(cfg.BandwidthSchedule, "device "+cfg.DeviceID.Short().String())
+
 	// A device cannot be simultaneously untrusted and an introducer, nor
 	// auto accept folders.
 	if cfg.Untrusted {
@@ -13,6 +13,8 @@ type FilesystemType int32
 const (
 	FilesystemTypeBasic FilesystemType = 0
 	FilesystemTypeFake  FilesystemType = 1
+	FilesystemTypeS3    FilesystemType = 2
+	FilesystemTypeSFTP  FilesystemType = 3
 )
 
 func (t FilesystemType) String() string {
@@ -21,6 +23,10 @@ func (t FilesystemType) String() string {
 		return "basic"
 	case FilesystemTypeFake:
 		return "fake"
+	case FilesystemTypeS3:
+		return "s3"
+	case FilesystemTypeSFTP:
+		return "sftp"
 	default:
 		return "unknown"
 	}
@@ -32,6 +38,10 @@ func (t FilesystemType) ToFS() fs.FilesystemType {
 		return fs.FilesystemTypeBasic
 	case FilesystemTypeFake:
 		return fs.FilesystemTypeFake
+	case FilesystemTypeS3:
+		return fs.FilesystemTypeS3
+	case FilesystemTypeSFTP:
+		return fs.FilesystemTypeSFTP
 	default:
 		return fs.FilesystemTypeBasic
 	}
@@ -47,6 +57,10 @@ func (t *FilesystemType) UnmarshalText(bs []byte) error {
 		*t = FilesystemTypeBasic
 	case "fake":
 		*t = FilesystemTypeFake
+	case "s3":
+		*t = FilesystemTypeS3
+	case "sftp":
+		*t = FilesystemTypeSFTP
 	default:
 		*t = FilesystemTypeBasic
 	}
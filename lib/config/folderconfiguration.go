@@ -25,6 +25,10 @@ import (
 	"github.com/syncthing/syncthing/lib/protocol"
 )
 
+// localEncryptionKeys caches the expensive key derivations for at-rest
+// encrypted folders, as the filesystem is created many times over.
+var localEncryptionKeys = protocol.NewKeyGenerator()
+
 var (
 	ErrPathNotDirectory = errors.New("folder path not a directory")
 	ErrPathMissing      = errors.New("folder path missing")
@@ -34,6 +38,7 @@ var (
 const (
 	DefaultMarkerName          = ".stfolder"
 	EncryptionTokenName        = "syncthing-encryption_password_token" //nolint: gosec
+	localEncryptionKeyLabel    = "syncthing-local-encryption-content"
 	maxConcurrentWritesDefault = 2
 	maxConcurrentWritesLimit   = 64
 )
@@ -67,6 +72,14 @@ type FolderConfiguration struct {
 	ScanProgressIntervalS   int                         `json:"scanProgressIntervalS" xml:"scanProgressIntervalS"`
 	PullerPauseS            int                         `json:"pullerPauseS" xml:"pullerPauseS"`
 	MaxConflicts            int                         `json:"maxConflicts" xml:"maxConflicts" default:"10"`
+	ConflictStrategy        ConflictStrategy            `json:"conflictStrategy" xml:"conflictStrategy"`
+	ConflictWinnerDevice    protocol.DeviceID           `json:"conflictWinnerDevice" xml:"conflictWinnerDevice"`
+	MergeCommand            string                      `json:"mergeCommand" xml:"mergeCommand"`
+	SelectiveSync           bool                        `json:"selectiveSync" xml:"selectiveSync"`
+	SelectiveSyncInclude    []string                    `json:"selectiveSyncInclude" xml:"selectiveSyncInclude"`
+	PullPreview             bool                        `json:"pullPreview" xml:"pullPreview"`
+	MaxDeletions            int                         `json:"maxDeletions" xml:"maxDeletions"`
+	MaxDeletionsPct         int                         `json:"maxDeletionsPct" xml:"maxDeletionsPct"`
 	DisableSparseFiles      bool                        `json:"disableSparseFiles" xml:"disableSparseFiles"`
 	DisableTempIndexes      bool                        `json:"disableTempIndexes" xml:"disableTempIndexes"`
 	Paused                  bool                        `json:"paused" xml:"paused"`
@@ -85,6 +98,8 @@ type FolderConfiguration struct {
 	SyncXattrs              bool                        `json:"syncXattrs" xml:"syncXattrs"`
 	SendXattrs              bool                        `json:"sendXattrs" xml:"sendXattrs"`
 	XattrFilter             XattrFilter                 `json:"xattrFilter" xml:"xattrFilter"`
+	LocalEncryptionPassword string                      `json:"localEncryptionPassword" xml:"localEncryptionPassword"`
+	SyncWindows             []SyncWindow                `json:"syncWindows" xml:"syncWindow"`
 	// Legacy deprecated
 	DeprecatedReadOnly       bool    `json:"-" xml:"ro,attr,omitempty"`        // Deprecated: Do not use.
 	DeprecatedMinDiskFreePct float64 `json:"-" xml:"minDiskFreePct,omitempty"` // Deprecated: Do not use.
@@ -114,6 +129,9 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 	c.Devices = make([]FolderDeviceConfiguration, len(f.Devices))
 	copy(c.Devices, f.Devices)
 	c.Versioning = f.Versioning.Copy()
+	c.SyncWindows = make([]SyncWindow, len(f.SyncWindows))
+	copy(c.SyncWindows, f.SyncWindows)
+	c.SelectiveSyncInclude = append([]string(nil), f.SelectiveSyncInclude...)
 	return c
 }
 
@@ -123,10 +141,17 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 func (f FolderConfiguration) Filesystem(fset *db.FileSet) fs.Filesystem {
 	// This is intentionally not a pointer method, because things like
 	// cfg.Folders["default"].Filesystem(nil) should be valid.
-	opts := make([]fs.Option, 0, 3)
+	opts := make([]fs.Option, 0, 4)
 	if f.FilesystemType == FilesystemTypeBasic && f.JunctionsAsDirs {
 		opts = append(opts, new(fs.OptionJunctionsAsDirs))
 	}
+	if f.LocalEncryptionPassword != "" && f.Type != FolderTypeReceiveEncrypted {
+		// Data in receive encrypted folders is already encrypted, and
+		// we can't read it anyway.
+		nameKey := localEncryptionKeys.KeyFromPassword(f.ID, f.LocalEncryptionPassword)
+		contentKey := localEncryptionKeys.FileKey(localEncryptionKeyLabel, nameKey)
+		opts = append(opts, fs.NewEncryptionOption(nameKey, contentKey))
+	}
 	if !f.CaseSensitiveFS {
 		opts = append(opts, new(fs.OptionDetectCaseConflicts))
 	}
@@ -136,6 +161,20 @@ func (f FolderConfiguration) Filesystem(fset *db.FileSet) fs.Filesystem {
 	return fs.NewFilesystem(f.FilesystemType.ToFS(), f.Path, opts...)
 }
 
+// InSyncWindow returns true if the folder may scan and pull at the given
+// time, which is always the case when there are no sync windows.
+func (f FolderConfiguration) InSyncWindow(t time.Time) bool {
+	if len(f.SyncWindows) == 0 {
+		return true
+	}
+	for _, w := range f.SyncWindows {
+		if w.ActiveAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
 func (f FolderConfiguration) ModTimeWindow() time.Duration {
 	dur := time.Duration(f.RawModTimeWindowS) * time.Second
 	if f.RawModTimeWindowS < 1 && build.IsAndroid {
@@ -319,6 +358,14 @@ func (f *FolderConfiguration) prepare(myID protocol.DeviceID, existingDevices ma
 		f.MarkerName = DefaultMarkerName
 	}
 
+	// Invalid sync windows are never active. They're kept, as dropping
+	// them could have the folder sync at times it shouldn't.
+	for _, w := range f.SyncWindows {
+		if err := w.Validate(); err != nil {
+			l.Warnf("Invalid sync window for folder %s (%s): %v", f.ID, f.Label, err)
+		}
+	}
+
 	if f.MaxConcurrentWrites <= 0 {
 		f.MaxConcurrentWrites = maxConcurrentWritesDefault
 	} else if f.MaxConcurrentWrites > maxConcurrentWritesLimit {
@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -19,25 +20,38 @@ import (
 )
 
 type GUIConfiguration struct {
-	Enabled                   bool     `json:"enabled" xml:"enabled,attr" default:"true"`
-	RawAddress                string   `json:"address" xml:"address" default:"127.0.0.1:8384"`
-	RawUnixSocketPermissions  string   `json:"unixSocketPermissions" xml:"unixSocketPermissions,omitempty"`
-	User                      string   `json:"user" xml:"user,omitempty"`
-	Password                  string   `json:"password" xml:"password,omitempty"`
-	AuthMode                  AuthMode `json:"authMode" xml:"authMode,omitempty"`
-	RawUseTLS                 bool     `json:"useTLS" xml:"tls,attr"`
-	APIKey                    string   `json:"apiKey" xml:"apikey,omitempty"`
-	InsecureAdminAccess       bool     `json:"insecureAdminAccess" xml:"insecureAdminAccess,omitempty"`
-	Theme                     string   `json:"theme" xml:"theme" default:"default"`
-	Debugging                 bool     `json:"debugging" xml:"debugging,attr"`
-	InsecureSkipHostCheck     bool     `json:"insecureSkipHostcheck" xml:"insecureSkipHostcheck,omitempty"`
-	InsecureAllowFrameLoading bool     `json:"insecureAllowFrameLoading" xml:"insecureAllowFrameLoading,omitempty"`
-	SendBasicAuthPrompt       bool     `json:"sendBasicAuthPrompt" xml:"sendBasicAuthPrompt,attr"`
+	Enabled                   bool       `json:"enabled" xml:"enabled,attr" default:"true"`
+	RawAddress                string     `json:"address" xml:"address" default:"127.0.0.1:8384"`
+	RawUnixSocketPermissions  string     `json:"unixSocketPermissions" xml:"unixSocketPermissions,omitempty"`
+	User                      string     `json:"user" xml:"user,omitempty"`
+	Password                  string     `json:"password" xml:"password,omitempty"`
+	AuthMode                  AuthMode   `json:"authMode" xml:"authMode,omitempty"`
+	RawUseTLS                 bool       `json:"useTLS" xml:"tls,attr"`
+	APIKey                    string     `json:"apiKey" xml:"apikey,omitempty"`
+	InsecureAdminAccess       bool       `json:"insecureAdminAccess" xml:"insecureAdminAccess,omitempty"`
+	Theme                     string     `json:"theme" xml:"theme" default:"default"`
+	Debugging                 bool       `json:"debugging" xml:"debugging,attr"`
+	InsecureSkipHostCheck     bool       `json:"insecureSkipHostcheck" xml:"insecureSkipHostcheck,omitempty"`
+	InsecureAllowFrameLoading bool       `json:"insecureAllowFrameLoading" xml:"insecureAllowFrameLoading,omitempty"`
+	SendBasicAuthPrompt       bool       `json:"sendBasicAuthPrompt" xml:"sendBasicAuthPrompt,attr"`
+	WebDAVEnabled             bool       `json:"webdavEnabled" xml:"webdavEnabled,attr"`
+	Users                     []GUIUser  `json:"users" xml:"users>user"`
+	APITokens                 []APIToken `json:"apiTokens" xml:"apiTokens>token"`
 }
 
 func (c GUIConfiguration) IsAuthEnabled() bool {
 	// This function should match isAuthEnabled() in syncthingController.js
-	return c.AuthMode == AuthModeLDAP || (len(c.User) > 0 && len(c.Password) > 0)
+	return c.AuthMode == AuthModeLDAP || (len(c.User) > 0 && len(c.Password) > 0) || len(c.Users) > 0
+}
+
+// UserByName returns the additional user with the given name.
+func (c GUIConfiguration) UserByName(name string) (GUIUser, bool) {
+	for _, user := range c.Users {
+		if user.Name == name {
+			return user, true
+		}
+	}
+	return GUIUser{}, false
 }
 
 func (GUIConfiguration) IsOverridden() bool {
@@ -137,17 +151,24 @@ var bcryptExpr = regexp.MustCompile(`^\$2[aby]\$\d+\$.{50,}`)
 // Plaintext passwords are hashed. Returns an error if the password is not
 // valid.
 func (c *GUIConfiguration) SetPassword(password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	c.Password = hash
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
 	if bcryptExpr.MatchString(password) {
 		// Already hashed
-		c.Password = password
-		return nil
+		return password, nil
 	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return "", err
 	}
-	c.Password = string(hash)
-	return nil
+	return string(hash), nil
 }
 
 // CompareHashedPassword returns nil when the given plaintext password matches the stored hash.
@@ -157,6 +178,17 @@ func (c GUIConfiguration) CompareHashedPassword(password string) error {
 	return bcrypt.CompareHashAndPassword(configPasswordBytes, passwordBytes)
 }
 
+// APIToken returns the API token with the given plaintext value, if it
+// exists and hasn't expired at the given time.
+func (c GUIConfiguration) APIToken(token string, now time.Time) (APIToken, bool) {
+	for _, t := range c.APITokens {
+		if t.Matches(token) && !t.Expired(now) {
+			return t, true
+		}
+	}
+	return APIToken{}, false
+}
+
 // IsValidAPIKey returns true when the given API key is valid, including both
 // the value in config and any overrides
 func (c GUIConfiguration) IsValidAPIKey(apiKey string) bool {
@@ -176,8 +208,36 @@ func (c *GUIConfiguration) prepare() {
 	if c.APIKey == "" {
 		c.APIKey = rand.String(32)
 	}
+	for i := range c.Users {
+		if c.Users[i].Password == "" {
+			continue
+		}
+		// Users may be added with a plaintext password, which we don't
+		// keep around.
+		if err := c.Users[i].SetPassword(c.Users[i].Password); err != nil {
+			l.Warnf("Hashing password of GUI user %q: %v", c.Users[i].Name, err)
+			c.Users[i].Password = ""
+		}
+	}
+	for i := range c.APITokens {
+		c.APITokens[i].prepare()
+	}
 }
 
 func (c GUIConfiguration) Copy() GUIConfiguration {
+	if c.Users != nil {
+		users := make([]GUIUser, len(c.Users))
+		for i, user := range c.Users {
+			users[i] = user.Copy()
+		}
+		c.Users = users
+	}
+	if c.APITokens != nil {
+		tokens := make([]APIToken, len(c.APITokens))
+		for i, token := range c.APITokens {
+			tokens[i] = token.Copy()
+		}
+		c.APITokens = tokens
+	}
 	return c
 }
@@ -0,0 +1,55 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "fmt"
+
+// GUIRole is what a GUI/API user may do. Roles are ordered, each allowing
+// everything the previous one does: read-only users may only look,
+// operators may also act on folders and devices (scan, pause, override,
+// ...), and admins may also change the configuration and control the
+// process (restart, shutdown, upgrade, debugging).
+type GUIRole int32
+
+const (
+	GUIRoleReadOnly GUIRole = 0
+	GUIRoleOperator GUIRole = 1
+	GUIRoleAdmin    GUIRole = 2
+)
+
+func (r GUIRole) String() string {
+	switch r {
+	case GUIRoleReadOnly:
+		return "readonly"
+	case GUIRoleOperator:
+		return "operator"
+	case GUIRoleAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+func (r GUIRole) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText parses a role, rejecting unknown ones instead of quietly
+// turning them into some role the user wasn't meant to have.
+func (r *GUIRole) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "admin":
+		*r = GUIRoleAdmin
+	case "operator":
+		*r = GUIRoleOperator
+	case "readonly":
+		*r = GUIRoleReadOnly
+	default:
+		return fmt.Errorf("unknown GUI role %q", bs)
+	}
+	return nil
+}
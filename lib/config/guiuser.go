@@ -0,0 +1,55 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"slices"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// A GUIUser is a GUI/API user in addition to the one set directly in the
+// GUI configuration, which is always an admin. When Folders is set, the
+// user only sees those folders; changing the configuration requires an
+// admin that sees all folders.
+type GUIUser struct {
+	Name     string   `json:"name" xml:"name,attr"`
+	Password string   `json:"password" xml:"password,omitempty"`
+	Role     GUIRole  `json:"role" xml:"role,attr"`
+	Folders  []string `json:"folders" xml:"folder"`
+}
+
+// SetPassword takes a bcrypt hash or a plaintext password and stores it.
+// Plaintext passwords are hashed.
+func (u *GUIUser) SetPassword(password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
+	return nil
+}
+
+// CompareHashedPassword returns nil when the given plaintext password matches the stored hash.
+func (u GUIUser) CompareHashedPassword(password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+}
+
+// CanAccessFolder returns true if the user sees the given folder.
+func (u GUIUser) CanAccessFolder(id string) bool {
+	return len(u.Folders) == 0 || slices.Contains(u.Folders, id)
+}
+
+// CanChangeConfig returns true if the user may change the configuration.
+func (u GUIUser) CanChangeConfig() bool {
+	return u.Role == GUIRoleAdmin && len(u.Folders) == 0
+}
+
+func (u GUIUser) Copy() GUIUser {
+	u.Folders = slices.Clone(u.Folders)
+	return u
+}
@@ -6,15 +6,69 @@
 
 package config
 
+import (
+	"slices"
+	"strings"
+)
+
 type LDAPConfiguration struct {
-	Address            string        `json:"address" xml:"address,omitempty"`
-	BindDN             string        `json:"bindDN" xml:"bindDN,omitempty"`
-	Transport          LDAPTransport `json:"transport" xml:"transport,omitempty"`
-	InsecureSkipVerify bool          `json:"insecureSkipVerify" xml:"insecureSkipVerify,omitempty" default:"false"`
-	SearchBaseDN       string        `json:"searchBaseDN" xml:"searchBaseDN,omitempty"`
-	SearchFilter       string        `json:"searchFilter" xml:"searchFilter,omitempty"`
+	Address            string          `json:"address" xml:"address,omitempty"`
+	BindDN             string          `json:"bindDN" xml:"bindDN,omitempty"`
+	Transport          LDAPTransport   `json:"transport" xml:"transport,omitempty"`
+	InsecureSkipVerify bool            `json:"insecureSkipVerify" xml:"insecureSkipVerify,omitempty" default:"false"`
+	SearchBaseDN       string          `json:"searchBaseDN" xml:"searchBaseDN,omitempty"`
+	SearchFilter       string          `json:"searchFilter" xml:"searchFilter,omitempty"`
+	GroupRoles         []LDAPGroupRole `json:"groupRoles" xml:"groupRole"`
+}
+
+// An LDAPGroupRole gives the members of an LDAP group, as listed in the
+// memberOf attribute of the user, a GUI role and optionally restricts them
+// to some folders. Without any group roles, all LDAP users are admins.
+type LDAPGroupRole struct {
+	Group   string   `json:"group" xml:"group,attr"`
+	Role    GUIRole  `json:"role" xml:"role,attr"`
+	Folders []string `json:"folders" xml:"folder"`
+}
+
+// UserForGroups returns the user with the given name and groups, with the
+// highest role of the matching group roles and access to the folders of
+// all of them. It returns false if no group matches.
+func (c LDAPConfiguration) UserForGroups(name string, groups []string) (GUIUser, bool) {
+	if len(c.GroupRoles) == 0 {
+		return GUIUser{Name: name, Role: GUIRoleAdmin}, true
+	}
+
+	user := GUIUser{Name: name}
+	matched, allFolders := false, false
+	for _, gr := range c.GroupRoles {
+		if !slices.ContainsFunc(groups, func(group string) bool { return strings.EqualFold(group, gr.Group) }) {
+			continue
+		}
+		matched = true
+		user.Role = max(user.Role, gr.Role)
+		if len(gr.Folders) == 0 {
+			allFolders = true
+		}
+		for _, folder := range gr.Folders {
+			if !slices.Contains(user.Folders, folder) {
+				user.Folders = append(user.Folders, folder)
+			}
+		}
+	}
+	if allFolders {
+		user.Folders = nil
+	}
+	return user, matched
 }
 
 func (c LDAPConfiguration) Copy() LDAPConfiguration {
+	if c.GroupRoles != nil {
+		groupRoles := make([]LDAPGroupRole, len(c.GroupRoles))
+		for i, gr := range c.GroupRoles {
+			gr.Folders = slices.Clone(gr.Folders)
+			groupRoles[i] = gr
+		}
+		c.GroupRoles = groupRoles
+	}
 	return c
 }
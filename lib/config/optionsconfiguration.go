@@ -17,54 +17,57 @@ import (
 )
 
 type OptionsConfiguration struct {
-	RawListenAddresses          []string `json:"listenAddresses" xml:"listenAddress" default:"default"`
-	RawGlobalAnnServers         []string `json:"globalAnnounceServers" xml:"globalAnnounceServer" default:"default"`
-	GlobalAnnEnabled            bool     `json:"globalAnnounceEnabled" xml:"globalAnnounceEnabled" default:"true"`
-	LocalAnnEnabled             bool     `json:"localAnnounceEnabled" xml:"localAnnounceEnabled" default:"true"`
-	LocalAnnPort                int      `json:"localAnnouncePort" xml:"localAnnouncePort" default:"21027"`
-	LocalAnnMCAddr              string   `json:"localAnnounceMCAddr" xml:"localAnnounceMCAddr" default:"[ff12::8384]:21027"`
-	MaxSendKbps                 int      `json:"maxSendKbps" xml:"maxSendKbps"`
-	MaxRecvKbps                 int      `json:"maxRecvKbps" xml:"maxRecvKbps"`
-	ReconnectIntervalS          int      `json:"reconnectionIntervalS" xml:"reconnectionIntervalS" default:"60"`
-	RelaysEnabled               bool     `json:"relaysEnabled" xml:"relaysEnabled" default:"true"`
-	RelayReconnectIntervalM     int      `json:"relayReconnectIntervalM" xml:"relayReconnectIntervalM" default:"10"`
-	StartBrowser                bool     `json:"startBrowser" xml:"startBrowser" default:"true"`
-	NATEnabled                  bool     `json:"natEnabled" xml:"natEnabled" default:"true"`
-	NATLeaseM                   int      `json:"natLeaseMinutes" xml:"natLeaseMinutes" default:"60"`
-	NATRenewalM                 int      `json:"natRenewalMinutes" xml:"natRenewalMinutes" default:"30"`
-	NATTimeoutS                 int      `json:"natTimeoutSeconds" xml:"natTimeoutSeconds" default:"10"`
-	URAccepted                  int      `json:"urAccepted" xml:"urAccepted"`
-	URSeen                      int      `json:"urSeen" xml:"urSeen"`
-	URUniqueID                  string   `json:"urUniqueId" xml:"urUniqueID"`
-	URURL                       string   `json:"urURL" xml:"urURL" default:"https://data.syncthing.net/newdata"`
-	URPostInsecurely            bool     `json:"urPostInsecurely" xml:"urPostInsecurely" default:"false"`
-	URInitialDelayS             int      `json:"urInitialDelayS" xml:"urInitialDelayS" default:"1800"`
-	AutoUpgradeIntervalH        int      `json:"autoUpgradeIntervalH" xml:"autoUpgradeIntervalH" default:"12"`
-	UpgradeToPreReleases        bool     `json:"upgradeToPreReleases" xml:"upgradeToPreReleases"`
-	KeepTemporariesH            int      `json:"keepTemporariesH" xml:"keepTemporariesH" default:"24"`
-	CacheIgnoredFiles           bool     `json:"cacheIgnoredFiles" xml:"cacheIgnoredFiles" default:"false"`
-	ProgressUpdateIntervalS     int      `json:"progressUpdateIntervalS" xml:"progressUpdateIntervalS" default:"5"`
-	LimitBandwidthInLan         bool     `json:"limitBandwidthInLan" xml:"limitBandwidthInLan" default:"false"`
-	MinHomeDiskFree             Size     `json:"minHomeDiskFree" xml:"minHomeDiskFree" default:"1 %"`
-	ReleasesURL                 string   `json:"releasesURL" xml:"releasesURL" default:"https://upgrades.syncthing.net/meta.json"`
-	AlwaysLocalNets             []string `json:"alwaysLocalNets" xml:"alwaysLocalNet"`
-	OverwriteRemoteDevNames     bool     `json:"overwriteRemoteDeviceNamesOnConnect" xml:"overwriteRemoteDeviceNamesOnConnect" default:"false"`
-	TempIndexMinBlocks          int      `json:"tempIndexMinBlocks" xml:"tempIndexMinBlocks" default:"10"`
-	UnackedNotificationIDs      []string `json:"unackedNotificationIDs" xml:"unackedNotificationID"`
-	TrafficClass                int      `json:"trafficClass" xml:"trafficClass"`
-	DeprecatedDefaultFolderPath string   `json:"-" xml:"defaultFolderPath,omitempty"` // Deprecated: Do not use.
-	SetLowPriority              bool     `json:"setLowPriority" xml:"setLowPriority" default:"true"`
-	RawMaxFolderConcurrency     int      `json:"maxFolderConcurrency" xml:"maxFolderConcurrency"`
-	CRURL                       string   `json:"crURL" xml:"crashReportingURL" default:"https://crash.syncthing.net/newcrash"`
-	CREnabled                   bool     `json:"crashReportingEnabled" xml:"crashReportingEnabled" default:"true"`
-	StunKeepaliveStartS         int      `json:"stunKeepaliveStartS" xml:"stunKeepaliveStartS" default:"180"`
-	StunKeepaliveMinS           int      `json:"stunKeepaliveMinS" xml:"stunKeepaliveMinS" default:"20"`
-	RawStunServers              []string `json:"stunServers" xml:"stunServer" default:"default"`
-	DatabaseTuning              Tuning   `json:"databaseTuning" xml:"databaseTuning" restart:"true"`
-	RawMaxCIRequestKiB          int      `json:"maxConcurrentIncomingRequestKiB" xml:"maxConcurrentIncomingRequestKiB"`
-	AnnounceLANAddresses        bool     `json:"announceLANAddresses" xml:"announceLANAddresses" default:"true"`
-	SendFullIndexOnUpgrade      bool     `json:"sendFullIndexOnUpgrade" xml:"sendFullIndexOnUpgrade"`
-	FeatureFlags                []string `json:"featureFlags" xml:"featureFlag"`
+	RawListenAddresses          []string                 `json:"listenAddresses" xml:"listenAddress" default:"default"`
+	RawGlobalAnnServers         []string                 `json:"globalAnnounceServers" xml:"globalAnnounceServer" default:"default"`
+	GlobalAnnEnabled            bool                     `json:"globalAnnounceEnabled" xml:"globalAnnounceEnabled" default:"true"`
+	LocalAnnEnabled             bool                     `json:"localAnnounceEnabled" xml:"localAnnounceEnabled" default:"true"`
+	LocalAnnPort                int                      `json:"localAnnouncePort" xml:"localAnnouncePort" default:"21027"`
+	LocalAnnMCAddr              string                   `json:"localAnnounceMCAddr" xml:"localAnnounceMCAddr" default:"[ff12::8384]:21027"`
+	MaxSendKbps                 int                      `json:"maxSendKbps" xml:"maxSendKbps"`
+	MaxRecvKbps                 int                      `json:"maxRecvKbps" xml:"maxRecvKbps"`
+	BandwidthSchedule           []BandwidthScheduleEntry `json:"bandwidthSchedule" xml:"bandwidthSchedule"`
+	ReconnectIntervalS          int                      `json:"reconnectionIntervalS" xml:"reconnectionIntervalS" default:"60"`
+	RelaysEnabled               bool                     `json:"relaysEnabled" xml:"relaysEnabled" default:"true"`
+	RelayReconnectIntervalM     int                      `json:"relayReconnectIntervalM" xml:"relayReconnectIntervalM" default:"10"`
+	HolePunchingEnabled         bool                     `json:"holePunchingEnabled" xml:"holePunchingEnabled" default:"true"`
+	StartBrowser                bool                     `json:"startBrowser" xml:"startBrowser" default:"true"`
+	NATEnabled                  bool                     `json:"natEnabled" xml:"natEnabled" default:"true"`
+	NATLeaseM                   int                      `json:"natLeaseMinutes" xml:"natLeaseMinutes" default:"60"`
+	NATRenewalM                 int                      `json:"natRenewalMinutes" xml:"natRenewalMinutes" default:"30"`
+	NATTimeoutS                 int                      `json:"natTimeoutSeconds" xml:"natTimeoutSeconds" default:"10"`
+	URAccepted                  int                      `json:"urAccepted" xml:"urAccepted"`
+	URSeen                      int                      `json:"urSeen" xml:"urSeen"`
+	URUniqueID                  string                   `json:"urUniqueId" xml:"urUniqueID"`
+	URURL                       string                   `json:"urURL" xml:"urURL" default:"https://data.syncthing.net/newdata"`
+	URPostInsecurely            bool                     `json:"urPostInsecurely" xml:"urPostInsecurely" default:"false"`
+	URInitialDelayS             int                      `json:"urInitialDelayS" xml:"urInitialDelayS" default:"1800"`
+	AutoUpgradeIntervalH        int                      `json:"autoUpgradeIntervalH" xml:"autoUpgradeIntervalH" default:"12"`
+	UpgradeToPreReleases        bool                     `json:"upgradeToPreReleases" xml:"upgradeToPreReleases"`
+	KeepTemporariesH            int                      `json:"keepTemporariesH" xml:"keepTemporariesH" default:"24"`
+	CacheIgnoredFiles           bool                     `json:"cacheIgnoredFiles" xml:"cacheIgnoredFiles" default:"false"`
+	ProgressUpdateIntervalS     int                      `json:"progressUpdateIntervalS" xml:"progressUpdateIntervalS" default:"5"`
+	LimitBandwidthInLan         bool                     `json:"limitBandwidthInLan" xml:"limitBandwidthInLan" default:"false"`
+	MinHomeDiskFree             Size                     `json:"minHomeDiskFree" xml:"minHomeDiskFree" default:"1 %"`
+	ReleasesURL                 string                   `json:"releasesURL" xml:"releasesURL" default:"https://upgrades.syncthing.net/meta.json"`
+	AlwaysLocalNets             []string                 `json:"alwaysLocalNets" xml:"alwaysLocalNet"`
+	OverwriteRemoteDevNames     bool                     `json:"overwriteRemoteDeviceNamesOnConnect" xml:"overwriteRemoteDeviceNamesOnConnect" default:"false"`
+	TempIndexMinBlocks          int                      `json:"tempIndexMinBlocks" xml:"tempIndexMinBlocks" default:"10"`
+	UnackedNotificationIDs      []string                 `json:"unackedNotificationIDs" xml:"unackedNotificationID"`
+	TrafficClass                int                      `json:"trafficClass" xml:"trafficClass"`
+	DeprecatedDefaultFolderPath string                   `json:"-" xml:"defaultFolderPath,omitempty"` // Deprecated: Do not use.
+	SetLowPriority              bool                     `json:"setLowPriority" xml:"setLowPriority" default:"true"`
+	RawMaxFolderConcurrency     int                      `json:"maxFolderConcurrency" xml:"maxFolderConcurrency"`
+	CRURL                       string                   `json:"crURL" xml:"crashReportingURL" default:"https://crash.syncthing.net/newcrash"`
+	CREnabled                   bool                     `json:"crashReportingEnabled" xml:"crashReportingEnabled" default:"true"`
+	StunKeepaliveStartS         int                      `json:"stunKeepaliveStartS" xml:"stunKeepaliveStartS" default:"180"`
+	StunKeepaliveMinS           int                      `json:"stunKeepaliveMinS" xml:"stunKeepaliveMinS" default:"20"`
+	RawStunServers              []string                 `json:"stunServers" xml:"stunServer" default:"default"`
+	DatabaseTuning              Tuning                   `json:"databaseTuning" xml:"databaseTuning" restart:"true"`
+	DatabaseBackend             DatabaseBackend          `json:"databaseBackend" xml:"databaseBackend" restart:"true"`
+	RawMaxCIRequestKiB          int                      `json:"maxConcurrentIncomingRequestKiB" xml:"maxConcurrentIncomingRequestKiB"`
+	AnnounceLANAddresses        bool                     `json:"announceLANAddresses" xml:"announceLANAddresses" default:"true"`
+	SendFullIndexOnUpgrade      bool                     `json:"sendFullIndexOnUpgrade" xml:"sendFullIndexOnUpgrade"`
+	FeatureFlags                []string                 `json:"featureFlags" xml:"featureFlag"`
 	// The number of connections at which we stop trying to connect to more
 	// devices, zero meaning no limit. Does not affect incoming connections.
 	ConnectionLimitEnough int `json:"connectionLimitEnough" xml:"connectionLimitEnough"`
@@ -79,6 +82,8 @@ type OptionsConfiguration struct {
 	ConnectionPriorityQUICLAN          int  `json:"connectionPriorityQuicLan" xml:"connectionPriorityQuicLan" default:"20"`
 	ConnectionPriorityTCPWAN           int  `json:"connectionPriorityTcpWan" xml:"connectionPriorityTcpWan" default:"30"`
 	ConnectionPriorityQUICWAN          int  `json:"connectionPriorityQuicWan" xml:"connectionPriorityQuicWan" default:"40"`
+	ConnectionPriorityWSSLAN           int  `json:"connectionPriorityWssLan" xml:"connectionPriorityWssLan" default:"25"`
+	ConnectionPriorityWSSWAN           int  `json:"connectionPriorityWssWan" xml:"connectionPriorityWssWan" default:"45"`
 	ConnectionPriorityRelay            int  `json:"connectionPriorityRelay" xml:"connectionPriorityRelay" default:"50"`
 	ConnectionPriorityUpgradeThreshold int  `json:"connectionPriorityUpgradeThreshold" xml:"connectionPriorityUpgradeThreshold" default:"0"`
 	// Legacy deprecated
@@ -101,6 +106,8 @@ func (opts OptionsConfiguration) Copy() OptionsConfiguration {
 	copy(optsCopy.AlwaysLocalNets, opts.AlwaysLocalNets)
 	optsCopy.UnackedNotificationIDs = make([]string, len(opts.UnackedNotificationIDs))
 	copy(optsCopy.UnackedNotificationIDs, opts.UnackedNotificationIDs)
+	optsCopy.BandwidthSchedule = make([]BandwidthScheduleEntry, len(opts.BandwidthSchedule))
+	copy(optsCopy.BandwidthSchedule, opts.BandwidthSchedule)
 	return optsCopy
 }
 
@@ -124,6 +131,8 @@ func (opts *OptionsConfiguration) prepare(guiPWIsSet bool) {
 		}
 	}
 
+	opts.BandwidthSchedule = validBandwidthSchedule(opts.BandwidthSchedule, "all devices")
+
 	// Negative limits are meaningless, zero means unlimited.
 	if opts.ConnectionLimitEnough < 0 {
 		opts.ConnectionLimitEnough = 0
@@ -140,6 +149,10 @@ func (opts *OptionsConfiguration) prepare(guiPWIsSet bool) {
 		l.Warnln("Connection priority number for TCP over WAN must be worse (higher) than TCP over LAN. Correcting.")
 		opts.ConnectionPriorityTCPWAN = opts.ConnectionPriorityTCPLAN + 1
 	}
+	if opts.ConnectionPriorityWSSWAN <= opts.ConnectionPriorityWSSLAN {
+		l.Warnln("Connection priority number for WebSocket over WAN must be worse (higher) than WebSocket over LAN. Correcting.")
+		opts.ConnectionPriorityWSSWAN = opts.ConnectionPriorityWSSLAN + 1
+	}
 
 	// If usage reporting is enabled we must have a unique ID.
 	if opts.URAccepted > 0 && opts.URUniqueID == "" {
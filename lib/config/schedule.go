@@ -0,0 +1,100 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// timeWindow is a recurring period of time on some days of the week, with
+// start and end in minutes since midnight.
+type timeWindow struct {
+	days       [7]bool
+	start, end int
+}
+
+// parseTimeWindow parses a comma separated list of days and day ranges
+// ("mon-fri,sun", empty meaning every day) and local times of day
+// ("09:00", empty meaning midnight). An end before the start means the
+// period extends past midnight, into the following day, and equal times
+// cover the whole day.
+func parseTimeWindow(days, start, end string) (timeWindow, error) {
+	var w timeWindow
+	if strings.TrimSpace(days) == "" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	} else {
+		for _, part := range strings.Split(strings.ToLower(days), ",") {
+			from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+			fromDay, ok := weekdayNames[strings.TrimSpace(from)]
+			if !ok {
+				return w, fmt.Errorf("unknown day %q", from)
+			}
+			toDay := fromDay
+			if isRange {
+				if toDay, ok = weekdayNames[strings.TrimSpace(to)]; !ok {
+					return w, fmt.Errorf("unknown day %q", to)
+				}
+			}
+			for d := fromDay; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == toDay {
+					break
+				}
+			}
+		}
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(start); err != nil {
+		return w, err
+	}
+	if w.end, err = parseTimeOfDay(end); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+// parseTimeOfDay returns the number of minutes since midnight for a time
+// of day in HH:MM format, where the empty string means midnight.
+func parseTimeOfDay(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains returns true if the given time falls within the window.
+func (w timeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := w.days[t.Weekday()]
+	yesterday := w.days[(t.Weekday()+6)%7]
+	switch {
+	case w.start < w.end:
+		return today && minute >= w.start && minute < w.end
+	case w.start > w.end:
+		return today && minute >= w.start || yesterday && minute < w.end
+	default:
+		return today
+	}
+}
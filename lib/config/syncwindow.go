@@ -0,0 +1,36 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"time"
+)
+
+// A SyncWindow is a recurring period during which a folder scans and
+// pulls. Days, Start and End have the same format as for a
+// BandwidthScheduleEntry.
+type SyncWindow struct {
+	Days  string `json:"days" xml:"days,attr"`
+	Start string `json:"start" xml:"start,attr"`
+	End   string `json:"end" xml:"end,attr"`
+}
+
+// Validate returns an error if the window can't be parsed.
+func (w SyncWindow) Validate() error {
+	_, err := parseTimeWindow(w.Days, w.Start, w.End)
+	return err
+}
+
+// ActiveAt returns true if the given time is within the window. Invalid
+// windows are never active.
+func (w SyncWindow) ActiveAt(t time.Time) bool {
+	tw, err := parseTimeWindow(w.Days, w.Start, w.End)
+	if err != nil {
+		return false
+	}
+	return tw.contains(t)
+}
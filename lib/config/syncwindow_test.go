@@ -0,0 +1,42 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInSyncWindow(t *testing.T) {
+	// 2024-01-01 is a Monday
+	monday := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	sunday := time.Date(2024, 1, 7, 23, 30, 0, 0, time.Local)
+
+	var fcfg FolderConfiguration
+	if !fcfg.InSyncWindow(monday) {
+		t.Error("folder without sync windows should always be in window")
+	}
+
+	fcfg.SyncWindows = []SyncWindow{
+		{Start: "23:00", End: "05:00"},
+		{Days: "sat-sun"},
+	}
+	if fcfg.InSyncWindow(monday) {
+		t.Error("should not be in window on monday at noon")
+	}
+	if !fcfg.InSyncWindow(monday.Add(-8 * time.Hour)) {
+		t.Error("should be in nightly window")
+	}
+	if !fcfg.InSyncWindow(sunday) {
+		t.Error("should be in weekend window")
+	}
+
+	fcfg.SyncWindows = []SyncWindow{{Days: "never"}}
+	if fcfg.InSyncWindow(sunday) {
+		t.Error("invalid window should never be active")
+	}
+}
@@ -24,3 +24,12 @@ func TestTuningMatches(t *testing.T) {
 		t.Error("mismatch for TuningLarge")
 	}
 }
+
+func TestDatabaseBackendMatches(t *testing.T) {
+	if int(config.DatabaseBackendLevelDB) != int(backend.KindLevelDB) {
+		t.Error("mismatch for DatabaseBackendLevelDB")
+	}
+	if int(config.DatabaseBackendSQLite) != int(backend.KindSQLite) {
+		t.Error("mismatch for DatabaseBackendSQLite")
+	}
+}
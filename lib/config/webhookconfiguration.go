@@ -0,0 +1,83 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A WebhookConfiguration describes a URL that events are POSTed to as they
+// happen. Events is a list of event type names, empty meaning the same
+// events as the default for the REST API. If Folders or Devices are given,
+// only events concerning one of those folders or devices are sent. If
+// Secret is set, requests are signed with an HMAC of the body.
+type WebhookConfiguration struct {
+	URL     string              `json:"url" xml:"url,attr"`
+	Events  []string            `json:"events" xml:"event"`
+	Folders []string            `json:"folders" xml:"folder"`
+	Devices []protocol.DeviceID `json:"devices" xml:"device"`
+	Secret  string              `json:"secret" xml:"secret,omitempty"`
+}
+
+func (c WebhookConfiguration) Copy() WebhookConfiguration {
+	cp := c
+	cp.Events = append([]string(nil), c.Events...)
+	cp.Folders = append([]string(nil), c.Folders...)
+	cp.Devices = append([]protocol.DeviceID(nil), c.Devices...)
+	return cp
+}
+
+// Validate returns an error if the URL isn't a valid HTTP(S) URL, or if any
+// of the event types are unknown.
+func (c WebhookConfiguration) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.New("not an http(s) URL")
+	}
+	for _, ev := range c.Events {
+		if events.UnmarshalEventType(ev) == 0 {
+			return fmt.Errorf("unknown event type %q", ev)
+		}
+	}
+	return nil
+}
+
+// EventMask returns the mask of the configured event types, or the given
+// default if there are none.
+func (c WebhookConfiguration) EventMask(def events.EventType) events.EventType {
+	if len(c.Events) == 0 {
+		return def
+	}
+	var mask events.EventType
+	for _, ev := range c.Events {
+		mask |= events.UnmarshalEventType(ev)
+	}
+	return mask
+}
+
+func (cfg *Configuration) prepareWebhooks() {
+	if len(cfg.Webhooks) == 0 {
+		return
+	}
+	valid := cfg.Webhooks[:0]
+	for _, hook := range cfg.Webhooks {
+		if err := hook.Validate(); err != nil {
+			l.Warnf("Removing invalid webhook %q: %v", hook.URL, err)
+			continue
+		}
+		valid = append(valid, hook)
+	}
+	cfg.Webhooks = valid
+}
@@ -15,8 +15,11 @@ import (
 	"io"
 	"math/rand"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -156,6 +159,7 @@ func TestGetDialer(t *testing.T) {
 	}{
 		{mustParseURI("tcp://1.2.3.4:5678"), true, false, false},   // ok
 		{mustParseURI("tcp4://1.2.3.4:5678"), true, false, false},  // ok
+		{mustParseURI("wss://1.2.3.4:5678"), true, false, false},   // ok
 		{mustParseURI("kcp://1.2.3.4:5678"), false, false, true},   // deprecated
 		{mustParseURI("relay://1.2.3.4:5678"), false, true, false}, // disabled
 		{mustParseURI("http://1.2.3.4:5678"), false, false, false}, // generally bad
@@ -301,6 +305,7 @@ func BenchmarkConnections(b *testing.B) {
 	addrs := []string{
 		"tcp://127.0.0.1:0",
 		"quic://127.0.0.1:0",
+		"wss://127.0.0.1:0",
 		"relay://127.0.0.1:22067",
 	}
 	sizes := []int{
@@ -374,6 +379,7 @@ func TestConnectionEstablishment(t *testing.T) {
 	addrs := []string{
 		"tcp://127.0.0.1:0",
 		"quic://127.0.0.1:0",
+		"wss://127.0.0.1:0",
 	}
 
 	send := make([]byte, 128<<10)
@@ -404,6 +410,74 @@ func TestConnectionEstablishment(t *testing.T) {
 	}
 }
 
+func TestWSSThroughProxy(t *testing.T) {
+	var connects atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		connects.Add(1)
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func(*http.Request) (*url.URL, error)) { wssProxyFunc = f }(wssProxyFunc)
+	wssProxyFunc = func(*http.Request) (*url.URL, error) { return proxyURL, nil }
+
+	withConnectionPair(t, "wss://127.0.0.1:0", func(client, server internalConn) {
+		if client.IsLocal() {
+			t.Error("connection through a proxy should not be local")
+		}
+	})
+	if connects.Load() == 0 {
+		t.Error("connection didn't go through the proxy")
+	}
+}
+
+func TestWSSProxied(t *testing.T) {
+	cases := []struct {
+		header, value string
+		proxied       bool
+	}{
+		{"", "", false},
+		{"User-Agent", "syncthing", false},
+		{"X-Forwarded-For", "192.0.2.42", true},
+		{"Forwarded", "for=192.0.2.42", true},
+		{"X-Real-IP", "192.0.2.42", true},
+		{"Via", "1.1 proxy", true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		if proxied := wssProxied(req); proxied != tc.proxied {
+			t.Errorf("%s: %q: got %v, expected %v", tc.header, tc.value, proxied, tc.proxied)
+		}
+	}
+}
+
 func withConnectionPair(b interface{ Fatal(...interface{}) }, connUri string, h func(client, server internalConn)) {
 	// Root of the service tree.
 	supervisor := suture.New("main", suture.Spec{
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/time/rate"
 
@@ -29,6 +30,9 @@ type limiter struct {
 	limitsLAN           atomic.Bool
 	deviceReadLimiters  map[protocol.DeviceID]*rate.Limiter
 	deviceWriteLimiters map[protocol.DeviceID]*rate.Limiter
+	cfg                 config.Configuration // as last committed
+	applied             scheduledConfig      // limits currently in effect
+	timeNow             func() time.Time
 }
 
 type waiter interface {
@@ -49,15 +53,111 @@ func newLimiter(myId protocol.DeviceID, cfg config.Wrapper) *limiter {
 		mu:                  sync.NewMutex(),
 		deviceReadLimiters:  make(map[protocol.DeviceID]*rate.Limiter),
 		deviceWriteLimiters: make(map[protocol.DeviceID]*rate.Limiter),
+		timeNow:             time.Now,
 	}
 
 	cfg.Subscribe(l)
-	prev := config.Configuration{Options: config.OptionsConfiguration{MaxRecvKbps: -1, MaxSendKbps: -1}}
+	l.applied.cfg = config.Configuration{Options: config.OptionsConfiguration{MaxRecvKbps: -1, MaxSendKbps: -1}}
 
-	l.CommitConfiguration(prev, cfg.RawCopy())
+	raw := cfg.RawCopy()
+	l.CommitConfiguration(raw, raw)
 	return l
 }
 
+// scheduledConfig is a configuration with the rate limits replaced by
+// those of the bandwidth schedule entries in effect, if any.
+type scheduledConfig struct {
+	cfg            config.Configuration
+	profile        string
+	deviceProfiles map[protocol.DeviceID]string
+}
+
+func newScheduledConfig(cfg config.Configuration, now time.Time) scheduledConfig {
+	sc := scheduledConfig{
+		cfg:            cfg,
+		deviceProfiles: make(map[protocol.DeviceID]string),
+	}
+	if entry, ok := config.ActiveBandwidthScheduleEntry(cfg.Options.BandwidthSchedule, now); ok {
+		sc.cfg.Options.MaxSendKbps = entry.MaxSendKbps
+		sc.cfg.Options.MaxRecvKbps = entry.MaxRecvKbps
+		sc.profile = entry.Name
+	}
+	sc.cfg.Devices = make([]config.DeviceConfiguration, len(cfg.Devices))
+	for i, dev := range cfg.Devices {
+		if entry, ok := config.ActiveBandwidthScheduleEntry(dev.BandwidthSchedule, now); ok {
+			dev.MaxSendKbps = entry.MaxSendKbps
+			dev.MaxRecvKbps = entry.MaxRecvKbps
+			sc.deviceProfiles[dev.DeviceID] = entry.Name
+		}
+		sc.cfg.Devices[i] = dev
+	}
+	return sc
+}
+
+// serve re-evaluates the bandwidth schedules at the start of every minute,
+// which is their resolution.
+func (lim *limiter) serve(ctx context.Context) error {
+	for {
+		now := lim.timeNow()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-timer.C:
+			lim.reevaluate()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reevaluate applies the limits of the bandwidth schedules currently in
+// effect.
+func (lim *limiter) reevaluate() {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	lim.applyLocked(newScheduledConfig(lim.cfg, lim.timeNow()))
+}
+
+// BandwidthLimitStatus describes the rate limits in effect, in KiB/s
+// where zero means unlimited, and the name of the bandwidth schedule entry
+// they come from. The profile is empty when the static limits apply.
+type BandwidthLimitStatus struct {
+	Profile     string `json:"profile"`
+	MaxSendKbps int    `json:"maxSendKbps"`
+	MaxRecvKbps int    `json:"maxRecvKbps"`
+}
+
+// BandwidthLimits describes the rate limits in effect overall and per
+// device, keyed by device ID.
+type BandwidthLimits struct {
+	Total   BandwidthLimitStatus            `json:"total"`
+	Devices map[string]BandwidthLimitStatus `json:"devices"`
+}
+
+func (lim *limiter) status() BandwidthLimits {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	res := BandwidthLimits{
+		Total: BandwidthLimitStatus{
+			Profile:     lim.applied.profile,
+			MaxSendKbps: max(lim.applied.cfg.Options.MaxSendKbps, 0),
+			MaxRecvKbps: max(lim.applied.cfg.Options.MaxRecvKbps, 0),
+		},
+		Devices: make(map[string]BandwidthLimitStatus, len(lim.applied.cfg.Devices)),
+	}
+	for _, dev := range lim.applied.cfg.Devices {
+		if dev.DeviceID == lim.myID {
+			continue
+		}
+		res.Devices[dev.DeviceID.String()] = BandwidthLimitStatus{
+			Profile:     lim.applied.deviceProfiles[dev.DeviceID],
+			MaxSendKbps: max(dev.MaxSendKbps, 0),
+			MaxRecvKbps: max(dev.MaxRecvKbps, 0),
+		}
+	}
+	return res
+}
+
 // This function sets limiters according to corresponding DeviceConfiguration
 func (lim *limiter) setLimitsLocked(device config.DeviceConfiguration) bool {
 	readLimiter := lim.getReadLimiterLocked(device.DeviceID)
@@ -122,18 +222,36 @@ func (lim *limiter) processDevicesConfigurationLocked(from, to config.Configurat
 	}
 }
 
-func (lim *limiter) CommitConfiguration(from, to config.Configuration) bool {
+func (lim *limiter) CommitConfiguration(_, to config.Configuration) bool {
 	// to ensure atomic update of configuration
 	lim.mu.Lock()
 	defer lim.mu.Unlock()
 
+	lim.cfg = to
+	lim.applyLocked(newScheduledConfig(to, lim.timeNow()))
+	return true
+}
+
+// applyLocked sets the limiters according to the given configuration,
+// compared to the one previously applied.
+func (lim *limiter) applyLocked(sc scheduledConfig) {
+	from, to := lim.applied.cfg, sc.cfg
+	if sc.profile != lim.applied.profile {
+		if sc.profile != "" {
+			l.Infof("Bandwidth schedule %q is now in effect", sc.profile)
+		} else if lim.applied.profile != "" {
+			l.Infof("Bandwidth schedule %q is no longer in effect", lim.applied.profile)
+		}
+	}
+	lim.applied = sc
+
 	// Delete, add or update limiters for devices
 	lim.processDevicesConfigurationLocked(from, to)
 
 	if from.Options.MaxRecvKbps == to.Options.MaxRecvKbps &&
 		from.Options.MaxSendKbps == to.Options.MaxSendKbps &&
 		from.Options.LimitBandwidthInLan == to.Options.LimitBandwidthInLan {
-		return true
+		return
 	}
 
 	limited := false
@@ -169,8 +287,6 @@ func (lim *limiter) CommitConfiguration(from, to config.Configuration) bool {
 			l.Infoln("Rate limits do not apply to LAN connections")
 		}
 	}
-
-	return true
 }
 
 func (*limiter) String() string {
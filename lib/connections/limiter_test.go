@@ -14,6 +14,7 @@ import (
 	"math/rand"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/time/rate"
 
@@ -217,6 +218,65 @@ func TestAddAndRemove(t *testing.T) {
 	checkActualAndExpected(t, actualR, actualW, expectedR, expectedW)
 }
 
+func TestBandwidthSchedule(t *testing.T) {
+	wrapper, wrapperCancel := initConfig()
+	defer wrapperCancel()
+
+	// 2024-01-01 is a Monday
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local)
+	lim := newLimiter(device1, wrapper)
+	lim.timeNow = func() time.Time { return now }
+
+	dev3Conf.BandwidthSchedule = []config.BandwidthScheduleEntry{
+		{Name: "night", Start: "22:00", End: "06:00"},
+		{Name: "office", Days: "mon-fri", Start: "09:00", End: "17:00", MaxSendKbps: 10, MaxRecvKbps: 20},
+	}
+	waiter, _ := wrapper.Modify(func(cfg *config.Configuration) {
+		cfg.Options.BandwidthSchedule = []config.BandwidthScheduleEntry{
+			{Name: "office", Days: "mon-fri", Start: "09:00", End: "17:00", MaxSendKbps: 100},
+		}
+		cfg.SetDevice(dev3Conf)
+	})
+	waiter.Wait()
+
+	if lim.write.Limit() != rate.Inf || lim.read.Limit() != rate.Inf {
+		t.Error("expected no overall limits outside of schedule")
+	}
+	if lim.deviceWriteLimiters[device3].Limit() != rate.Inf {
+		t.Error("expected no device limits outside of schedule")
+	}
+
+	now = now.Add(time.Hour)
+	lim.reevaluate()
+
+	if lim.write.Limit() != 100*1024 || lim.read.Limit() != rate.Inf {
+		t.Errorf("unexpected overall limits during schedule, send %v, recv %v", lim.write.Limit(), lim.read.Limit())
+	}
+	if lim.deviceWriteLimiters[device3].Limit() != 10*1024 || lim.deviceReadLimiters[device3].Limit() != 20*1024 {
+		t.Error("unexpected device limits during schedule")
+	}
+	status := lim.status()
+	if status.Total.Profile != "office" || status.Total.MaxSendKbps != 100 {
+		t.Errorf("unexpected overall status: %+v", status.Total)
+	}
+	if dev := status.Devices[device3.String()]; dev.Profile != "office" || dev.MaxRecvKbps != 20 {
+		t.Errorf("unexpected device status: %+v", dev)
+	}
+	if dev := status.Devices[device2.String()]; dev.Profile != "" || dev.MaxSendKbps != dev2Conf.MaxSendKbps {
+		t.Errorf("unexpected unscheduled device status: %+v", dev)
+	}
+
+	now = now.Add(14 * time.Hour)
+	lim.reevaluate()
+
+	if lim.write.Limit() != rate.Inf {
+		t.Error("expected no overall limits after schedule")
+	}
+	if status := lim.status(); status.Devices[device3.String()].Profile != "night" {
+		t.Errorf("unexpected device status: %+v", status.Devices[device3.String()])
+	}
+}
+
 func TestLimitedWriterWrite(t *testing.T) {
 	// Check that the limited writer writes the correct data in the correct manner.
 
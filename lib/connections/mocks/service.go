@@ -19,6 +19,16 @@ type Service struct {
 	allAddressesReturnsOnCall map[int]struct {
 		result1 []string
 	}
+	BandwidthLimitsStub        func() connections.BandwidthLimits
+	bandwidthLimitsMutex       sync.RWMutex
+	bandwidthLimitsArgsForCall []struct {
+	}
+	bandwidthLimitsReturns struct {
+		result1 connections.BandwidthLimits
+	}
+	bandwidthLimitsReturnsOnCall map[int]struct {
+		result1 connections.BandwidthLimits
+	}
 	ConnectionStatusStub        func() map[string]connections.ConnectionStatusEntry
 	connectionStatusMutex       sync.RWMutex
 	connectionStatusArgsForCall []struct {
@@ -127,6 +137,59 @@ func (fake *Service) AllAddressesReturnsOnCall(i int, result1 []string) {
 	}{result1}
 }
 
+func (fake *Service) BandwidthLimits() connections.BandwidthLimits {
+	fake.bandwidthLimitsMutex.Lock()
+	ret, specificReturn := fake.bandwidthLimitsReturnsOnCall[len(fake.bandwidthLimitsArgsForCall)]
+	fake.bandwidthLimitsArgsForCall = append(fake.bandwidthLimitsArgsForCall, struct {
+	}{})
+	stub := fake.BandwidthLimitsStub
+	fakeReturns := fake.bandwidthLimitsReturns
+	fake.recordInvocation("BandwidthLimits", []interface{}{})
+	fake.bandwidthLimitsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Service) BandwidthLimitsCallCount() int {
+	fake.bandwidthLimitsMutex.RLock()
+	defer fake.bandwidthLimitsMutex.RUnlock()
+	return len(fake.bandwidthLimitsArgsForCall)
+}
+
+func (fake *Service) BandwidthLimitsCalls(stub func() connections.BandwidthLimits) {
+	fake.bandwidthLimitsMutex.Lock()
+	defer fake.bandwidthLimitsMutex.Unlock()
+	fake.BandwidthLimitsStub = stub
+}
+
+func (fake *Service) BandwidthLimitsReturns(result1 connections.BandwidthLimits) {
+	fake.bandwidthLimitsMutex.Lock()
+	defer fake.bandwidthLimitsMutex.Unlock()
+	fake.BandwidthLimitsStub = nil
+	fake.bandwidthLimitsReturns = struct {
+		result1 connections.BandwidthLimits
+	}{result1}
+}
+
+func (fake *Service) BandwidthLimitsReturnsOnCall(i int, result1 connections.BandwidthLimits) {
+	fake.bandwidthLimitsMutex.Lock()
+	defer fake.bandwidthLimitsMutex.Unlock()
+	fake.BandwidthLimitsStub = nil
+	if fake.bandwidthLimitsReturnsOnCall == nil {
+		fake.bandwidthLimitsReturnsOnCall = make(map[int]struct {
+			result1 connections.BandwidthLimits
+		})
+	}
+	fake.bandwidthLimitsReturnsOnCall[i] = struct {
+		result1 connections.BandwidthLimits
+	}{result1}
+}
+
 func (fake *Service) ConnectionStatus() map[string]connections.ConnectionStatusEntry {
 	fake.connectionStatusMutex.Lock()
 	ret, specificReturn := fake.connectionStatusReturnsOnCall[len(fake.connectionStatusArgsForCall)]
@@ -405,6 +468,8 @@ func (fake *Service) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.allAddressesMutex.RLock()
 	defer fake.allAddressesMutex.RUnlock()
+	fake.bandwidthLimitsMutex.RLock()
+	defer fake.bandwidthLimitsMutex.RUnlock()
 	fake.connectionStatusMutex.RLock()
 	defer fake.connectionStatusMutex.RUnlock()
 	fake.externalAddressesMutex.RLock()
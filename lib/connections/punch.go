@@ -0,0 +1,292 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections/registry"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/stringutil"
+)
+
+// TCP hole punching lets two devices behind NAT connect directly, by having
+// both dial each other at the same time from their TCP listening port
+// (a "simultaneous open"), so that each NAT sees outgoing traffic before
+// the incoming. The devices agree on addresses and timing over a
+// rendezvous session through a relay the other device is reachable at:
+// the punch dialer asks the relay for a session as the relay dialer does,
+// but negotiates punchProtocolName instead of BEP, and the relay listener
+// on the other side answers it. Punching is done over IPv4 only, as that's
+// where the NATs are.
+
+const (
+	punchProtocolName = "syncthing-punch/1.0"
+	punchScheme       = "punch"
+
+	// How long to keep dialing the other side.
+	punchDuration = 10 * time.Second
+	// How long each dial attempt may take, and how long to wait between
+	// them.
+	punchDialTimeout = time.Second
+	punchInterval    = 250 * time.Millisecond
+	// How long the exchange over the rendezvous session may take.
+	punchRendezvousTimeout = 10 * time.Second
+)
+
+var errNoPunchListener = errors.New("no TCP listener to punch from")
+
+// punchMessage is sent as JSON over the rendezvous session.
+type punchMessage struct {
+	Addresses []string `json:"addresses,omitempty"`
+	Start     bool     `json:"start,omitempty"`
+}
+
+// A punchAddressSource knows addresses at which we might be reachable from
+// the outside on the given TCP port. Listeners register as such in the
+// registry, under punchScheme.
+type punchAddressSource interface {
+	punchAddresses(port int) []string
+}
+
+// punchURIs returns the rendezvous addresses for hole punching through the
+// relays among the given addresses.
+func punchURIs(addrs []string) []string {
+	var uris []string
+	for _, addr := range addrs {
+		if rest, ok := strings.CutPrefix(addr, "relay://"); ok {
+			uris = append(uris, punchScheme+"://"+rest)
+		}
+	}
+	return uris
+}
+
+// punchCandidates returns the local address to punch from, being that of
+// our TCP listener, and the addresses we might be reachable at on it.
+func punchCandidates(reg *registry.Registry) (*net.TCPAddr, []string, error) {
+	if !dialer.SupportsReusePort {
+		return nil, nil, fmt.Errorf("%w: port reuse not supported", errUnsupported)
+	}
+	laddr, ok := reg.Get("tcp4", func(addr interface{}) bool {
+		return addr.(*net.TCPAddr).IP.IsUnspecified()
+	}).(*net.TCPAddr)
+	if !ok {
+		return nil, nil, errNoPunchListener
+	}
+
+	var addrs []string
+	for _, item := range reg.All(punchScheme) {
+		if src, ok := item.(punchAddressSource); ok {
+			addrs = append(addrs, src.punchAddresses(laddr.Port)...)
+		}
+	}
+	return laddr, validPunchAddresses(addrs), nil
+}
+
+// validPunchAddresses returns the addresses that are worth dialing: IPv4
+// addresses with a port, that aren't ours by definition.
+func validPunchAddresses(addrs []string) []string {
+	valid := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host).To4()
+		if ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+			continue
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 {
+			continue
+		}
+		valid = append(valid, addr)
+	}
+	return stringutil.UniqueTrimmedStrings(valid)
+}
+
+// punchRendezvous tells the other side of the rendezvous session our
+// addresses, and returns theirs once it's time to start punching. The
+// initiator tells the other side to start, and waits half the round trip
+// time it measured before starting itself, so that both start at about the
+// same time.
+func punchRendezvous(conn net.Conn, initiator bool, ours []string) ([]string, error) {
+	_ = conn.SetDeadline(time.Now().Add(punchRendezvousTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	var theirs punchMessage
+	if initiator {
+		t0 := time.Now()
+		if err := enc.Encode(punchMessage{Addresses: ours}); err != nil {
+			return nil, err
+		}
+		if err := dec.Decode(&theirs); err != nil {
+			return nil, err
+		}
+		rtt := time.Since(t0)
+		if err := enc.Encode(punchMessage{Start: true}); err != nil {
+			return nil, err
+		}
+		time.Sleep(rtt / 2)
+	} else {
+		if err := dec.Decode(&theirs); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(punchMessage{Addresses: ours}); err != nil {
+			return nil, err
+		}
+		var start punchMessage
+		if err := dec.Decode(&start); err != nil {
+			return nil, err
+		}
+		if !start.Start {
+			return nil, errors.New("rendezvous: expected start")
+		}
+	}
+
+	addrs := validPunchAddresses(theirs.Addresses)
+	if len(addrs) == 0 {
+		return nil, errors.New("rendezvous: no addresses to punch")
+	}
+	return addrs, nil
+}
+
+// punch dials the addresses from the local address until one of them
+// connects, or the time is up.
+func punch(ctx context.Context, laddr *net.TCPAddr, addrs []string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, punchDuration)
+	defer cancel()
+
+	res := make(chan net.Conn)
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			d := net.Dialer{
+				Control:   dialer.ReusePortControl,
+				LocalAddr: laddr,
+				Timeout:   punchDialTimeout,
+			}
+			for ctx.Err() == nil {
+				conn, err := d.DialContext(ctx, "tcp4", addr)
+				if err == nil {
+					select {
+					case res <- conn:
+					case <-ctx.Done():
+						conn.Close()
+					}
+					return
+				}
+				l.Debugln("Punch (BEP/tcp): dialing", addr, "from", laddr, err)
+				select {
+				case <-time.After(punchInterval):
+				case <-ctx.Done():
+				}
+			}
+		}(addr)
+	}
+	go func() {
+		wg.Wait()
+		close(res)
+	}()
+
+	conn, ok := <-res
+	if !ok {
+		return nil, fmt.Errorf("punching %v: no connection within %v", addrs, punchDuration)
+	}
+	return conn, nil
+}
+
+// rendezvousPeer returns the device on the other side of the rendezvous
+// session.
+func rendezvousPeer(tc *tls.Conn) (protocol.DeviceID, error) {
+	cs := tc.ConnectionState()
+	if cs.NegotiatedProtocol != punchProtocolName {
+		return protocol.EmptyDeviceID, fmt.Errorf("rendezvous: negotiated %q instead of %s", cs.NegotiatedProtocol, punchProtocolName)
+	}
+	if len(cs.PeerCertificates) != 1 {
+		return protocol.EmptyDeviceID, errors.New("rendezvous: expected one peer certificate")
+	}
+	return protocol.NewDeviceID(cs.PeerCertificates[0].Raw), nil
+}
+
+// answerPunch takes the responding side of a rendezvous session set up by
+// a punch dialer, and hands the resulting connection over as a listener
+// would.
+func answerPunch(ctx context.Context, rendezvous *tls.Conn, cfg config.Wrapper, tlsCfg *tls.Config, reg *registry.Registry, lanChecker *lanChecker, conns chan<- internalConn) {
+	defer rendezvous.Close()
+
+	opts := cfg.Options()
+	if !opts.HolePunchingEnabled {
+		l.Debugln("Punch (BEP/tcp): ignoring rendezvous as hole punching is disabled")
+		return
+	}
+	remoteID, err := rendezvousPeer(rendezvous)
+	if err != nil {
+		l.Debugln("Punch (BEP/tcp):", err)
+		return
+	}
+	// Our addresses are only for known devices to see.
+	if dev, ok := cfg.Device(remoteID); !ok || dev.Paused {
+		l.Debugf("Punch (BEP/tcp): ignoring rendezvous from unknown or paused device %s", remoteID)
+		return
+	}
+
+	laddr, ours, err := punchCandidates(reg)
+	if err != nil {
+		l.Debugln("Punch (BEP/tcp):", err)
+		return
+	}
+	theirs, err := punchRendezvous(rendezvous, false, ours)
+	if err != nil {
+		l.Debugf("Punch (BEP/tcp): rendezvous with %s: %v", remoteID, err)
+		return
+	}
+	conn, err := punch(ctx, laddr, theirs)
+	if err != nil {
+		l.Debugf("Punch (BEP/tcp): %s: %v", remoteID, err)
+		return
+	}
+
+	if err := dialer.SetTCPOptions(conn); err != nil {
+		l.Debugln("Punch (BEP/tcp): setting tcp options:", err)
+	}
+	if err := dialer.SetTrafficClass(conn, opts.TrafficClass); err != nil {
+		l.Debugln("Punch (BEP/tcp): setting traffic class:", err)
+	}
+
+	tc := tls.Server(conn, tlsCfg)
+	if err := tlsTimedHandshake(tc); err != nil {
+		l.Infoln("Punch (BEP/tcp): TLS handshake:", err)
+		tc.Close()
+		return
+	}
+
+	priority := opts.ConnectionPriorityTCPWAN
+	isLocal := lanChecker.isLAN(conn.RemoteAddr())
+	if isLocal {
+		priority = opts.ConnectionPriorityTCPLAN
+	}
+	select {
+	case conns <- newInternalConn(tc, connTypeTCPServer, isLocal, priority):
+	case <-ctx.Done():
+		tc.Close()
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections/registry"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/relay/client"
+)
+
+func init() {
+	dialers[punchScheme] = punchDialerFactory{}
+}
+
+// punchDialer establishes a direct TCP connection by hole punching, using
+// the relay in the URI for the rendezvous.
+type punchDialer struct {
+	commonDialer
+	registry *registry.Registry
+}
+
+func (d *punchDialer) Dial(ctx context.Context, id protocol.DeviceID, uri *url.URL) (internalConn, error) {
+	laddr, ours, err := punchCandidates(d.registry)
+	if err != nil {
+		return internalConn{}, err
+	}
+
+	relayURI := *uri
+	relayURI.Scheme = "relay"
+	inv, err := client.GetInvitationFromRelay(ctx, &relayURI, id, d.tlsCfg.Certificates, 10*time.Second)
+	if err != nil {
+		return internalConn{}, err
+	}
+	rconn, err := client.JoinSession(ctx, inv)
+	if err != nil {
+		return internalConn{}, err
+	}
+
+	rendezvousCfg := d.tlsCfg.Clone()
+	rendezvousCfg.NextProtos = []string{punchProtocolName}
+	var rendezvous *tls.Conn
+	if inv.ServerSocket {
+		rendezvous = tls.Server(rconn, rendezvousCfg)
+	} else {
+		rendezvous = tls.Client(rconn, rendezvousCfg)
+	}
+	defer rendezvous.Close()
+	if err := tlsTimedHandshake(rendezvous); err != nil {
+		return internalConn{}, err
+	}
+	if remoteID, err := rendezvousPeer(rendezvous); err != nil {
+		return internalConn{}, err
+	} else if remoteID != id {
+		return internalConn{}, fmt.Errorf("rendezvous: unexpected device %s", remoteID)
+	}
+
+	theirs, err := punchRendezvous(rendezvous, true, ours)
+	if err != nil {
+		return internalConn{}, err
+	}
+	conn, err := punch(ctx, laddr, theirs)
+	if err != nil {
+		return internalConn{}, err
+	}
+
+	err = dialer.SetTCPOptions(conn)
+	if err != nil {
+		l.Debugln("Dial (BEP/punch): setting tcp options:", err)
+	}
+
+	err = dialer.SetTrafficClass(conn, d.trafficClass)
+	if err != nil {
+		l.Debugln("Dial (BEP/punch): setting traffic class:", err)
+	}
+
+	tc := tls.Client(conn, d.tlsCfg)
+	err = tlsTimedHandshake(tc)
+	if err != nil {
+		tc.Close()
+		return internalConn{}, err
+	}
+
+	priority := d.wanPriority
+	isLocal := d.lanChecker.isLAN(conn.RemoteAddr())
+	if isLocal {
+		priority = d.lanPriority
+	}
+
+	return newInternalConn(tc, connTypeTCPClient, isLocal, priority), nil
+}
+
+type punchDialerFactory struct{}
+
+func (punchDialerFactory) New(opts config.OptionsConfiguration, tlsCfg *tls.Config, registry *registry.Registry, lanChecker *lanChecker) genericDialer {
+	return &punchDialer{
+		commonDialer: commonDialer{
+			trafficClass:      opts.TrafficClass,
+			reconnectInterval: time.Duration(opts.RelayReconnectIntervalM) * time.Minute,
+			tlsCfg:            tlsCfg,
+			lanChecker:        lanChecker,
+			lanPriority:       opts.ConnectionPriorityTCPLAN,
+			wanPriority:       opts.ConnectionPriorityTCPWAN,
+			allowsMultiConns:  true,
+		},
+		registry: registry,
+	}
+}
+
+func (punchDialerFactory) AlwaysWAN() bool {
+	return false
+}
+
+func (punchDialerFactory) Valid(cfg config.Configuration) error {
+	if !cfg.Options.RelaysEnabled || !cfg.Options.HolePunchingEnabled {
+		return errDisabled
+	}
+	return nil
+}
+
+func (punchDialerFactory) String() string {
+	return "TCP Hole Punching Dialer"
+}
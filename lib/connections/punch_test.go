@@ -0,0 +1,138 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/connections/registry"
+)
+
+func TestPunchURIs(t *testing.T) {
+	addrs := []string{
+		"tcp://192.0.2.42:22000",
+		"relay://192.0.2.43:22067/?id=ABC&pingInterval=1m0s",
+		"quic://192.0.2.42:22000",
+	}
+	uris := punchURIs(addrs)
+	expected := []string{"punch://192.0.2.43:22067/?id=ABC&pingInterval=1m0s"}
+	if !slices.Equal(uris, expected) {
+		t.Errorf("got %v, expected %v", uris, expected)
+	}
+}
+
+func TestValidPunchAddresses(t *testing.T) {
+	addrs := []string{
+		"192.0.2.42:22000",
+		"192.0.2.42:22000",
+		"0.0.0.0:22000",
+		"127.0.0.1:22000",
+		"192.0.2.42:0",
+		"[2001:db8::1]:22000",
+		"banana",
+		"198.51.100.1:22001",
+	}
+	valid := validPunchAddresses(addrs)
+	expected := []string{"192.0.2.42:22000", "198.51.100.1:22001"}
+	if !slices.Equal(valid, expected) {
+		t.Errorf("got %v, expected %v", valid, expected)
+	}
+}
+
+type fakePunchSource []string
+
+func (s fakePunchSource) punchAddresses(_ int) []string {
+	return s
+}
+
+func TestPunchRendezvous(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	type result struct {
+		addrs []string
+		err   error
+	}
+	res := make(chan result, 1)
+	go func() {
+		addrs, err := punchRendezvous(b, false, []string{"198.51.100.2:22000"})
+		res <- result{addrs, err}
+	}()
+
+	theirs, err := punchRendezvous(a, true, []string{"198.51.100.1:22000", "127.0.0.1:22000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(theirs, []string{"198.51.100.2:22000"}) {
+		t.Errorf("initiator got %v", theirs)
+	}
+	r := <-res
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if !slices.Equal(r.addrs, []string{"198.51.100.1:22000"}) {
+		t.Errorf("responder got %v", r.addrs)
+	}
+}
+
+func TestPunchCandidates(t *testing.T) {
+	reg := registry.New()
+	if _, _, err := punchCandidates(reg); err == nil {
+		t.Fatal("expected an error without a TCP listener")
+	}
+
+	laddr := &net.TCPAddr{IP: net.IPv4zero, Port: 22000}
+	reg.Register("tcp", laddr)
+	reg.Register(punchScheme, fakePunchSource{"192.0.2.42:22000", "0.0.0.0:22000"})
+	reg.Register(punchScheme, fakePunchSource{"198.51.100.1:22000"})
+
+	got, addrs, err := punchCandidates(reg)
+	if errors.Is(err, errUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != laddr {
+		t.Errorf("got local address %v, expected %v", got, laddr)
+	}
+	slices.Sort(addrs)
+	if expected := []string{"192.0.2.42:22000", "198.51.100.1:22000"}; !slices.Equal(addrs, expected) {
+		t.Errorf("got %v, expected %v", addrs, expected)
+	}
+}
+
+func TestPunch(t *testing.T) {
+	// One side listening stands in for the other side punching.
+	lst, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lst.Close()
+	go func() {
+		if conn, err := lst.Accept(); err == nil {
+			_, _ = conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+
+	laddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	conn, err := punch(context.Background(), laddr, []string{"127.0.0.1:1", lst.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	buf := make([]byte, 5)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "hello" {
+		t.Errorf("got %q, %v", buf, err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"errors"
 	"net"
 	"net/url"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -112,6 +113,8 @@ func (t *quicListener) serve(ctx context.Context) error {
 
 	t.registry.Register(t.uri.Scheme, quicTransport)
 	defer t.registry.Unregister(t.uri.Scheme, quicTransport)
+	t.registry.Register(punchScheme, t)
+	defer t.registry.Unregister(punchScheme, t)
 
 	listener, err := quicTransport.Listen(t.tlsCfg, quicConfig)
 	if err != nil {
@@ -210,6 +213,21 @@ func (t *quicListener) LANAddresses() []*url.URL {
 	return addrs
 }
 
+// punchAddresses returns the external address found by STUN, with the
+// given port, as NATs commonly keep the port when they can.
+func (t *quicListener) punchAddresses(port int) []string {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.address == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(t.address.Host)
+	if err != nil {
+		return nil
+	}
+	return []string{net.JoinHostPort(host, strconv.Itoa(port))}
+}
+
 func (t *quicListener) String() string {
 	return t.uri.String()
 }
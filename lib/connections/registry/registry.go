@@ -80,3 +80,17 @@ func (r *Registry) Get(scheme string, preferred func(interface{}) bool) interfac
 	}
 	return best
 }
+
+// All returns the items for all schemas compatible with the given scheme.
+func (r *Registry) All(scheme string) []interface{} {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	var all []interface{}
+	for availableScheme, items := range r.available {
+		if strings.HasPrefix(scheme, availableScheme) {
+			all = append(all, items...)
+		}
+	}
+	return all
+}
@@ -79,6 +79,23 @@ func TestShortSchemeFirst(t *testing.T) {
 	}
 }
 
+func TestAll(t *testing.T) {
+	r := New()
+	r.Register("int", 1)
+	r.Register("int4", 4)
+	r.Register("int6", 6)
+
+	if res := r.All("int4"); len(res) != 2 {
+		t.Error("unexpected", res)
+	}
+	if res := r.All("int"); len(res) != 1 || res[0] != 1 {
+		t.Error("unexpected", res)
+	}
+	if res := r.All("float"); len(res) != 0 {
+		t.Error("unexpected", res)
+	}
+}
+
 func BenchmarkGet(b *testing.B) {
 	r := New()
 	for _, addr := range []string{"192.168.1.1", "172.1.1.1", "10.1.1.1"} {
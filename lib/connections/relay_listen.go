@@ -11,6 +11,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"net/url"
+	"slices"
 	"sync"
 	"time"
 
@@ -33,11 +34,13 @@ type relayListener struct {
 	svcutil.ServiceWithError
 	onAddressesChangedNotifier
 
-	uri     *url.URL
-	cfg     config.Wrapper
-	tlsCfg  *tls.Config
-	conns   chan internalConn
-	factory listenerFactory
+	uri        *url.URL
+	cfg        config.Wrapper
+	tlsCfg     *tls.Config
+	conns      chan internalConn
+	factory    listenerFactory
+	registry   *registry.Registry
+	lanChecker *lanChecker
 
 	client client.RelayClient
 	mut    sync.RWMutex
@@ -68,6 +71,10 @@ func (t *relayListener) serve(ctx context.Context) error {
 func (t *relayListener) handleInvitations(ctx context.Context, clnt client.RelayClient) {
 	invitations := clnt.Invitations()
 
+	// Sessions are either BEP or a rendezvous for hole punching.
+	tlsCfg := t.tlsCfg.Clone()
+	tlsCfg.NextProtos = append(slices.Clone(t.tlsCfg.NextProtos), punchProtocolName)
+
 	// Start with nil, so that we send a addresses changed notification as soon as we connect somewhere.
 	var oldURI *url.URL
 
@@ -94,9 +101,9 @@ func (t *relayListener) handleInvitations(ctx context.Context, clnt client.Relay
 
 			var tc *tls.Conn
 			if inv.ServerSocket {
-				tc = tls.Server(conn, t.tlsCfg)
+				tc = tls.Server(conn, tlsCfg)
 			} else {
-				tc = tls.Client(conn, t.tlsCfg)
+				tc = tls.Client(conn, tlsCfg)
 			}
 
 			err = tlsTimedHandshake(tc)
@@ -106,6 +113,11 @@ func (t *relayListener) handleInvitations(ctx context.Context, clnt client.Relay
 				continue
 			}
 
+			if tc.ConnectionState().NegotiatedProtocol == punchProtocolName {
+				go answerPunch(ctx, tc, t.cfg, t.tlsCfg, t.registry, t.lanChecker, t.conns)
+				continue
+			}
+
 			t.conns <- newInternalConn(tc, connTypeRelayServer, false, t.cfg.Options().ConnectionPriorityRelay)
 
 		// Poor mans notifier that informs the connection service that the
@@ -177,13 +189,15 @@ func (*relayListener) NATType() string {
 
 type relayListenerFactory struct{}
 
-func (f *relayListenerFactory) New(uri *url.URL, cfg config.Wrapper, tlsCfg *tls.Config, conns chan internalConn, _ *nat.Service, _ *registry.Registry, _ *lanChecker) genericListener {
+func (f *relayListenerFactory) New(uri *url.URL, cfg config.Wrapper, tlsCfg *tls.Config, conns chan internalConn, _ *nat.Service, registry *registry.Registry, lanChecker *lanChecker) genericListener {
 	t := &relayListener{
-		uri:     uri,
-		cfg:     cfg,
-		tlsCfg:  tlsCfg,
-		conns:   conns,
-		factory: f,
+		uri:        uri,
+		cfg:        cfg,
+		tlsCfg:     tlsCfg,
+		conns:      conns,
+		factory:    f,
+		registry:   registry,
+		lanChecker: lanChecker,
 	}
 	t.ServiceWithError = svcutil.AsService(t.serve, t.String())
 	return t
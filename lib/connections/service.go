@@ -133,6 +133,7 @@ type Service interface {
 	discover.AddressLister
 	ListenerStatus() map[string]ListenerStatusEntry
 	ConnectionStatus() map[string]ConnectionStatusEntry
+	BandwidthLimits() BandwidthLimits
 	NATType() string
 }
 
@@ -180,6 +181,10 @@ type service struct {
 	dialNowDevices    map[protocol.DeviceID]struct{}
 	dialNowDevicesMut sync.Mutex
 
+	directAddrsMut       sync.Mutex
+	directAddrs          map[protocol.DeviceID][]string // announced by devices connected through relays
+	announcedDirectAddrs []string                       // last announced by us
+
 	listenersMut   sync.RWMutex
 	listeners      map[string]genericListener
 	listenerTokens map[string]suture.ServiceToken
@@ -211,6 +216,9 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 		dialNow:           make(chan struct{}, 1),
 		dialNowDevices:    make(map[protocol.DeviceID]struct{}),
 
+		directAddrsMut: sync.NewMutex(),
+		directAddrs:    make(map[protocol.DeviceID][]string),
+
 		listenersMut:   sync.NewRWMutex(),
 		listeners:      make(map[string]genericListener),
 		listenerTokens: make(map[string]suture.ServiceToken),
@@ -232,6 +240,8 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 	service.Add(svcutil.AsService(service.connect, fmt.Sprintf("%s/connect", service)))
 	service.Add(svcutil.AsService(service.handleConns, fmt.Sprintf("%s/handleConns", service)))
 	service.Add(svcutil.AsService(service.handleHellos, fmt.Sprintf("%s/handleHellos", service)))
+	service.Add(svcutil.AsService(service.handleDirectAddresses, fmt.Sprintf("%s/handleDirectAddresses", service)))
+	service.Add(svcutil.AsService(service.limiter.serve, fmt.Sprintf("%s/limiter", service)))
 	service.Add(service.natService)
 
 	svcutil.OnSupervisorDone(service.Supervisor, func() {
@@ -445,11 +455,15 @@ func (s *service) handleHellos(ctx context.Context) error {
 		// connections are limited.
 		rd, wr := s.limiter.getLimiters(remoteID, c, c.IsLocal())
 
-		protoConn := protocol.NewConnection(remoteID, rd, wr, c, s.model, c, deviceCfg.Compression.ToProtocol(), s.cfg.FolderPasswords(remoteID), s.keyGen)
+		protoConn := protocol.NewConnection(remoteID, rd, wr, c, connectionModel{s.model, s}, c, deviceCfg.Compression.ToProtocol(), s.cfg.FolderPasswords(remoteID), s.keyGen)
+		wasRelayedOnly := s.isRelayedOnly(remoteID)
 		s.accountAddedConnection(protoConn, hello, s.cfg.Options().ConnectionPriorityUpgradeThreshold)
 		go func() {
 			<-protoConn.Closed()
 			s.accountRemovedConnection(protoConn)
+			if s.numConnectionsForDevice(remoteID) == 0 {
+				s.forgetDirectAddresses(remoteID)
+			}
 			s.dialNowDevicesMut.Lock()
 			s.dialNowDevices[remoteID] = struct{}{}
 			s.scheduleDialNow()
@@ -459,6 +473,20 @@ func (s *service) handleHellos(ctx context.Context) error {
 		l.Infof("Established secure connection to %s at %s", remoteID.Short(), c)
 
 		s.model.AddConnection(protoConn, hello)
+
+		if wasRelayedOnly && !isRelayed(c) {
+			l.Infof("Upgraded relayed connection to %s to %s at %s", remoteID.Short(), c.Type(), c.RemoteAddr())
+			s.evLogger.Log(events.ConnectionUpgraded, map[string]interface{}{
+				"device":   remoteID.String(),
+				"type":     c.Type(),
+				"address":  c.RemoteAddr().String(),
+				"priority": c.Priority(),
+			})
+		} else if isRelayed(c) && s.isRelayedOnly(remoteID) {
+			if addrs := directURIs(s.ExternalAddresses()); len(addrs) > 0 {
+				s.sendDirectAddresses(protoConn, addrs)
+			}
+		}
 		continue
 	}
 }
@@ -658,6 +686,11 @@ func (s *service) resolveDialTargets(ctx context.Context, now time.Time, cfg con
 	deviceID := deviceCfg.DeviceID
 
 	addrs := s.resolveDeviceAddrs(ctx, deviceCfg)
+	if cfg.Options.RelaysEnabled && cfg.Options.HolePunchingEnabled && s.numConnectionsForDevice(deviceID) > 0 {
+		// The relays the device is reachable at can serve as rendezvous
+		// for upgrading the connection by hole punching.
+		addrs = append(addrs, punchURIs(addrs)...)
+	}
 	l.Debugln("Resolved device", deviceID.Short(), "addresses:", addrs)
 
 	dialTargets := make([]dialTarget, 0, len(addrs))
@@ -739,6 +772,9 @@ func (s *service) resolveDeviceAddrs(ctx context.Context, cfg config.DeviceConfi
 					addrs = append(addrs, t...)
 				}
 			}
+			// Addresses the device told us about over a relayed
+			// connection, which are as dynamic as they get.
+			addrs = append(addrs, s.receivedDirectAddresses(cfg.DeviceID)...)
 		} else {
 			addrs = append(addrs, addr)
 		}
@@ -1001,6 +1037,10 @@ func (s *service) ListenerStatus() map[string]ListenerStatusEntry {
 	return result
 }
 
+func (s *service) BandwidthLimits() BandwidthLimits {
+	return s.limiter.status()
+}
+
 type connectionStatusHandler struct {
 	connectionStatusMut sync.RWMutex
 	connectionStatus    map[string]ConnectionStatusEntry // address -> latest error/status
@@ -1449,6 +1489,40 @@ func (c *deviceConnectionTracker) worstConnectionPriority(d protocol.DeviceID) i
 	return worstPriority
 }
 
+// isRelayedOnly returns whether we're connected to the device, but only
+// through relays.
+func (c *deviceConnectionTracker) isRelayedOnly(d protocol.DeviceID) bool {
+	c.connectionsMut.Lock()
+	defer c.connectionsMut.Unlock()
+	return c.isRelayedOnlyLocked(d)
+}
+
+func (c *deviceConnectionTracker) isRelayedOnlyLocked(d protocol.DeviceID) bool {
+	if len(c.connections[d]) == 0 {
+		return false
+	}
+	for _, conn := range c.connections[d] {
+		if !isRelayed(conn) {
+			return false
+		}
+	}
+	return true
+}
+
+// relayedOnlyConnections returns one connection to each device we're
+// connected to only through relays.
+func (c *deviceConnectionTracker) relayedOnlyConnections() []protocol.Connection {
+	c.connectionsMut.Lock()
+	defer c.connectionsMut.Unlock()
+	var conns []protocol.Connection
+	for d, dconns := range c.connections {
+		if c.isRelayedOnlyLocked(d) {
+			conns = append(conns, dconns[0])
+		}
+	}
+	return conns
+}
+
 // closeWorsePriorityConnectionsLocked closes all connections to the given
 // device that are worse than the cutoff priority. Must be called with the
 // lock held.
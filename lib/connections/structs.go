@@ -54,6 +54,8 @@ const (
 	connTypeTCPServer
 	connTypeQUICClient
 	connTypeQUICServer
+	connTypeWSSClient
+	connTypeWSSServer
 )
 
 func (t connType) String() string {
@@ -70,6 +72,10 @@ func (t connType) String() string {
 		return "quic-client"
 	case connTypeQUICServer:
 		return "quic-server"
+	case connTypeWSSClient:
+		return "wss-client"
+	case connTypeWSSServer:
+		return "wss-server"
 	default:
 		return "unknown-type"
 	}
@@ -83,6 +89,8 @@ func (t connType) Transport() string {
 		return "tcp"
 	case connTypeQUICClient, connTypeQUICServer:
 		return "quic"
+	case connTypeWSSClient, connTypeWSSServer:
+		return "wss"
 	default:
 		return "unknown"
 	}
@@ -221,8 +229,15 @@ type genericListener interface {
 	NATType() string
 }
 
+// Model is what receives the messages of the connections, except for the
+// direct addresses devices announce, which the service handles itself.
 type Model interface {
-	protocol.Model
+	Index(conn protocol.Connection, idx *protocol.Index) error
+	IndexUpdate(conn protocol.Connection, idxUp *protocol.IndexUpdate) error
+	Request(conn protocol.Connection, req *protocol.Request) (protocol.RequestResponse, error)
+	ClusterConfig(conn protocol.Connection, config *protocol.ClusterConfig) error
+	Closed(conn protocol.Connection, err error)
+	DownloadProgress(conn protocol.Connection, p *protocol.DownloadProgress) error
 	AddConnection(conn protocol.Connection, hello protocol.Hello)
 	OnHello(protocol.DeviceID, net.Addr, protocol.Hello) error
 	DeviceStatistics() (map[protocol.DeviceID]stats.DeviceStatistics, error)
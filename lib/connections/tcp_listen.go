@@ -73,6 +73,8 @@ func (t *tcpListener) serve(ctx context.Context) error {
 
 	t.registry.Register(t.uri.Scheme, tcaddr)
 	defer t.registry.Unregister(t.uri.Scheme, tcaddr)
+	t.registry.Register(punchScheme, t)
+	defer t.registry.Unregister(punchScheme, t)
 
 	l.Infof("TCP listener (%v) starting", tcaddr)
 	defer l.Infof("TCP listener (%v) shutting down", tcaddr)
@@ -214,6 +216,16 @@ func (t *tcpListener) LANAddresses() []*url.URL {
 	return addrs
 }
 
+// punchAddresses returns our addresses from the NAT mapping and the local
+// network adapters.
+func (t *tcpListener) punchAddresses(_ int) []string {
+	var addrs []string
+	for _, uri := range append(t.WANAddresses(), t.LANAddresses()...) {
+		addrs = append(addrs, uri.Host)
+	}
+	return addrs
+}
+
 func (t *tcpListener) String() string {
 	return t.uri.String()
 }
@@ -0,0 +1,179 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/stringutil"
+)
+
+// Devices connected to each other only through relays tell each other the
+// addresses at which they might be reachable directly, as learned by the
+// listeners from NAT mappings and STUN: once when the relayed connection is
+// established, and again whenever those addresses change. Both sides then
+// dial right away instead of waiting for the dial loop to come around, so
+// that a direct connection can replace the relayed one as soon as possible.
+
+const (
+	// The most addresses we send or accept in one message.
+	maxDirectAddresses = 32
+	// How long to wait for listener addresses to settle before announcing
+	// them.
+	directAddressesSettleTime = 2 * time.Second
+	// How long sending the addresses to a device may take.
+	directAddressesSendTimeout = time.Minute
+)
+
+// directURIs returns the addresses among the given ones that can be dialed
+// directly by someone who doesn't otherwise know where we are: those for a
+// dialer other than the relay ones, with a specified IP and port.
+func directURIs(addrs []string) []string {
+	direct := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		uri, err := url.Parse(addr)
+		if err != nil {
+			continue
+		}
+		if _, ok := dialers[uri.Scheme]; !ok || uri.Scheme == "relay" || uri.Scheme == punchScheme {
+			continue
+		}
+		host, port, err := net.SplitHostPort(uri.Host)
+		if err != nil || port == "" || port == "0" {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+			continue
+		}
+		direct = append(direct, addr)
+	}
+	direct = stringutil.UniqueTrimmedStrings(direct)
+	slices.Sort(direct)
+	if len(direct) > maxDirectAddresses {
+		direct = direct[:maxDirectAddresses]
+	}
+	return direct
+}
+
+func isRelayed(c protocol.ConnectionInfo) bool {
+	return strings.HasPrefix(c.Transport(), "relay")
+}
+
+// connectionModel is the model as the connections see it, except that the
+// direct addresses devices announce go to the service instead.
+type connectionModel struct {
+	Model
+	service *service
+}
+
+func (m connectionModel) DirectAddresses(conn protocol.Connection, da *protocol.DirectAddresses) error {
+	m.service.directAddressesReceived(conn.DeviceID(), da.Addresses)
+	return nil
+}
+
+// handleDirectAddresses announces our direct addresses to devices we're
+// connected to only through relays when they change.
+func (s *service) handleDirectAddresses(ctx context.Context) error {
+	sub := s.evLogger.Subscribe(events.ListenAddressesChanged)
+	defer sub.Unsubscribe()
+
+	timer := time.NewTimer(directAddressesSettleTime)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-sub.C():
+			if !ok {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			// Listeners tend to change their addresses several times in a
+			// row, so wait for them to settle.
+			timer.Reset(directAddressesSettleTime)
+		case <-timer.C:
+			s.announceDirectAddresses()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// announceDirectAddresses sends our direct addresses to all devices we're
+// connected to only through relays, if they changed since the last time.
+func (s *service) announceDirectAddresses() {
+	addrs := directURIs(s.ExternalAddresses())
+
+	s.directAddrsMut.Lock()
+	changed := !slices.Equal(addrs, s.announcedDirectAddrs)
+	s.announcedDirectAddrs = addrs
+	s.directAddrsMut.Unlock()
+	if !changed || len(addrs) == 0 {
+		return
+	}
+
+	for _, conn := range s.relayedOnlyConnections() {
+		s.sendDirectAddresses(conn, addrs)
+	}
+}
+
+// sendDirectAddresses sends our direct addresses over the given connection,
+// and dials the device right away, as it's about to do the same.
+func (s *service) sendDirectAddresses(conn protocol.Connection, addrs []string) {
+	l.Debugf("Sending direct addresses %v to %s over %s", addrs, conn.DeviceID().Short(), conn)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), directAddressesSendTimeout)
+		defer cancel()
+		conn.DirectAddresses(ctx, &protocol.DirectAddresses{Addresses: addrs})
+	}()
+	s.dialNowDevicesMut.Lock()
+	s.dialNowDevices[conn.DeviceID()] = struct{}{}
+	s.scheduleDialNow()
+	s.dialNowDevicesMut.Unlock()
+}
+
+// directAddressesReceived remembers the addresses a device connected only
+// through relays announced, and dials it right away.
+func (s *service) directAddressesReceived(device protocol.DeviceID, addrs []string) {
+	addrs = directURIs(addrs)
+	if len(addrs) == 0 || !s.isRelayedOnly(device) {
+		l.Debugf("Ignoring direct addresses %v from %s", addrs, device.Short())
+		return
+	}
+	l.Debugf("Received direct addresses %v from %s", addrs, device.Short())
+
+	s.directAddrsMut.Lock()
+	s.directAddrs[device] = addrs
+	s.directAddrsMut.Unlock()
+
+	s.dialNowDevicesMut.Lock()
+	s.dialNowDevices[device] = struct{}{}
+	s.scheduleDialNow()
+	s.dialNowDevicesMut.Unlock()
+}
+
+// receivedDirectAddresses returns the addresses the device announced while
+// connected through relays.
+func (s *service) receivedDirectAddresses(device protocol.DeviceID) []string {
+	s.directAddrsMut.Lock()
+	defer s.directAddrsMut.Unlock()
+	return s.directAddrs[device]
+}
+
+func (s *service) forgetDirectAddresses(device protocol.DeviceID) {
+	s.directAddrsMut.Lock()
+	delete(s.directAddrs, device)
+	s.directAddrsMut.Unlock()
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	protocolmocks "github.com/syncthing/syncthing/lib/protocol/mocks"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestDirectURIs(t *testing.T) {
+	addrs := []string{
+		"tcp://192.0.2.42:22000",
+		"tcp://0.0.0.0:22000",
+		"tcp://192.0.2.42:0",
+		"tcp://127.0.0.1:22000",
+		"tcp://example.com:22000",
+		"relay://192.0.2.43:22067/?id=ABC",
+		"punch://192.0.2.43:22067/?id=ABC",
+		"banana://192.0.2.42:22000",
+		"quic://[2001:db8::1]:22000",
+		"tcp://192.0.2.42:22000",
+	}
+	direct := directURIs(addrs)
+	expected := []string{"quic://[2001:db8::1]:22000", "tcp://192.0.2.42:22000"}
+	if !slices.Equal(direct, expected) {
+		t.Errorf("got %v, expected %v", direct, expected)
+	}
+}
+
+func newFakeConnection(id protocol.DeviceID, transport string, priority int) *protocolmocks.Connection {
+	conn := &protocolmocks.Connection{}
+	conn.DeviceIDReturns(id)
+	conn.TransportReturns(transport)
+	conn.PriorityReturns(priority)
+	return conn
+}
+
+func TestDirectAddressesReceived(t *testing.T) {
+	s := &service{
+		dialNow:           make(chan struct{}, 1),
+		dialNowDevices:    make(map[protocol.DeviceID]struct{}),
+		dialNowDevicesMut: sync.NewMutex(),
+		directAddrsMut:    sync.NewMutex(),
+		directAddrs:       make(map[protocol.DeviceID][]string),
+	}
+	device := protocol.NewDeviceID([]byte{1})
+	addrs := []string{"tcp://192.0.2.42:22000", "relay://192.0.2.43:22067"}
+
+	// Not connected at all, so nothing to upgrade.
+	s.directAddressesReceived(device, addrs)
+	if addrs := s.receivedDirectAddresses(device); len(addrs) != 0 {
+		t.Errorf("got addresses %v for unconnected device", addrs)
+	}
+
+	s.accountAddedConnection(newFakeConnection(device, "relay4", 200), protocol.Hello{}, 0)
+	if !s.isRelayedOnly(device) {
+		t.Fatal("expected device to be connected through relays only")
+	}
+	if conns := s.relayedOnlyConnections(); len(conns) != 1 {
+		t.Errorf("got %d relayed connections, expected 1", len(conns))
+	}
+
+	conn := newFakeConnection(device, "relay4", 200)
+	if err := (connectionModel{service: s}).DirectAddresses(conn, &protocol.DirectAddresses{Addresses: addrs}); err != nil {
+		t.Fatal(err)
+	}
+	if addrs := s.receivedDirectAddresses(device); !slices.Equal(addrs, []string{"tcp://192.0.2.42:22000"}) {
+		t.Errorf("got addresses %v", addrs)
+	}
+
+	// Only dialed for devices whose addresses are dynamic.
+	dynamic := config.DeviceConfiguration{DeviceID: device, Addresses: []string{"dynamic"}}
+	if addrs := s.resolveDeviceAddrs(context.Background(), dynamic); !slices.Equal(addrs, []string{"tcp://192.0.2.42:22000"}) {
+		t.Errorf("got addresses %v for dynamic device", addrs)
+	}
+	static := config.DeviceConfiguration{DeviceID: device, Addresses: []string{"tcp://192.0.2.1:22000"}}
+	if addrs := s.resolveDeviceAddrs(context.Background(), static); !slices.Equal(addrs, []string{"tcp://192.0.2.1:22000"}) {
+		t.Errorf("got addresses %v for static device", addrs)
+	}
+	select {
+	case <-s.dialNow:
+	default:
+		t.Error("expected a dial to be scheduled")
+	}
+	if _, ok := s.dialNowDevices[device]; !ok {
+		t.Error("expected the device to be dialed")
+	}
+
+	s.accountAddedConnection(newFakeConnection(device, "tcp4", 10), protocol.Hello{}, 0)
+	if s.isRelayedOnly(device) {
+		t.Error("expected device to be connected directly")
+	}
+	if conns := s.relayedOnlyConnections(); len(conns) != 0 {
+		t.Errorf("got %d relayed connections, expected none", len(conns))
+	}
+}
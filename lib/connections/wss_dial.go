@@ -0,0 +1,125 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections/registry"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func init() {
+	factory := &wssDialerFactory{}
+	for _, scheme := range []string{"wss", "wss4", "wss6"} {
+		dialers[scheme] = factory
+	}
+}
+
+type wssDialer struct {
+	commonDialer
+}
+
+func (d *wssDialer) Dial(ctx context.Context, _ protocol.DeviceID, uri *url.URL) (internalConn, error) {
+	uri = fixupPort(uri, config.DefaultWSSPort)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, proxied, err := dialWSSTransport(timeoutCtx, wssNetwork(uri.Scheme), uri.Host)
+	if err != nil {
+		return internalConn{}, err
+	}
+
+	err = dialer.SetTCPOptions(conn)
+	if err != nil {
+		l.Debugln("Dial (BEP/wss): setting tcp options:", err)
+	}
+
+	err = dialer.SetTrafficClass(conn, d.trafficClass)
+	if err != nil {
+		l.Debugln("Dial (BEP/wss): setting traffic class:", err)
+	}
+
+	// The certificate of the outer TLS session may be anyone's, such as
+	// that of a reverse proxy; the peer is verified in the inner session.
+	hc := tls.Client(conn, &tls.Config{
+		ServerName:         uri.Hostname(),
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"http/1.1"},
+		MinVersion:         tls.VersionTLS12,
+	})
+	if err := tlsTimedHandshake(hc); err != nil {
+		hc.Close()
+		return internalConn{}, err
+	}
+
+	wsURL := url.URL{Scheme: "wss", Host: uri.Host, Path: wssPath(uri)}
+	wsCfg, err := websocket.NewConfig(wsURL.String(), "https://"+uri.Host)
+	if err != nil {
+		hc.Close()
+		return internalConn{}, err
+	}
+	_ = hc.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	ws, err := websocket.NewClient(wsCfg, hc)
+	if err != nil {
+		hc.Close()
+		return internalConn{}, err
+	}
+	_ = hc.SetDeadline(time.Time{})
+
+	tc := tls.Client(newWSSConn(ws, conn.LocalAddr(), conn.RemoteAddr()), d.tlsCfg)
+	if err := tlsTimedHandshake(tc); err != nil {
+		tc.Close()
+		return internalConn{}, err
+	}
+
+	// A connection through a proxy is never local, whatever the address
+	// of the proxy.
+	priority := d.wanPriority
+	isLocal := !proxied && d.lanChecker.isLAN(conn.RemoteAddr())
+	if isLocal {
+		priority = d.lanPriority
+	}
+
+	return newInternalConn(tc, connTypeWSSClient, isLocal, priority), nil
+}
+
+type wssDialerFactory struct{}
+
+func (wssDialerFactory) New(opts config.OptionsConfiguration, tlsCfg *tls.Config, _ *registry.Registry, lanChecker *lanChecker) genericDialer {
+	return &wssDialer{
+		commonDialer: commonDialer{
+			trafficClass:      opts.TrafficClass,
+			reconnectInterval: time.Duration(opts.ReconnectIntervalS) * time.Second,
+			tlsCfg:            tlsCfg,
+			lanChecker:        lanChecker,
+			lanPriority:       opts.ConnectionPriorityWSSLAN,
+			wanPriority:       opts.ConnectionPriorityWSSWAN,
+			allowsMultiConns:  true,
+		},
+	}
+}
+
+func (wssDialerFactory) AlwaysWAN() bool {
+	return false
+}
+
+func (wssDialerFactory) Valid(_ config.Configuration) error {
+	// Always valid
+	return nil
+}
+
+func (wssDialerFactory) String() string {
+	return "WebSocket Dialer"
+}
@@ -0,0 +1,258 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections/registry"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/nat"
+	"github.com/syncthing/syncthing/lib/svcutil"
+)
+
+func init() {
+	factory := &wssListenerFactory{}
+	for _, scheme := range []string{"wss", "wss4", "wss6"} {
+		listeners[scheme] = factory
+	}
+}
+
+type wssListener struct {
+	svcutil.ServiceWithError
+	onAddressesChangedNotifier
+
+	uri        *url.URL
+	cfg        config.Wrapper
+	tlsCfg     *tls.Config
+	conns      chan internalConn
+	factory    listenerFactory
+	lanChecker *lanChecker
+
+	natService *nat.Service
+	mapping    *nat.Mapping
+	laddr      net.Addr
+
+	mut sync.RWMutex
+}
+
+func (t *wssListener) serve(ctx context.Context) error {
+	network := wssNetwork(t.uri.Scheme)
+	tcaddr, err := net.ResolveTCPAddr(network, t.uri.Host)
+	if err != nil {
+		l.Infoln("Listen (BEP/wss):", err)
+		return err
+	}
+
+	listener, err := net.ListenTCP(network, tcaddr)
+	if err != nil {
+		l.Infoln("Listen (BEP/wss):", err)
+		return err
+	}
+	defer listener.Close()
+
+	// We might bind to :0, so use the port we've been given.
+	tcaddr = listener.Addr().(*net.TCPAddr)
+
+	t.notifyAddressesChanged(t)
+	defer t.clearAddresses(t)
+
+	l.Infof("WebSocket listener (%v) starting", tcaddr)
+	defer l.Infof("WebSocket listener (%v) shutting down", tcaddr)
+
+	var ipVersion nat.IPVersion
+	if network == "tcp4" {
+		ipVersion = nat.IPv4Only
+	} else if network == "tcp6" {
+		ipVersion = nat.IPv6Only
+	} else {
+		ipVersion = nat.IPvAny
+	}
+	mapping := t.natService.NewMapping(nat.TCP, ipVersion, tcaddr.IP, tcaddr.Port)
+	mapping.OnChanged(func() {
+		t.notifyAddressesChanged(t)
+	})
+	// Should be called after t.mapping is nil'ed out.
+	defer t.natService.RemoveMapping(mapping)
+
+	t.mut.Lock()
+	t.mapping = mapping
+	t.laddr = tcaddr
+	t.mut.Unlock()
+	defer func() {
+		t.mut.Lock()
+		t.mapping = nil
+		t.laddr = nil
+		t.mut.Unlock()
+	}()
+
+	// The outer TLS session only needs a certificate, which might as well
+	// be ours; peers are verified in the inner session.
+	outerCfg := t.tlsCfg.Clone()
+	outerCfg.NextProtos = []string{"http/1.1"}
+	outerCfg.ClientAuth = tls.NoClientCert
+
+	mux := http.NewServeMux()
+	mux.Handle(wssPath(t.uri), websocket.Server{Handler: t.handle})
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: tlsHandshakeTimeout,
+		// Failed handshakes from port scanners and the like aren't
+		// interesting.
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	err = srv.Serve(tls.NewListener(tcpKeepAliveListener{listener, t.cfg}, outerCfg))
+	if ctx.Err() != nil || errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	l.Warnln("Listen (BEP/wss):", err)
+	return err
+}
+
+// handle runs the BEP TLS session over an accepted WebSocket connection.
+// The connection is closed when this returns, so it waits until the
+// connection is closed on our side.
+func (t *wssListener) handle(ws *websocket.Conn) {
+	req := ws.Request()
+	localAddr, _ := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	remoteAddr, err := net.ResolveTCPAddr("tcp", req.RemoteAddr)
+	if err != nil {
+		l.Debugln("Listen (BEP/wss): remote address:", err)
+		return
+	}
+	l.Debugln("Listen (BEP/wss): connect from", remoteAddr)
+
+	conn := newWSSConn(ws, localAddr, remoteAddr)
+	tc := tls.Server(conn, t.tlsCfg)
+	if err := tlsTimedHandshake(tc); err != nil {
+		l.Infoln("Listen (BEP/wss): TLS handshake:", err)
+		tc.Close()
+		return
+	}
+
+	priority := t.cfg.Options().ConnectionPriorityWSSWAN
+	// Connections through a reverse proxy count as WAN connections, even
+	// when the proxy itself is on the LAN.
+	isLocal := !wssProxied(req) && t.lanChecker.isLAN(remoteAddr)
+	if isLocal {
+		priority = t.cfg.Options().ConnectionPriorityWSSLAN
+	}
+	t.conns <- newInternalConn(tc, connTypeWSSServer, isLocal, priority)
+
+	<-conn.closed
+}
+
+func (t *wssListener) URI() *url.URL {
+	return t.uri
+}
+
+func (t *wssListener) WANAddresses() []*url.URL {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	uris := []*url.URL{
+		maybeReplacePort(t.uri, t.laddr),
+	}
+	if t.mapping != nil {
+		addrs := t.mapping.ExternalAddresses()
+		for _, addr := range addrs {
+			uri := *t.uri
+			// Does net.JoinHostPort internally
+			uri.Host = addr.String()
+			uris = append(uris, &uri)
+
+			// For every address with a specified IP, add one without an IP,
+			// just in case the specified IP is still internal (router behind DMZ).
+			if len(addr.IP) != 0 && !addr.IP.IsUnspecified() {
+				zeroUri := *t.uri
+				addr.IP = nil
+				zeroUri.Host = addr.String()
+				uris = append(uris, &zeroUri)
+			}
+		}
+	}
+	return uris
+}
+
+func (t *wssListener) LANAddresses() []*url.URL {
+	t.mut.RLock()
+	uri := maybeReplacePort(t.uri, t.laddr)
+	t.mut.RUnlock()
+	addrs := []*url.URL{uri}
+	addrs = append(addrs, getURLsForAllAdaptersIfUnspecified(wssNetwork(uri.Scheme), uri)...)
+	return addrs
+}
+
+func (t *wssListener) String() string {
+	return t.uri.String()
+}
+
+func (t *wssListener) Factory() listenerFactory {
+	return t.factory
+}
+
+func (*wssListener) NATType() string {
+	return "unknown"
+}
+
+// tcpKeepAliveListener sets our usual TCP options on accepted connections.
+type tcpKeepAliveListener struct {
+	*net.TCPListener
+	cfg config.Wrapper
+}
+
+func (t tcpKeepAliveListener) Accept() (net.Conn, error) {
+	conn, err := t.TCPListener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if err := dialer.SetTCPOptions(conn); err != nil {
+		l.Debugln("Listen (BEP/wss): setting tcp options:", err)
+	}
+	if tc := t.cfg.Options().TrafficClass; tc != 0 {
+		if err := dialer.SetTrafficClass(conn, tc); err != nil {
+			l.Debugln("Listen (BEP/wss): setting traffic class:", err)
+		}
+	}
+	return conn, nil
+}
+
+type wssListenerFactory struct{}
+
+func (f *wssListenerFactory) New(uri *url.URL, cfg config.Wrapper, tlsCfg *tls.Config, conns chan internalConn, natService *nat.Service, _ *registry.Registry, lanChecker *lanChecker) genericListener {
+	l := &wssListener{
+		uri:        fixupPort(uri, config.DefaultWSSPort),
+		cfg:        cfg,
+		tlsCfg:     tlsCfg,
+		conns:      conns,
+		natService: natService,
+		factory:    f,
+		lanChecker: lanChecker,
+	}
+	l.ServiceWithError = svcutil.AsService(l.serve, l.String())
+	return l
+}
+
+func (wssListenerFactory) Valid(_ config.Configuration) error {
+	// Always valid
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/syncthing/syncthing/lib/dialer"
+)
+
+// The WebSocket transport carries BEP over a WebSocket connection, which
+// in turn runs over HTTPS. The outer TLS layer is only there to look like
+// any other HTTPS traffic to proxies and firewalls, and may be terminated
+// by a reverse proxy. The peers authenticate each other by the inner TLS
+// session, as on any other connection.
+
+// wssNetwork returns the network to use for the given wss scheme.
+func wssNetwork(scheme string) string {
+	return "tcp" + strings.TrimPrefix(scheme, "wss")
+}
+
+// wssPath returns the HTTP path of the WebSocket endpoint in the URI.
+func wssPath(uri *url.URL) string {
+	if uri.Path == "" {
+		return "/"
+	}
+	return uri.Path
+}
+
+// wssProxied returns true if the request came through a reverse proxy, as
+// far as we can tell from the headers it added. The address of the peer is
+// then the proxy's, which says nothing about whether the peer is on the
+// LAN; the forwarded addresses could say, but anyone can set those.
+func wssProxied(req *http.Request) bool {
+	for _, header := range []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Real-Ip", "Via"} {
+		if req.Header.Get(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// wssConn is a WebSocket connection carrying binary frames, with the
+// addresses of the underlying connection instead of the WebSocket URLs.
+type wssConn struct {
+	*websocket.Conn
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	closeOnce  sync.Once
+	closed     chan struct{}
+}
+
+func newWSSConn(ws *websocket.Conn, localAddr, remoteAddr net.Addr) *wssConn {
+	ws.PayloadType = websocket.BinaryFrame
+	return &wssConn{
+		Conn:       ws,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		closed:     make(chan struct{}),
+	}
+}
+
+func (c *wssConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+func (c *wssConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *wssConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// wssProxyFunc returns the HTTP proxy to use for a request, if any. It
+// can be overridden for testing.
+var wssProxyFunc = http.ProxyFromEnvironment
+
+// dialWSSTransport dials the host of a WebSocket URI, through the HTTPS
+// proxy configured in the environment if there is one. It returns whether
+// the connection goes through a proxy.
+func dialWSSTransport(ctx context.Context, network, host string) (net.Conn, bool, error) {
+	proxyURL, err := wssProxyFunc(&http.Request{URL: &url.URL{Scheme: "https", Host: host}})
+	if err != nil {
+		return nil, false, err
+	}
+	if proxyURL == nil {
+		conn, err := dialer.DialContext(ctx, network, host)
+		return conn, false, err
+	}
+	conn, err := dialHTTPProxy(ctx, proxyURL, host)
+	return conn, true, err
+}
+
+// dialHTTPProxy opens a tunnel to the host through the HTTP proxy, using
+// the CONNECT method.
+func dialHTTPProxy(ctx context.Context, proxyURL *url.URL, host string) (net.Conn, error) {
+	proxyHost := proxyURL.Host
+	if proxyURL.Port() == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			proxyHost = net.JoinHostPort(proxyURL.Hostname(), "443")
+		case "http", "":
+			proxyHost = net.JoinHostPort(proxyURL.Hostname(), "80")
+		}
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", proxyHost)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if proxyURL.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %s", proxyHost, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// The server doesn't speak before we do, so there's nothing the
+		// proxy should have passed on yet.
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: unexpected data after CONNECT response", proxyHost)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
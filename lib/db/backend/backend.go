@@ -8,6 +8,8 @@ package backend
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -125,10 +127,87 @@ const (
 	TuningLarge
 )
 
-func Open(path string, tuning Tuning) (Backend, error) {
+// Kind is the type of database backend.
+type Kind int
+
+const (
+	// N.b. these constants must match those in lib/config.DatabaseBackend!
+	KindLevelDB Kind = iota
+	KindSQLite
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindLevelDB:
+		return "leveldb"
+	case KindSQLite:
+		return "sqlite"
+	default:
+		return "unknown"
+	}
+}
+
+// Open opens the database at the given path. An existing database is
+// always opened with the backend it was created with; the kind only
+// decides the backend used for a new database.
+func Open(path string, kind Kind, tuning Tuning) (Backend, error) {
+	if existing, ok := DetectKind(path); ok {
+		kind = existing
+	}
+	if kind == KindSQLite {
+		return OpenSQLite(path)
+	}
 	return OpenLevelDB(path, tuning)
 }
 
+// OpenRO opens the existing database at the given path, read only.
+func OpenRO(path string) (Backend, error) {
+	if kind, _ := DetectKind(path); kind == KindSQLite {
+		return OpenSQLiteRO(path)
+	}
+	return OpenLevelDBRO(path)
+}
+
+// DetectKind returns the kind of the database at the given path, and false
+// if there is no database there.
+func DetectKind(path string) (Kind, bool) {
+	if isSQLite(path) {
+		return KindSQLite, true
+	}
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err == nil {
+		return KindLevelDB, true
+	}
+	return KindLevelDB, false
+}
+
+// Copy copies all keys and values from src into dst.
+func Copy(dst, src Backend) error {
+	it, err := src.NewPrefixIterator(nil)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	t, err := dst.NewWriteTransaction()
+	if err != nil {
+		return err
+	}
+	defer t.Release()
+
+	for it.Next() {
+		if err := t.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		if err := t.Checkpoint(); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return t.Commit()
+}
+
 func OpenMemory() Backend {
 	return OpenLevelDBMemory()
 }
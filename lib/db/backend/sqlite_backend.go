@@ -0,0 +1,441 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	_ "modernc.org/sqlite" // register the "sqlite" driver
+)
+
+// The name of the database file within the database directory.
+const sqliteFileName = "index.sqlite"
+
+// sqliteBackend implements Backend on top of a single key/value table in
+// an SQLite database in WAL mode. Readers never block writers, and vice
+// versa, so there are no compaction stalls as with LevelDB.
+type sqliteBackend struct {
+	db       *sql.DB
+	lock     storage.Storage
+	closeWG  *closeWaitGroup
+	location string
+}
+
+// OpenSQLite opens or creates the SQLite database in the given directory.
+func OpenSQLite(location string) (Backend, error) {
+	return openSQLite(location, false)
+}
+
+// OpenSQLiteRO opens the SQLite database in the given directory, read
+// only.
+func OpenSQLiteRO(location string) (Backend, error) {
+	return openSQLite(location, true)
+}
+
+func openSQLite(location string, readOnly bool) (Backend, error) {
+	// Use the same directory lock as LevelDB, to prevent concurrent use of
+	// the database by multiple instances.
+	lock, err := storage.OpenFile(location, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(60000)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	if readOnly {
+		params.Set("mode", "ro")
+	} else {
+		// auto_vacuum only takes effect when set before the table is
+		// created.
+		params.Add("_pragma", "auto_vacuum(INCREMENTAL)")
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+	dsn := "file:" + filepath.ToSlash(filepath.Join(location, sqliteFileName)) + "?" + params.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	db.SetMaxIdleConns(8)
+
+	if !readOnly {
+		if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS kv (key BLOB NOT NULL PRIMARY KEY, value BLOB) WITHOUT ROWID`); err != nil {
+			db.Close()
+			lock.Close()
+			return nil, err
+		}
+	}
+
+	return &sqliteBackend{
+		db:       db,
+		lock:     lock,
+		closeWG:  &closeWaitGroup{},
+		location: location,
+	}, nil
+}
+
+// isSQLite returns true if there is an SQLite database in the given
+// directory.
+func isSQLite(location string) bool {
+	_, err := os.Stat(filepath.Join(location, sqliteFileName))
+	return err == nil
+}
+
+func (b *sqliteBackend) NewReadTransaction() (ReadTransaction, error) {
+	return b.newReadTransaction()
+}
+
+func (b *sqliteBackend) newReadTransaction() (*sqliteReadTransaction, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		rel.Release()
+		return nil, err
+	}
+	// The snapshot is established by the first read in the transaction,
+	// not by beginning it.
+	var discard int
+	if err := tx.QueryRow(`SELECT count(*) FROM (SELECT 1 FROM kv LIMIT 1)`).Scan(&discard); err != nil {
+		tx.Rollback()
+		rel.Release()
+		return nil, err
+	}
+	return &sqliteReadTransaction{
+		querier: tx,
+		tx:      tx,
+		rel:     rel,
+	}, nil
+}
+
+func (b *sqliteBackend) NewWriteTransaction(hooks ...CommitHook) (WriteTransaction, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := b.newReadTransaction()
+	if err != nil {
+		rel.Release()
+		return nil, err
+	}
+	return &sqliteWriteTransaction{
+		sqliteReadTransaction: snap,
+		db:                    b.db,
+		rel:                   rel,
+		commitHooks:           hooks,
+	}, nil
+}
+
+func (b *sqliteBackend) Close() error {
+	b.closeWG.CloseWait()
+	err := b.db.Close()
+	if lerr := b.lock.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+func (b *sqliteBackend) Get(key []byte) ([]byte, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	defer rel.Release()
+	return sqliteGet(b.db, key)
+}
+
+func (b *sqliteBackend) NewPrefixIterator(prefix []byte) (Iterator, error) {
+	tx, err := b.newReadTransaction()
+	if err != nil {
+		return nil, err
+	}
+	it, err := tx.NewPrefixIterator(prefix)
+	if err != nil {
+		tx.Release()
+		return nil, err
+	}
+	it.(*sqliteIterator).release = tx.Release
+	return it, nil
+}
+
+func (b *sqliteBackend) NewRangeIterator(first, last []byte) (Iterator, error) {
+	tx, err := b.newReadTransaction()
+	if err != nil {
+		return nil, err
+	}
+	it, err := tx.NewRangeIterator(first, last)
+	if err != nil {
+		tx.Release()
+		return nil, err
+	}
+	it.(*sqliteIterator).release = tx.Release
+	return it, nil
+}
+
+func (b *sqliteBackend) Put(key, val []byte) error {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return err
+	}
+	defer rel.Release()
+	_, err = b.db.Exec(`INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)`, key, val)
+	return err
+}
+
+func (b *sqliteBackend) Delete(key []byte) error {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return err
+	}
+	defer rel.Release()
+	_, err = b.db.Exec(`DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+func (b *sqliteBackend) Compact() error {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return err
+	}
+	defer rel.Release()
+	if _, err := b.db.Exec(`PRAGMA incremental_vacuum`); err != nil {
+		return err
+	}
+	_, err = b.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+func (b *sqliteBackend) Location() string {
+	return b.location
+}
+
+// querier is what's common between *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func sqliteGet(q querier, key []byte) ([]byte, error) {
+	var val []byte
+	err := q.QueryRow(`SELECT value FROM kv WHERE key = ?`, key).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotFound
+	}
+	return val, err
+}
+
+// sqliteReadTransaction implements backend.ReadTransaction
+type sqliteReadTransaction struct {
+	querier
+	tx  *sql.Tx
+	rel *releaser
+}
+
+func (t *sqliteReadTransaction) Get(key []byte) ([]byte, error) {
+	return sqliteGet(t.querier, key)
+}
+
+func (t *sqliteReadTransaction) NewPrefixIterator(prefix []byte) (Iterator, error) {
+	if len(prefix) == 0 {
+		return t.NewRangeIterator(nil, nil)
+	}
+	// The first key after all keys with the given prefix, or nil if
+	// there is no such key (the prefix is all 0xff).
+	var limit []byte
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			limit = make([]byte, i+1)
+			copy(limit, prefix)
+			limit[i]++
+			break
+		}
+	}
+	return t.NewRangeIterator(prefix, limit)
+}
+
+func (t *sqliteReadTransaction) NewRangeIterator(first, last []byte) (Iterator, error) {
+	var rows *sql.Rows
+	var err error
+	switch {
+	case first == nil && last == nil:
+		rows, err = t.Query(`SELECT key, value FROM kv ORDER BY key`)
+	case last == nil:
+		rows, err = t.Query(`SELECT key, value FROM kv WHERE key >= ? ORDER BY key`, first)
+	case first == nil:
+		rows, err = t.Query(`SELECT key, value FROM kv WHERE key < ? ORDER BY key`, last)
+	default:
+		rows, err = t.Query(`SELECT key, value FROM kv WHERE key >= ? AND key < ? ORDER BY key`, first, last)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteIterator{rows: rows}, nil
+}
+
+func (t *sqliteReadTransaction) Release() {
+	t.tx.Rollback()
+	t.rel.Release()
+}
+
+// sqliteWriteTransaction implements backend.WriteTransaction. Like the
+// LevelDB one it reads from a snapshot and collects writes in a batch,
+// which is written in a separate transaction when flushed.
+type sqliteWriteTransaction struct {
+	*sqliteReadTransaction
+	db          *sql.DB
+	batch       []sqliteOp
+	batchSize   int
+	rel         *releaser
+	commitHooks []CommitHook
+	inFlush     bool
+}
+
+type sqliteOp struct {
+	key, val []byte
+	delete   bool
+}
+
+func (t *sqliteWriteTransaction) Put(key, val []byte) error {
+	t.batch = append(t.batch, sqliteOp{
+		key: append([]byte(nil), key...),
+		val: append([]byte(nil), val...),
+	})
+	t.batchSize += len(key) + len(val)
+	return t.checkFlush(dbFlushBatchMax)
+}
+
+func (t *sqliteWriteTransaction) Delete(key []byte) error {
+	t.batch = append(t.batch, sqliteOp{
+		key:    append([]byte(nil), key...),
+		delete: true,
+	})
+	t.batchSize += len(key)
+	return t.checkFlush(dbFlushBatchMax)
+}
+
+func (t *sqliteWriteTransaction) Checkpoint() error {
+	return t.checkFlush(dbFlushBatchMin)
+}
+
+func (t *sqliteWriteTransaction) Commit() error {
+	err := t.flush()
+	t.sqliteReadTransaction.Release()
+	t.rel.Release()
+	return err
+}
+
+func (t *sqliteWriteTransaction) Release() {
+	t.sqliteReadTransaction.Release()
+	t.rel.Release()
+}
+
+// checkFlush flushes and resets the batch if its size exceeds the given size.
+func (t *sqliteWriteTransaction) checkFlush(size int) error {
+	// Hooks might put values in the database, which triggers a checkFlush
+	// which might trigger a flush, which might trigger the hooks. Don't
+	// recurse...
+	if t.inFlush || t.batchSize < size {
+		return nil
+	}
+	return t.flush()
+}
+
+func (t *sqliteWriteTransaction) flush() error {
+	t.inFlush = true
+	defer func() { t.inFlush = false }()
+
+	for _, hook := range t.commitHooks {
+		if err := hook(t); err != nil {
+			return err
+		}
+	}
+	if len(t.batch) == 0 {
+		return nil
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	put, err := tx.Prepare(`INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer put.Close()
+	del, err := tx.Prepare(`DELETE FROM kv WHERE key = ?`)
+	if err != nil {
+		return err
+	}
+	defer del.Close()
+	for _, op := range t.batch {
+		if op.delete {
+			_, err = del.Exec(op.key)
+		} else {
+			_, err = put.Exec(op.key, op.val)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	t.batch = t.batch[:0]
+	t.batchSize = 0
+	return nil
+}
+
+type sqliteIterator struct {
+	rows     *sql.Rows
+	key, val []byte
+	err      error
+	release  func()
+}
+
+func (it *sqliteIterator) Next() bool {
+	if !it.rows.Next() {
+		return false
+	}
+	if err := it.rows.Scan(&it.key, &it.val); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+func (it *sqliteIterator) Key() []byte {
+	return it.key
+}
+
+func (it *sqliteIterator) Value() []byte {
+	return it.val
+}
+
+func (it *sqliteIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *sqliteIterator) Release() {
+	it.rows.Close()
+	if it.release != nil {
+		it.release()
+		it.release = nil
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSQLiteBackendBehavior(t *testing.T) {
+	testBackendBehavior(t, func() Backend {
+		db, err := OpenSQLite(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestOpenKeepsExistingKind(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := Open(dir, KindSQLite, TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if kind, ok := DetectKind(dir); !ok || kind != KindSQLite {
+		t.Fatalf("detected %v, %v, expected sqlite", kind, ok)
+	}
+
+	// Asking for LevelDB should still open the existing SQLite database.
+	db, err = Open(dir, KindLevelDB, TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if val, err := db.Get([]byte("key")); err != nil || string(val) != "value" {
+		t.Fatalf("unexpected get result %q, %v", val, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src, err := OpenLevelDB(t.TempDir(), TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := OpenSQLite(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	val := bytes.Repeat([]byte("x"), 1000)
+	for i := 0; i < 2000; i++ {
+		key := []byte{byte(i >> 8), byte(i)}
+		if err := src.Put(key, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := dst.NewPrefixIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Release()
+	n := 0
+	for it.Next() {
+		if key := []byte{byte(n >> 8), byte(n)}; !bytes.Equal(it.Key(), key) {
+			t.Fatalf("unexpected key %x at %d", it.Key(), n)
+		}
+		if !bytes.Equal(it.Value(), val) {
+			t.Fatalf("unexpected value for key %x", it.Key())
+		}
+		n++
+	}
+	if n != 2000 {
+		t.Errorf("copied %d keys, expected 2000", n)
+	}
+}
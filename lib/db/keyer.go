@@ -74,6 +74,9 @@ const (
 
 	// KeyTypePendingDevice <device ID in wire format> = ObservedDevice
 	KeyTypePendingDevice byte = 17
+
+	// KeyTypeFolderFetch <folder ID as string> 0x00 <file name> = bool
+	KeyTypeFolderFetch byte = 18
 )
 
 type keyer interface {
@@ -694,6 +694,10 @@ func (db *Lowlevel) dropFolderMeta(folder []byte) error {
 	return db.dropPrefix(key)
 }
 
+func (db *Lowlevel) dropFolderFetches(folder []byte) error {
+	return db.dropPrefix([]byte(folderFetchPrefix(string(folder))))
+}
+
 func (db *Lowlevel) dropPrefix(prefix []byte) error {
 	t, err := db.newReadWriteTransaction()
 	if err != nil {
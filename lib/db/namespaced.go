@@ -143,6 +143,18 @@ func NewFolderStatisticsNamespace(db backend.Backend, folder string) *Namespaced
 	return NewNamespacedKV(db, string(KeyTypeFolderStatistic)+folder)
 }
 
+// NewFolderFetchNamespace creates a KV namespace for the files that have
+// been requested on demand in a selectively synced folder.
+func NewFolderFetchNamespace(db backend.Backend, folder string) *NamespacedKV {
+	return NewNamespacedKV(db, folderFetchPrefix(folder))
+}
+
+// folderFetchPrefix terminates the folder ID, as file names follow it
+// directly.
+func folderFetchPrefix(folder string) string {
+	return string(KeyTypeFolderFetch) + folder + "\x00"
+}
+
 // NewMiscDataNamespace creates a KV namespace for miscellaneous metadata.
 func NewMiscDataNamespace(db backend.Backend) *NamespacedKV {
 	return NewNamespacedKV(db, string(KeyTypeMiscData))
@@ -451,6 +451,7 @@ func DropFolder(db *Lowlevel, folder string) {
 		db.dropMtimes,
 		db.dropFolderMeta,
 		db.dropFolderIndexIDs,
+		db.dropFolderFetches,
 		db.folderIdx.Delete,
 	}
 	for _, drop := range droppers {
@@ -826,7 +826,7 @@ func BenchmarkUpdateOneFile(b *testing.B) {
 		protocol.FileInfo{Name: "zajksdhaskjdh/askjdhaskjdashkajshd/kasjdhaskjdhaskdjhaskdjash/dkjashdaksjdhaskdjahskdjh", Version: protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1000}}}, Blocks: genBlocks(8)},
 	}
 
-	be, err := backend.Open("testdata/benchmarkupdate.db", backend.TuningAuto)
+	be, err := backend.Open("testdata/benchmarkupdate.db", backend.KindLevelDB, backend.TuningAuto)
 	if err != nil {
 		b.Fatal(err)
 	}
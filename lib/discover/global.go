@@ -17,6 +17,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	stdsync "sync"
 	"time"
@@ -70,6 +71,7 @@ type serverOptions struct {
 	noAnnounce bool   // don't announce
 	noLookup   bool   // don't use for lookups
 	id         string // expected server device ID
+	token      string // bearer token for lookups
 }
 
 // A lookupError is any other error but with a cache validity time attached.
@@ -91,11 +93,17 @@ func NewGlobal(server string, cert tls.Certificate, addrList AddressLister, evLo
 	}
 
 	var devID protocol.DeviceID
+	var verifyConnection func(tls.ConnectionState) error
 	if opts.id != "" {
 		devID, err = protocol.DeviceIDFromString(opts.id)
 		if err != nil {
 			return nil, err
 		}
+		// Check the server identity during the handshake, before anything
+		// (such as a token) is sent to it.
+		verifyConnection = func(cs tls.ConnectionState) error {
+			return checkServerID(cs, devID)
+		}
 	}
 
 	// The http.Client used for announcements. It needs to have our
@@ -107,7 +115,7 @@ func NewGlobal(server string, cert tls.Certificate, addrList AddressLister, evLo
 	} else {
 		dialContext = dialer.DialContext
 	}
-	var announceClient httpClient = &contextClient{&http.Client{
+	var announceClient httpClient = &contextClient{Client: &http.Client{
 		Timeout: requestTimeout,
 		Transport: http2EnabledTransport(&http.Transport{
 			DialContext:       dialContext,
@@ -115,6 +123,7 @@ func NewGlobal(server string, cert tls.Certificate, addrList AddressLister, evLo
 			DisableKeepAlives: true, // announcements are few and far between, so don't keep the connection open
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: opts.insecure,
+				VerifyConnection:   verifyConnection,
 				Certificates:       []tls.Certificate{cert},
 				MinVersion:         tls.VersionTLS12,
 				ClientSessionCache: tls.NewLRUClientSessionCache(0),
@@ -127,7 +136,8 @@ func NewGlobal(server string, cert tls.Certificate, addrList AddressLister, evLo
 
 	// The http.Client used for queries. We don't need to present our
 	// certificate here, so lets not include it. May be insecure if requested.
-	var queryClient httpClient = &contextClient{&http.Client{
+	// Private servers may require a token instead.
+	var queryClient httpClient = &contextClient{token: opts.token, Client: &http.Client{
 		Timeout: requestTimeout,
 		Transport: http2EnabledTransport(&http.Transport{
 			DialContext:     dialer.DialContext,
@@ -135,6 +145,7 @@ func NewGlobal(server string, cert tls.Certificate, addrList AddressLister, evLo
 			IdleConnTimeout: time.Second,
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: opts.insecure,
+				VerifyConnection:   verifyConnection,
 				MinVersion:         tls.VersionTLS12,
 				ClientSessionCache: tls.NewLRUClientSessionCache(0),
 			},
@@ -323,6 +334,16 @@ func (*globalClient) Cache() map[protocol.DeviceID]CacheEntry {
 	return nil
 }
 
+// matches the value of the token option in a discovery server address
+var serverTokenExpr = regexp.MustCompile(`([?&]token=)[^&#\s]*`)
+
+// RedactServerToken returns the string, usually a discovery server address
+// or something mentioning it, with the value of any token option replaced,
+// for logging or showing to those who shouldn't have the token.
+func RedactServerToken(s string) string {
+	return serverTokenExpr.ReplaceAllString(s, "${1}redacted")
+}
+
 // parseOptions parses and strips away any ?query=val options, setting the
 // corresponding field in the serverOptions struct. Unknown query options are
 // ignored and removed.
@@ -338,6 +359,7 @@ func parseOptions(dsn string) (server string, opts serverOptions, err error) {
 	opts.insecure = opts.id != "" || queryBool(q, "insecure")
 	opts.noAnnounce = queryBool(q, "noannounce")
 	opts.noLookup = queryBool(q, "nolookup")
+	opts.token = q.Get("token")
 
 	// Check for disallowed combinations
 	if p.Scheme == "http" {
@@ -350,6 +372,10 @@ func parseOptions(dsn string) (server string, opts serverOptions, err error) {
 	} else if p.Scheme != "https" {
 		return "", serverOptions{}, errors.New("unsupported scheme " + p.Scheme)
 	}
+	if opts.token != "" && (p.Scheme == "http" || opts.insecure && opts.id == "") {
+		// The token would go to whoever is on the other side.
+		return "", serverOptions{}, errors.New("token requires a verified server certificate or id")
+	}
 
 	// Remove the query string
 	p.RawQuery = ""
@@ -385,13 +411,17 @@ func (c *idCheckingHTTPClient) check(resp *http.Response) error {
 	if resp.TLS == nil {
 		return errors.New("security: not TLS")
 	}
+	return checkServerID(*resp.TLS, c.id)
+}
 
-	if len(resp.TLS.PeerCertificates) == 0 {
+// checkServerID returns an error unless the server certificate of the
+// connection is that of the given device ID.
+func checkServerID(cs tls.ConnectionState, id protocol.DeviceID) error {
+	if len(cs.PeerCertificates) == 0 {
 		return errors.New("security: no certificates")
 	}
 
-	id := protocol.NewDeviceID(resp.TLS.PeerCertificates[0].Raw)
-	if !id.Equals(c.id) {
+	if !protocol.NewDeviceID(cs.PeerCertificates[0].Raw).Equals(id) {
 		return errors.New("security: incorrect device id")
 	}
 
@@ -442,6 +472,7 @@ func (e *errorHolder) Error() error {
 
 type contextClient struct {
 	*http.Client
+	token string // sent as bearer token, if set
 }
 
 func (c *contextClient) Get(ctx context.Context, url string) (*http.Response, error) {
@@ -449,6 +480,9 @@ func (c *contextClient) Get(ctx context.Context, url string) (*http.Response, er
 	if err != nil {
 		return nil, err
 	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 	return c.Client.Do(req)
 }
 
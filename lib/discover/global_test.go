@@ -34,6 +34,8 @@ func TestParseOptions(t *testing.T) {
 		{"https://example.com/?insecure=yes", "https://example.com/", serverOptions{insecure: true}},
 		{"https://example.com/?insecure=false&noannounce", "https://example.com/", serverOptions{noAnnounce: true}},
 		{"https://example.com/?id=abc", "https://example.com/", serverOptions{id: "abc", insecure: true}},
+		{"https://example.com/?token=s3cret", "https://example.com/", serverOptions{token: "s3cret"}},
+		{"https://example.com/?id=abc&token=s3cret", "https://example.com/", serverOptions{id: "abc", insecure: true, token: "s3cret"}},
 	}
 
 	for _, tc := range testcases {
@@ -51,6 +53,36 @@ func TestParseOptions(t *testing.T) {
 	}
 }
 
+func TestParseOptionsTokenNeedsVerifiedServer(t *testing.T) {
+	for _, in := range []string{
+		"https://example.com/?insecure&token=s3cret",
+		"http://example.com/?insecure&noannounce&token=s3cret",
+		"http://example.com/?id=abc&noannounce&token=s3cret",
+	} {
+		if _, _, err := parseOptions(in); err == nil {
+			t.Errorf("Expected an error for %v", in)
+		}
+	}
+}
+
+func TestRedactServerToken(t *testing.T) {
+	testcases := []struct {
+		in, out string
+	}{
+		{"https://example.com/", "https://example.com/"},
+		{"https://example.com/?token=s3cret", "https://example.com/?token=redacted"},
+		{"https://example.com/?id=abc&token=s3cret&nolookup", "https://example.com/?id=abc&token=redacted&nolookup"},
+		{"global discovery server https://example.com/?token=s3cret", "global discovery server https://example.com/?token=redacted"},
+		{"https://example.com/?notoken=abc", "https://example.com/?notoken=abc"},
+	}
+
+	for _, tc := range testcases {
+		if res := RedactServerToken(tc.in); res != tc.out {
+			t.Errorf("Incorrect redaction, %v != %v for %v", res, tc.out, tc.in)
+		}
+	}
+}
+
 func TestGlobalOverHTTP(t *testing.T) {
 	// HTTP works for queries, but is obviously insecure and we can't do
 	// announces over it (as we don't present a certificate). As such, http://
@@ -165,6 +197,50 @@ func TestGlobalOverHTTPS(t *testing.T) {
 	}
 }
 
+func TestGlobalTokenWithID(t *testing.T) {
+	cert, err := tlsutil.NewCertificateInMemory("syncthing", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tls.Listen("tcp4", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+
+	tokens := make(chan string, 10)
+	s := new(fakeDiscoveryServer)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.Header.Get("Authorization")
+		s.handler(w, r)
+	})
+	go func() { _ = http.Serve(list, mux) }()
+
+	// With an incorrect id the connection must be refused before the token
+	// is sent.
+
+	url := "https://" + list.Addr().String() + "?noannounce&token=s3cret&id=" + protocol.LocalDeviceID.String()
+	if _, err := testLookup(url); err == nil {
+		t.Fatal("unexpected nil error for incorrect discovery server ID")
+	}
+	select {
+	case token := <-tokens:
+		t.Fatalf("request with %q sent to server with incorrect ID", token)
+	default:
+	}
+
+	id := protocol.NewDeviceID(cert.Certificate[0])
+	url = "https://" + list.Addr().String() + "?noannounce&token=s3cret&id=" + id.String()
+	if _, err := testLookup(url); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token := <-tokens; token != "Bearer s3cret" {
+		t.Errorf("incorrect authorization %q", token)
+	}
+}
+
 func TestGlobalAnnounce(t *testing.T) {
 	// Generate a server certificate.
 	cert, err := tlsutil.NewCertificateInMemory("syncthing", 30)
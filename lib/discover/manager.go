@@ -89,7 +89,7 @@ func (m *manager) addLocked(identity string, finder Finder, cacheTime, negCacheT
 		entry.token = &token
 	}
 	m.finders[identity] = entry
-	l.Infoln("Using discovery mechanism:", identity)
+	l.Infoln("Using discovery mechanism:", RedactServerToken(identity))
 }
 
 func (m *manager) removeLocked(identity string) {
@@ -100,11 +100,11 @@ func (m *manager) removeLocked(identity string) {
 	if entry.token != nil {
 		err := m.Supervisor.Remove(*entry.token)
 		if err != nil {
-			l.Warnf("removing discovery %s: %s", identity, err)
+			l.Warnf("removing discovery %s: %s", RedactServerToken(identity), err)
 		}
 	}
 	delete(m.finders, identity)
-	l.Infoln("Stopped using discovery mechanism: ", identity)
+	l.Infoln("Stopped using discovery mechanism: ", RedactServerToken(identity))
 }
 
 // Lookup attempts to resolve the device ID using any of the added Finders,
@@ -179,7 +179,7 @@ func (m *manager) ChildErrors() map[string]error {
 	children := make(map[string]error, len(m.finders))
 	m.mut.RLock()
 	for _, f := range m.finders {
-		children[f.String()] = f.Error()
+		children[RedactServerToken(f.String())] = f.Error()
 	}
 	m.mut.RUnlock()
 	return children
@@ -262,7 +262,7 @@ func (m *manager) CommitConfiguration(_, to config.Configuration) (handled bool)
 			}
 			gd, err := NewGlobal(srv, m.cert, m.addressLister, m.evLogger, m.registry)
 			if err != nil {
-				l.Warnln("Global discovery:", err)
+				l.Warnln("Global discovery:", RedactServerToken(err.Error()))
 				continue
 			}
 
@@ -0,0 +1,865 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miscreant/miscreant.go"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/syncthing/syncthing/lib/ignore/ignoreresult"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+const (
+	encKeySize          = 32
+	encChunkSize        = 64 << 10 // plaintext bytes per encrypted chunk
+	encChunkOverhead    = chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead
+	encDiskChunkSize    = encChunkSize + encChunkOverhead
+	encFileIDSize       = 16 // random per file identifier in the header
+	encHeaderSize       = encFileIDSize
+	encExtendBatch      = 16  // chunks written at a time when extending
+	encMaxNameComponent = 255 // bytes, after encryption and encoding
+	encNameAlgo         = "AES-SIV"
+)
+
+var (
+	errEncryptedNameTooLong = errors.New("name too long to be stored encrypted")
+	errEncryptedNameInvalid = errors.New("not a validly encrypted name")
+	errEncryptedDataInvalid = errors.New("encrypted data failed authentication")
+
+	encNameEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+)
+
+type optionEncryption struct {
+	nameKey    *[encKeySize]byte
+	contentKey *[encKeySize]byte
+}
+
+// NewEncryptionOption makes the filesystem store names and file contents
+// encrypted on disk, while presenting them in plain text. Path components
+// are encrypted deterministically with AES-SIV using nameKey, so that
+// they can be looked up, and file contents are encrypted in chunks with
+// XChaCha20-Poly1305 using contentKey.
+func NewEncryptionOption(nameKey, contentKey *[encKeySize]byte) Option {
+	return &optionEncryption{
+		nameKey:    nameKey,
+		contentKey: contentKey,
+	}
+}
+
+func (o *optionEncryption) apply(fs Filesystem) Filesystem {
+	return &encryptedFilesystem{
+		Filesystem: fs,
+		nameKey:    o.nameKey,
+		contentKey: o.contentKey,
+	}
+}
+
+func (o *optionEncryption) String() string {
+	// Different keys have different effects, without us wanting to
+	// expose the keys themselves.
+	h := sha256.New()
+	h.Write(o.nameKey[:])
+	h.Write(o.contentKey[:])
+	return fmt.Sprintf("encryption-%x", h.Sum(nil)[:8])
+}
+
+// The encryptedFilesystem keeps data encrypted at rest on the underlying
+// filesystem. Each path component is encrypted separately, keeping the
+// directory structure intact, which limits plain text names to somewhat
+// over half the length the underlying filesystem allows. Files start with
+// a header holding a random file identifier, followed by the contents
+// split into chunks. Each chunk is encrypted with a random nonce and
+// authenticated together with the file identifier, its position in the
+// file and whether it's the last one, so that chunks can't be moved
+// between or within files and truncation is detected. An empty file has
+// a single empty chunk. The plain text size is implied by the size on
+// disk. Extended attributes are passed through unencrypted.
+type encryptedFilesystem struct {
+	Filesystem
+	nameKey    *[encKeySize]byte
+	contentKey *[encKeySize]byte
+}
+
+func (f *encryptedFilesystem) encryptComponent(comp string) (string, error) {
+	aead, err := miscreant.NewAEAD(encNameAlgo, f.nameKey[:], 0)
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	enc := encNameEncoding.EncodeToString(aead.Seal(nil, nil, []byte(comp), nil))
+	if len(enc) > encMaxNameComponent {
+		return "", errEncryptedNameTooLong
+	}
+	return enc, nil
+}
+
+func (f *encryptedFilesystem) decryptComponent(comp string) (string, error) {
+	bs, err := encNameEncoding.DecodeString(comp)
+	if err != nil {
+		return "", errEncryptedNameInvalid
+	}
+	aead, err := miscreant.NewAEAD(encNameAlgo, f.nameKey[:], 0)
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	dec, err := aead.Open(nil, nil, bs, nil)
+	if err != nil {
+		return "", errEncryptedNameInvalid
+	}
+	return string(dec), nil
+}
+
+// encryptPath returns the on disk name for the given plain text name.
+func (f *encryptedFilesystem) encryptPath(name string) (string, error) {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return name, nil
+	}
+	comps := strings.Split(name, string(PathSeparator))
+	for i, comp := range comps {
+		if comps[i], err = f.encryptComponent(comp); err != nil {
+			return "", &os.PathError{Op: "encrypt", Path: name, Err: err}
+		}
+	}
+	return filepath.Join(comps...), nil
+}
+
+// decryptPath is the inverse of encryptPath.
+func (f *encryptedFilesystem) decryptPath(name string) (string, error) {
+	if name == "." || name == "" {
+		return name, nil
+	}
+	comps := strings.Split(name, string(PathSeparator))
+	var err error
+	for i, comp := range comps {
+		if comps[i], err = f.decryptComponent(comp); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(comps...), nil
+}
+
+func (f *encryptedFilesystem) Chmod(name string, mode FileMode) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Chmod(enc, mode)
+}
+
+func (f *encryptedFilesystem) Lchown(name, uid, gid string) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Lchown(enc, uid, gid)
+}
+
+func (f *encryptedFilesystem) Chtimes(name string, atime, mtime time.Time) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Chtimes(enc, atime, mtime)
+}
+
+func (f *encryptedFilesystem) Create(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (f *encryptedFilesystem) CreateSymlink(target, name string) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	// The target is not necessarily a path within the folder, so it's
+	// encrypted as a whole into something that doesn't resolve at all.
+	aead, err := miscreant.NewAEAD(encNameAlgo, f.nameKey[:], 0)
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	return f.Filesystem.CreateSymlink(encNameEncoding.EncodeToString(aead.Seal(nil, nil, []byte(target), nil)), enc)
+}
+
+func (f *encryptedFilesystem) DirNames(name string) ([]string, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Filesystem.DirNames(enc)
+	if err != nil {
+		return nil, err
+	}
+	res := names[:0]
+	for _, n := range names {
+		dec, err := f.decryptComponent(n)
+		if err != nil {
+			// Something we didn't put there. It can't be represented in
+			// plain text, so pretend it doesn't exist.
+			l.Debugf("encrypted fs: skipping %s in %s: %v", n, name, err)
+			continue
+		}
+		res = append(res, dec)
+	}
+	return res, nil
+}
+
+func (f *encryptedFilesystem) Lstat(name string) (FileInfo, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Filesystem.Lstat(enc)
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptedFileInfo(info, name), nil
+}
+
+func (f *encryptedFilesystem) Mkdir(name string, perm FileMode) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Mkdir(enc, perm)
+}
+
+func (f *encryptedFilesystem) MkdirAll(name string, perm FileMode) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.MkdirAll(enc, perm)
+}
+
+func (f *encryptedFilesystem) Open(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *encryptedFilesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Partial writes require reading back the surrounding chunk, and
+	// appending is emulated as the underlying file offsets are not those
+	// of the plain text.
+	appending := flags&os.O_APPEND != 0
+	flags &^= os.O_APPEND
+	if flags&os.O_WRONLY != 0 {
+		flags = flags&^os.O_WRONLY | os.O_RDWR
+	}
+
+	fd, err := f.Filesystem.OpenFile(enc, flags, mode)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	file := &encryptedFile{
+		next: fd,
+		fs:   f,
+		name: name,
+		size: encPlainSize(info.Size()),
+	}
+	switch {
+	case info.Size() == 0 && flags&os.O_RDWR != 0:
+		// A new file, which gets its identifier and empty chunk.
+		err = file.init()
+	case info.Size() == 0:
+		// A new file being created by someone else, or truncated by
+		// someone else; there's nothing to read in either case.
+	case info.Size() < encHeaderSize+encChunkOverhead:
+		err = &os.PathError{Op: "open", Path: name, Err: errEncryptedDataInvalid}
+	default:
+		file.id = make([]byte, encFileIDSize)
+		_, err = fd.ReadAt(file.id, 0)
+	}
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if appending {
+		file.offset = file.size
+	}
+	return file, nil
+}
+
+func (f *encryptedFilesystem) ReadSymlink(name string) (string, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return "", err
+	}
+	target, err := f.Filesystem.ReadSymlink(enc)
+	if err != nil {
+		return "", err
+	}
+	bs, err := encNameEncoding.DecodeString(target)
+	if err != nil {
+		return "", errEncryptedNameInvalid
+	}
+	aead, err := miscreant.NewAEAD(encNameAlgo, f.nameKey[:], 0)
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	dec, err := aead.Open(nil, nil, bs, nil)
+	if err != nil {
+		return "", errEncryptedNameInvalid
+	}
+	return string(dec), nil
+}
+
+func (f *encryptedFilesystem) Remove(name string) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Remove(enc)
+}
+
+func (f *encryptedFilesystem) RemoveAll(name string) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.RemoveAll(enc)
+}
+
+func (f *encryptedFilesystem) Rename(oldname, newname string) error {
+	oldEnc, err := f.encryptPath(oldname)
+	if err != nil {
+		return err
+	}
+	newEnc, err := f.encryptPath(newname)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Rename(oldEnc, newEnc)
+}
+
+func (f *encryptedFilesystem) Stat(name string) (FileInfo, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Filesystem.Stat(enc)
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptedFileInfo(info, name), nil
+}
+
+func (*encryptedFilesystem) Walk(_ string, _ WalkFunc) error {
+	// Walking is provided by the walkFilesystem wrapper.
+	return errors.New("not implemented")
+}
+
+func (f *encryptedFilesystem) Watch(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, errs, err := f.Filesystem.Watch(enc, &encryptedMatcher{ignore, f}, ctx, ignorePerms)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outEvents := make(chan Event)
+	go func() {
+		defer close(outEvents)
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				dec, err := f.decryptPath(ev.Name)
+				if err != nil {
+					l.Debugf("encrypted fs: skipping event for %s: %v", ev.Name, err)
+					continue
+				}
+				ev.Name = dec
+				select {
+				case outEvents <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return outEvents, errs, nil
+}
+
+func (f *encryptedFilesystem) Hide(name string) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Hide(enc)
+}
+
+func (f *encryptedFilesystem) Unhide(name string) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.Unhide(enc)
+}
+
+func (f *encryptedFilesystem) Glob(pattern string) ([]string, error) {
+	dir := filepath.Dir(pattern)
+	file := filepath.Base(pattern)
+	names, err := f.DirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, n := range names {
+		matched, err := filepath.Match(file, n)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, n))
+		}
+	}
+	return matches, nil
+}
+
+func (f *encryptedFilesystem) Usage(name string) (Usage, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return Usage{}, err
+	}
+	return f.Filesystem.Usage(enc)
+}
+
+func (f *encryptedFilesystem) SameFile(fi1, fi2 FileInfo) bool {
+	if e1, ok := fi1.(encryptedFileInfo); ok {
+		fi1 = e1.FileInfo
+	}
+	if e2, ok := fi2.(encryptedFileInfo); ok {
+		fi2 = e2.FileInfo
+	}
+	return f.Filesystem.SameFile(fi1, fi2)
+}
+
+func (f *encryptedFilesystem) PlatformData(name string, withOwnership, withXattrs bool, xattrFilter XattrFilter) (protocol.PlatformData, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return protocol.PlatformData{}, err
+	}
+	return f.Filesystem.PlatformData(enc, withOwnership, withXattrs, xattrFilter)
+}
+
+func (f *encryptedFilesystem) GetXattr(name string, xattrFilter XattrFilter) ([]protocol.Xattr, error) {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Filesystem.GetXattr(enc, xattrFilter)
+}
+
+func (f *encryptedFilesystem) SetXattr(name string, xattrs []protocol.Xattr, xattrFilter XattrFilter) error {
+	enc, err := f.encryptPath(name)
+	if err != nil {
+		return err
+	}
+	return f.Filesystem.SetXattr(enc, xattrs, xattrFilter)
+}
+
+func (f *encryptedFilesystem) underlying() (Filesystem, bool) {
+	return f.Filesystem, true
+}
+
+func (*encryptedFilesystem) wrapperType() filesystemWrapperType {
+	return filesystemWrapperTypeEncryption
+}
+
+// encryptedMatcher lets the underlying watcher match encrypted names
+// against plain text patterns.
+type encryptedMatcher struct {
+	next Matcher
+	fs   *encryptedFilesystem
+}
+
+func (m *encryptedMatcher) Match(name string) ignoreresult.R {
+	dec, err := m.fs.decryptPath(name)
+	if err != nil {
+		return ignoreresult.NotIgnored
+	}
+	return m.next.Match(dec)
+}
+
+// encryptedFileInfo presents the plain text name and size.
+type encryptedFileInfo struct {
+	FileInfo
+	name string
+	size int64
+}
+
+func newEncryptedFileInfo(info FileInfo, name string) encryptedFileInfo {
+	size := info.Size()
+	if info.IsRegular() {
+		size = encPlainSize(size)
+	}
+	return encryptedFileInfo{
+		FileInfo: info,
+		name:     filepath.Base(name),
+		size:     size,
+	}
+}
+
+func (e encryptedFileInfo) Name() string {
+	return e.name
+}
+
+func (e encryptedFileInfo) Size() int64 {
+	return e.size
+}
+
+// encryptedFile is an open file in an encryptedFilesystem. All operations
+// are serialised, as writes need to read and rewrite whole chunks.
+//
+// It intentionally does not provide unwrap(), so that copy range
+// optimisations never operate on the encrypted data directly.
+type encryptedFile struct {
+	next   File
+	fs     *encryptedFilesystem
+	name   string
+	id     []byte // from the header
+	mut    sync.Mutex
+	size   int64 // plain text
+	offset int64 // plain text
+}
+
+// encPlainSize returns the plain text size for a given encrypted size.
+func encPlainSize(diskSize int64) int64 {
+	diskSize -= encHeaderSize
+	if diskSize <= 0 {
+		return 0
+	}
+	size := diskSize / encDiskChunkSize * encChunkSize
+	if rem := diskSize % encDiskChunkSize; rem > encChunkOverhead {
+		size += rem - encChunkOverhead
+	}
+	return size
+}
+
+// encDiskSize returns the encrypted size for a given plain text size.
+func encDiskSize(size int64) int64 {
+	if size == 0 {
+		return encHeaderSize + encChunkOverhead
+	}
+	diskSize := encHeaderSize + size/encChunkSize*encDiskChunkSize
+	if rem := size % encChunkSize; rem > 0 {
+		diskSize += rem + encChunkOverhead
+	}
+	return diskSize
+}
+
+// encLastChunk returns the index of the last chunk of a file of the given
+// plain text size.
+func encLastChunk(size int64) int64 {
+	if size == 0 {
+		return 0
+	}
+	return (size - 1) / encChunkSize
+}
+
+// aead returns the cipher and the additional data for the given chunk.
+func (f *encryptedFile) aead(idx int64, last bool) (cipher.AEAD, []byte) {
+	aead, err := chacha20poly1305.NewX(f.fs.contentKey[:])
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	ad := make([]byte, 0, encFileIDSize+9)
+	ad = append(ad, f.id...)
+	ad = binary.BigEndian.AppendUint64(ad, uint64(idx))
+	if last {
+		ad = append(ad, 1)
+	} else {
+		ad = append(ad, 0)
+	}
+	return aead, ad
+}
+
+// init writes the header with a new file identifier and the empty chunk
+// of an empty file.
+func (f *encryptedFile) init() error {
+	f.id = make([]byte, encFileIDSize)
+	if _, err := rand.Read(f.id); err != nil {
+		panic("catastrophic randomness failure: " + err.Error())
+	}
+	if _, err := f.next.WriteAt(f.id, 0); err != nil {
+		return err
+	}
+	f.size = 0
+	return f.writeChunk(0, nil, true)
+}
+
+// readChunk returns the plain text of the given chunk.
+func (f *encryptedFile) readChunk(idx int64) ([]byte, error) {
+	start := idx * encChunkSize
+	if start >= f.size {
+		return nil, nil
+	}
+	plainLen := f.size - start
+	if plainLen > encChunkSize {
+		plainLen = encChunkSize
+	}
+	buf := make([]byte, plainLen+encChunkOverhead)
+	if _, err := f.next.ReadAt(buf, encHeaderSize+idx*encDiskChunkSize); err != nil {
+		return nil, err
+	}
+	aead, ad := f.aead(idx, idx == encLastChunk(f.size))
+	plain, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: f.name, Err: errEncryptedDataInvalid}
+	}
+	return plain, nil
+}
+
+// writeChunk encrypts and writes the plain text of the given chunk, which
+// is the last one of the file or not.
+func (f *encryptedFile) writeChunk(idx int64, plain []byte, last bool) error {
+	aead, ad := f.aead(idx, last)
+	nonce := make([]byte, aead.NonceSize(), encDiskChunkSize)
+	if _, err := rand.Read(nonce); err != nil {
+		panic("catastrophic randomness failure: " + err.Error())
+	}
+	_, err := f.next.WriteAt(aead.Seal(nonce, nonce, plain, ad), encHeaderSize+idx*encDiskChunkSize)
+	return err
+}
+
+func (f *encryptedFile) Close() error {
+	return f.next.Close()
+}
+
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.readAt(p, off)
+}
+
+func (f *encryptedFile) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	var n int
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.size {
+			return n, io.EOF
+		}
+		plain, err := f.readChunk(pos / encChunkSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], plain[pos%encChunkSize:])
+	}
+	return n, nil
+}
+
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return f.offset, errors.New("seek before start")
+	}
+	f.offset = offset
+	return f.offset, nil
+}
+
+func (f *encryptedFile) Write(p []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *encryptedFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.writeAt(p, off)
+}
+
+func (f *encryptedFile) writeAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if f.id == nil {
+		if err := f.init(); err != nil {
+			return 0, err
+		}
+	}
+	if off > f.size {
+		// Holes would not be valid encrypted data, so fill the gap with
+		// encrypted zeroes first.
+		if err := f.extend(off); err != nil {
+			return 0, err
+		}
+	}
+
+	// Chunks are read with the current size and written with the new
+	// one, which decides which one is the last. When that moves to a
+	// chunk after the current last one and this write doesn't cover the
+	// latter, it needs to be rewritten as not being the last any more.
+	oldSize := f.size
+	newSize := max(f.size, off+int64(len(p)))
+	oldLast, newLast := encLastChunk(oldSize), encLastChunk(newSize)
+	if newLast > oldLast && off/encChunkSize > oldLast {
+		plain, err := f.readChunk(oldLast)
+		if err != nil {
+			return 0, err
+		}
+		if err := f.writeChunk(oldLast, plain, false); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	for n < len(p) {
+		pos := off + int64(n)
+		idx := pos / encChunkSize
+		inChunk := pos % encChunkSize
+		toWrite := len(p) - n
+		if max := encChunkSize - int(inChunk); toWrite > max {
+			toWrite = max
+		}
+
+		var plain []byte
+		if inChunk != 0 || (toWrite < encChunkSize && pos+int64(toWrite) < oldSize) {
+			// Partial chunk overwrite; merge with what's there.
+			existing, err := f.readChunk(idx)
+			if err != nil {
+				return n, err
+			}
+			plain = existing
+		}
+		if need := int(inChunk) + toWrite; len(plain) < need {
+			plain = append(plain, make([]byte, need-len(plain))...)
+		}
+		copy(plain[inChunk:], p[n:n+toWrite])
+
+		if err := f.writeChunk(idx, plain, idx == newLast); err != nil {
+			return n, err
+		}
+		n += toWrite
+	}
+	f.size = newSize
+	return n, nil
+}
+
+// extend grows the file to the given size, padding with zeroes. It's done
+// in batches of chunks to limit both the memory used and the number of
+// times the last chunk is rewritten.
+func (f *encryptedFile) extend(size int64) error {
+	zeroes := make([]byte, encExtendBatch*encChunkSize)
+	for f.size < size {
+		n := size - f.size
+		if rem := int64(len(zeroes)) - f.size%encChunkSize; n > rem {
+			n = rem
+		}
+		if _, err := f.writeAt(zeroes[:n], f.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *encryptedFile) Name() string {
+	return f.name
+}
+
+func (f *encryptedFile) Truncate(size int64) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if size > f.size {
+		return f.extend(size)
+	}
+	if size == f.size {
+		return nil
+	}
+	// The new last chunk needs to be rewritten with its new length, and
+	// as being the last one.
+	idx := encLastChunk(size)
+	plain, err := f.readChunk(idx)
+	if err != nil {
+		return err
+	}
+	if err := f.writeChunk(idx, plain[:size-idx*encChunkSize], true); err != nil {
+		return err
+	}
+	if err := f.next.Truncate(encDiskSize(size)); err != nil {
+		return err
+	}
+	f.size = size
+	return nil
+}
+
+func (f *encryptedFile) Stat() (FileInfo, error) {
+	info, err := f.next.Stat()
+	if err != nil {
+		return nil, err
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return encryptedFileInfo{
+		FileInfo: info,
+		name:     filepath.Base(f.name),
+		size:     f.size,
+	}, nil
+}
+
+func (f *encryptedFile) Sync() error {
+	return f.next.Sync()
+}
@@ -0,0 +1,296 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+func newTestEncryptedFilesystem(t *testing.T, dir string, key byte) Filesystem {
+	t.Helper()
+	var nameKey, contentKey [32]byte
+	nameKey[0] = key
+	contentKey[0] = key + 1
+	return NewFilesystem(FilesystemTypeBasic, dir, NewEncryptionOption(&nameKey, &contentKey))
+}
+
+func readEncryptedFile(fs Filesystem, name string) ([]byte, error) {
+	fd, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return io.ReadAll(fd)
+}
+
+func TestEncryptedFS(t *testing.T) {
+	dir := t.TempDir()
+	fs := newTestEncryptedFilesystem(t, dir, 1)
+
+	if err := fs.MkdirAll("dira/dirb", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 3*encChunkSize+1234)
+	rand.Read(data)
+	if err := WriteFile(fs, "dira/dirb/secret", data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing in plain text on disk
+	var diskNames []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		diskNames = append(diskNames, rel)
+		if info.Mode().IsRegular() {
+			bs, _ := os.ReadFile(path)
+			if bytes.Contains(bs, data[:64]) {
+				t.Error("plain text data on disk in", rel)
+			}
+			if info.Size() != encDiskSize(int64(len(data))) {
+				t.Errorf("unexpected size on disk %d", info.Size())
+			}
+		}
+		return nil
+	})
+	if len(diskNames) != 4 {
+		t.Fatal("unexpected files on disk:", diskNames)
+	}
+	for _, n := range diskNames {
+		if strings.Contains(n, "dir") || strings.Contains(n, "secret") {
+			t.Error("plain text name on disk:", n)
+		}
+	}
+
+	info, err := fs.Lstat("dira/dirb/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "secret" || info.Size() != int64(len(data)) {
+		t.Errorf("unexpected file info: name=%q size=%d", info.Name(), info.Size())
+	}
+	fd, err := fs.Open("dira/dirb/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, data) {
+		t.Error("data mismatch after roundtrip")
+	}
+
+	var seen []string
+	if err := fs.Walk(".", func(path string, _ FileInfo, err error) error {
+		seen = append(seen, filepath.ToSlash(path))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(seen)
+	if strings.Join(seen, " ") != ". dira dira/dirb dira/dirb/secret" {
+		t.Error("unexpected walk:", seen)
+	}
+
+	if err := fs.Rename("dira/dirb/secret", "dira/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("dira/dirb/secret"); !IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+	matches, err := fs.Glob("dira/m*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || filepath.ToSlash(matches[0]) != "dira/moved" {
+		t.Error("unexpected glob result:", matches)
+	}
+
+	if err := fs.CreateSymlink("../somewhere", "dira/link"); err == nil {
+		target, err := fs.ReadSymlink("dira/link")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != "../somewhere" {
+			t.Error("unexpected symlink target:", target)
+		}
+	}
+
+	// A different key sees nothing and can't read anything
+	other := newTestEncryptedFilesystem(t, dir, 2)
+	names, err := other.DirNames(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Error("unexpected names with wrong key:", names)
+	}
+}
+
+func TestEncryptedFSPartialWrites(t *testing.T) {
+	fs := newTestEncryptedFilesystem(t, t.TempDir(), 1)
+
+	fd, err := fs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	var expected []byte
+	writeAt := func(p []byte, off int) {
+		t.Helper()
+		if _, err := fd.WriteAt(p, int64(off)); err != nil {
+			t.Fatal(err)
+		}
+		if end := off + len(p); end > len(expected) {
+			expected = append(expected, make([]byte, end-len(expected))...)
+		}
+		copy(expected[off:], p)
+	}
+	check := func() {
+		t.Helper()
+		info, err := fd.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(expected)) {
+			t.Fatalf("size %d != expected %d", info.Size(), len(expected))
+		}
+		bs := make([]byte, len(expected))
+		if _, err := fd.ReadAt(bs, 0); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bs, expected) {
+			t.Fatal("data mismatch")
+		}
+		// What's on disk checks out too.
+		bs, err = readEncryptedFile(fs, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bs, expected) {
+			t.Fatal("data mismatch on disk")
+		}
+	}
+
+	// Write past the end, leaving a gap
+	writeAt([]byte("hello"), encChunkSize+100)
+	check()
+	// Straddle a chunk boundary
+	writeAt(bytes.Repeat([]byte("x"), 200), encChunkSize-100)
+	check()
+	// Overwrite in the middle of a chunk
+	writeAt([]byte("abc"), 10)
+	check()
+
+	if err := fd.Truncate(encChunkSize + 50); err != nil {
+		t.Fatal(err)
+	}
+	expected = expected[:encChunkSize+50]
+	check()
+	if err := fd.Truncate(2*encChunkSize + 7); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, make([]byte, encChunkSize-43)...)
+	check()
+
+	// Sequential writes continue where the seek put us
+	if _, err := fd.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("tail")); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, "tail"...)
+	check()
+
+	// Grow exactly to and past chunk boundaries, then shrink back
+	writeAt(make([]byte, 3*encChunkSize-len(expected)), len(expected))
+	check()
+	writeAt([]byte("more"), 3*encChunkSize)
+	check()
+	if err := fd.Truncate(2 * encChunkSize); err != nil {
+		t.Fatal(err)
+	}
+	expected = expected[:2*encChunkSize]
+	check()
+	if err := fd.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	expected = expected[:0]
+	check()
+
+	// Extend by more than one batch of chunks at a time
+	writeAt([]byte("far"), (encExtendBatch+3)*encChunkSize+5)
+	check()
+}
+
+func TestEncryptedFSTampering(t *testing.T) {
+	dir := t.TempDir()
+	var nameKey, contentKey [32]byte
+	nameKey[0] = 1
+	contentKey[0] = 2
+	fs := NewFilesystem(FilesystemTypeBasic, dir, NewEncryptionOption(&nameKey, &contentKey))
+	names := &encryptedFilesystem{nameKey: &nameKey}
+	diskPath := func(name string) string {
+		enc, err := names.encryptPath(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filepath.Join(dir, enc)
+	}
+
+	data := make([]byte, 2*encChunkSize)
+	rand.Read(data)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := WriteFile(fs, name, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A chunk taken from another file, at the same position
+	a, _ := os.ReadFile(diskPath("a"))
+	b, _ := os.ReadFile(diskPath("b"))
+	copy(b[encHeaderSize:], a[encHeaderSize:encHeaderSize+encDiskChunkSize])
+	if err := os.WriteFile(diskPath("b"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEncryptedFile(fs, "b"); err == nil {
+		t.Error("expected an error reading a file with a chunk from another file")
+	}
+
+	// Truncated at a chunk boundary
+	if err := os.Truncate(diskPath("c"), encHeaderSize+encDiskChunkSize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEncryptedFile(fs, "c"); err == nil {
+		t.Error("expected an error reading a truncated file")
+	}
+
+	// Unharmed
+	if bs, err := readEncryptedFile(fs, "a"); err != nil || !bytes.Equal(bs, data) {
+		t.Error("unexpected result reading an intact file:", err)
+	}
+}
+
+func TestEncryptedFSLongName(t *testing.T) {
+	fs := newTestEncryptedFilesystem(t, t.TempDir(), 1)
+	if err := fs.Mkdir(strings.Repeat("a", 200), 0o755); err == nil {
+		t.Error("expected error for name too long to encrypt")
+	}
+}
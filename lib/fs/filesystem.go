@@ -11,6 +11,7 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,6 +31,7 @@ const (
 	filesystemWrapperTypeWalk
 	filesystemWrapperTypeLog
 	filesystemWrapperTypeMetrics
+	filesystemWrapperTypeEncryption
 )
 
 type XattrFilter interface {
@@ -226,12 +228,33 @@ type Option interface {
 	apply(Filesystem) Filesystem
 }
 
+// RedactURI returns the URI with the password and any secret parameters
+// replaced, suitable for logging.
+func RedactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "<unparseable URI>"
+	}
+	if u.RawQuery != "" {
+		params := u.Query()
+		for key := range params {
+			switch strings.ToLower(key) {
+			case "password", "secret", "secretkey", "secret_key", "token", "sessiontoken", "session_token":
+				params.Set(key, "xxxxx")
+			}
+		}
+		u.RawQuery = params.Encode()
+	}
+	return u.Redacted()
+}
+
 func NewFilesystem(fsType FilesystemType, uri string, opts ...Option) Filesystem {
 	var caseOpt Option
 	var mtimeOpt Option
+	var encOpt Option
 	i := 0
 	for _, opt := range opts {
-		if caseOpt != nil && mtimeOpt != nil {
+		if caseOpt != nil && mtimeOpt != nil && encOpt != nil {
 			break
 		}
 		switch opt.(type) {
@@ -239,6 +262,8 @@ func NewFilesystem(fsType FilesystemType, uri string, opts ...Option) Filesystem
 			caseOpt = opt
 		case *optionMtime:
 			mtimeOpt = opt
+		case *optionEncryption:
+			encOpt = opt
 		default:
 			opts[i] = opt
 			i++
@@ -252,15 +277,43 @@ func NewFilesystem(fsType FilesystemType, uri string, opts ...Option) Filesystem
 		fs = newBasicFilesystem(uri, opts...)
 	case FilesystemTypeFake:
 		fs = newFakeFilesystem(uri, opts...)
+	case FilesystemTypeS3:
+		if s3fs, err := newS3Filesystem(uri); err != nil {
+			l.Debugln("Invalid S3 filesystem", RedactURI(uri), err)
+			fs = &errorFilesystem{
+				fsType: fsType,
+				uri:    RedactURI(uri),
+				err:    err,
+			}
+		} else {
+			fs = s3fs
+		}
+	case FilesystemTypeSFTP:
+		if sftpfs, err := newSFTPFilesystem(uri); err != nil {
+			l.Debugln("Invalid SFTP filesystem", RedactURI(uri), err)
+			fs = &errorFilesystem{
+				fsType: fsType,
+				uri:    RedactURI(uri),
+				err:    err,
+			}
+		} else {
+			fs = sftpfs
+		}
 	default:
-		l.Debugln("Unknown filesystem", fsType, uri)
+		l.Debugln("Unknown filesystem", fsType, RedactURI(uri))
 		fs = &errorFilesystem{
 			fsType: fsType,
-			uri:    uri,
+			uri:    RedactURI(uri),
 			err:    errors.New("filesystem with type " + fsType.String() + " does not exist."),
 		}
 	}
 
+	// Encryption is the innermost layer, everything above deals in plain
+	// text names and data.
+	if encOpt != nil {
+		fs = encOpt.apply(fs)
+	}
+
 	// mtime handling should happen inside walking, as filesystem calls while
 	// walking should be mtime-resolved too
 	if mtimeOpt != nil {
@@ -0,0 +1,871 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/s3"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3MetaMtime     = "mtime"
+	s3MetaMode      = "mode"
+)
+
+var (
+	errS3NotSupported      = errors.New("operation not supported on S3 filesystems")
+	errS3NotDirectory      = errors.New("not a directory")
+	errS3DirectoryNotEmpty = errors.New("directory not empty")
+	errS3FileReadOnly      = errors.New("file not opened for writing")
+	errS3InvalidURI        = errors.New("invalid S3 URI, expected http(s)://[key:secret@]host[:port]/bucket[/prefix]")
+)
+
+// s3Filesystem stores files as objects in an S3-compatible bucket. The
+// root URI has the form
+//
+//	http(s)://[accesskey:secret@]host[:port]/bucket[/prefix][?params]
+//
+// where the optional parameters are
+//
+//	region=name     the bucket region (default us-east-1)
+//	pathstyle=false use virtual host style bucket addressing (default true)
+//
+// If the URI contains no credentials, they are taken from the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.
+//
+// Object storage has no notion of directories, so those are represented
+// by empty objects with a trailing slash in their key; directories that
+// only exist implicitly, as a prefix of some other key, are also
+// recognised. Modification times and permissions are kept in the object
+// metadata. Objects cannot be partially written, so files opened for
+// writing are buffered in a local temporary file and uploaded when synced
+// or closed. Symlinks, ownership, extended attributes and watching are
+// not supported.
+type s3Filesystem struct {
+	uri    string
+	prefix string
+	sess   *s3.Session
+}
+
+func newS3Filesystem(rootURI string) (*s3Filesystem, error) {
+	uri, err := url.Parse(rootURI)
+	if err != nil {
+		return nil, err
+	}
+	if uri.Scheme != "http" && uri.Scheme != "https" || uri.Host == "" {
+		return nil, errS3InvalidURI
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(uri.Path, "/"), "/")
+	if bucket == "" {
+		return nil, errS3InvalidURI
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	params := uri.Query()
+	region := params.Get("region")
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	var opts []s3.Option
+	if params.Get("pathstyle") != "false" {
+		opts = append(opts, s3.WithPathStyle())
+	}
+
+	accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if uri.User != nil {
+		accessKeyID = uri.User.Username()
+		secretKey, _ = uri.User.Password()
+	}
+
+	endpoint := (&url.URL{Scheme: uri.Scheme, Host: uri.Host}).String()
+	sess, err := s3.NewSession(endpoint, region, bucket, accessKeyID, secretKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never keep the credentials in the URI we present to the outside.
+	uri.User = nil
+	return &s3Filesystem{
+		uri:    uri.String(),
+		prefix: prefix,
+		sess:   sess,
+	}, nil
+}
+
+// key returns the object key for the given name. The root directory maps
+// to the prefix itself.
+func (f *s3Filesystem) key(name string) (string, error) {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return f.prefix, nil
+	}
+	return f.prefix + filepath.ToSlash(name), nil
+}
+
+func (f *s3Filesystem) Chmod(name string, mode FileMode) error {
+	return f.updateMeta(name, func(meta map[string]string) {
+		meta[s3MetaMode] = strconv.FormatUint(uint64(mode&ModePerm), 8)
+	})
+}
+
+func (*s3Filesystem) Lchown(_, _, _ string) error {
+	// Ownership is meaningless for objects.
+	return nil
+}
+
+func (f *s3Filesystem) Chtimes(name string, _ time.Time, mtime time.Time) error {
+	return f.updateMeta(name, func(meta map[string]string) {
+		meta[s3MetaMtime] = strconv.FormatInt(mtime.UnixNano(), 10)
+	})
+}
+
+// updateMeta rewrites the metadata of the object (or directory marker)
+// for name in place, by way of a server side copy.
+func (f *s3Filesystem) updateMeta(name string, fn func(map[string]string)) error {
+	info, err := f.lstat(name)
+	if err != nil {
+		return err
+	}
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if key == f.prefix {
+		// The root has no marker and nothing to update.
+		return nil
+	}
+	if info.dir {
+		key += "/"
+		if info.implicit {
+			meta := info.meta()
+			fn(meta)
+			return f.sess.Put(bytes.NewReader(nil), key, meta)
+		}
+	}
+	meta := info.meta()
+	fn(meta)
+	return f.sess.Copy(key, key, meta)
+}
+
+func (f *s3Filesystem) Create(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (*s3Filesystem) CreateSymlink(_, _ string) error {
+	return errS3NotSupported
+}
+
+func (f *s3Filesystem) DirNames(name string) ([]string, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+	dirKey := key
+	if key != f.prefix {
+		dirKey += "/"
+	}
+
+	objects, prefixes, err := f.sess.ListDir(dirKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 && len(prefixes) == 0 {
+		// Distinguish an empty directory from a nonexistent one.
+		info, err := f.lstat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.dir {
+			return nil, errS3NotDirectory
+		}
+	}
+
+	names := make([]string, 0, len(objects)+len(prefixes))
+	for _, obj := range objects {
+		child := strings.TrimPrefix(*obj.Key, dirKey)
+		if child == "" {
+			// The directory marker itself
+			continue
+		}
+		names = append(names, child)
+	}
+	for _, p := range prefixes {
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(p, dirKey), "/"))
+	}
+	return names, nil
+}
+
+func (f *s3Filesystem) Lstat(name string) (FileInfo, error) {
+	info, err := f.lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (f *s3Filesystem) lstat(name string) (*s3FileInfo, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(name)
+	if key == f.prefix {
+		// The root always exists, as far as we can tell. If the bucket
+		// doesn't, we'll find out soon enough when looking for the marker.
+		return &s3FileInfo{name: base, dir: true, mode: 0o777}, nil
+	}
+
+	if obj, err := f.sess.Stat(key); err == nil {
+		return newS3FileInfo(base, obj, false), nil
+	} else if !s3.IsNotFound(err) {
+		return nil, err
+	}
+
+	if obj, err := f.sess.Stat(key + "/"); err == nil {
+		return newS3FileInfo(base, obj, true), nil
+	} else if !s3.IsNotFound(err) {
+		return nil, err
+	}
+
+	// The directory may still exist implicitly, without a marker.
+	implicit := false
+	if err := f.sess.ListPrefix(key+"/", func(*s3.Object) bool {
+		implicit = true
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	if implicit {
+		return &s3FileInfo{name: base, dir: true, implicit: true, mode: 0o777}, nil
+	}
+
+	return nil, &os.PathError{Op: "lstat", Path: name, Err: ErrNotExist}
+}
+
+func (f *s3Filesystem) Mkdir(name string, perm FileMode) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if key == f.prefix {
+		return &os.PathError{Op: "mkdir", Path: name, Err: ErrExist}
+	}
+	if parent := filepath.Dir(name); parent != "." {
+		info, err := f.lstat(parent)
+		if err != nil {
+			return err
+		}
+		if !info.dir {
+			return errS3NotDirectory
+		}
+	}
+	if _, err := f.lstat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: ErrExist}
+	} else if !IsNotExist(err) {
+		return err
+	}
+	return f.putDirMarker(key, perm)
+}
+
+func (f *s3Filesystem) MkdirAll(name string, perm FileMode) error {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return nil
+	}
+	var cur string
+	for _, comp := range strings.Split(filepath.ToSlash(name), "/") {
+		cur = path.Join(cur, comp)
+		info, err := f.lstat(cur)
+		if err == nil {
+			if !info.dir {
+				return errS3NotDirectory
+			}
+			continue
+		}
+		if !IsNotExist(err) {
+			return err
+		}
+		if err := f.putDirMarker(f.prefix+cur, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *s3Filesystem) putDirMarker(key string, perm FileMode) error {
+	meta := map[string]string{
+		s3MetaMtime: strconv.FormatInt(time.Now().UnixNano(), 10),
+		s3MetaMode:  strconv.FormatUint(uint64(perm&ModePerm), 8),
+	}
+	return f.sess.Put(bytes.NewReader(nil), key+"/", meta)
+}
+
+func (f *s3Filesystem) Open(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *s3Filesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.lstat(name)
+	switch {
+	case err == nil && flags&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrExist}
+	case err == nil:
+	case IsNotExist(err) && flags&os.O_CREATE != 0:
+		info = nil
+	default:
+		return nil, err
+	}
+
+	file := &s3File{fs: f, name: name, key: key, info: info}
+	if info != nil && info.dir {
+		if flags&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		}
+		return file, nil
+	}
+	if flags&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return file, nil
+	}
+
+	// Writable files are buffered locally and uploaded in full when
+	// synced or closed.
+	file.local, err = os.CreateTemp("", "syncthing-s3-*")
+	if err != nil {
+		return nil, err
+	}
+	file.mode = mode & ModePerm
+	switch {
+	case info == nil || flags&os.O_TRUNC != 0:
+		file.dirty = true
+		if info != nil {
+			file.mode = info.mode
+		}
+	default:
+		file.mode = info.mode
+		if err := file.download(); err != nil {
+			file.discard()
+			return nil, err
+		}
+	}
+	if flags&os.O_APPEND != 0 {
+		if _, err := file.local.Seek(0, io.SeekEnd); err != nil {
+			file.discard()
+			return nil, err
+		}
+	}
+	if file.dirty {
+		// Make the file visible right away, as it would be on a
+		// regular filesystem.
+		if err := file.Sync(); err != nil {
+			file.discard()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (*s3Filesystem) ReadSymlink(_ string) (string, error) {
+	return "", errS3NotSupported
+}
+
+func (f *s3Filesystem) Remove(name string) error {
+	info, err := f.lstat(name)
+	if err != nil {
+		return err
+	}
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if !info.dir {
+		return f.sess.Delete(key)
+	}
+	if key == f.prefix {
+		return errS3NotSupported
+	}
+	children, err := f.DirNames(name)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: errS3DirectoryNotEmpty}
+	}
+	return f.sess.Delete(key + "/")
+}
+
+func (f *s3Filesystem) RemoveAll(name string) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if key == f.prefix {
+		return errS3NotSupported
+	}
+	var keys []string
+	if err := f.sess.ListPrefix(key+"/", func(obj *s3.Object) bool {
+		keys = append(keys, *obj.Key)
+		return true
+	}); err != nil {
+		return err
+	}
+	keys = append(keys, key)
+	for _, k := range keys {
+		if err := f.sess.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *s3Filesystem) Rename(oldname, newname string) error {
+	info, err := f.lstat(oldname)
+	if err != nil {
+		return err
+	}
+	oldKey, err := f.key(oldname)
+	if err != nil {
+		return err
+	}
+	newKey, err := f.key(newname)
+	if err != nil {
+		return err
+	}
+	if oldKey == f.prefix || newKey == f.prefix {
+		return errS3NotSupported
+	}
+	if parent := filepath.Dir(newname); parent != "." {
+		if _, err := f.lstat(parent); err != nil {
+			return err
+		}
+	}
+	if dst, err := f.lstat(newname); err == nil && dst.dir {
+		return &os.PathError{Op: "rename", Path: newname, Err: errors.New("is a directory")}
+	}
+
+	if !info.dir {
+		if err := f.sess.Copy(oldKey, newKey, info.meta()); err != nil {
+			return err
+		}
+		return f.sess.Delete(oldKey)
+	}
+
+	// Directories are renamed by moving every object below them, marker
+	// included.
+	var keys []string
+	if err := f.sess.ListPrefix(oldKey+"/", func(obj *s3.Object) bool {
+		keys = append(keys, *obj.Key)
+		return true
+	}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		obj, err := f.sess.Stat(k)
+		if err != nil {
+			return err
+		}
+		dst := newKey + strings.TrimPrefix(k, oldKey)
+		if err := f.sess.Copy(k, dst, obj.Metadata); err != nil {
+			return err
+		}
+		if err := f.sess.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *s3Filesystem) Stat(name string) (FileInfo, error) {
+	return f.Lstat(name)
+}
+
+func (*s3Filesystem) SymlinksSupported() bool {
+	return false
+}
+
+func (*s3Filesystem) Walk(_ string, _ WalkFunc) error {
+	// Walking is provided by the walkFilesystem wrapper.
+	return errors.New("not implemented")
+}
+
+func (*s3Filesystem) Watch(_ string, _ Matcher, _ context.Context, _ bool) (<-chan Event, <-chan error, error) {
+	return nil, nil, ErrWatchNotSupported
+}
+
+func (*s3Filesystem) Hide(_ string) error {
+	return nil
+}
+
+func (*s3Filesystem) Unhide(_ string) error {
+	return nil
+}
+
+func (f *s3Filesystem) Glob(pattern string) ([]string, error) {
+	dir := filepath.Dir(pattern)
+	file := filepath.Base(pattern)
+	names, err := f.DirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, n := range names {
+		matched, err := filepath.Match(file, n)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, n))
+		}
+	}
+	return matches, nil
+}
+
+func (*s3Filesystem) Roots() ([]string, error) {
+	return []string{"/"}, nil
+}
+
+func (*s3Filesystem) Usage(_ string) (Usage, error) {
+	return Usage{}, errS3NotSupported
+}
+
+func (*s3Filesystem) Type() FilesystemType {
+	return FilesystemTypeS3
+}
+
+func (f *s3Filesystem) URI() string {
+	return f.uri
+}
+
+func (*s3Filesystem) Options() []Option {
+	return nil
+}
+
+func (*s3Filesystem) SameFile(fi1, fi2 FileInfo) bool {
+	return fi1.Name() == fi2.Name() && fi1.Size() == fi2.Size() && fi1.ModTime().Equal(fi2.ModTime()) && fi1.IsDir() == fi2.IsDir()
+}
+
+func (*s3Filesystem) PlatformData(_ string, _, _ bool, _ XattrFilter) (protocol.PlatformData, error) {
+	return protocol.PlatformData{}, nil
+}
+
+func (*s3Filesystem) GetXattr(_ string, _ XattrFilter) ([]protocol.Xattr, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (*s3Filesystem) SetXattr(_ string, _ []protocol.Xattr, _ XattrFilter) error {
+	return ErrXattrsNotSupported
+}
+
+func (*s3Filesystem) underlying() (Filesystem, bool) {
+	return nil, false
+}
+
+func (*s3Filesystem) wrapperType() filesystemWrapperType {
+	return filesystemWrapperTypeNone
+}
+
+// s3File is an open object. Files opened read only are read directly from
+// the bucket using ranged requests, writable files are backed by a local
+// temporary file.
+type s3File struct {
+	fs     *s3Filesystem
+	name   string
+	key    string
+	info   *s3FileInfo // nil for newly created files
+	mode   FileMode
+	mut    sync.Mutex
+	offset int64
+	local  *os.File
+	dirty  bool
+}
+
+func (f *s3File) download() error {
+	r, err := f.fs.sess.ReadRange(f.key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, err := io.Copy(f.local, r); err != nil {
+		return err
+	}
+	_, err = f.local.Seek(0, io.SeekStart)
+	return err
+}
+
+func (f *s3File) discard() {
+	f.local.Close()
+	os.Remove(f.local.Name())
+}
+
+func (f *s3File) Close() error {
+	if f.local == nil {
+		return nil
+	}
+	err := f.Sync()
+	f.discard()
+	return err
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.local != nil {
+		f.mut.Lock()
+		defer f.mut.Unlock()
+		return f.local.Read(p)
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if f.local != nil {
+		return f.local.ReadAt(p, off)
+	}
+	n, err := f.readAt(p, off)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *s3File) readAt(p []byte, off int64) (int, error) {
+	if f.info.dir {
+		return 0, errors.New("is a directory")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= f.info.size {
+		return 0, io.EOF
+	}
+	length := int64(len(p))
+	if off+length > f.info.size {
+		length = f.info.size - off
+	}
+	r, err := f.fs.sess.ReadRange(f.key, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	n, err := io.ReadFull(r, p[:length])
+	if err == nil && int(length) < len(p) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.local != nil {
+		return f.local.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return f.offset, errors.New("seek before start")
+	}
+	f.offset = offset
+	return f.offset, nil
+}
+
+func (f *s3File) Write(p []byte) (int, error) {
+	if f.local == nil {
+		return 0, errS3FileReadOnly
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.dirty = true
+	return f.local.Write(p)
+}
+
+func (f *s3File) WriteAt(p []byte, off int64) (int, error) {
+	if f.local == nil {
+		return 0, errS3FileReadOnly
+	}
+	f.mut.Lock()
+	f.dirty = true
+	f.mut.Unlock()
+	return f.local.WriteAt(p, off)
+}
+
+func (f *s3File) Name() string {
+	return f.name
+}
+
+func (f *s3File) Truncate(size int64) error {
+	if f.local == nil {
+		return errS3FileReadOnly
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.dirty = true
+	return f.local.Truncate(size)
+}
+
+func (f *s3File) Stat() (FileInfo, error) {
+	if f.local == nil {
+		return f.info, nil
+	}
+	fi, err := f.local.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &s3FileInfo{
+		name:  filepath.Base(f.name),
+		size:  fi.Size(),
+		mode:  f.mode,
+		mtime: fi.ModTime(),
+	}, nil
+}
+
+// Sync uploads the local contents, if they have changed. This is the only
+// point at which data written to the file becomes visible in the bucket.
+func (f *s3File) Sync() error {
+	if f.local == nil {
+		return nil
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if !f.dirty {
+		return nil
+	}
+	meta := map[string]string{
+		s3MetaMtime: strconv.FormatInt(time.Now().UnixNano(), 10),
+		s3MetaMode:  strconv.FormatUint(uint64(f.mode), 8),
+	}
+	fi, err := f.local.Stat()
+	if err != nil {
+		return err
+	}
+	if err := f.fs.sess.Put(io.NewSectionReader(f.local, 0, fi.Size()), f.key, meta); err != nil {
+		return fmt.Errorf("uploading %s: %w", f.name, err)
+	}
+	f.dirty = false
+	return nil
+}
+
+// s3FileInfo is the stat result for an object or directory.
+type s3FileInfo struct {
+	name     string
+	size     int64
+	mode     FileMode
+	mtime    time.Time
+	dir      bool
+	implicit bool // directory without a marker object
+}
+
+func newS3FileInfo(name string, obj *s3.ObjectInfo, dir bool) *s3FileInfo {
+	info := &s3FileInfo{
+		name:  name,
+		size:  obj.Size,
+		mode:  0o644,
+		mtime: obj.LastModified,
+		dir:   dir,
+	}
+	if dir {
+		info.size = 0
+		info.mode = 0o755
+	}
+	if v, err := strconv.ParseInt(obj.Metadata[s3MetaMtime], 10, 64); err == nil {
+		info.mtime = time.Unix(0, v)
+	}
+	if v, err := strconv.ParseUint(obj.Metadata[s3MetaMode], 8, 32); err == nil {
+		info.mode = FileMode(v)
+	}
+	return info
+}
+
+// meta returns the object metadata that represents this file info.
+func (f *s3FileInfo) meta() map[string]string {
+	return map[string]string{
+		s3MetaMtime: strconv.FormatInt(f.mtime.UnixNano(), 10),
+		s3MetaMode:  strconv.FormatUint(uint64(f.mode), 8),
+	}
+}
+
+func (f *s3FileInfo) Name() string {
+	return f.name
+}
+
+func (f *s3FileInfo) Mode() FileMode {
+	return f.mode
+}
+
+func (f *s3FileInfo) Size() int64 {
+	return f.size
+}
+
+func (f *s3FileInfo) ModTime() time.Time {
+	return f.mtime
+}
+
+func (f *s3FileInfo) IsDir() bool {
+	return f.dir
+}
+
+func (f *s3FileInfo) IsRegular() bool {
+	return !f.dir
+}
+
+func (*s3FileInfo) IsSymlink() bool {
+	return false
+}
+
+func (*s3FileInfo) Owner() int {
+	return -1
+}
+
+func (*s3FileInfo) Group() int {
+	return -1
+}
+
+func (*s3FileInfo) Sys() interface{} {
+	return nil
+}
+
+func (*s3FileInfo) InodeChangeTime() time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,423 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3FS(t *testing.T) {
+	srv := newFakeS3Server(t)
+	fs := NewFilesystem(FilesystemTypeS3, srv.URL+"/bucket/some/prefix")
+	if fs.Type() != FilesystemTypeS3 {
+		t.Fatal("unexpected type", fs.Type())
+	}
+	if strings.Contains(fs.URI(), "secret") {
+		t.Error("credentials leaked into URI:", fs.URI())
+	}
+
+	if err := fs.MkdirAll("dira/dirb", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Mkdir("dira/dirb/dirc", 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Mkdir("dira/dirb/dirc", 0o700); !IsExist(err) {
+		t.Error("expected exist error, got", err)
+	}
+	if err := fs.Mkdir("nonexistent/dir", 0o700); !IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+	info, err := fs.Stat("dira/dirb/dirc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() || info.Mode() != 0o700 {
+		t.Errorf("unexpected dir info: dir=%v mode=%v", info.IsDir(), info.Mode())
+	}
+
+	// Create and write a file
+	fd, err := fs.Create("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte(" world"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = fs.Lstat("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsRegular() || info.Size() != 11 || info.Name() != "test" {
+		t.Errorf("unexpected file info: regular=%v size=%d name=%q", info.IsRegular(), info.Size(), info.Name())
+	}
+
+	// Modify times and permissions
+	mtime := time.Date(2020, 3, 4, 5, 6, 7, 8, time.UTC)
+	if err := fs.Chtimes("dira/dirb/test", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Chmod("dira/dirb/test", 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err = fs.Lstat("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Error("wrong mtime:", info.ModTime())
+	}
+	if info.Mode() != 0o600 {
+		t.Error("wrong mode:", info.Mode())
+	}
+
+	// Read it back, both sequentially and at an offset
+	fd, err = fs.Open("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "hello world" {
+		t.Errorf("wrong contents: %q", bs)
+	}
+	buf := make([]byte, 5)
+	if _, err := fd.ReadAt(buf, 6); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "world" {
+		t.Errorf("wrong contents at offset: %q", buf)
+	}
+	if n, err := fd.ReadAt(buf[:0], 2); n != 0 || err != nil {
+		t.Errorf("expected nothing reading into an empty buffer, got %d, %v", n, err)
+	}
+	if _, err := fd.ReadAt(buf, 8); err != io.EOF {
+		t.Error("expected EOF reading past the end, got", err)
+	}
+	if _, err := fd.Write([]byte("nope")); err == nil {
+		t.Error("unexpected nil error writing to read only file")
+	}
+	fd.Close()
+
+	// Reopen for writing without truncation, which keeps the contents
+	fd, err = fs.OpenFile("dira/dirb/test", OptReadWrite, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte("J"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fd, err = fs.Open("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ = io.ReadAll(fd)
+	fd.Close()
+	if string(bs) != "Jello world" {
+		t.Errorf("wrong contents after partial write: %q", bs)
+	}
+
+	// Listing
+	names, err := fs.DirNames("dira/dirb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[dirc test]" {
+		t.Error("unexpected dir names:", names)
+	}
+	if _, err := fs.DirNames("dira/nonexistent"); !IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+
+	// Renaming files and directories
+	before, err := fs.Lstat("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dira/dirb/test", "dira/dirb/dirc/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("dira/dirb/test"); !IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+	info, err = fs.Lstat("dira/dirb/dirc/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(before.ModTime()) || info.Mode() != 0o600 {
+		t.Error("metadata lost in rename")
+	}
+	if err := fs.Rename("dira/dirb", "dira/renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("dira/renamed/dirc/moved"); err != nil {
+		t.Error(err)
+	}
+
+	// Removal
+	if err := fs.Remove("dira/renamed/dirc"); err == nil {
+		t.Error("unexpected nil error removing non-empty directory")
+	}
+	if err := fs.Remove("dira/renamed/dirc/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("dira/renamed/dirc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.RemoveAll("dira"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("dira"); !IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+	if keys := srv.keys(); len(keys) != 0 {
+		t.Error("objects left behind:", keys)
+	}
+
+	// Unsupported things
+	if _, _, err := fs.Watch(".", nil, nil, false); err != ErrWatchNotSupported {
+		t.Error("expected watch not supported error, got", err)
+	}
+	if fs.SymlinksSupported() {
+		t.Error("symlinks should not be supported")
+	}
+}
+
+func TestS3FSImplicitDirs(t *testing.T) {
+	srv := newFakeS3Server(t)
+	srv.put("bucket/a/b/c", []byte("data"), nil)
+
+	fs := NewFilesystem(FilesystemTypeS3, srv.URL+"/bucket")
+	info, err := fs.Lstat("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Error("expected implicit directory")
+	}
+	names, err := fs.DirNames(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[a]" {
+		t.Error("unexpected dir names:", names)
+	}
+
+	var seen []string
+	if err := fs.Walk(".", func(path string, _ FileInfo, err error) error {
+		seen = append(seen, path)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(seen) != "[. a a/b a/b/c]" {
+		t.Error("unexpected walk:", seen)
+	}
+}
+
+func TestS3FSInvalidURI(t *testing.T) {
+	for _, uri := range []string{"", "/some/path", "http://host", "ftp://host/bucket"} {
+		fs := NewFilesystem(FilesystemTypeS3, uri)
+		if _, err := fs.Lstat("."); err == nil {
+			t.Errorf("expected error for %q", uri)
+		}
+	}
+}
+
+// fakeS3Server implements just enough of the S3 API, with path style
+// addressing, to exercise the s3Filesystem.
+type fakeS3Server struct {
+	*httptest.Server
+	mut     sync.Mutex
+	objects map[string]*fakeS3Object
+}
+
+type fakeS3Object struct {
+	data     []byte
+	meta     map[string]string
+	modified time.Time
+}
+
+func newFakeS3Server(t *testing.T) *fakeS3Server {
+	t.Helper()
+	srv := &fakeS3Server{objects: make(map[string]*fakeS3Object)}
+	srv.Server = httptest.NewServer(srv)
+	t.Cleanup(srv.Close)
+	uri, _ := url.Parse(srv.Server.URL)
+	uri.User = url.UserPassword("key", "secret")
+	srv.URL = uri.String()
+	return srv
+}
+
+func (s *fakeS3Server) put(key string, data []byte, meta map[string]string) {
+	s.mut.Lock()
+	s.objects[key] = &fakeS3Object{data: data, meta: meta, modified: time.Now().UTC()}
+	s.mut.Unlock()
+}
+
+func (s *fakeS3Server) keys() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if key == "" && r.Method == http.MethodGet {
+		s.list(w, r, bucket)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(strings.TrimPrefix(src, "/"))
+			obj, ok := s.objects[src]
+			if !ok {
+				s.notFound(w)
+				return
+			}
+			s.objects[path] = &fakeS3Object{data: obj.data, meta: fakeS3Meta(r.Header), modified: time.Now().UTC()}
+			fmt.Fprintf(w, "<CopyObjectResult><LastModified>%s</LastModified><ETag>\"x\"</ETag></CopyObjectResult>", time.Now().UTC().Format(time.RFC3339))
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		s.objects[path] = &fakeS3Object{data: data, meta: fakeS3Meta(r.Header), modified: time.Now().UTC()}
+
+	case http.MethodHead, http.MethodGet:
+		obj, ok := s.objects[path]
+		if !ok {
+			s.notFound(w)
+			return
+		}
+		for k, v := range obj.meta {
+			w.Header().Set("X-Amz-Meta-"+k, v)
+		}
+		w.Header().Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+		data := obj.data
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, end, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+			from, _ := strconv.Atoi(start)
+			to := len(data) - 1
+			if end != "" {
+				to, _ = strconv.Atoi(end)
+			}
+			if to < from {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, to, len(data)))
+			data = data[from : to+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case http.MethodDelete:
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *fakeS3Server) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	type contents struct {
+		Key          string
+		LastModified string
+		Size         int
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	type result struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		IsTruncated    bool
+		Contents       []contents
+		CommonPrefixes []commonPrefix
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	res := result{Name: bucket, Prefix: prefix}
+	seen := make(map[string]bool)
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key, ok := strings.CutPrefix(k, bucket+"/")
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				p := key[:len(prefix)+idx+len(delimiter)]
+				if !seen[p] {
+					seen[p] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{p})
+				}
+				continue
+			}
+		}
+		obj := s.objects[k]
+		res.Contents = append(res.Contents, contents{Key: key, LastModified: obj.modified.Format(time.RFC3339), Size: len(obj.data)})
+	}
+	xml.NewEncoder(w).Encode(res)
+}
+
+func (*fakeS3Server) notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>")
+}
+
+func fakeS3Meta(h http.Header) map[string]string {
+	meta := make(map[string]string)
+	for k := range h {
+		if name, ok := strings.CutPrefix(k, "X-Amz-Meta-"); ok {
+			meta[strings.ToLower(name)] = h.Get(k)
+		}
+	}
+	return meta
+}
@@ -11,6 +11,8 @@ type FilesystemType int32
 const (
 	FilesystemTypeBasic FilesystemType = 0
 	FilesystemTypeFake  FilesystemType = 1
+	FilesystemTypeS3    FilesystemType = 2
+	FilesystemTypeSFTP  FilesystemType = 3
 )
 
 func (t FilesystemType) String() string {
@@ -19,6 +21,10 @@ func (t FilesystemType) String() string {
 		return "basic"
 	case FilesystemTypeFake:
 		return "fake"
+	case FilesystemTypeS3:
+		return "s3"
+	case FilesystemTypeSFTP:
+		return "sftp"
 	default:
 		return "unknown"
 	}
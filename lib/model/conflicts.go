@@ -0,0 +1,281 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// ConflictResolution is the way a conflict is resolved.
+type ConflictResolution string
+
+const (
+	// ConflictKeepOriginal removes the conflict copy.
+	ConflictKeepOriginal ConflictResolution = "keepOriginal"
+	// ConflictKeepConflict replaces the original with the conflict copy.
+	ConflictKeepConflict ConflictResolution = "keepConflict"
+	// ConflictKeepBoth renames the conflict copy to a name that is no
+	// longer recognized as a conflict.
+	ConflictKeepBoth ConflictResolution = "keepBoth"
+)
+
+var (
+	// ErrNotAConflict is returned when resolving a file that isn't a
+	// conflict copy.
+	ErrNotAConflict = errors.New("not a conflict copy")
+	// ErrUnknownConflictResolution is returned when resolving a conflict
+	// in a way we don't know.
+	ErrUnknownConflictResolution = errors.New("unknown conflict resolution")
+	// ErrConflictEncrypted is returned when resolving a conflict in a
+	// receive-encrypted folder.
+	ErrConflictEncrypted = errors.New("conflicts cannot be resolved in receive-encrypted folders")
+)
+
+const conflictTimeFormat = "20060102-150405"
+
+// conflictNameExp matches the base name of a conflict copy as created by
+// conflictName, capturing the original name without extension, the time,
+// the short ID of the device and the extension.
+var conflictNameExp = regexp.MustCompile(`^(.*)\.sync-conflict-(\d{8}-\d{6})-([A-Z2-7]{7})(\.[^.]*)?$`)
+
+// A Conflict is a conflict copy in a folder, as recorded in the database.
+type Conflict struct {
+	Name            string            `json:"name"`
+	Original        string            `json:"original"`
+	OriginalExists  bool              `json:"originalExists"`
+	OriginalModTime time.Time         `json:"originalModTime,omitempty"`
+	OriginalSize    int64             `json:"originalSize"`
+	ModifiedBy      string            `json:"modifiedBy"`
+	Device          protocol.DeviceID `json:"device"`
+	Created         time.Time         `json:"created"`
+	ModTime         time.Time         `json:"modTime"`
+	Size            int64             `json:"size"`
+}
+
+// parseConflictName returns the name of the original file, the time the
+// conflict copy was created and the short ID of the device that last
+// modified it, given the name of a conflict copy.
+func parseConflictName(name string) (string, time.Time, string, bool) {
+	dir, base := filepath.Split(name)
+	m := conflictNameExp.FindStringSubmatch(base)
+	if m == nil {
+		return "", time.Time{}, "", false
+	}
+	t, err := time.ParseInLocation(conflictTimeFormat, m[2], time.Local)
+	if err != nil {
+		return "", time.Time{}, "", false
+	}
+	return dir + m[1] + m[4], t, m[3], true
+}
+
+// keepBothName returns the name that a conflict copy is given when keeping
+// both files: the conflict name without the conflict marker.
+func keepBothName(original string, created time.Time, modifiedBy string) string {
+	ext := filepath.Ext(original)
+	return original[:len(original)-len(ext)] + created.Format("."+conflictTimeFormat+"-") + modifiedBy + ext
+}
+
+// listConflicts returns the conflict copies in the given snapshot. The
+// devices map is used to find the full device ID from the short ID in the
+// conflict name.
+func listConflicts(snap *db.Snapshot, devices map[string]protocol.DeviceID) []Conflict {
+	conflicts := []Conflict{}
+	snap.WithHaveTruncated(protocol.LocalDeviceID, func(f protocol.FileInfo) bool {
+		if f.IsDeleted() || f.IsDirectory() || !isConflict(f.FileName()) {
+			return true
+		}
+		original, created, modifiedBy, ok := parseConflictName(f.FileName())
+		if !ok {
+			return true
+		}
+		c := Conflict{
+			Name:       f.FileName(),
+			Original:   original,
+			ModifiedBy: modifiedBy,
+			Device:     devices[modifiedBy],
+			Created:    created,
+			ModTime:    f.ModTime(),
+			Size:       f.FileSize(),
+		}
+		if of, ok := snap.Get(protocol.LocalDeviceID, original); ok && !of.IsDeleted() {
+			c.OriginalExists = true
+			c.OriginalModTime = of.ModTime()
+			c.OriginalSize = of.FileSize()
+		}
+		conflicts = append(conflicts, c)
+		return true
+	})
+	return conflicts
+}
+
+func (f *folder) ResolveConflict(name string, resolution ConflictResolution) error {
+	<-f.initialScanFinished
+	return f.doInSync(func() error { return f.resolveConflict(name, resolution) })
+}
+
+// resolveConflict performs the file operations for resolving the conflict
+// and then scans the affected files, so that the result is synced like any
+// other local change. Files that are replaced or removed are archived if the
+// folder has versioning.
+func (f *folder) resolveConflict(name string, resolution ConflictResolution) error {
+	original, created, modifiedBy, ok := parseConflictName(name)
+	if !ok {
+		return ErrNotAConflict
+	}
+	if info, err := f.mtimefs.Lstat(name); err != nil {
+		return err
+	} else if !info.IsRegular() {
+		return ErrNotAConflict
+	}
+
+	remove := f.mtimefs.Remove
+	if f.versioner != nil {
+		remove = f.versioner.Archive
+	}
+
+	scan := []string{name}
+	var err error
+	switch resolution {
+	case ConflictKeepOriginal:
+		err = inWritableDir(remove, f.mtimefs, name, f.IgnorePerms)
+
+	case ConflictKeepConflict:
+		err = inWritableDir(remove, f.mtimefs, original, f.IgnorePerms)
+		if err != nil && !fs.IsNotExist(err) {
+			break
+		}
+		err = f.mtimefs.Rename(name, original)
+		scan = append(scan, original)
+
+	case ConflictKeepBoth:
+		newName := keepBothName(original, created, modifiedBy)
+		if _, err = f.mtimefs.Lstat(newName); err == nil {
+			err = fmt.Errorf("%s: %w", newName, os.ErrExist)
+			break
+		} else if !fs.IsNotExist(err) {
+			break
+		}
+		err = f.mtimefs.Rename(name, newName)
+		scan = append(scan, newName)
+
+	default:
+		return ErrUnknownConflictResolution
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.scanSubdirs(scan)
+}
+
+// isPresentConflict returns whether the file is a conflict copy we have.
+func isPresentConflict(f protocol.FileInfo) bool {
+	return !f.IsDeleted() && !f.IsDirectory() && isConflict(f.FileName())
+}
+
+// countConflicts counts the conflict copies we have in full, to be kept up
+// to date by updateLocalFiles and removeLocalFiles from then on.
+func (f *folder) countConflicts() {
+	f.conflictsMut.Lock()
+	defer f.conflictsMut.Unlock()
+
+	snap, err := f.dbSnapshot()
+	if err != nil {
+		return
+	}
+	defer snap.Release()
+
+	conflicts := 0
+	snap.WithHaveTruncated(protocol.LocalDeviceID, func(fi protocol.FileInfo) bool {
+		if isPresentConflict(fi) {
+			conflicts++
+		}
+		return true
+	})
+	f.conflicts = conflicts
+	f.conflictsKnown = true
+	metricFolderConflicts.WithLabelValues(f.ID).Set(float64(conflicts))
+}
+
+// updateLocalFiles updates our files in the database, adjusting the number
+// of conflict copies as they come and go.
+func (f *folder) updateLocalFiles(fs []protocol.FileInfo) {
+	f.conflictsMut.Lock()
+	defer f.conflictsMut.Unlock()
+
+	delta := 0
+	if f.conflictsKnown {
+		delta = f.conflictsDelta(fs)
+	}
+	f.fset.Update(protocol.LocalDeviceID, fs)
+	f.addConflicts(delta)
+}
+
+// removeLocalFiles removes our files with the given names from the
+// database, adjusting the number of conflict copies.
+func (f *folder) removeLocalFiles(names []string) {
+	f.conflictsMut.Lock()
+	defer f.conflictsMut.Unlock()
+
+	delta := 0
+	if f.conflictsKnown {
+		removed := make([]protocol.FileInfo, 0, len(names))
+		for _, name := range names {
+			removed = append(removed, protocol.FileInfo{Name: name, Deleted: true})
+		}
+		delta = f.conflictsDelta(removed)
+	}
+	f.fset.RemoveLocalItems(names)
+	f.addConflicts(delta)
+}
+
+// conflictsDelta returns the change in the number of conflict copies we
+// have when our files are replaced by the given ones. Only conflict copies
+// are looked up in the database, which keeps this cheap.
+func (f *folder) conflictsDelta(fs []protocol.FileInfo) int {
+	var snap *db.Snapshot
+	delta := 0
+	for _, fi := range fs {
+		if !isConflict(fi.Name) {
+			continue
+		}
+		if snap == nil {
+			var err error
+			if snap, err = f.dbSnapshot(); err != nil {
+				// We can't tell any more; the count is unknown until
+				// the folder restarts.
+				f.conflictsKnown = false
+				return 0
+			}
+			defer snap.Release()
+		}
+		if cur, ok := snap.Get(protocol.LocalDeviceID, fi.Name); ok && isPresentConflict(cur) {
+			delta--
+		}
+		if isPresentConflict(fi) {
+			delta++
+		}
+	}
+	return delta
+}
+
+func (f *folder) addConflicts(delta int) {
+	if !f.conflictsKnown || delta == 0 {
+		return
+	}
+	f.conflicts += delta
+	metricFolderConflicts.WithLabelValues(f.ID).Set(float64(f.conflicts))
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestParseConflictName(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	cases := []struct {
+		name     string
+		original string
+		ok       bool
+	}{
+		{"file.sync-conflict-20240102-030405-ABCDEF2.txt", "file.txt", true},
+		{"dir/file.tar.sync-conflict-20240102-030405-ABCDEF2.gz", "dir/file.tar.gz", true},
+		{"dir.sync-conflict-x/noext.sync-conflict-20240102-030405-ABCDEF2", "dir.sync-conflict-x/noext", true},
+		{"file.txt", "", false},
+		{"file.sync-conflict-2024-ABCDEF2.txt", "", false},
+	}
+	for _, tc := range cases {
+		original, ts, modifiedBy, ok := parseConflictName(tc.name)
+		if ok != tc.ok || original != tc.original {
+			t.Errorf("%s: got %q, %v; expected %q, %v", tc.name, original, ok, tc.original, tc.ok)
+			continue
+		}
+		if ok && (!ts.Equal(created) || modifiedBy != "ABCDEF2") {
+			t.Errorf("%s: got %v, %v", tc.name, ts, modifiedBy)
+		}
+	}
+}
+
+func TestConflictResolution(t *testing.T) {
+	wcfg, fcfg, wcfgCancel := newDefaultCfgWrapper()
+	defer wcfgCancel()
+	m := setupModel(t, wcfg)
+	defer cleanupModel(m)
+	tfs := fcfg.Filesystem(nil)
+
+	short := device1.Short().String()
+	conflictFor := func(name string, sec int) string {
+		return fmt.Sprintf("%s.sync-conflict-20240101-00000%d-%s", name, sec, short)
+	}
+	writeFile(t, tfs, "a", []byte("original a"))
+	writeFile(t, tfs, conflictFor("a", 1), []byte("conflict a"))
+	writeFile(t, tfs, "b", []byte("original b"))
+	writeFile(t, tfs, conflictFor("b", 2), []byte("conflict b"))
+	writeFile(t, tfs, "c", []byte("original c"))
+	writeFile(t, tfs, conflictFor("c", 3), []byte("conflict c"))
+	must(t, m.ScanFolder(fcfg.ID))
+
+	conflicts, err := m.FolderConflicts(fcfg.ID)
+	must(t, err)
+	if len(conflicts) != 3 {
+		t.Fatalf("expected three conflicts, got %v", conflicts)
+	}
+	for _, c := range conflicts {
+		if c.Device != device1 || !c.OriginalExists || c.Size != int64(len("conflict a")) {
+			t.Errorf("unexpected conflict %+v", c)
+		}
+	}
+
+	must(t, m.ResolveConflict(fcfg.ID, conflictFor("a", 1), ConflictKeepOriginal))
+	must(t, m.ResolveConflict(fcfg.ID, conflictFor("b", 2), ConflictKeepConflict))
+	must(t, m.ResolveConflict(fcfg.ID, conflictFor("c", 3), ConflictKeepBoth))
+
+	if err := m.ResolveConflict(fcfg.ID, "a", ConflictKeepBoth); err != ErrNotAConflict {
+		t.Errorf("expected ErrNotAConflict, got %v", err)
+	}
+
+	// The changes are in the database, ready to be synced.
+	conflicts, err = m.FolderConflicts(fcfg.ID)
+	must(t, err)
+	if len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", conflicts)
+	}
+	for _, name := range []string{conflictFor("a", 1), conflictFor("b", 2), conflictFor("c", 3)} {
+		if f, ok := m.testCurrentFolderFile(fcfg.ID, name); !ok || !f.IsDeleted() {
+			t.Errorf("%s should be deleted in the database", name)
+		}
+	}
+	keptBoth := "c.20240101-000003-" + short
+	if f, ok := m.testCurrentFolderFile(fcfg.ID, keptBoth); !ok || f.IsDeleted() {
+		t.Errorf("%s should exist in the database", keptBoth)
+	}
+
+	for name, content := range map[string]string{
+		"a":      "original a",
+		"b":      "conflict b",
+		"c":      "original c",
+		keptBoth: "conflict c",
+	} {
+		if got := readFile(t, tfs, name); got != content {
+			t.Errorf("%s: got %q, expected %q", name, got, content)
+		}
+	}
+}
+
+func readFile(t *testing.T, filesystem fs.Filesystem, name string) string {
+	t.Helper()
+	fd, err := filesystem.Open(name)
+	must(t, err)
+	defer fd.Close()
+	bs, err := io.ReadAll(fd)
+	must(t, err)
+	return string(bs)
+}
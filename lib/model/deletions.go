@@ -0,0 +1,124 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var (
+	// ErrNoHeldDeletions is returned when confirming deletions in a folder
+	// that isn't holding any.
+	ErrNoHeldDeletions = errors.New("no deletions are being held")
+
+	errMassDeletion = errors.New("too many deletions")
+	errDeletionHeld = errors.New("deletion held until confirmed")
+)
+
+func (*folder) ConfirmDeletions() error {
+	return ErrNoHeldDeletions
+}
+
+// ConfirmDeletions lets the deletions that were held back for exceeding the
+// threshold be carried out, as long as they don't change in the meantime.
+func (f *sendReceiveFolder) ConfirmDeletions() error {
+	f.deletionsMut.Lock()
+	if len(f.heldDeletions) == 0 {
+		f.deletionsMut.Unlock()
+		return ErrNoHeldDeletions
+	}
+	f.confirmedDeletions = f.heldDeletions
+	f.heldDeletions = nil
+	f.deletionsMut.Unlock()
+
+	f.SchedulePull()
+	return nil
+}
+
+// holdMassDeletions returns an error if there are more unconfirmed
+// deletions than the folder allows at once, in which case the deletions are
+// held and reported as folder errors until confirmed.
+func (f *sendReceiveFolder) holdMassDeletions(fileDeletions map[string]protocol.FileInfo, dirDeletions []protocol.FileInfo, snap *db.Snapshot) error {
+	if f.MaxDeletions <= 0 && f.MaxDeletionsPct <= 0 {
+		return nil
+	}
+	deletions := deletionList(fileDeletions, dirDeletions)
+
+	f.deletionsMut.Lock()
+	if !f.deletionsHeldLocked(deletions, snap) {
+		f.confirmedDeletions = nil
+		f.deletionsMut.Unlock()
+		return nil
+	}
+	f.heldDeletions = make(map[string]protocol.Vector, len(deletions))
+	for _, file := range deletions {
+		f.heldDeletions[file.Name] = file.Version
+	}
+	f.deletionsMut.Unlock()
+
+	errs := make([]FileError, len(deletions))
+	for i, file := range deletions {
+		errs[i] = FileError{Path: file.Name, Err: errDeletionHeld.Error()}
+	}
+	f.errorsMut.Lock()
+	f.pullErrors = errs
+	f.errorsMut.Unlock()
+	f.evLogger.Log(events.FolderErrors, map[string]interface{}{
+		"folder": f.folderID,
+		"errors": f.Errors(),
+	})
+
+	return fmt.Errorf("%w: holding %d deletions until confirmed", errMassDeletion, len(deletions))
+}
+
+// deletionsHeld returns true if pulling would hold back the deletions until
+// confirmed, without holding them.
+func (f *sendReceiveFolder) deletionsHeld(deletions []protocol.FileInfo, snap *db.Snapshot) bool {
+	if f.MaxDeletions <= 0 && f.MaxDeletionsPct <= 0 {
+		return false
+	}
+	f.deletionsMut.Lock()
+	defer f.deletionsMut.Unlock()
+	return f.deletionsHeldLocked(deletions, snap)
+}
+
+func (f *sendReceiveFolder) deletionsHeldLocked(deletions []protocol.FileInfo, snap *db.Snapshot) bool {
+	unconfirmed := 0
+	for _, file := range deletions {
+		if version, ok := f.confirmedDeletions[file.Name]; !ok || !version.Equal(file.Version) {
+			unconfirmed++
+		}
+	}
+	return f.exceedsDeletionThreshold(unconfirmed, snap.LocalSize())
+}
+
+// deletionList returns the file deletions, ordered by name, followed by the
+// directory deletions.
+func deletionList(fileDeletions map[string]protocol.FileInfo, dirDeletions []protocol.FileInfo) []protocol.FileInfo {
+	deletions := make([]protocol.FileInfo, 0, len(fileDeletions)+len(dirDeletions))
+	for _, file := range fileDeletions {
+		deletions = append(deletions, file)
+	}
+	sort.Slice(deletions, func(i, j int) bool {
+		return deletions[i].Name < deletions[j].Name
+	})
+	return append(deletions, dirDeletions...)
+}
+
+func (f *sendReceiveFolder) exceedsDeletionThreshold(deletions int, local db.Counts) bool {
+	if f.MaxDeletions > 0 && deletions > f.MaxDeletions {
+		return true
+	}
+	total := local.Files + local.Directories + local.Symlinks
+	return f.MaxDeletionsPct > 0 && total > 0 && deletions*100 > total*f.MaxDeletionsPct
+}
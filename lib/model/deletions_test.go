@@ -0,0 +1,134 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestMassDeletionHeld(t *testing.T) {
+	m, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	ffs := f.Filesystem(nil)
+	f.MaxDeletions = 2
+
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		writeFile(t, ffs, name, []byte(name))
+	}
+	must(t, f.scanSubdirs(nil))
+
+	var deletions []protocol.FileInfo
+	for _, name := range names[:3] {
+		file, ok := m.testCurrentFolderFile(f.ID, name)
+		if !ok {
+			t.Fatal("missing file", name)
+		}
+		file.Deleted = true
+		file.Version = file.Version.Update(device1.Short())
+		deletions = append(deletions, file)
+	}
+	f.fset.Update(device1, deletions)
+
+	scanChan := make(chan string, len(names))
+	if _, err := f.pullerIteration(scanChan); !errors.Is(err, errMassDeletion) {
+		t.Fatalf("expected %v, got %v", errMassDeletion, err)
+	}
+	for _, name := range names {
+		if _, err := ffs.Lstat(name); err != nil {
+			t.Errorf("%s was deleted before confirmation: %v", name, err)
+		}
+	}
+	if errs := f.Errors(); len(errs) != 3 {
+		t.Errorf("expected 3 held deletions as errors, got %v", errs)
+	}
+
+	must(t, f.ConfirmDeletions())
+	if _, err := f.pullerIteration(scanChan); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names[:3] {
+		if _, err := ffs.Lstat(name); !fs.IsNotExist(err) {
+			t.Errorf("%s wasn't deleted after confirmation: %v", name, err)
+		}
+	}
+	if err := f.ConfirmDeletions(); err != ErrNoHeldDeletions {
+		t.Errorf("expected %v, got %v", ErrNoHeldDeletions, err)
+	}
+}
+
+func TestExceedsDeletionThreshold(t *testing.T) {
+	f := &sendReceiveFolder{}
+	local := db.Counts{Files: 90, Directories: 10}
+
+	f.MaxDeletionsPct = 50
+	if f.exceedsDeletionThreshold(50, local) {
+		t.Error("50% shouldn't exceed a 50% threshold")
+	}
+	if !f.exceedsDeletionThreshold(51, local) {
+		t.Error("51% should exceed a 50% threshold")
+	}
+
+	f.MaxDeletions = 10
+	if !f.exceedsDeletionThreshold(11, local) {
+		t.Error("11 should exceed a threshold of 10")
+	}
+}
+
+func TestMassDeletionHeldSymlinksAndMismatchedTypes(t *testing.T) {
+	m, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	ffs := f.Filesystem(nil)
+	f.MaxDeletions = 1
+
+	writeFile(t, ffs, "file", []byte("file"))
+	must(t, ffs.CreateSymlink("file", "link"))
+	must(t, ffs.Mkdir("dir", 0o755))
+	must(t, f.scanSubdirs(nil))
+
+	var deletions []protocol.FileInfo
+	for _, name := range []string{"link", "dir"} {
+		file, ok := m.testCurrentFolderFile(f.ID, name)
+		if !ok {
+			t.Fatal("missing file", name)
+		}
+		file.Deleted = true
+		file.Version = file.Version.Update(device1.Short())
+		if name == "dir" {
+			// Deleted as a file remotely, so of the wrong type here
+			file.Type = protocol.FileInfoTypeFile
+		}
+		deletions = append(deletions, file)
+	}
+	f.fset.Update(device1, deletions)
+
+	scanChan := make(chan string, 3)
+	if _, err := f.pullerIteration(scanChan); !errors.Is(err, errMassDeletion) {
+		t.Fatalf("expected %v, got %v", errMassDeletion, err)
+	}
+	for _, name := range []string{"file", "link", "dir"} {
+		if _, err := ffs.Lstat(name); err != nil {
+			t.Errorf("%s was deleted before confirmation: %v", name, err)
+		}
+	}
+	if errs := f.Errors(); len(errs) != 2 {
+		t.Errorf("expected 2 held deletions as errors, got %v", errs)
+	}
+
+	must(t, f.ConfirmDeletions())
+	if _, err := f.pullerIteration(scanChan); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ffs.Lstat("link"); !fs.IsNotExist(err) {
+		t.Errorf("link wasn't deleted after confirmation: %v", err)
+	}
+}
@@ -65,6 +65,12 @@ type folder struct {
 	pullPause     time.Duration
 	pullFailTimer *time.Timer
 
+	// Pulls and scans outside of the sync windows are deferred until the
+	// next window opens.
+	syncWindowTimer *time.Timer
+	pullDeferred    bool
+	scanDeferred    bool
+
 	scanErrors []FileError
 	pullErrors []FileError
 	errorsMut  sync.Mutex
@@ -84,6 +90,12 @@ type folder struct {
 	puller    puller
 	versioner versioner.Versioner
 
+	// The number of conflict copies we have, counted in full once and
+	// then kept up to date as local files change.
+	conflicts      int
+	conflictsKnown bool
+	conflictsMut   sync.Mutex
+
 	warnedKqueue bool
 }
 
@@ -123,6 +135,8 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 
 		errorsMut: sync.NewMutex(),
 
+		conflictsMut: sync.NewMutex(),
+
 		doInSyncChan: make(chan syncRequest),
 
 		forcedRescanRequested: make(chan struct{}, 1),
@@ -138,6 +152,8 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 	f.pullPause = f.pullBasePause()
 	f.pullFailTimer = time.NewTimer(0)
 	<-f.pullFailTimer.C
+	f.syncWindowTimer = time.NewTimer(0)
+	<-f.syncWindowTimer.C
 
 	registerFolderMetrics(f.ID)
 
@@ -156,9 +172,15 @@ func (f *folder) Serve(ctx context.Context) error {
 	defer func() {
 		f.scanTimer.Stop()
 		f.versionCleanupTimer.Stop()
+		f.syncWindowTimer.Stop()
 		f.setState(FolderIdle)
+		forgetFolderDeviceMetrics(f.ID)
 	}()
 
+	if len(f.SyncWindows) > 0 {
+		f.syncWindowTimerFired()
+	}
+
 	if f.FSWatcherEnabled && f.getHealthErrorAndLoadIgnores() == nil {
 		f.startWatch()
 	}
@@ -171,6 +193,9 @@ func (f *folder) Serve(ctx context.Context) error {
 		}
 	}
 
+	f.countConflicts()
+	f.removeMergeBases()
+
 	initialCompleted := f.initialScanFinished
 
 	for {
@@ -218,6 +243,10 @@ func (f *folder) Serve(ctx context.Context) error {
 			f.scanTimer.Reset(0)
 
 		case fsEvents := <-f.watchChan:
+			if f.deferScan() {
+				l.Debugln(f, "Deferring scan due to watcher until sync window")
+				break
+			}
 			l.Debugln(f, "Scan due to watcher")
 			err = f.scanSubdirs(fsEvents)
 
@@ -228,6 +257,9 @@ func (f *folder) Serve(ctx context.Context) error {
 		case <-f.versionCleanupTimer.C:
 			l.Debugln(f, "Doing version cleanup")
 			f.versionCleanupTimerFired()
+
+		case <-f.syncWindowTimer.C:
+			f.syncWindowTimerFired()
 		}
 
 		if err != nil {
@@ -359,6 +391,13 @@ func (f *folder) pull() (success bool, err error) {
 		return true, nil
 	}
 
+	if !f.InSyncWindow(time.Now()) {
+		// The pull will be scheduled when the next sync window opens
+		l.Debugln(f, "Deferring pull until sync window")
+		f.pullDeferred = true
+		return true, nil
+	}
+
 	defer func() {
 		if success {
 			// We're good, reset the pause interval.
@@ -572,7 +611,7 @@ func (b *scanBatch) Remove(item string) {
 
 func (b *scanBatch) flushToRemove() {
 	if len(b.toRemove) > 0 {
-		b.f.fset.RemoveLocalItems(b.toRemove)
+		b.f.removeLocalFiles(b.toRemove)
 		b.toRemove = b.toRemove[:0]
 	}
 }
@@ -913,6 +952,11 @@ func (f *folder) findRename(snap *db.Snapshot, file protocol.FileInfo, alreadyUs
 }
 
 func (f *folder) scanTimerFired() error {
+	if f.deferScan() {
+		l.Debugln(f, "Deferring scan until sync window")
+		return nil
+	}
+
 	err := f.scanSubdirs(nil)
 
 	select {
@@ -931,6 +975,45 @@ func (f *folder) scanTimerFired() error {
 	return err
 }
 
+// deferScan returns true if a scan should not happen now as the folder is
+// outside of its sync windows, remembering to do it when the next window
+// opens. The initial scan is never deferred, so that the folder starts out
+// with a known state.
+func (f *folder) deferScan() bool {
+	select {
+	case <-f.initialScanFinished:
+	default:
+		return false
+	}
+	if f.InSyncWindow(time.Now()) {
+		return false
+	}
+	f.scanDeferred = true
+	return true
+}
+
+// syncWindowTimerFired updates the state according to the sync windows and
+// does any deferred scan and pull when one opens. It runs at the start of
+// every minute, the resolution of sync windows.
+func (f *folder) syncWindowTimerFired() {
+	now := time.Now()
+	open := f.InSyncWindow(now)
+	f.setOutsideSyncWindow(!open)
+	if open {
+		if f.scanDeferred {
+			l.Debugln(f, "Sync window opened, scanning")
+			f.scanDeferred = false
+			f.scanTimer.Reset(0)
+		}
+		if f.pullDeferred {
+			l.Debugln(f, "Sync window opened, pulling")
+			f.pullDeferred = false
+			f.SchedulePull()
+		}
+	}
+	f.syncWindowTimer.Reset(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+}
+
 func (f *folder) versionCleanupTimerFired() {
 	f.setState(FolderCleanWaiting)
 	defer f.setState(FolderIdle)
@@ -1022,6 +1105,13 @@ func (f *folder) monitorWatch(ctx context.Context) {
 		select {
 		case <-failTimer.C:
 			eventChan, errChan, err = f.mtimefs.Watch(".", f.ignores, ctx, f.IgnorePerms)
+			if errors.Is(err, fs.ErrWatchNotSupported) {
+				// Not an error condition and nothing will change by
+				// retrying; the regular rescan interval takes over.
+				l.Infof("Filesystem watching is not supported for folder %s, relying on periodic rescans every %ds", f.Description(), f.RescanIntervalS)
+				aggrCancel()
+				return
+			}
 			// We do this once per minute initially increased to
 			// max one hour in case of repeat failures.
 			f.scanOnWatchErr()
@@ -1193,6 +1283,10 @@ outer:
 	f.scanErrors = filtered
 }
 
+func (f *folder) localFilesystem() fs.Filesystem {
+	return f.mtimefs
+}
+
 func (f *folder) Errors() []FileError {
 	f.errorsMut.Lock()
 	defer f.errorsMut.Unlock()
@@ -1218,18 +1312,20 @@ func (f *folder) ScheduleForceRescan(path string) {
 
 func (f *folder) updateLocalsFromScanning(fs []protocol.FileInfo) {
 	f.updateLocals(fs)
+	f.saveMergeBases(fs)
 
 	f.emitDiskChangeEvents(fs, events.LocalChangeDetected)
 }
 
 func (f *folder) updateLocalsFromPulling(fs []protocol.FileInfo) {
 	f.updateLocals(fs)
+	f.pruneMergeBases(fs)
 
 	f.emitDiskChangeEvents(fs, events.RemoteChangeDetected)
 }
 
 func (f *folder) updateLocals(fs []protocol.FileInfo) {
-	f.fset.Update(protocol.LocalDeviceID, fs)
+	f.updateLocalFiles(fs)
 
 	filenames := make([]string, len(fs))
 	f.forcedRescanPathsMut.Lock()
@@ -1295,7 +1391,7 @@ func (f *folder) handleForcedRescans() error {
 	}
 
 	batch := db.NewFileInfoBatch(func(fs []protocol.FileInfo) error {
-		f.fset.Update(protocol.LocalDeviceID, fs)
+		f.updateLocalFiles(fs)
 		return nil
 	})
 
@@ -126,6 +126,15 @@ type sendReceiveFolder struct {
 	queue              *jobQueue
 	blockPullReorderer blockPullReorderer
 	writeLimiter       *semaphore.Semaphore
+	fetched            *db.NamespacedKV // files fetched on demand, with selective sync
+
+	approvedMut     sync.Mutex
+	approved        map[string]protocol.Vector // changes approved for pulling, with pull preview
+	heldForApproval int                        // needed items not pulled for lack of approval
+
+	deletionsMut       sync.Mutex
+	heldDeletions      map[string]protocol.Vector // deletions exceeding the threshold
+	confirmedDeletions map[string]protocol.Vector // held deletions that may be carried out
 
 	tempPullErrors map[string]string // pull errors that might be just transient
 }
@@ -136,6 +145,9 @@ func newSendReceiveFolder(model *model, fset *db.FileSet, ignores *ignore.Matche
 		queue:              newJobQueue(),
 		blockPullReorderer: newBlockPullReorderer(cfg.BlockPullOrder, model.id, cfg.DeviceIDs()),
 		writeLimiter:       semaphore.New(cfg.MaxConcurrentWrites),
+		fetched:            db.NewFolderFetchNamespace(model.db, cfg.ID),
+		approvedMut:        sync.NewMutex(),
+		deletionsMut:       sync.NewMutex(),
 	}
 	f.folder.puller = f
 
@@ -206,6 +218,15 @@ func (f *sendReceiveFolder) pull() (bool, error) {
 		}
 	}
 
+	if f.PullPreview {
+		if changed == 0 {
+			// Everything approved is done, further changes need a new
+			// approval.
+			f.clearApproved()
+		}
+		f.setAwaitingApproval(f.heldForApproval > 0)
+	}
+
 	f.errorsMut.Lock()
 	pullErrNum := len(f.tempPullErrors)
 	if pullErrNum > 0 {
@@ -304,7 +325,10 @@ func (f *sendReceiveFolder) pullerIteration(scanChan chan<- string) (int, error)
 	doneWg.Wait()
 
 	if err == nil {
-		f.processDeletions(fileDeletions, dirDeletions, snap, dbUpdateChan, scanChan)
+		err = f.holdMassDeletions(fileDeletions, dirDeletions, snap)
+		if err == nil {
+			f.processDeletions(fileDeletions, dirDeletions, snap, dbUpdateChan, scanChan)
+		}
 	}
 
 	// Wait for db updates and scan scheduling to complete
@@ -321,6 +345,7 @@ func (f *sendReceiveFolder) processNeeded(snap *db.Snapshot, dbUpdateChan chan<-
 	var dirDeletions []protocol.FileInfo
 	fileDeletions := map[string]protocol.FileInfo{}
 	buckets := map[string][]protocol.FileInfo{}
+	f.heldForApproval = 0
 
 	// Iterate the list of items that we need and sort them into piles.
 	// Regular files to pull goes into the file queue, everything else
@@ -333,20 +358,27 @@ func (f *sendReceiveFolder) processNeeded(snap *db.Snapshot, dbUpdateChan chan<-
 		default:
 		}
 
-		if f.IgnoreDelete && file.IsDeleted() {
+		action, cur, _ := f.classifyNeeded(file, snap)
+		if action == neededSkip {
 			l.Debugln(f, "ignore file deletion (config)", file.FileName())
 			return true
 		}
 
+		if action.needsApproval() && !f.approvedForPull(file) {
+			l.Debugln(f, "holding back change until approved", file.FileName())
+			f.heldForApproval++
+			return true
+		}
+
 		changed++
 
-		switch {
-		case f.ignores.Match(file.Name).IsIgnored():
+		switch action {
+		case neededIgnore:
 			file.SetIgnored()
 			l.Debugln(f, "Handling ignored file", file)
 			dbUpdateChan <- dbUpdateJob{file, dbUpdateInvalidate}
 
-		case build.IsWindows && fs.WindowsInvalidFilename(file.Name) != nil:
+		case neededInvalidName:
 			if file.IsDeleted() {
 				// Just pretend we deleted it, no reason to create an error
 				// about a deleted file that we can't have anyway.
@@ -354,49 +386,53 @@ func (f *sendReceiveFolder) processNeeded(snap *db.Snapshot, dbUpdateChan chan<-
 				// ignored at some point.
 				dbUpdateChan <- dbUpdateJob{file, dbUpdateDeleteFile}
 			} else {
-				// We can't pull an invalid file. Grab the error again since
-				// we couldn't assign it directly in the case clause.
+				// We can't pull an invalid file.
 				f.newPullError(file.Name, fs.WindowsInvalidFilename(file.Name))
 				// No reason to retry for this
 				changed--
 			}
 
-		case file.IsDeleted():
-			if file.IsDirectory() {
-				// Perform directory deletions at the end, as we may have
-				// files to delete inside them before we get to that point.
-				dirDeletions = append(dirDeletions, file)
-			} else if file.IsSymlink() {
-				f.deleteFile(file, snap, dbUpdateChan, scanChan)
-			} else {
-				df, ok := snap.Get(protocol.LocalDeviceID, file.Name)
-				// Local file can be already deleted, but with a lower version
-				// number, hence the deletion coming in again as part of
-				// WithNeed, furthermore, the file can simply be of the wrong
-				// type if we haven't yet managed to pull it.
-				if ok && !df.IsDeleted() && !df.IsSymlink() && !df.IsDirectory() && !df.IsInvalid() {
-					fileDeletions[file.Name] = file
-					// Put files into buckets per first hash
-					key := string(df.BlocksHash)
-					buckets[key] = append(buckets[key], df)
-				} else {
-					f.deleteFileWithCurrent(file, df, ok, dbUpdateChan, scanChan)
-				}
+		case neededDeleteDir:
+			// Perform directory deletions at the end, as we may have
+			// files to delete inside them before we get to that point.
+			dirDeletions = append(dirDeletions, file)
+
+		case neededDeleteSymlink, neededDeleteMissing:
+			// Deleted along with the files, after checking that there
+			// aren't too many deletions at once.
+			fileDeletions[file.Name] = file
+
+		case neededDeleteFile:
+			fileDeletions[file.Name] = file
+			// Put files into buckets per first hash
+			key := string(cur.BlocksHash)
+			buckets[key] = append(buckets[key], cur)
+
+		case neededNotSelected:
+			// Left to be fetched on demand, no reason to retry
+			l.Debugln(f, "Skipping item not selected for sync", file.Name)
+			changed--
+
+		case neededShortcut:
+			// We are supposed to copy the entire file, and then fetch nothing. We
+			// are only updating metadata, so we don't actually *need* to make the
+			// copy.
+			f.shortcutFile(file, dbUpdateChan)
+
+		case neededKeepLocal:
+			if f.keepConflictWinner(file, cur, dbUpdateChan, scanChan) {
+				l.Debugln(f, "Keeping local file winning conflict", file.Name)
+				break
 			}
+			// The local file changed since it was last scanned, so pull
+			// as usual and let the conflict be handled then.
+			f.queue.Push(file.Name, file.Size, file.ModTime())
 
-		case file.Type == protocol.FileInfoTypeFile:
-			curFile, hasCurFile := snap.Get(protocol.LocalDeviceID, file.Name)
-			if hasCurFile && file.BlocksEqual(curFile) {
-				// We are supposed to copy the entire file, and then fetch nothing. We
-				// are only updating metadata, so we don't actually *need* to make the
-				// copy.
-				f.shortcutFile(file, dbUpdateChan)
-			} else {
-				// Queue files for processing after directories and symlinks.
-				f.queue.Push(file.Name, file.Size, file.ModTime())
-			}
+		case neededPullFile:
+			// Queue files for processing after directories and symlinks.
+			f.queue.Push(file.Name, file.Size, file.ModTime())
 
-		case (build.IsWindows || build.IsAndroid) && file.IsSymlink():
+		case neededUnsupportedSymlink:
 			if err := f.handleSymlinkCheckExisting(file, snap, scanChan); err != nil {
 				f.newPullError(file.Name, fmt.Errorf("handling unsupported symlink: %w", err))
 				break
@@ -405,21 +441,17 @@ func (f *sendReceiveFolder) processNeeded(snap *db.Snapshot, dbUpdateChan chan<-
 			l.Debugln(f, "Invalidating symlink (unsupported)", file.Name)
 			dbUpdateChan <- dbUpdateJob{file, dbUpdateInvalidate}
 
-		case file.IsDirectory() && !file.IsSymlink():
+		case neededDir:
 			l.Debugln(f, "Handling directory", file.Name)
 			if f.checkParent(file.Name, scanChan) {
 				f.handleDir(file, snap, dbUpdateChan, scanChan)
 			}
 
-		case file.IsSymlink():
+		case neededSymlink:
 			l.Debugln(f, "Handling symlink", file.Name)
 			if f.checkParent(file.Name, scanChan) {
 				f.handleSymlink(file, snap, dbUpdateChan, scanChan)
 			}
-
-		default:
-			l.Warnln(file)
-			panic("unhandleable item type, can't happen")
 		}
 
 		return true
@@ -515,6 +547,98 @@ nextFile:
 	return changed, fileDeletions, dirDeletions, nil
 }
 
+// neededAction is what pulling does with a needed item.
+type neededAction int
+
+const (
+	neededSkip               neededAction = iota // deletion ignored by configuration
+	neededIgnore                                 // ignored, only invalidated in the database
+	neededInvalidName                            // name that can't exist on this system
+	neededNotSelected                            // not selected for selective sync
+	neededDeleteDir                              // directory deletion, done last
+	neededDeleteSymlink                          // symlink deletion
+	neededDeleteFile                             // deletion of a local file, possibly renamed
+	neededDeleteMissing                          // deletion of something that isn't a local file
+	neededShortcut                               // file with only metadata changes
+	neededKeepLocal                              // file whose local copy wins the conflict
+	neededPullFile                               // file to pull
+	neededUnsupportedSymlink                     // symlink that can't be created on this system
+	neededDir                                    // directory to create or update
+	neededSymlink                                // symlink to create or update
+)
+
+// needsApproval returns true if the action may change the folder on disk,
+// and so needs approval in folders that require it.
+func (a neededAction) needsApproval() bool {
+	switch a {
+	case neededSkip, neededIgnore, neededInvalidName, neededNotSelected, neededUnsupportedSymlink:
+		return false
+	default:
+		return true
+	}
+}
+
+// classifyNeeded decides what pulling does with the needed item, going by
+// the database and configuration only, and returns it together with the
+// current local version of the item. Both pulling and the pull preview go
+// by it.
+func (f *sendReceiveFolder) classifyNeeded(file protocol.FileInfo, snap *db.Snapshot) (neededAction, protocol.FileInfo, bool) {
+	if f.IgnoreDelete && file.IsDeleted() {
+		return neededSkip, protocol.FileInfo{}, false
+	}
+	if f.ignores.Match(file.Name).IsIgnored() {
+		return neededIgnore, protocol.FileInfo{}, false
+	}
+	if build.IsWindows && fs.WindowsInvalidFilename(file.Name) != nil {
+		return neededInvalidName, protocol.FileInfo{}, false
+	}
+
+	cur, hasCur := snap.Get(protocol.LocalDeviceID, file.Name)
+	switch {
+	case file.IsDeleted():
+		switch {
+		case file.IsDirectory():
+			return neededDeleteDir, cur, hasCur
+		case file.IsSymlink():
+			return neededDeleteSymlink, cur, hasCur
+		case hasCur && !cur.IsDeleted() && !cur.IsSymlink() && !cur.IsDirectory() && !cur.IsInvalid():
+			return neededDeleteFile, cur, hasCur
+		default:
+			// Local file can be already deleted, but with a lower version
+			// number, hence the deletion coming in again as part of
+			// WithNeed, furthermore, the file can simply be of the wrong
+			// type if we haven't yet managed to pull it.
+			return neededDeleteMissing, cur, hasCur
+		}
+
+	case !f.selectedForSync(file, cur, hasCur):
+		return neededNotSelected, cur, hasCur
+
+	case file.Type == protocol.FileInfoTypeFile:
+		switch {
+		case hasCur && file.BlocksEqual(cur):
+			return neededShortcut, cur, hasCur
+		case f.localWinsConflict(file, cur, hasCur):
+			return neededKeepLocal, cur, hasCur
+		default:
+			return neededPullFile, cur, hasCur
+		}
+
+	case (build.IsWindows || build.IsAndroid) && file.IsSymlink():
+		return neededUnsupportedSymlink, cur, hasCur
+
+	case file.IsDirectory() && !file.IsSymlink():
+		return neededDir, cur, hasCur
+
+	case file.IsSymlink():
+		return neededSymlink, cur, hasCur
+
+	default:
+		l.Warnln(file)
+		panic("unhandleable item type, can't happen")
+	}
+}
+
 func popCandidate(buckets map[string][]protocol.FileInfo, key string) (protocol.FileInfo, bool) {
 	cands := buckets[key]
 	if len(cands) == 0 {
@@ -603,9 +727,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, snap *db.Snapshot,
 			// archiving.
 			// Symlinks aren't checked for conflicts.
 
-			err = f.inWritableDir(func(name string) error {
-				return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-			}, curFile.Name)
+			err = f.moveConflictingAside(curFile, file, snap, scanChan)
 		} else {
 			err = f.deleteItemOnDisk(curFile, snap, scanChan)
 		}
@@ -793,9 +915,7 @@ func (f *sendReceiveFolder) handleSymlinkCheckExisting(file protocol.FileInfo, s
 		// archiving.
 		// Directories and symlinks aren't checked for conflicts.
 
-		return f.inWritableDir(func(name string) error {
-			return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-		}, curFile.Name)
+		return f.moveConflictingAside(curFile, file, snap, scanChan)
 	} else {
 		return f.deleteItemOnDisk(curFile, snap, scanChan)
 	}
@@ -902,7 +1022,7 @@ func (f *sendReceiveFolder) deleteFileWithCurrent(file, cur protocol.FileInfo, h
 	}
 
 	if f.versioner != nil && !cur.IsSymlink() {
-		err = f.inWritableDir(f.versioner.Archive, file.Name)
+		err = f.inWritableDir(f.archiveFn(cur), file.Name)
 	} else {
 		err = f.inWritableDir(f.mtimefs.Remove, file.Name)
 	}
@@ -1021,7 +1141,7 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, sn
 		if err == nil {
 			err = osutil.Copy(f.CopyRangeMethod.ToFS(), f.mtimefs, f.mtimefs, source.Name, tempName)
 			if err == nil {
-				err = f.inWritableDir(f.versioner.Archive, source.Name)
+				err = f.inWritableDir(f.archiveFn(source), source.Name)
 			}
 		}
 	} else {
@@ -1633,10 +1753,23 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 			// should file it away as a conflict instead of just removing or
 			// archiving.
 			// Directories and symlinks aren't checked for conflicts.
-
-			err = f.inWritableDir(func(name string) error {
-				return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-			}, curFile.Name)
+			// Depending on the conflict strategy, we may replace the
+			// existing file instead. Keeping it was decided before
+			// pulling, in localWinsConflict.
+
+			// If there is a merge command we try that before resorting to
+			// a conflict copy.
+
+			if f.conflictOutcome(curFile, file) == conflictOutcomeCopy && f.MergeCommand != "" {
+				merged, err := f.mergeConflicting(curFile, file, tempName)
+				if err == nil {
+					f.saveMergeBase(merged)
+					dbUpdateChan <- dbUpdateJob{merged, dbUpdateHandleFile}
+					return nil
+				}
+				l.Infof("Puller (folder %s, item %q): merging conflict failed, keeping a conflict copy: %v", f.Description(), file.Name, err)
+			}
+			err = f.moveConflictingAside(curFile, file, snap, scanChan)
 		} else {
 			err = f.deleteItemOnDisk(curFile, snap, scanChan)
 		}
@@ -1658,6 +1791,7 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 
 	// Record the updated file in the index
 	dbUpdateChan <- dbUpdateJob{file, dbUpdateHandleFile}
+	f.saveMergeBase(file)
 	return nil
 }
 
@@ -1838,6 +1972,85 @@ func (f *sendReceiveFolder) inConflict(current, replacement protocol.Vector) boo
 	return false
 }
 
+type conflictOutcome int
+
+const (
+	// The local file is moved to a conflict copy.
+	conflictOutcomeCopy conflictOutcome = iota
+	// The local file is replaced, and archived if there is a versioner.
+	conflictOutcomeReplace
+	// The local file is kept, and the remote change is discarded.
+	conflictOutcomeKeep
+)
+
+// conflictOutcome returns what should happen to the local file cur, which
+// is in conflict with the remote file, according to the conflict strategy
+// of the folder.
+func (f *sendReceiveFolder) conflictOutcome(cur, file protocol.FileInfo) conflictOutcome {
+	switch f.ConflictStrategy {
+	case config.ConflictStrategyNewest:
+		if cur.WinsConflict(file) {
+			return conflictOutcomeKeep
+		}
+		return conflictOutcomeReplace
+
+	case config.ConflictStrategyDevice:
+		if f.ConflictWinnerDevice == protocol.EmptyDeviceID {
+			break
+		}
+		winner := f.ConflictWinnerDevice.Short()
+		if file.ModifiedBy == winner {
+			return conflictOutcomeReplace
+		}
+		if cur.ModifiedBy == winner {
+			return conflictOutcomeKeep
+		}
+
+	case config.ConflictStrategyVersion:
+		if f.versioner != nil {
+			return conflictOutcomeReplace
+		}
+	}
+	return conflictOutcomeCopy
+}
+
+// moveConflictingAside gets the local file cur, which is in conflict with
+// the remote file, out of the way by replacing it or by moving it to a
+// conflict copy. The local file can't be kept here, so in that case a
+// conflict copy is made.
+func (f *sendReceiveFolder) moveConflictingAside(cur, file protocol.FileInfo, snap *db.Snapshot, scanChan chan<- string) error {
+	if f.conflictOutcome(cur, file) == conflictOutcomeReplace {
+		return f.deleteItemOnDisk(cur, snap, scanChan)
+	}
+	return f.inWritableDir(func(name string) error {
+		return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
+	}, cur.Name)
+}
+
+// localWinsConflict returns whether the local file cur is in conflict with
+// the needed file and wins according to the conflict strategy, in which
+// case the remote change is discarded without pulling it.
+func (f *sendReceiveFolder) localWinsConflict(file, cur protocol.FileInfo, hasCur bool) bool {
+	if !hasCur || cur.IsDeleted() || cur.IsInvalid() || cur.IsDirectory() || cur.IsSymlink() || !f.inConflict(cur.Version, file.Version) {
+		return false
+	}
+	return f.conflictOutcome(cur, file) == conflictOutcomeKeep
+}
+
+// keepConflictWinner keeps the local file winning the conflict. Its version
+// is bumped past the remote one, so that it wins on the other devices too.
+// A local file that changed since it was last scanned is left for the
+// puller to deal with, after scanning.
+func (f *sendReceiveFolder) keepConflictWinner(file, cur protocol.FileInfo, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) bool {
+	stat, err := f.mtimefs.Lstat(cur.Name)
+	if err != nil || f.scanIfItemChanged(cur.Name, stat, cur, true, false, scanChan) != nil {
+		return false
+	}
+	cur.Version = cur.Version.Merge(file.Version).Update(f.shortID)
+	dbUpdateChan <- dbUpdateJob{cur, dbUpdateHandleFile}
+	return true
+}
+
 func (f *sendReceiveFolder) moveForConflict(name, lastModBy string, scanChan chan<- string) error {
 	if isConflict(name) {
 		l.Infoln("Conflict for", name, "which is already a conflict copy; not copying again.")
@@ -1925,12 +2138,23 @@ func (f *sendReceiveFolder) deleteItemOnDisk(item protocol.FileInfo, snap *db.Sn
 		// an error.
 		// Symlinks aren't archived.
 
-		return f.inWritableDir(f.versioner.Archive, item.Name)
+		return f.inWritableDir(f.archiveFn(item), item.Name)
 	}
 
 	return f.inWritableDir(f.mtimefs.Remove, item.Name)
 }
 
+// archiveFn returns a function archiving a file with the versioner, letting
+// it make use of what we know about the file on disk.
+func (f *sendReceiveFolder) archiveFn(file protocol.FileInfo) func(string) error {
+	if fa, ok := f.versioner.(versioner.FileArchiver); ok {
+		return func(name string) error {
+			return fa.ArchiveFile(name, &file)
+		}
+	}
+	return f.versioner.Archive
+}
+
 // deleteDirOnDisk attempts to delete a directory. It checks for files/dirs inside
 // the directory and removes them if possible or returns an error if it fails
 func (f *sendReceiveFolder) deleteDirOnDisk(dir string, snap *db.Snapshot, scanChan chan<- string) error {
@@ -28,6 +28,7 @@ import (
 	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/versioner"
 )
 
 var blocks = []protocol.BlockInfo{
@@ -975,6 +976,122 @@ func TestSRConflictReplaceFileByLink(t *testing.T) {
 	}
 }
 
+// TestSRConflictStrategies checks what happens to a local file that is in
+// conflict with a pulled file, for each conflict strategy.
+func TestSRConflictStrategies(t *testing.T) {
+	type expectation int
+	const (
+		expectCopy expectation = iota
+		expectReplace
+		expectKeep
+	)
+	cases := []struct {
+		name      string
+		strategy  config.ConflictStrategy
+		winner    protocol.DeviceID
+		remoteAge int64 // seconds the remote file is older than the local one
+		versioner bool
+		expect    expectation
+	}{
+		{"copy", config.ConflictStrategyCopy, protocol.EmptyDeviceID, 0, false, expectCopy},
+		{"newest remote", config.ConflictStrategyNewest, protocol.EmptyDeviceID, -10, false, expectReplace},
+		{"newest local", config.ConflictStrategyNewest, protocol.EmptyDeviceID, 10, false, expectKeep},
+		{"device remote", config.ConflictStrategyDevice, device1, 0, false, expectReplace},
+		{"device local", config.ConflictStrategyDevice, myID, 0, false, expectKeep},
+		{"device other", config.ConflictStrategyDevice, device2, 0, false, expectCopy},
+		{"version", config.ConflictStrategyVersion, protocol.EmptyDeviceID, 0, true, expectReplace},
+		{"version without versioner", config.ConflictStrategyVersion, protocol.EmptyDeviceID, 0, false, expectCopy},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, f, wcfgCancel := setupSendReceiveFolder(t)
+			defer wcfgCancel()
+			ffs := f.Filesystem(nil)
+			f.ConflictStrategy = tc.strategy
+			f.ConflictWinnerDevice = tc.winner
+			if tc.versioner {
+				f.Versioning = config.VersioningConfiguration{
+					Type:   "simple",
+					FSType: config.FilesystemTypeFake,
+					FSPath: rand.String(32),
+				}
+				ver, err := versioner.New(f.FolderConfiguration)
+				must(t, err)
+				f.versioner = ver
+			}
+
+			name := "foo"
+			writeFile(t, ffs, name, []byte("local"))
+			must(t, f.scanSubdirs(nil))
+			snap := dbSnapshot(t, m, f.ID)
+			defer snap.Release()
+			cur, ok := snap.Get(protocol.LocalDeviceID, name)
+			if !ok {
+				t.Fatal("file is missing")
+			}
+
+			remote := cur
+			remote.Version = protocol.Vector{}.Update(device1.Short())
+			remote.ModifiedBy = device1.Short()
+			remote.ModifiedS = cur.ModifiedS - tc.remoteAge
+			remote.Size = int64(len("remote"))
+
+			// Keeping the local file is decided before pulling, the rest
+			// when finishing the pulled file.
+			scanChan := make(chan string, 1)
+			dbUpdateChan := make(chan dbUpdateJob, 1)
+			kept := f.localWinsConflict(remote, cur, true) && f.keepConflictWinner(remote, cur, dbUpdateChan, scanChan)
+			if kept != (tc.expect == expectKeep) {
+				t.Fatalf("Expected kept=%v before pulling, got %v", tc.expect == expectKeep, kept)
+			}
+			if !kept {
+				temp := fs.TempName(name)
+				writeFile(t, ffs, temp, []byte("remote"))
+				must(t, f.performFinish(remote, cur, true, temp, snap, dbUpdateChan, scanChan))
+			}
+
+			update := <-dbUpdateChan
+			confls := existingConflicts(name, ffs)
+			content := readFile(t, ffs, name)
+
+			switch tc.expect {
+			case expectCopy:
+				if len(confls) != 1 {
+					t.Error("Expected one conflict, got", len(confls))
+				}
+				if content != "remote" {
+					t.Errorf("Expected remote content, got %q", content)
+				}
+			case expectReplace:
+				if len(confls) != 0 {
+					t.Error("Expected no conflict, got", len(confls))
+				}
+				if content != "remote" {
+					t.Errorf("Expected remote content, got %q", content)
+				}
+			case expectKeep:
+				if len(confls) != 0 {
+					t.Error("Expected no conflict, got", len(confls))
+				}
+				if content != "local" {
+					t.Errorf("Expected local content, got %q", content)
+				}
+				if c := update.file.Version.Compare(remote.Version); c != protocol.Greater {
+					t.Errorf("Expected kept version to be greater than the remote one, got %v", c)
+				}
+			}
+
+			if tc.versioner {
+				versionsFs := fs.NewFilesystem(f.Versioning.FSType.ToFS(), f.Versioning.FSPath)
+				if matches, err := versionsFs.Glob(name + "~*"); err != nil || len(matches) != 1 {
+					t.Errorf("Expected one archived version, got %v, %v", matches, err)
+				}
+			}
+		})
+	}
+}
+
 // TestDeleteBehindSymlink checks that we don't delete or schedule a scan
 // when trying to delete a file behind a symlink.
 func TestDeleteBehindSymlink(t *testing.T) {
@@ -271,7 +271,7 @@ func (c *folderSummaryService) processUpdate(ev events.Event) {
 
 	case events.StateChanged:
 		data := ev.Data.(map[string]interface{})
-		if data["to"].(string) != "idle" {
+		if to := data["to"].(string); to != FolderIdle.String() && to != FolderScheduledIdle.String() && to != FolderPreview.String() {
 			return
 		}
 		if from := data["from"].(string); from != "syncing" && from != "sync-preparing" {
@@ -392,6 +392,8 @@ func (c *folderSummaryService) sendSummary(ctx context.Context, folder string) {
 	metricFolderSummary.WithLabelValues(folder, metricScopeNeed, metricTypeDeleted).Set(float64(data.NeedDeletes))
 	metricFolderSummary.WithLabelValues(folder, metricScopeNeed, metricTypeBytes).Set(float64(data.NeedBytes))
 
+	metricFolderErrors.WithLabelValues(folder).Set(float64(data.Errors))
+
 	for _, devCfg := range c.cfg.Folders()[folder].Devices {
 		select {
 		case <-ctx.Done():
@@ -415,5 +417,11 @@ func (c *folderSummaryService) sendSummary(ctx context.Context, folder string) {
 		ev["folder"] = folder
 		ev["device"] = devCfg.DeviceID.String()
 		c.evLogger.Log(events.FolderCompletion, ev)
+
+		device := devCfg.DeviceID.String()
+		metricFolderCompletionPct.WithLabelValues(folder, device).Set(comp.CompletionPct)
+		metricFolderRemoteNeed.WithLabelValues(folder, device, metricTypeItems).Set(float64(comp.NeedItems))
+		metricFolderRemoteNeed.WithLabelValues(folder, device, metricTypeDeleted).Set(float64(comp.NeedDeletes))
+		metricFolderRemoteNeed.WithLabelValues(folder, device, metricTypeBytes).Set(float64(comp.NeedBytes))
 	}
 }
@@ -8,12 +8,15 @@ package model
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 
 	"github.com/syncthing/syncthing/lib/build"
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
@@ -191,3 +194,55 @@ func TestSetPlatformData(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSyncWindowDefersScan(t *testing.T) {
+	// A window on another day, so that we're outside of it now.
+	otherDay := strings.ToLower(time.Now().Add(48 * time.Hour).Weekday().String()[:3])
+	fcfg := config.FolderConfiguration{
+		FilesystemType:  config.FilesystemTypeFake,
+		ID:              "default",
+		Path:            rand.String(32),
+		Type:            config.FolderTypeSendReceive,
+		RescanIntervalS: 1,
+		MarkerName:      config.DefaultMarkerName,
+		SyncWindows:     []config.SyncWindow{{Days: otherDay}},
+	}
+	cfg, cancel := newConfigWrapper(config.Configuration{
+		Version: config.CurrentVersion,
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{{DeviceID: device1}},
+	})
+	defer cancel()
+	m := newModel(t, cfg, myID, nil)
+
+	ffs := fcfg.Filesystem(nil)
+	ffs.Mkdir(config.DefaultMarkerName, 0o755)
+
+	sub := m.evLogger.Subscribe(events.StateChanged)
+	defer sub.Unsubscribe()
+	m.ServeBackground()
+	defer cleanupModel(m)
+
+	// The initial scan happens regardless, after which the folder waits
+	// for its sync window.
+	timeout := time.After(5 * time.Second)
+	for scheduled := false; !scheduled; {
+		select {
+		case ev := <-sub.C():
+			data := ev.Data.(map[string]interface{})
+			scheduled = data["from"] == FolderScanning.String() && data["to"] == FolderScheduledIdle.String()
+		case <-timeout:
+			t.Fatal("timed out waiting for scheduled-idle state")
+		}
+	}
+
+	// Periodic scans are deferred.
+	writeFile(t, ffs, "file", []byte("data"))
+	time.Sleep(2 * time.Second)
+	if _, ok := m.testCurrentFolderFile(fcfg.ID, "file"); ok {
+		t.Error("file was scanned outside of the sync window")
+	}
+	if state, _, err := m.State(fcfg.ID); state != FolderScheduledIdle.String() || err != nil {
+		t.Errorf("unexpected state %v (err %v)", state, err)
+	}
+}
@@ -25,6 +25,8 @@ const (
 	FolderCleaning
 	FolderCleanWaiting
 	FolderError
+	FolderScheduledIdle
+	FolderPreview
 )
 
 func (s folderState) String() string {
@@ -47,6 +49,10 @@ func (s folderState) String() string {
 		return "clean-waiting"
 	case FolderError:
 		return "error"
+	case FolderScheduledIdle:
+		return "scheduled-idle"
+	case FolderPreview:
+		return "preview"
 	default:
 		return "unknown"
 	}
@@ -88,6 +94,13 @@ type stateTracker struct {
 	current folderState
 	err     error
 	changed time.Time
+
+	// When outside of its sync windows the folder is scheduled-idle
+	// instead of idle.
+	outsideSyncWindow bool
+	// When changes are held back until a pull preview is approved the
+	// folder is in preview instead of idle.
+	awaitingApproval bool
 }
 
 func newStateTracker(id string, evLogger events.Logger) stateTracker {
@@ -107,6 +120,9 @@ func (s *stateTracker) setState(newState folderState) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
+	if newState == FolderIdle {
+		newState = s.idleStateLocked()
+	}
 	if newState == s.current {
 		return
 	}
@@ -137,6 +153,45 @@ func (s *stateTracker) setState(newState folderState) {
 	s.evLogger.Log(events.StateChanged, eventData)
 }
 
+// setOutsideSyncWindow sets whether the folder is outside of its sync
+// windows, switching between idle and scheduled-idle as appropriate.
+func (s *stateTracker) setOutsideSyncWindow(outside bool) {
+	s.mut.Lock()
+	s.outsideSyncWindow = outside
+	current := s.current
+	s.mut.Unlock()
+
+	if current == FolderIdle || current == FolderScheduledIdle || current == FolderPreview {
+		s.setState(FolderIdle)
+	}
+}
+
+// setAwaitingApproval sets whether the folder holds back changes until a
+// pull preview is approved, switching between idle and preview as
+// appropriate.
+func (s *stateTracker) setAwaitingApproval(awaiting bool) {
+	s.mut.Lock()
+	s.awaitingApproval = awaiting
+	current := s.current
+	s.mut.Unlock()
+
+	if current == FolderIdle || current == FolderScheduledIdle || current == FolderPreview {
+		s.setState(FolderIdle)
+	}
+}
+
+// idleStateLocked returns the state the folder is in when it's idle.
+func (s *stateTracker) idleStateLocked() folderState {
+	switch {
+	case s.outsideSyncWindow:
+		return FolderScheduledIdle
+	case s.awaitingApproval:
+		return FolderPreview
+	default:
+		return FolderIdle
+	}
+}
+
 // getState returns the current state, the time when it last changed, and the
 // current error or nil.
 func (s *stateTracker) getState() (current folderState, changed time.Time, err error) {
@@ -165,7 +220,7 @@ func (s *stateTracker) setError(err error) {
 		eventData["error"] = err.Error()
 		s.current = FolderError
 	} else {
-		s.current = FolderIdle
+		s.current = s.idleStateLocked()
 	}
 
 	eventData["to"] = s.current.String()
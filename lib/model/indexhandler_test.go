@@ -31,12 +31,12 @@ func TestIndexhandlerConcurrency(t *testing.T) {
 	ci := &protomock.ConnectionInfo{}
 
 	m1 := &mocks.Model{}
-	c1 := protocol.NewConnection(protocol.EmptyDeviceID, ar, bw, testutil.NoopCloser{}, m1, ci, protocol.CompressionNever, nil, nil)
+	c1 := protocol.NewConnection(protocol.EmptyDeviceID, ar, bw, testutil.NoopCloser{}, protocolModel{m1}, ci, protocol.CompressionNever, nil, nil)
 	c1.Start()
 	defer c1.Close(io.EOF)
 
 	m2 := &mocks.Model{}
-	c2 := protocol.NewConnection(protocol.EmptyDeviceID, br, aw, testutil.NoopCloser{}, m2, ci, protocol.CompressionNever, nil, nil)
+	c2 := protocol.NewConnection(protocol.EmptyDeviceID, br, aw, testutil.NoopCloser{}, protocolModel{m2}, ci, protocol.CompressionNever, nil, nil)
 	c2.Start()
 	defer c2.Close(io.EOF)
 
@@ -97,3 +97,14 @@ func TestIndexhandlerConcurrency(t *testing.T) {
 		t.Error("didn't receive all expected messages", recvdEntries, sentEntries)
 	}
 }
+
+// protocolModel is the model as connections see it, for tests that connect
+// it directly instead of through the connection service, which handles the
+// direct addresses.
+type protocolModel struct {
+	*mocks.Model
+}
+
+func (protocolModel) DirectAddresses(protocol.Connection, *protocol.DirectAddresses) error {
+	return nil
+}
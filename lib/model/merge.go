@@ -0,0 +1,375 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syncthing/syncthing/internal/gen/bep"
+	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// Merge bases are copies of files as they were announced or pulled, used
+// as the common ancestor when merging a conflict. They are kept inside the
+// folder marker directory, only for files up to maxMergeBaseSize, and only
+// in folders with a merge command. Each file has a directory of up to
+// maxMergeBases copies, named after the version they are of, with the
+// version itself alongside in a file with the mergeBaseVersionExt
+// extension.
+const (
+	maxMergeBaseSize    = 4 << 20
+	maxMergeBases       = 4
+	mergeBaseDirName    = "mergebase"
+	mergeBaseVersionExt = ".version"
+)
+
+var errNoMergeBase = errors.New("no common ancestor to merge against")
+
+// mergeBaseDir returns the directory the merge bases are kept in, or ""
+// if there is no marker directory to keep them in.
+func (f *folder) mergeBaseDir() string {
+	marker := filepath.Clean(f.MarkerName)
+	if marker == "." || marker == "" {
+		return ""
+	}
+	return filepath.Join(marker, mergeBaseDirName)
+}
+
+// mergeBasePath returns the directory the merge bases of the file are kept
+// in.
+func (f *folder) mergeBasePath(name string) string {
+	return filepath.Join(f.mergeBaseDir(), name)
+}
+
+// mergeBaseKey returns the name of the merge base of the given version.
+func mergeBaseKey(version protocol.Vector) (string, []byte, error) {
+	bs, err := proto.Marshal(version.ToWire())
+	if err != nil {
+		return "", nil, err
+	}
+	hash := sha256.Sum256(bs)
+	return hex.EncodeToString(hash[:8]), bs, nil
+}
+
+// saveMergeBase keeps a copy of the file as it is on disk, which was just
+// announced or pulled, in case a later change to it ends up in conflict.
+func (f *folder) saveMergeBase(file protocol.FileInfo) {
+	if f.MergeCommand == "" || f.mergeBaseDir() == "" {
+		return
+	}
+	if file.IsDeleted() || file.IsInvalid() || file.Type != protocol.FileInfoTypeFile || file.Size > maxMergeBaseSize {
+		f.removeMergeBase(file.Name)
+		return
+	}
+	// The copy must be of the announced version, not of later changes
+	// that haven't been scanned yet.
+	if info, err := f.mtimefs.Lstat(file.Name); err != nil || info.Size() != file.Size || !info.ModTime().Equal(file.ModTime()) {
+		return
+	}
+
+	key, version, err := mergeBaseKey(file.Version)
+	if err != nil {
+		l.Debugln(f, "saving merge base:", err)
+		return
+	}
+	dir := f.mergeBasePath(file.Name)
+	if info, err := f.mtimefs.Lstat(dir); err == nil && !info.IsDir() {
+		// Left over from keeping a single merge base per file.
+		f.removeMergeBase(file.Name)
+	}
+	if err := f.mtimefs.MkdirAll(dir, 0o755); err != nil {
+		l.Debugln(f, "creating merge base directory:", err)
+		return
+	}
+	base := filepath.Join(dir, key)
+	if err := osutil.Copy(f.CopyRangeMethod.ToFS(), f.mtimefs, f.mtimefs, file.Name, base); err != nil {
+		l.Debugln(f, "saving merge base:", err)
+		return
+	}
+	if err := fs.WriteFile(f.mtimefs, base+mergeBaseVersionExt, version, 0o644); err != nil {
+		l.Debugln(f, "saving merge base:", err)
+		_ = f.mtimefs.Remove(base)
+		return
+	}
+	f.trimMergeBases(dir)
+}
+
+type mergeBase struct {
+	path    string
+	version protocol.Vector
+	saved   time.Time
+}
+
+// mergeBases returns the merge bases kept in the given directory.
+func (f *folder) mergeBases(dir string) []mergeBase {
+	names, err := f.mtimefs.DirNames(dir)
+	if err != nil {
+		return nil
+	}
+	var bases []mergeBase
+	for _, name := range names {
+		if !strings.HasSuffix(name, mergeBaseVersionExt) {
+			continue
+		}
+		versionPath := filepath.Join(dir, name)
+		info, err := f.mtimefs.Lstat(versionPath)
+		if err != nil {
+			continue
+		}
+		bs, err := readMergeBaseVersion(f.mtimefs, versionPath)
+		if err != nil {
+			continue
+		}
+		var wire bep.Vector
+		if err := proto.Unmarshal(bs, &wire); err != nil {
+			continue
+		}
+		bases = append(bases, mergeBase{
+			path:    strings.TrimSuffix(versionPath, mergeBaseVersionExt),
+			version: protocol.VectorFromWire(&wire),
+			saved:   info.ModTime(),
+		})
+	}
+	return bases
+}
+
+func readMergeBaseVersion(filesystem fs.Filesystem, name string) ([]byte, error) {
+	fd, err := filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return io.ReadAll(fd)
+}
+
+// trimMergeBases removes the oldest merge bases in the given directory,
+// beyond maxMergeBases.
+func (f *folder) trimMergeBases(dir string) {
+	bases := f.mergeBases(dir)
+	if len(bases) <= maxMergeBases {
+		return
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		return bases[i].saved.Before(bases[j].saved)
+	})
+	for _, base := range bases[:len(bases)-maxMergeBases] {
+		_ = f.mtimefs.Remove(base.path + mergeBaseVersionExt)
+		_ = f.mtimefs.Remove(base.path)
+	}
+}
+
+// commonMergeBase returns the path to the latest merge base of the file
+// that both the local and remote versions descend from, if there is one.
+func (f *folder) commonMergeBase(name string, local, remote protocol.Vector) (string, bool) {
+	var common *mergeBase
+	for _, base := range f.mergeBases(f.mergeBasePath(name)) {
+		if base.version.IsEmpty() || !base.version.LesserEqual(local) || !base.version.LesserEqual(remote) {
+			continue
+		}
+		if common == nil || base.version.Compare(common.version) == protocol.Greater {
+			common = &base
+		}
+	}
+	if common == nil {
+		return "", false
+	}
+	return common.path, true
+}
+
+// saveMergeBases keeps merge bases of the given scanned files, which are
+// being announced, or removes them for those that are deleted or no longer
+// regular files.
+func (f *folder) saveMergeBases(files []protocol.FileInfo) {
+	if f.MergeCommand == "" || f.mergeBaseDir() == "" {
+		return
+	}
+	for _, file := range files {
+		f.saveMergeBase(file)
+	}
+}
+
+// pruneMergeBases removes the merge bases of those of the given files that
+// are deleted or no longer regular files, as they are of no further use.
+func (f *folder) pruneMergeBases(files []protocol.FileInfo) {
+	if f.MergeCommand == "" || f.mergeBaseDir() == "" {
+		return
+	}
+	for _, file := range files {
+		if !file.IsDeleted() && file.Type == protocol.FileInfoTypeFile {
+			continue
+		}
+		f.removeMergeBase(file.Name)
+	}
+}
+
+func (f *folder) removeMergeBase(name string) {
+	if err := f.mtimefs.RemoveAll(f.mergeBasePath(name)); err != nil && !fs.IsNotExist(err) {
+		l.Debugln(f, "removing merge base:", err)
+	}
+}
+
+// removeMergeBases removes all merge bases, which are of no use once the
+// folder has no merge command.
+func (f *folder) removeMergeBases() {
+	if f.MergeCommand != "" || f.mergeBaseDir() == "" {
+		return
+	}
+	if err := f.mtimefs.RemoveAll(f.mergeBaseDir()); err != nil && !fs.IsNotExist(err) {
+		l.Debugln(f, "removing merge bases:", err)
+	}
+}
+
+// mergeConflicting runs the merge command on the local file cur and the
+// pulled remote file in tempName, which are in conflict. If the command
+// succeeds the merged result replaces the local file, and the returned file
+// info describes it, with a version that supersedes both.
+func (f *sendReceiveFolder) mergeConflicting(cur, file protocol.FileInfo, tempName string) (protocol.FileInfo, error) {
+	base, ok := f.commonMergeBase(file.Name, cur.Version, file.Version)
+	if !ok {
+		return protocol.FileInfo{}, errNoMergeBase
+	}
+
+	// The command works on copies in a temporary directory, so that it
+	// gets real paths whatever the folder filesystem is.
+	dir, err := os.MkdirTemp("", "syncthing-merge-")
+	if err != nil {
+		return protocol.FileInfo{}, err
+	}
+	defer os.RemoveAll(dir)
+	tmpFs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
+	method := f.CopyRangeMethod.ToFS()
+
+	// Keep the extension, as merge tools may look at it.
+	ext := filepath.Ext(file.Name)
+	ancestor, local, remote, merged := "ancestor"+ext, "local"+ext, "remote"+ext, "merged"+ext
+
+	for _, c := range []struct {
+		src  fs.Filesystem
+		from string
+		to   string
+	}{
+		{f.mtimefs, base, ancestor},
+		{f.mtimefs, cur.Name, local},
+		{f.mtimefs, cur.Name, merged},
+		{f.mtimefs, tempName, remote},
+	} {
+		if err := osutil.Copy(method, c.src, tmpFs, c.from, c.to); err != nil {
+			return protocol.FileInfo{}, err
+		}
+	}
+
+	if err := f.runMergeCommand(file.Name, filepath.Join(dir, ancestor), filepath.Join(dir, local), filepath.Join(dir, remote), filepath.Join(dir, merged)); err != nil {
+		return protocol.FileInfo{}, err
+	}
+
+	// Put the result in place, through a temp file of its own so that the
+	// pulled file is left intact should this fail.
+	mergedTemp := fs.TempNameWithPrefix(file.Name, fs.UnixTempPrefix+"merged.")
+	if err := f.placeMerged(tmpFs, merged, mergedTemp, file); err != nil {
+		_ = f.mtimefs.Remove(mergedTemp)
+		return protocol.FileInfo{}, err
+	}
+	_ = f.mtimefs.Remove(tempName)
+
+	info, err := f.mtimefs.Lstat(file.Name)
+	if err != nil {
+		return protocol.FileInfo{}, err
+	}
+	blockSize := protocol.BlockSize(info.Size())
+	blocks, err := scanner.HashFile(f.ctx, f.ID, f.mtimefs, file.Name, blockSize, nil, true)
+	if err != nil {
+		return protocol.FileInfo{}, err
+	}
+
+	result := file
+	result.Version = cur.Version.Merge(file.Version).Update(f.shortID)
+	result.ModifiedBy = f.shortID
+	result.Size = info.Size()
+	result.ModifiedS = info.ModTime().Unix()
+	result.ModifiedNs = int32(info.ModTime().Nanosecond())
+	result.RawBlockSize = int32(blockSize)
+	result.Blocks = blocks
+	result.BlocksHash = protocol.BlocksHash(blocks)
+	return result, nil
+}
+
+func (f *sendReceiveFolder) placeMerged(tmpFs fs.Filesystem, merged, mergedTemp string, file protocol.FileInfo) error {
+	method := f.CopyRangeMethod.ToFS()
+	if err := osutil.Copy(method, tmpFs, f.mtimefs, merged, mergedTemp); err != nil {
+		return err
+	}
+	if !f.IgnorePerms && !file.NoPermissions {
+		if err := f.mtimefs.Chmod(mergedTemp, fs.FileMode(file.Permissions&0o777)); err != nil {
+			return err
+		}
+	}
+	return osutil.RenameOrCopy(method, f.mtimefs, f.mtimefs, mergedTemp, file.Name)
+}
+
+// runMergeCommand runs the merge command of the folder, which is expected
+// to write the result of merging the local and remote files into the
+// merged file, which starts out as a copy of the local one. The command
+// fails if it doesn't exit successfully.
+func (f *sendReceiveFolder) runMergeCommand(name, ancestor, local, remote, merged string) error {
+	command := f.MergeCommand
+	if build.IsWindows {
+		command = strings.ReplaceAll(command, `\`, `\\`)
+	}
+
+	words, err := shellquote.Split(command)
+	if err != nil {
+		return fmt.Errorf("merge command is invalid: %w", err)
+	}
+	if len(words) == 0 {
+		return errors.New("merge command is empty")
+	}
+
+	context := map[string]string{
+		"%FOLDER_FILESYSTEM%": f.mtimefs.Type().String(),
+		"%FOLDER_PATH%":       f.mtimefs.URI(),
+		"%FILE_PATH%":         name,
+		"%ANCESTOR%":          ancestor,
+		"%LOCAL%":             local,
+		"%REMOTE%":            remote,
+		"%MERGED%":            merged,
+	}
+	for i, word := range words {
+		for key, val := range context {
+			word = strings.ReplaceAll(word, key, val)
+		}
+		words[i] = word
+	}
+
+	cmd := exec.CommandContext(f.ctx, words[0], words[1:]...)
+	// filter STGUIAUTH and STGUIAPIKEY from environment variables
+	for _, x := range os.Environ() {
+		if !strings.HasPrefix(x, "STGUIAUTH=") && !strings.HasPrefix(x, "STGUIAPIKEY=") {
+			cmd.Env = append(cmd.Env, x)
+		}
+	}
+	combinedOutput, err := cmd.CombinedOutput()
+	l.Debugln(f, "merge command output:", string(combinedOutput))
+	return err
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestMergeConflict(t *testing.T) {
+	if build.IsWindows {
+		t.Skip("merge command uses sh")
+	}
+
+	cases := []struct {
+		name    string
+		command string
+		merged  bool
+	}{
+		{"success", `sh -c 'cat "$1" "$2" "$3" > "$0"' %MERGED% %ANCESTOR% %LOCAL% %REMOTE%`, true},
+		{"failure", `sh -c 'echo nope > "$0"; exit 1' %MERGED%`, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, f, wcfgCancel := setupSendReceiveFolder(t)
+			defer wcfgCancel()
+			ffs := f.Filesystem(nil)
+			f.MergeCommand = tc.command
+
+			name := "foo.txt"
+			writeFile(t, ffs, name, []byte("base\n"))
+			must(t, f.scanSubdirs(nil))
+			snap := dbSnapshot(t, m, f.ID)
+			base, _ := snap.Get(protocol.LocalDeviceID, name)
+			snap.Release()
+
+			writeFile(t, ffs, name, []byte("local\n"))
+			must(t, f.scanSubdirs(nil))
+			snap = dbSnapshot(t, m, f.ID)
+			defer snap.Release()
+			cur, ok := snap.Get(protocol.LocalDeviceID, name)
+			if !ok {
+				t.Fatal("file is missing")
+			}
+
+			remote := cur
+			remote.Version = base.Version.Update(device1.Short())
+			remote.ModifiedBy = device1.Short()
+			temp := fs.TempName(name)
+			writeFile(t, ffs, temp, []byte("remote\n"))
+
+			scanChan := make(chan string, 1)
+			dbUpdateChan := make(chan dbUpdateJob, 1)
+			must(t, f.performFinish(remote, cur, true, temp, snap, dbUpdateChan, scanChan))
+			update := <-dbUpdateChan
+			confls := existingConflicts(name, ffs)
+
+			if !tc.merged {
+				if len(confls) != 1 {
+					t.Error("Expected one conflict, got", len(confls))
+				}
+				if content := readFile(t, ffs, name); content != "remote\n" {
+					t.Errorf("Expected remote content, got %q", content)
+				}
+				return
+			}
+
+			if len(confls) != 0 {
+				t.Error("Expected no conflict, got", len(confls))
+			}
+			expected := "base\nlocal\nremote\n"
+			if content := readFile(t, ffs, name); content != expected {
+				t.Errorf("Expected merged content, got %q", content)
+			}
+			for _, v := range []protocol.Vector{cur.Version, remote.Version} {
+				if c := update.file.Version.Compare(v); c != protocol.Greater {
+					t.Errorf("Expected merged version to be greater than %v, got %v", v, c)
+				}
+			}
+			if update.file.Size != int64(len(expected)) || update.file.ModifiedBy != f.shortID {
+				t.Errorf("Unexpected merged file info %v", update.file)
+			}
+			if content := readMergeBase(t, f, name, update.file.Version); content != expected {
+				t.Errorf("Expected merge base to be updated, got %q", content)
+			}
+			if _, err := ffs.Lstat(temp); !fs.IsNotExist(err) {
+				t.Error("Expected temp file to be removed, got", err)
+			}
+		})
+	}
+}
+
+func TestMergeBasePruned(t *testing.T) {
+	m, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	ffs := f.Filesystem(nil)
+	f.MergeCommand = "true"
+	f.MarkerName = ".custommarker"
+	must(t, ffs.Mkdir(f.MarkerName, 0o755))
+
+	name := "foo.txt"
+	writeFile(t, ffs, name, []byte("base\n"))
+	must(t, f.scanSubdirs(nil))
+	snap := dbSnapshot(t, m, f.ID)
+	base, _ := snap.Get(protocol.LocalDeviceID, name)
+	snap.Release()
+
+	basePath := filepath.Join(f.MarkerName, "mergebase", name)
+	if content := readMergeBase(t, f, name, base.Version); content != "base\n" {
+		t.Fatalf("Expected merge base in the folder marker, got %q", content)
+	}
+
+	must(t, ffs.Remove(name))
+	must(t, f.scanSubdirs([]string{name}))
+	if _, err := ffs.Lstat(basePath); !fs.IsNotExist(err) {
+		t.Error("Expected merge base to be removed with the file, got", err)
+	}
+}
+
+func TestMergeCommonAncestor(t *testing.T) {
+	if build.IsWindows {
+		t.Skip("merge command uses sh")
+	}
+
+	m, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	ffs := f.Filesystem(nil)
+	f.MergeCommand = `sh -c 'cat "$1" > "$0"' %MERGED% %ANCESTOR%`
+
+	name := "foo.txt"
+	get := func() protocol.FileInfo {
+		t.Helper()
+		snap := dbSnapshot(t, m, f.ID)
+		defer snap.Release()
+		file, ok := snap.Get(protocol.LocalDeviceID, name)
+		if !ok {
+			t.Fatal("file is missing")
+		}
+		return file
+	}
+	merge := func(remote, cur protocol.FileInfo) error {
+		t.Helper()
+		temp := fs.TempName(name)
+		writeFile(t, ffs, temp, []byte("remote\n"))
+		_, err := f.mergeConflicting(cur, remote, temp)
+		return err
+	}
+
+	writeFile(t, ffs, name, []byte("first\n"))
+	must(t, f.scanSubdirs(nil))
+	first := get()
+
+	// A locally changed version, which was announced and then changed
+	// remotely as well as locally, is the common ancestor.
+	writeFile(t, ffs, name, []byte("announced\n"))
+	must(t, f.scanSubdirs(nil))
+	announced := get()
+	writeFile(t, ffs, name, []byte("local\n"))
+	must(t, f.scanSubdirs(nil))
+	cur := get()
+
+	remote := announced
+	remote.Version = announced.Version.Update(device1.Short())
+	must(t, merge(remote, cur))
+	if content := readFile(t, ffs, name); content != "announced\n" {
+		t.Errorf("Expected the announced version as ancestor, got %q", content)
+	}
+
+	// A remote change to the first version has that as common ancestor.
+	writeFile(t, ffs, name, []byte("local\n"))
+	must(t, f.scanSubdirs(nil))
+	cur = get()
+	remote = first
+	remote.Version = first.Version.Update(device1.Short())
+	must(t, merge(remote, cur))
+	if content := readFile(t, ffs, name); content != "first\n" {
+		t.Errorf("Expected the first version as ancestor, got %q", content)
+	}
+
+	// A remote version without common history can't be merged.
+	writeFile(t, ffs, name, []byte("local\n"))
+	must(t, f.scanSubdirs(nil))
+	cur = get()
+	remote = cur
+	remote.Version = protocol.Vector{}.Update(device1.Short())
+	if err := merge(remote, cur); !errors.Is(err, errNoMergeBase) {
+		t.Errorf("Expected %v, got %v", errNoMergeBase, err)
+	}
+}
+
+func readMergeBase(t *testing.T, f *sendReceiveFolder, name string, version protocol.Vector) string {
+	t.Helper()
+	base, ok := f.commonMergeBase(name, version, version)
+	if !ok {
+		t.Fatalf("No merge base of %v for %s", version, name)
+	}
+	return readFile(t, f.Filesystem(nil), base)
+}
@@ -24,6 +24,30 @@ var (
 		Name:      "folder_summary",
 		Help:      "Current folder summary data (counts for global/local/need files/directories/symlinks/deleted/bytes)",
 	}, []string{"folder", "scope", "type"})
+	metricFolderErrors = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "syncthing",
+		Subsystem: "model",
+		Name:      "folder_errors",
+		Help:      "Current number of pull errors, per folder ID",
+	}, []string{"folder"})
+	metricFolderConflicts = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "syncthing",
+		Subsystem: "model",
+		Name:      "folder_conflicts",
+		Help:      "Current number of conflict copies in the folder, per folder ID",
+	}, []string{"folder"})
+	metricFolderCompletionPct = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "syncthing",
+		Subsystem: "model",
+		Name:      "folder_completion_percent",
+		Help:      "Current completion percentage of the folder on a remote device, per folder ID and device ID",
+	}, []string{"folder", "device"})
+	metricFolderRemoteNeed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "syncthing",
+		Subsystem: "model",
+		Name:      "folder_remote_need",
+		Help:      "Current amount of data a remote device needs to complete the folder, per folder ID and device ID (counts for items/deleted/bytes)",
+	}, []string{"folder", "device", "type"})
 
 	metricFolderPulls = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "syncthing",
@@ -75,12 +99,15 @@ const (
 	metricTypeSymlinks    = "symlinks"
 	metricTypeDeleted     = "deleted"
 	metricTypeBytes       = "bytes"
+	metricTypeItems       = "items"
 )
 
 func registerFolderMetrics(folderID string) {
 	// Register metrics for this folder, so that counters are present even
 	// when zero.
 	metricFolderState.WithLabelValues(folderID)
+	metricFolderErrors.WithLabelValues(folderID)
+	metricFolderConflicts.WithLabelValues(folderID)
 	metricFolderPulls.WithLabelValues(folderID)
 	metricFolderPullSeconds.WithLabelValues(folderID)
 	metricFolderScans.WithLabelValues(folderID)
@@ -91,3 +118,11 @@ func registerFolderMetrics(folderID string) {
 	metricFolderProcessedBytesTotal.WithLabelValues(folderID, metricSourceLocalShifted)
 	metricFolderProcessedBytesTotal.WithLabelValues(folderID, metricSourceSkipped)
 }
+
+// forgetFolderDeviceMetrics removes the metrics of the folder on remote
+// devices, so that those of devices it's no longer shared with don't linger.
+// The folder summary sets them again for the devices it's shared with.
+func forgetFolderDeviceMetrics(folderID string) {
+	metricFolderCompletionPct.DeletePartialMatch(prometheus.Labels{"folder": folderID})
+	metricFolderRemoteNeed.DeletePartialMatch(prometheus.Labels{"folder": folderID})
+}
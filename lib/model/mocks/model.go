@@ -23,6 +23,18 @@ type Model struct {
 		arg1 protocol.Connection
 		arg2 protocol.Hello
 	}
+	ApprovePullStub        func(string, string) error
+	approvePullMutex       sync.RWMutex
+	approvePullArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	approvePullReturns struct {
+		result1 error
+	}
+	approvePullReturnsOnCall map[int]struct {
+		result1 error
+	}
 	AvailabilityStub        func(string, protocol.FileInfo, protocol.BlockInfo) ([]model.Availability, error)
 	availabilityMutex       sync.RWMutex
 	availabilityArgsForCall []struct {
@@ -76,6 +88,17 @@ type Model struct {
 		result1 model.FolderCompletion
 		result2 error
 	}
+	ConfirmDeletionsStub        func(string) error
+	confirmDeletionsMutex       sync.RWMutex
+	confirmDeletionsArgsForCall []struct {
+		arg1 string
+	}
+	confirmDeletionsReturns struct {
+		result1 error
+	}
+	confirmDeletionsReturnsOnCall map[int]struct {
+		result1 error
+	}
 	ConnectedToStub        func(protocol.DeviceID) bool
 	connectedToMutex       sync.RWMutex
 	connectedToArgsForCall []struct {
@@ -210,6 +233,31 @@ type Model struct {
 	downloadProgressReturnsOnCall map[int]struct {
 		result1 error
 	}
+	FetchFileStub        func(string, string) error
+	fetchFileMutex       sync.RWMutex
+	fetchFileArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	fetchFileReturns struct {
+		result1 error
+	}
+	fetchFileReturnsOnCall map[int]struct {
+		result1 error
+	}
+	FolderConflictsStub        func(string) ([]model.Conflict, error)
+	folderConflictsMutex       sync.RWMutex
+	folderConflictsArgsForCall []struct {
+		arg1 string
+	}
+	folderConflictsReturns struct {
+		result1 []model.Conflict
+		result2 error
+	}
+	folderConflictsReturnsOnCall map[int]struct {
+		result1 []model.Conflict
+		result2 error
+	}
 	FolderErrorsStub        func(string) ([]model.FileError, error)
 	folderErrorsMutex       sync.RWMutex
 	folderErrorsArgsForCall []struct {
@@ -375,6 +423,20 @@ type Model struct {
 	onHelloReturnsOnCall map[int]struct {
 		result1 error
 	}
+	OpenLocalFileStub        func(string, string) (fs.File, error)
+	openLocalFileMutex       sync.RWMutex
+	openLocalFileArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	openLocalFileReturns struct {
+		result1 fs.File
+		result2 error
+	}
+	openLocalFileReturnsOnCall map[int]struct {
+		result1 fs.File
+		result2 error
+	}
 	OverrideStub        func(string)
 	overrideMutex       sync.RWMutex
 	overrideArgsForCall []struct {
@@ -405,6 +467,19 @@ type Model struct {
 		result1 map[string]db.PendingFolder
 		result2 error
 	}
+	PullPreviewStub        func(string) (model.PullPlan, error)
+	pullPreviewMutex       sync.RWMutex
+	pullPreviewArgsForCall []struct {
+		arg1 string
+	}
+	pullPreviewReturns struct {
+		result1 model.PullPlan
+		result2 error
+	}
+	pullPreviewReturnsOnCall map[int]struct {
+		result1 model.PullPlan
+		result2 error
+	}
 	RemoteNeedFolderFilesStub        func(string, protocol.DeviceID, int, int) ([]protocol.FileInfo, error)
 	remoteNeedFolderFilesMutex       sync.RWMutex
 	remoteNeedFolderFilesArgsForCall []struct {
@@ -468,6 +543,19 @@ type Model struct {
 	resetFolderReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ResolveConflictStub        func(string, string, model.ConflictResolution) error
+	resolveConflictMutex       sync.RWMutex
+	resolveConflictArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 model.ConflictResolution
+	}
+	resolveConflictReturns struct {
+		result1 error
+	}
+	resolveConflictReturnsOnCall map[int]struct {
+		result1 error
+	}
 	RestoreFolderVersionsStub        func(string, map[string]time.Time) (map[string]error, error)
 	restoreFolderVersionsMutex       sync.RWMutex
 	restoreFolderVersionsArgsForCall []struct {
@@ -613,6 +701,68 @@ func (fake *Model) AddConnectionArgsForCall(i int) (protocol.Connection, protoco
 	return argsForCall.arg1, argsForCall.arg2
 }
 
+func (fake *Model) ApprovePull(arg1 string, arg2 string) error {
+	fake.approvePullMutex.Lock()
+	ret, specificReturn := fake.approvePullReturnsOnCall[len(fake.approvePullArgsForCall)]
+	fake.approvePullArgsForCall = append(fake.approvePullArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.ApprovePullStub
+	fakeReturns := fake.approvePullReturns
+	fake.recordInvocation("ApprovePull", []interface{}{arg1, arg2})
+	fake.approvePullMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Model) ApprovePullCallCount() int {
+	fake.approvePullMutex.RLock()
+	defer fake.approvePullMutex.RUnlock()
+	return len(fake.approvePullArgsForCall)
+}
+
+func (fake *Model) ApprovePullCalls(stub func(string, string) error) {
+	fake.approvePullMutex.Lock()
+	defer fake.approvePullMutex.Unlock()
+	fake.ApprovePullStub = stub
+}
+
+func (fake *Model) ApprovePullArgsForCall(i int) (string, string) {
+	fake.approvePullMutex.RLock()
+	defer fake.approvePullMutex.RUnlock()
+	argsForCall := fake.approvePullArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Model) ApprovePullReturns(result1 error) {
+	fake.approvePullMutex.Lock()
+	defer fake.approvePullMutex.Unlock()
+	fake.ApprovePullStub = nil
+	fake.approvePullReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Model) ApprovePullReturnsOnCall(i int, result1 error) {
+	fake.approvePullMutex.Lock()
+	defer fake.approvePullMutex.Unlock()
+	fake.ApprovePullStub = nil
+	if fake.approvePullReturnsOnCall == nil {
+		fake.approvePullReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.approvePullReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Model) Availability(arg1 string, arg2 protocol.FileInfo, arg3 protocol.BlockInfo) ([]model.Availability, error) {
 	fake.availabilityMutex.Lock()
 	ret, specificReturn := fake.availabilityReturnsOnCall[len(fake.availabilityArgsForCall)]
@@ -872,6 +1022,67 @@ func (fake *Model) CompletionReturnsOnCall(i int, result1 model.FolderCompletion
 	}{result1, result2}
 }
 
+func (fake *Model) ConfirmDeletions(arg1 string) error {
+	fake.confirmDeletionsMutex.Lock()
+	ret, specificReturn := fake.confirmDeletionsReturnsOnCall[len(fake.confirmDeletionsArgsForCall)]
+	fake.confirmDeletionsArgsForCall = append(fake.confirmDeletionsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ConfirmDeletionsStub
+	fakeReturns := fake.confirmDeletionsReturns
+	fake.recordInvocation("ConfirmDeletions", []interface{}{arg1})
+	fake.confirmDeletionsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Model) ConfirmDeletionsCallCount() int {
+	fake.confirmDeletionsMutex.RLock()
+	defer fake.confirmDeletionsMutex.RUnlock()
+	return len(fake.confirmDeletionsArgsForCall)
+}
+
+func (fake *Model) ConfirmDeletionsCalls(stub func(string) error) {
+	fake.confirmDeletionsMutex.Lock()
+	defer fake.confirmDeletionsMutex.Unlock()
+	fake.ConfirmDeletionsStub = stub
+}
+
+func (fake *Model) ConfirmDeletionsArgsForCall(i int) string {
+	fake.confirmDeletionsMutex.RLock()
+	defer fake.confirmDeletionsMutex.RUnlock()
+	argsForCall := fake.confirmDeletionsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Model) ConfirmDeletionsReturns(result1 error) {
+	fake.confirmDeletionsMutex.Lock()
+	defer fake.confirmDeletionsMutex.Unlock()
+	fake.ConfirmDeletionsStub = nil
+	fake.confirmDeletionsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Model) ConfirmDeletionsReturnsOnCall(i int, result1 error) {
+	fake.confirmDeletionsMutex.Lock()
+	defer fake.confirmDeletionsMutex.Unlock()
+	fake.ConfirmDeletionsStub = nil
+	if fake.confirmDeletionsReturnsOnCall == nil {
+		fake.confirmDeletionsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.confirmDeletionsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Model) ConnectedTo(arg1 protocol.DeviceID) bool {
 	fake.connectedToMutex.Lock()
 	ret, specificReturn := fake.connectedToReturnsOnCall[len(fake.connectedToArgsForCall)]
@@ -1527,6 +1738,132 @@ func (fake *Model) DownloadProgressReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Model) FetchFile(arg1 string, arg2 string) error {
+	fake.fetchFileMutex.Lock()
+	ret, specificReturn := fake.fetchFileReturnsOnCall[len(fake.fetchFileArgsForCall)]
+	fake.fetchFileArgsForCall = append(fake.fetchFileArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.FetchFileStub
+	fakeReturns := fake.fetchFileReturns
+	fake.recordInvocation("FetchFile", []interface{}{arg1, arg2})
+	fake.fetchFileMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Model) FetchFileCallCount() int {
+	fake.fetchFileMutex.RLock()
+	defer fake.fetchFileMutex.RUnlock()
+	return len(fake.fetchFileArgsForCall)
+}
+
+func (fake *Model) FetchFileCalls(stub func(string, string) error) {
+	fake.fetchFileMutex.Lock()
+	defer fake.fetchFileMutex.Unlock()
+	fake.FetchFileStub = stub
+}
+
+func (fake *Model) FetchFileArgsForCall(i int) (string, string) {
+	fake.fetchFileMutex.RLock()
+	defer fake.fetchFileMutex.RUnlock()
+	argsForCall := fake.fetchFileArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Model) FetchFileReturns(result1 error) {
+	fake.fetchFileMutex.Lock()
+	defer fake.fetchFileMutex.Unlock()
+	fake.FetchFileStub = nil
+	fake.fetchFileReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Model) FetchFileReturnsOnCall(i int, result1 error) {
+	fake.fetchFileMutex.Lock()
+	defer fake.fetchFileMutex.Unlock()
+	fake.FetchFileStub = nil
+	if fake.fetchFileReturnsOnCall == nil {
+		fake.fetchFileReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.fetchFileReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Model) FolderConflicts(arg1 string) ([]model.Conflict, error) {
+	fake.folderConflictsMutex.Lock()
+	ret, specificReturn := fake.folderConflictsReturnsOnCall[len(fake.folderConflictsArgsForCall)]
+	fake.folderConflictsArgsForCall = append(fake.folderConflictsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.FolderConflictsStub
+	fakeReturns := fake.folderConflictsReturns
+	fake.recordInvocation("FolderConflicts", []interface{}{arg1})
+	fake.folderConflictsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *Model) FolderConflictsCallCount() int {
+	fake.folderConflictsMutex.RLock()
+	defer fake.folderConflictsMutex.RUnlock()
+	return len(fake.folderConflictsArgsForCall)
+}
+
+func (fake *Model) FolderConflictsCalls(stub func(string) ([]model.Conflict, error)) {
+	fake.folderConflictsMutex.Lock()
+	defer fake.folderConflictsMutex.Unlock()
+	fake.FolderConflictsStub = stub
+}
+
+func (fake *Model) FolderConflictsArgsForCall(i int) string {
+	fake.folderConflictsMutex.RLock()
+	defer fake.folderConflictsMutex.RUnlock()
+	argsForCall := fake.folderConflictsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Model) FolderConflictsReturns(result1 []model.Conflict, result2 error) {
+	fake.folderConflictsMutex.Lock()
+	defer fake.folderConflictsMutex.Unlock()
+	fake.FolderConflictsStub = nil
+	fake.folderConflictsReturns = struct {
+		result1 []model.Conflict
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Model) FolderConflictsReturnsOnCall(i int, result1 []model.Conflict, result2 error) {
+	fake.folderConflictsMutex.Lock()
+	defer fake.folderConflictsMutex.Unlock()
+	fake.FolderConflictsStub = nil
+	if fake.folderConflictsReturnsOnCall == nil {
+		fake.folderConflictsReturnsOnCall = make(map[int]struct {
+			result1 []model.Conflict
+			result2 error
+		})
+	}
+	fake.folderConflictsReturnsOnCall[i] = struct {
+		result1 []model.Conflict
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *Model) FolderErrors(arg1 string) ([]model.FileError, error) {
 	fake.folderErrorsMutex.Lock()
 	ret, specificReturn := fake.folderErrorsReturnsOnCall[len(fake.folderErrorsArgsForCall)]
@@ -2296,6 +2633,71 @@ func (fake *Model) OnHelloReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Model) OpenLocalFile(arg1 string, arg2 string) (fs.File, error) {
+	fake.openLocalFileMutex.Lock()
+	ret, specificReturn := fake.openLocalFileReturnsOnCall[len(fake.openLocalFileArgsForCall)]
+	fake.openLocalFileArgsForCall = append(fake.openLocalFileArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.OpenLocalFileStub
+	fakeReturns := fake.openLocalFileReturns
+	fake.recordInvocation("OpenLocalFile", []interface{}{arg1, arg2})
+	fake.openLocalFileMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *Model) OpenLocalFileCallCount() int {
+	fake.openLocalFileMutex.RLock()
+	defer fake.openLocalFileMutex.RUnlock()
+	return len(fake.openLocalFileArgsForCall)
+}
+
+func (fake *Model) OpenLocalFileCalls(stub func(string, string) (fs.File, error)) {
+	fake.openLocalFileMutex.Lock()
+	defer fake.openLocalFileMutex.Unlock()
+	fake.OpenLocalFileStub = stub
+}
+
+func (fake *Model) OpenLocalFileArgsForCall(i int) (string, string) {
+	fake.openLocalFileMutex.RLock()
+	defer fake.openLocalFileMutex.RUnlock()
+	argsForCall := fake.openLocalFileArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Model) OpenLocalFileReturns(result1 fs.File, result2 error) {
+	fake.openLocalFileMutex.Lock()
+	defer fake.openLocalFileMutex.Unlock()
+	fake.OpenLocalFileStub = nil
+	fake.openLocalFileReturns = struct {
+		result1 fs.File
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Model) OpenLocalFileReturnsOnCall(i int, result1 fs.File, result2 error) {
+	fake.openLocalFileMutex.Lock()
+	defer fake.openLocalFileMutex.Unlock()
+	fake.OpenLocalFileStub = nil
+	if fake.openLocalFileReturnsOnCall == nil {
+		fake.openLocalFileReturnsOnCall = make(map[int]struct {
+			result1 fs.File
+			result2 error
+		})
+	}
+	fake.openLocalFileReturnsOnCall[i] = struct {
+		result1 fs.File
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *Model) Override(arg1 string) {
 	fake.overrideMutex.Lock()
 	fake.overrideArgsForCall = append(fake.overrideArgsForCall, struct {
@@ -2448,6 +2850,70 @@ func (fake *Model) PendingFoldersReturnsOnCall(i int, result1 map[string]db.Pend
 	}{result1, result2}
 }
 
+func (fake *Model) PullPreview(arg1 string) (model.PullPlan, error) {
+	fake.pullPreviewMutex.Lock()
+	ret, specificReturn := fake.pullPreviewReturnsOnCall[len(fake.pullPreviewArgsForCall)]
+	fake.pullPreviewArgsForCall = append(fake.pullPreviewArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.PullPreviewStub
+	fakeReturns := fake.pullPreviewReturns
+	fake.recordInvocation("PullPreview", []interface{}{arg1})
+	fake.pullPreviewMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *Model) PullPreviewCallCount() int {
+	fake.pullPreviewMutex.RLock()
+	defer fake.pullPreviewMutex.RUnlock()
+	return len(fake.pullPreviewArgsForCall)
+}
+
+func (fake *Model) PullPreviewCalls(stub func(string) (model.PullPlan, error)) {
+	fake.pullPreviewMutex.Lock()
+	defer fake.pullPreviewMutex.Unlock()
+	fake.PullPreviewStub = stub
+}
+
+func (fake *Model) PullPreviewArgsForCall(i int) string {
+	fake.pullPreviewMutex.RLock()
+	defer fake.pullPreviewMutex.RUnlock()
+	argsForCall := fake.pullPreviewArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Model) PullPreviewReturns(result1 model.PullPlan, result2 error) {
+	fake.pullPreviewMutex.Lock()
+	defer fake.pullPreviewMutex.Unlock()
+	fake.PullPreviewStub = nil
+	fake.pullPreviewReturns = struct {
+		result1 model.PullPlan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Model) PullPreviewReturnsOnCall(i int, result1 model.PullPlan, result2 error) {
+	fake.pullPreviewMutex.Lock()
+	defer fake.pullPreviewMutex.Unlock()
+	fake.PullPreviewStub = nil
+	if fake.pullPreviewReturnsOnCall == nil {
+		fake.pullPreviewReturnsOnCall = make(map[int]struct {
+			result1 model.PullPlan
+			result2 error
+		})
+	}
+	fake.pullPreviewReturnsOnCall[i] = struct {
+		result1 model.PullPlan
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *Model) RemoteNeedFolderFiles(arg1 string, arg2 protocol.DeviceID, arg3 int, arg4 int) ([]protocol.FileInfo, error) {
 	fake.remoteNeedFolderFilesMutex.Lock()
 	ret, specificReturn := fake.remoteNeedFolderFilesReturnsOnCall[len(fake.remoteNeedFolderFilesArgsForCall)]
@@ -2719,6 +3185,69 @@ func (fake *Model) ResetFolderReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Model) ResolveConflict(arg1 string, arg2 string, arg3 model.ConflictResolution) error {
+	fake.resolveConflictMutex.Lock()
+	ret, specificReturn := fake.resolveConflictReturnsOnCall[len(fake.resolveConflictArgsForCall)]
+	fake.resolveConflictArgsForCall = append(fake.resolveConflictArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 model.ConflictResolution
+	}{arg1, arg2, arg3})
+	stub := fake.ResolveConflictStub
+	fakeReturns := fake.resolveConflictReturns
+	fake.recordInvocation("ResolveConflict", []interface{}{arg1, arg2, arg3})
+	fake.resolveConflictMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Model) ResolveConflictCallCount() int {
+	fake.resolveConflictMutex.RLock()
+	defer fake.resolveConflictMutex.RUnlock()
+	return len(fake.resolveConflictArgsForCall)
+}
+
+func (fake *Model) ResolveConflictCalls(stub func(string, string, model.ConflictResolution) error) {
+	fake.resolveConflictMutex.Lock()
+	defer fake.resolveConflictMutex.Unlock()
+	fake.ResolveConflictStub = stub
+}
+
+func (fake *Model) ResolveConflictArgsForCall(i int) (string, string, model.ConflictResolution) {
+	fake.resolveConflictMutex.RLock()
+	defer fake.resolveConflictMutex.RUnlock()
+	argsForCall := fake.resolveConflictArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *Model) ResolveConflictReturns(result1 error) {
+	fake.resolveConflictMutex.Lock()
+	defer fake.resolveConflictMutex.Unlock()
+	fake.ResolveConflictStub = nil
+	fake.resolveConflictReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Model) ResolveConflictReturnsOnCall(i int, result1 error) {
+	fake.resolveConflictMutex.Lock()
+	defer fake.resolveConflictMutex.Unlock()
+	fake.ResolveConflictStub = nil
+	if fake.resolveConflictReturnsOnCall == nil {
+		fake.resolveConflictReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.resolveConflictReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Model) RestoreFolderVersions(arg1 string, arg2 map[string]time.Time) (map[string]error, error) {
 	fake.restoreFolderVersionsMutex.Lock()
 	ret, specificReturn := fake.restoreFolderVersionsReturnsOnCall[len(fake.restoreFolderVersionsArgsForCall)]
@@ -3292,6 +3821,8 @@ func (fake *Model) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.addConnectionMutex.RLock()
 	defer fake.addConnectionMutex.RUnlock()
+	fake.approvePullMutex.RLock()
+	defer fake.approvePullMutex.RUnlock()
 	fake.availabilityMutex.RLock()
 	defer fake.availabilityMutex.RUnlock()
 	fake.bringToFrontMutex.RLock()
@@ -3302,6 +3833,8 @@ func (fake *Model) Invocations() map[string][][]interface{} {
 	defer fake.clusterConfigMutex.RUnlock()
 	fake.completionMutex.RLock()
 	defer fake.completionMutex.RUnlock()
+	fake.confirmDeletionsMutex.RLock()
+	defer fake.confirmDeletionsMutex.RUnlock()
 	fake.connectedToMutex.RLock()
 	defer fake.connectedToMutex.RUnlock()
 	fake.connectionStatsMutex.RLock()
@@ -3324,6 +3857,10 @@ func (fake *Model) Invocations() map[string][][]interface{} {
 	defer fake.dismissPendingFolderMutex.RUnlock()
 	fake.downloadProgressMutex.RLock()
 	defer fake.downloadProgressMutex.RUnlock()
+	fake.fetchFileMutex.RLock()
+	defer fake.fetchFileMutex.RUnlock()
+	fake.folderConflictsMutex.RLock()
+	defer fake.folderConflictsMutex.RUnlock()
 	fake.folderErrorsMutex.RLock()
 	defer fake.folderErrorsMutex.RUnlock()
 	fake.folderProgressBytesCompletedMutex.RLock()
@@ -3348,12 +3885,16 @@ func (fake *Model) Invocations() map[string][][]interface{} {
 	defer fake.needFolderFilesMutex.RUnlock()
 	fake.onHelloMutex.RLock()
 	defer fake.onHelloMutex.RUnlock()
+	fake.openLocalFileMutex.RLock()
+	defer fake.openLocalFileMutex.RUnlock()
 	fake.overrideMutex.RLock()
 	defer fake.overrideMutex.RUnlock()
 	fake.pendingDevicesMutex.RLock()
 	defer fake.pendingDevicesMutex.RUnlock()
 	fake.pendingFoldersMutex.RLock()
 	defer fake.pendingFoldersMutex.RUnlock()
+	fake.pullPreviewMutex.RLock()
+	defer fake.pullPreviewMutex.RUnlock()
 	fake.remoteNeedFolderFilesMutex.RLock()
 	defer fake.remoteNeedFolderFilesMutex.RUnlock()
 	fake.requestMutex.RLock()
@@ -3362,6 +3903,8 @@ func (fake *Model) Invocations() map[string][][]interface{} {
 	defer fake.requestGlobalMutex.RUnlock()
 	fake.resetFolderMutex.RLock()
 	defer fake.resetFolderMutex.RUnlock()
+	fake.resolveConflictMutex.RLock()
+	defer fake.resolveConflictMutex.RUnlock()
 	fake.restoreFolderVersionsMutex.RLock()
 	defer fake.restoreFolderVersionsMutex.RUnlock()
 	fake.revertMutex.RLock()
@@ -57,12 +57,17 @@ type service interface {
 	SchedulePull()                                    // something relevant changed, we should try a pull
 	Jobs(page, perpage int) ([]string, []string, int) // In progress, Queued, skipped
 	Scan(subs []string) error
+	ResolveConflict(name string, resolution ConflictResolution) error
+	PullPlan() (PullPlan, error)
+	ApprovePull(id string) error
+	ConfirmDeletions() error
 	Errors() []FileError
 	WatchError() error
 	ScheduleForceRescan(path string)
 	GetStatistics() (stats.FolderStatistics, error)
 
 	getState() (folderState, time.Time, error)
+	localFilesystem() fs.Filesystem
 }
 
 type Availability struct {
@@ -86,6 +91,10 @@ type Model interface {
 	Override(folder string)
 	Revert(folder string)
 	BringToFront(folder, file string)
+	FetchFile(folder, file string) error
+	PullPreview(folder string) (PullPlan, error)
+	ApprovePull(folder, plan string) error
+	ConfirmDeletions(folder string) error
 	LoadIgnores(folder string) ([]string, []string, error)
 	CurrentIgnores(folder string) ([]string, []string, error)
 	SetIgnores(folder string, content []string) error
@@ -93,6 +102,9 @@ type Model interface {
 	GetFolderVersions(folder string) (map[string][]versioner.FileVersion, error)
 	RestoreFolderVersions(folder string, versions map[string]time.Time) (map[string]error, error)
 
+	FolderConflicts(folder string) ([]Conflict, error)
+	ResolveConflict(folder, name string, resolution ConflictResolution) error
+
 	DBSnapshot(folder string) (*db.Snapshot, error)
 	NeedFolderFiles(folder string, page, perpage int) ([]protocol.FileInfo, []protocol.FileInfo, []protocol.FileInfo, error)
 	RemoteNeedFolderFiles(folder string, device protocol.DeviceID, page, perpage int) ([]protocol.FileInfo, error)
@@ -102,6 +114,7 @@ type Model interface {
 	CurrentFolderFile(folder string, file string) (protocol.FileInfo, bool, error)
 	CurrentGlobalFile(folder string, file string) (protocol.FileInfo, bool, error)
 	GetMtimeMapping(folder string, file string) (fs.MtimeMapping, error)
+	OpenLocalFile(folder string, file string) (fs.File, error)
 	Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) ([]Availability, error)
 
 	Completion(device protocol.DeviceID, folder string) (FolderCompletion, error)
@@ -1376,7 +1389,7 @@ func (m *model) ccHandleFolders(folders []protocol.Folder, deviceCfg config.Devi
 	var folderDevice config.FolderDeviceConfiguration
 	tempIndexFolders := make([]string, 0, len(folders))
 	seenFolders := make(map[string]remoteFolderState, len(folders))
-	updatedPending := make([]updatedPendingFolder, 0, len(folders))
+	updatedPending := make([]UpdatedPendingFolder, 0, len(folders))
 	deviceID := deviceCfg.DeviceID
 	expiredPending, err := m.db.PendingFoldersForDevice(deviceID)
 	if err != nil {
@@ -1406,7 +1419,7 @@ func (m *model) ccHandleFolders(folders []protocol.Folder, deviceCfg config.Devi
 			if !folder.Paused {
 				indexHandlers.AddIndexInfo(folder.ID, ccDeviceInfos[folder.ID])
 			}
-			updatedPending = append(updatedPending, updatedPendingFolder{
+			updatedPending = append(updatedPending, UpdatedPendingFolder{
 				FolderID:         folder.ID,
 				FolderLabel:      folder.Label,
 				DeviceID:         deviceID,
@@ -2159,6 +2172,23 @@ func (m *model) CurrentFolderFile(folder string, file string) (protocol.FileInfo
 	return f, ok, nil
 }
 
+// OpenLocalFile opens the local copy of the file for reading, using the
+// filesystem of the running folder.
+func (m *model) OpenLocalFile(folder string, file string) (fs.File, error) {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	runner, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	ffs := runner.localFilesystem()
+	if err := osutil.TraversesSymlink(ffs, filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	return ffs.Open(file)
+}
+
 func (m *model) CurrentGlobalFile(folder string, file string) (protocol.FileInfo, bool, error) {
 	m.mut.RLock()
 	ffs, ok := m.folderFiles[folder]
@@ -2862,6 +2892,78 @@ func (m *model) RestoreFolderVersions(folder string, versions map[string]time.Ti
 	return restoreErrors, nil
 }
 
+func (m *model) FolderConflicts(folder string) ([]Conflict, error) {
+	snap, err := m.DBSnapshot(folder)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Release()
+
+	devices := make(map[string]protocol.DeviceID)
+	for id := range m.cfg.Devices() {
+		devices[id.Short().String()] = id
+	}
+
+	return listConflicts(snap, devices), nil
+}
+
+func (m *model) ResolveConflict(folder, name string, resolution ConflictResolution) error {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	fcfg := m.folderCfgs[folder]
+	runner, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	if err != nil {
+		return err
+	}
+	if fcfg.Type == config.FolderTypeReceiveEncrypted {
+		return ErrConflictEncrypted
+	}
+
+	return runner.ResolveConflict(name, resolution)
+}
+
+// PullPreview returns what pulling the folder would do, without doing it.
+func (m *model) PullPreview(folder string) (PullPlan, error) {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	runner, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	if err != nil {
+		return PullPlan{}, err
+	}
+
+	return runner.PullPlan()
+}
+
+// ApprovePull lets a folder that requires approval pull the changes in the
+// given plan, as returned by PullPreview.
+func (m *model) ApprovePull(folder, plan string) error {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	runner, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return runner.ApprovePull(plan)
+}
+
+// ConfirmDeletions lets a folder carry out the deletions it holds for
+// exceeding its threshold.
+func (m *model) ConfirmDeletions(folder string) error {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	runner, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return runner.ConfirmDeletions()
+}
+
 func (m *model) Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) ([]Availability, error) {
 	m.mut.RLock()
 	defer m.mut.RUnlock()
@@ -2941,6 +3043,41 @@ func (m *model) BringToFront(folder, file string) {
 	}
 }
 
+// FetchFile marks the given file, or directory, in a selectively synced
+// folder to be pulled and bumps it to the front of the queue. The mark is
+// persisted, so the file keeps being synced from then on.
+func (m *model) FetchFile(folder, file string) error {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	fcfg := m.folderCfgs[folder]
+	ffs := m.folderFiles[folder]
+	runner, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	if err != nil {
+		return err
+	}
+	if !fcfg.SelectiveSync {
+		return ErrFetchNotSelective
+	}
+
+	snap, err := ffs.Snapshot()
+	if err != nil {
+		return err
+	}
+	gf, ok := snap.GetGlobal(file)
+	snap.Release()
+	if !ok || gf.IsDeleted() {
+		return ErrFetchNotFound
+	}
+
+	if err := db.NewFolderFetchNamespace(m.db, folder).PutBool(gf.Name, true); err != nil {
+		return err
+	}
+	runner.SchedulePull()
+	runner.BringToFront(gf.Name)
+	return nil
+}
+
 func (m *model) ResetFolder(folder string) error {
 	m.mut.RLock()
 	defer m.mut.RUnlock()
@@ -3476,7 +3613,9 @@ func newFolderConfiguration(w config.Wrapper, id, label string, fsType config.Fi
 	return fcfg
 }
 
-type updatedPendingFolder struct {
+// An UpdatedPendingFolder is a folder added to the pending folders, as
+// given by the PendingFoldersChanged event.
+type UpdatedPendingFolder struct {
 	FolderID         string            `json:"folderID"`
 	FolderLabel      string            `json:"folderLabel"`
 	DeviceID         protocol.DeviceID `json:"deviceID"`
@@ -24,6 +24,8 @@ import (
 	"testing"
 	"time"
 
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+
 	"github.com/syncthing/syncthing/lib/build"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
@@ -2972,7 +2974,7 @@ func TestConnCloseOnRestart(t *testing.T) {
 	nw := &testutil.NoopRW{}
 	ci := &protocolmocks.ConnectionInfo{}
 	ci.ConnectionIDReturns(srand.String(16))
-	m.AddConnection(protocol.NewConnection(device1, br, nw, testutil.NoopCloser{}, m, ci, protocol.CompressionNever, nil, m.keyGen), protocol.Hello{})
+	m.AddConnection(protocol.NewConnection(device1, br, nw, testutil.NoopCloser{}, protocolModel{m}, ci, protocol.CompressionNever, nil, m.keyGen), protocol.Hello{})
 	m.mut.RLock()
 	if len(m.closed) != 1 {
 		t.Fatalf("Expected just one conn (len(m.closed) == %v)", len(m.closed))
@@ -3154,6 +3156,85 @@ func TestSummaryPausedNoError(t *testing.T) {
 	}
 }
 
+func TestSummaryMetrics(t *testing.T) {
+	wcfg, fcfg, wcfgCancel := newDefaultCfgWrapper()
+	defer wcfgCancel()
+	m := setupModel(t, wcfg)
+	defer cleanupModel(m)
+
+	localIndexUpdate(m, fcfg.ID, []protocol.FileInfo{
+		{Name: "file", Size: 10, Version: protocol.Vector{}.Update(myID.Short())},
+		{Name: "other", Size: 10, Version: protocol.Vector{}.Update(myID.Short())},
+	})
+
+	fss := NewFolderSummaryService(wcfg, m, myID, events.NoopLogger).(*folderSummaryService)
+	fss.sendSummary(context.Background(), fcfg.ID)
+
+	// device1 has nothing, so needs both existing files.
+	if n := promtestutil.ToFloat64(metricFolderRemoteNeed.WithLabelValues(fcfg.ID, device1.String(), metricTypeBytes)); n != 20 {
+		t.Errorf("expected device1 to need 20 bytes, got %v", n)
+	}
+	if pct := promtestutil.ToFloat64(metricFolderCompletionPct.WithLabelValues(fcfg.ID, device1.String())); pct != 0 {
+		t.Errorf("expected device1 to be at 0%%, got %v", pct)
+	}
+
+	// Once device1 no longer shares the folder, its metrics are gone.
+	waiter, err := wcfg.Modify(func(cfg *config.Configuration) {
+		fcfg := cfg.FolderMap()[fcfg.ID]
+		fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: myID}}
+		cfg.SetFolder(fcfg)
+	})
+	must(t, err)
+	waiter.Wait()
+	fss.sendSummary(context.Background(), fcfg.ID)
+	if metricFolderCompletionPct.DeleteLabelValues(fcfg.ID, device1.String()) {
+		t.Error("expected the completion of device1 to be forgotten")
+	}
+	if metricFolderRemoteNeed.DeleteLabelValues(fcfg.ID, device1.String(), metricTypeBytes) {
+		t.Error("expected the need of device1 to be forgotten")
+	}
+}
+
+func TestConflictsMetric(t *testing.T) {
+	m, f, wcfgCancel := setupSendReceiveFolder(t, protocol.FileInfo{
+		Name: "a.sync-conflict-20240101-000000-ABCDEFG", Size: 10, Version: protocol.Vector{}.Update(myID.Short()),
+	})
+	defer cleanupModelAndRemoveDir(m, f.Filesystem(nil).URI())
+	defer wcfgCancel()
+	conflicts := func() float64 {
+		return promtestutil.ToFloat64(metricFolderConflicts.WithLabelValues(f.ID))
+	}
+
+	// Counted in full to start with.
+	f.countConflicts()
+	if n := conflicts(); n != 1 {
+		t.Errorf("expected one conflict, got %v", n)
+	}
+
+	// Then kept up to date as files change.
+	v := protocol.Vector{}.Update(myID.Short())
+	f.updateLocalFiles([]protocol.FileInfo{
+		{Name: "file", Size: 10, Version: v},
+		{Name: "b.sync-conflict-20240101-000000-ABCDEFG", Size: 10, Version: v},
+		{Name: "c.sync-conflict-20240101-000000-ABCDEFG", Size: 10, Version: v},
+		{Name: "d.sync-conflict-20240101-000000-ABCDEFG", Type: protocol.FileInfoTypeDirectory, Version: v},
+	})
+	if n := conflicts(); n != 3 {
+		t.Errorf("expected three conflicts, got %v", n)
+	}
+	f.updateLocalFiles([]protocol.FileInfo{
+		{Name: "a.sync-conflict-20240101-000000-ABCDEFG", Deleted: true, Version: v.Update(myID.Short())},
+		{Name: "b.sync-conflict-20240101-000000-ABCDEFG", Size: 20, Version: v.Update(myID.Short())},
+	})
+	if n := conflicts(); n != 2 {
+		t.Errorf("expected two conflicts, got %v", n)
+	}
+	f.removeLocalFiles([]string{"c.sync-conflict-20240101-000000-ABCDEFG", "d.sync-conflict-20240101-000000-ABCDEFG"})
+	if n := conflicts(); n != 1 {
+		t.Errorf("expected one conflict, got %v", n)
+	}
+}
+
 func TestFolderAPIErrors(t *testing.T) {
 	wcfg, fcfg, wcfgCancel := newDefaultCfgWrapper()
 	defer wcfgCancel()
@@ -3174,6 +3255,16 @@ func TestFolderAPIErrors(t *testing.T) {
 			_, err := m.RestoreFolderVersions(folder, nil)
 			return err
 		},
+		func(folder string) error {
+			_, err := m.FolderConflicts(folder)
+			return err
+		},
+		func(folder string) error {
+			return m.ResolveConflict(folder, "", ConflictKeepBoth)
+		},
+		func(folder string) error {
+			return m.FetchFile(folder, "")
+		},
 	}
 
 	for i, method := range methods {
@@ -0,0 +1,241 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var (
+	// ErrPullPlanChanged is returned when approving a pull plan that is no
+	// longer what pulling would do.
+	ErrPullPlanChanged = errors.New("the pull plan has changed")
+
+	errPullPreviewUnsupported = errors.New("folder type does not pull")
+	errPullPreviewDisabled    = errors.New("folder does not require approval to pull")
+)
+
+// A PlannedChange is a change that pulling would make to an item.
+type PlannedChange struct {
+	Name       string                `json:"name"`
+	From       string                `json:"from,omitempty"`
+	Type       protocol.FileInfoType `json:"type"`
+	Size       int64                 `json:"size"`
+	ModTime    time.Time             `json:"modTime"`
+	ModifiedBy string                `json:"modifiedBy"`
+}
+
+// PlannedChanges are the changes of one kind in a pull plan, with their
+// count and the bytes involved.
+type PlannedChanges struct {
+	Count int             `json:"count"`
+	Bytes int64           `json:"bytes"`
+	Items []PlannedChange `json:"items"`
+}
+
+func (c *PlannedChanges) add(change PlannedChange, bytes int64) {
+	c.Count++
+	c.Bytes += bytes
+	c.Items = append(c.Items, change)
+}
+
+// A PullPlan describes what pulling a folder would do to it, as of when it
+// was computed. The ID identifies the set of needed items it covers, for
+// approving exactly that.
+type PullPlan struct {
+	ID        string         `json:"id"`
+	Creates   PlannedChanges `json:"creates"`
+	Modifies  PlannedChanges `json:"modifies"`
+	Deletes   PlannedChanges `json:"deletes"`
+	Renames   PlannedChanges `json:"renames"`
+	Conflicts PlannedChanges `json:"conflicts"`
+	// Deletions exceeding the folder's threshold, which are held back
+	// until confirmed.
+	HeldDeletes PlannedChanges `json:"heldDeletes"`
+
+	versions map[string]protocol.Vector // of all items covered
+}
+
+func newPlannedChange(file protocol.FileInfo) PlannedChange {
+	return PlannedChange{
+		Name:       file.Name,
+		Type:       file.Type,
+		Size:       file.FileSize(),
+		ModTime:    file.ModTime(),
+		ModifiedBy: file.ModifiedBy.String(),
+	}
+}
+
+func (*folder) PullPlan() (PullPlan, error) {
+	return PullPlan{}, errPullPreviewUnsupported
+}
+
+func (*folder) ApprovePull(string) error {
+	return errPullPreviewUnsupported
+}
+
+func (f *sendReceiveFolder) PullPlan() (PullPlan, error) {
+	snap, err := f.dbSnapshot()
+	if err != nil {
+		return PullPlan{}, err
+	}
+	defer snap.Release()
+	return f.planPull(snap), nil
+}
+
+// ApprovePull lets the changes in the plan with the given ID be pulled, if
+// the folder requires approval and that is still the current plan.
+func (f *sendReceiveFolder) ApprovePull(id string) error {
+	if !f.PullPreview {
+		return errPullPreviewDisabled
+	}
+	plan, err := f.PullPlan()
+	if err != nil {
+		return err
+	}
+	if plan.ID != id {
+		return ErrPullPlanChanged
+	}
+
+	f.approvedMut.Lock()
+	f.approved = plan.versions
+	f.approvedMut.Unlock()
+
+	f.SchedulePull()
+	return nil
+}
+
+// approvedForPull returns true if the needed item may be pulled, which it
+// always may unless the folder requires approval.
+func (f *sendReceiveFolder) approvedForPull(file protocol.FileInfo) bool {
+	if !f.PullPreview {
+		return true
+	}
+	f.approvedMut.Lock()
+	defer f.approvedMut.Unlock()
+	version, ok := f.approved[file.Name]
+	return ok && version.Equal(file.Version)
+}
+
+func (f *sendReceiveFolder) clearApproved() {
+	f.approvedMut.Lock()
+	f.approved = nil
+	f.approvedMut.Unlock()
+}
+
+// planPull goes through the needed items, classified the same way as when
+// pulling, but only records what would happen to them instead of doing it.
+// Items that would only be updated in the database, such as ignored ones,
+// need no approval and are left out of the plan.
+func (f *sendReceiveFolder) planPull(snap *db.Snapshot) PullPlan {
+	plan := PullPlan{versions: make(map[string]protocol.Vector)}
+	for _, c := range []*PlannedChanges{&plan.Creates, &plan.Modifies, &plan.Deletes, &plan.Renames, &plan.Conflicts, &plan.HeldDeletes} {
+		c.Items = make([]PlannedChange, 0)
+	}
+	h := sha256.New()
+
+	locals := make(map[string]protocol.FileInfo) // of the items that exist locally
+	var queued []protocol.FileInfo
+	var dirDeletions []protocol.FileInfo
+	fileDeletions := make(map[string]protocol.FileInfo)
+	buckets := map[string][]protocol.FileInfo{}
+
+	snap.WithNeed(protocol.LocalDeviceID, func(file protocol.FileInfo) bool {
+		action, cur, hasCur := f.classifyNeeded(file, snap)
+		if !action.needsApproval() {
+			return true
+		}
+
+		plan.versions[file.Name] = file.Version
+		fmt.Fprintf(h, "%s\x00%v\x00", file.Name, file.Version)
+
+		exists := hasCur && !cur.IsDeleted() && !cur.IsInvalid()
+		if exists {
+			locals[file.Name] = cur
+		}
+		conflict := exists && f.inConflict(cur.Version, file.Version)
+
+		switch action {
+		case neededDeleteDir:
+			dirDeletions = append(dirDeletions, file)
+
+		case neededDeleteFile:
+			// Might turn out to be a rename
+			fileDeletions[file.Name] = file
+			buckets[string(cur.BlocksHash)] = append(buckets[string(cur.BlocksHash)], cur)
+
+		case neededDeleteSymlink, neededDeleteMissing:
+			fileDeletions[file.Name] = file
+
+		case neededShortcut:
+			plan.Modifies.add(newPlannedChange(file), 0)
+
+		case neededKeepLocal:
+			plan.Conflicts.add(newPlannedChange(cur), 0)
+
+		case neededPullFile:
+			queued = append(queued, file)
+
+		case neededDir, neededSymlink:
+			switch {
+			case conflict && !(action == neededDir && cur.IsDirectory()):
+				// Directories only get their permissions updated.
+				plan.Conflicts.add(newPlannedChange(file), 0)
+			case exists:
+				plan.Modifies.add(newPlannedChange(file), 0)
+			default:
+				plan.Creates.add(newPlannedChange(file), 0)
+			}
+		}
+		return true
+	})
+
+	for _, file := range queued {
+		if cand, ok := popCandidate(buckets, string(file.BlocksHash)); ok {
+			change := newPlannedChange(file)
+			change.From = cand.Name
+			plan.Renames.add(change, 0)
+			delete(fileDeletions, cand.Name)
+			continue
+		}
+		cur, exists := locals[file.Name]
+		switch {
+		case exists && f.inConflict(cur.Version, file.Version):
+			plan.Conflicts.add(newPlannedChange(file), file.FileSize())
+		case exists:
+			plan.Modifies.add(newPlannedChange(file), file.FileSize())
+		default:
+			plan.Creates.add(newPlannedChange(file), file.FileSize())
+		}
+	}
+
+	deletions := deletionList(fileDeletions, dirDeletions)
+	held := f.deletionsHeld(deletions, snap)
+	for _, file := range deletions {
+		cur, exists := locals[file.Name]
+		switch {
+		case held && exists:
+			plan.HeldDeletes.add(newPlannedChange(cur), cur.FileSize())
+		case held:
+			plan.HeldDeletes.add(newPlannedChange(file), 0)
+		case !exists:
+		case f.inConflict(cur.Version, file.Version):
+			plan.Conflicts.add(newPlannedChange(cur), 0)
+		default:
+			plan.Deletes.add(newPlannedChange(cur), cur.FileSize())
+		}
+	}
+
+	plan.ID = fmt.Sprintf("%x", h.Sum(nil)[:16])
+	return plan
+}
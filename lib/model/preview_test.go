@@ -0,0 +1,193 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestPullPreview(t *testing.T) {
+	m, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	ffs := f.Filesystem(nil)
+
+	for _, name := range []string{"modified", "deleted", "renamedFrom", "conflict"} {
+		writeFile(t, ffs, name, []byte(name))
+	}
+	must(t, f.scanSubdirs(nil))
+
+	snap := dbSnapshot(t, m, f.ID)
+	local := func(name string) protocol.FileInfo {
+		t.Helper()
+		fi, ok := snap.Get(protocol.LocalDeviceID, name)
+		if !ok {
+			t.Fatal("missing local file", name)
+		}
+		return fi
+	}
+	modified := local("modified")
+	deleted := local("deleted")
+	renamedFrom := local("renamedFrom")
+	conflict := local("conflict")
+	snap.Release()
+
+	blocks := []protocol.BlockInfo{{Hash: []byte("0123456789abcdef0123456789abcdef"), Size: 10}}
+	created := protocol.FileInfo{
+		Name:       "created",
+		Type:       protocol.FileInfoTypeFile,
+		Size:       10,
+		Version:    protocol.Vector{}.Update(device1.Short()),
+		Blocks:     blocks,
+		BlocksHash: protocol.BlocksHash(blocks),
+	}
+	dir := protocol.FileInfo{
+		Name:    "dir",
+		Type:    protocol.FileInfoTypeDirectory,
+		Version: protocol.Vector{}.Update(device1.Short()),
+	}
+	modified.Version = modified.Version.Update(device1.Short())
+	modified.Size = 10
+	modified.Blocks = blocks
+	modified.BlocksHash = protocol.BlocksHash(blocks)
+	deleted.Version = deleted.Version.Update(device1.Short())
+	deleted.Deleted = true
+	renamedTo := renamedFrom
+	renamedTo.Name = "renamedTo"
+	renamedTo.Version = protocol.Vector{}.Update(device1.Short())
+	renamedFrom.Version = renamedFrom.Version.Update(device1.Short())
+	renamedFrom.Deleted = true
+	conflict.Version = protocol.Vector{}.Update(device1.Short())
+	conflict.ModifiedS += 10 // to win the conflict
+	conflict.Size = 10
+	conflict.Blocks = blocks
+	conflict.BlocksHash = protocol.BlocksHash(blocks)
+	f.fset.Update(device1, []protocol.FileInfo{created, dir, modified, deleted, renamedFrom, renamedTo, conflict})
+
+	snap = dbSnapshot(t, m, f.ID)
+	plan := f.planPull(snap)
+	snap.Release()
+
+	expect := func(what string, changes PlannedChanges, bytes int64, names ...string) {
+		t.Helper()
+		if changes.Count != len(names) || len(changes.Items) != len(names) {
+			t.Errorf("%s: expected %v, got %+v", what, names, changes.Items)
+			return
+		}
+		for i, name := range names {
+			if changes.Items[i].Name != name {
+				t.Errorf("%s: expected %v, got %+v", what, names, changes.Items)
+			}
+		}
+		if changes.Bytes != bytes {
+			t.Errorf("%s: expected %d bytes, got %d", what, bytes, changes.Bytes)
+		}
+	}
+	expect("creates", plan.Creates, 10, "dir", "created")
+	expect("modifies", plan.Modifies, 10, "modified")
+	expect("deletes", plan.Deletes, int64(len("deleted")), "deleted")
+	expect("renames", plan.Renames, 0, "renamedTo")
+	expect("conflicts", plan.Conflicts, 10, "conflict")
+	expect("held deletes", plan.HeldDeletes, 0)
+	if len(plan.Renames.Items) == 1 && plan.Renames.Items[0].From != "renamedFrom" {
+		t.Errorf("renamed from %q, expected renamedFrom", plan.Renames.Items[0].From)
+	}
+
+	// With approval required, nothing is pulled until the plan is
+	// approved, and then only what's in it.
+	f.PullPreview = true
+	snap = dbSnapshot(t, m, f.ID)
+	defer snap.Release()
+	changed, _, _, err := f.processNeeded(snap, nil, nil, nil)
+	must(t, err)
+	if changed != 0 || f.heldForApproval != 7 {
+		t.Errorf("expected all 7 items to be held back, got %d changed and %d held", changed, f.heldForApproval)
+	}
+
+	if err := f.ApprovePull("nonsense"); err != ErrPullPlanChanged {
+		t.Errorf("expected %v, got %v", ErrPullPlanChanged, err)
+	}
+	must(t, f.ApprovePull(plan.ID))
+	if !f.approvedForPull(created) {
+		t.Error("approved change is not approved")
+	}
+	created.Version = created.Version.Update(device2.Short())
+	if f.approvedForPull(created) {
+		t.Error("later change is approved")
+	}
+}
+
+func TestPullPreviewAgreesWithPuller(t *testing.T) {
+	m, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	ffs := f.Filesystem(nil)
+
+	for _, name := range []string{"deleted1", "deleted2", "kept"} {
+		writeFile(t, ffs, name, []byte(name))
+	}
+	must(t, f.scanSubdirs(nil))
+
+	snap := dbSnapshot(t, m, f.ID)
+	var remote []protocol.FileInfo
+	for _, name := range []string{"deleted1", "deleted2"} {
+		fi, _ := snap.Get(protocol.LocalDeviceID, name)
+		fi.Version = fi.Version.Update(device1.Short())
+		fi.Deleted = true
+		remote = append(remote, fi)
+	}
+	kept, _ := snap.Get(protocol.LocalDeviceID, "kept")
+	snap.Release()
+	blocks := []protocol.BlockInfo{{Hash: []byte("0123456789abcdef0123456789abcdef"), Size: 10}}
+	kept.Version = protocol.Vector{}.Update(device1.Short())
+	kept.ModifiedS += 10 // to be the global version
+	kept.ModifiedBy = device1.Short()
+	kept.Size = 10
+	kept.Blocks = blocks
+	kept.BlocksHash = protocol.BlocksHash(blocks)
+	ignored := protocol.FileInfo{
+		Name:    "ignored",
+		Type:    protocol.FileInfoTypeDirectory,
+		Version: protocol.Vector{}.Update(device1.Short()),
+	}
+	f.fset.Update(device1, append(remote, kept, ignored))
+
+	matcher := ignore.New(ffs)
+	must(t, matcher.Parse(bytes.NewBufferString("ignored"), ""))
+	f.ignores = matcher
+	f.ConflictStrategy = config.ConflictStrategyDevice
+	f.ConflictWinnerDevice = myID
+	f.MaxDeletions = 1
+
+	snap = dbSnapshot(t, m, f.ID)
+	defer snap.Release()
+	plan := f.planPull(snap)
+	if plan.Deletes.Count != 0 || plan.HeldDeletes.Count != 2 {
+		t.Errorf("expected 2 held deletions, got %+v and %+v", plan.Deletes, plan.HeldDeletes)
+	}
+	if plan.Conflicts.Count != 1 || plan.Conflicts.Items[0].Name != "kept" || plan.Conflicts.Bytes != 0 {
+		t.Errorf("expected the local file to win the conflict, got %+v", plan.Conflicts)
+	}
+	if _, ok := plan.versions["ignored"]; ok || plan.Creates.Count != 0 {
+		t.Errorf("expected ignored item to be left out, got %+v", plan.Creates)
+	}
+
+	// Ignored items are handled without approval.
+	f.PullPreview = true
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	changed, _, _, err := f.processNeeded(snap, dbUpdateChan, nil, nil)
+	must(t, err)
+	if changed != 1 || f.heldForApproval != 3 {
+		t.Errorf("expected 1 changed and 3 held, got %d changed and %d held", changed, f.heldForApproval)
+	}
+	if job := <-dbUpdateChan; job.file.Name != "ignored" || job.jobType != dbUpdateInvalidate {
+		t.Errorf("expected ignored item to be invalidated, got %v", job)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var (
+	// ErrFetchNotSelective is returned when fetching a file in a folder
+	// that isn't selectively synced.
+	ErrFetchNotSelective = errors.New("folder does not use selective sync")
+	// ErrFetchNotFound is returned when fetching a file that isn't in the
+	// global index.
+	ErrFetchNotFound = errors.New("no such file in the global index")
+)
+
+// selectedForSync returns true if the file, directory or symlink should be
+// pulled. In a selectively synced folder that is the case for items that
+// are already present locally, so that they are kept up to date, items
+// matching the include set, directories leading to them, and items that have
+// been fetched on demand. Items that aren't selected stay needed in the
+// global index, without being invalidated, so they can be fetched later.
+func (f *sendReceiveFolder) selectedForSync(file, cur protocol.FileInfo, hasCur bool) bool {
+	if !f.SelectiveSync {
+		return true
+	}
+	name := file.Name
+	if hasCur && !cur.IsDeleted() && !cur.IsInvalid() {
+		return true
+	}
+	if selectiveSyncIncludes(f.SelectiveSyncInclude, name) {
+		return true
+	}
+	if file.IsDirectory() && selectiveSyncLeadsTo(f.SelectiveSyncInclude, name) {
+		return true
+	}
+	// Fetching a directory fetches everything in it.
+	for p := name; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+		if ok, _, _ := f.fetched.Bool(p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectiveSyncIncludes returns true if the name, or any of its parent
+// directories, is given by one of the patterns. Patterns are paths relative
+// to the folder root, with forward slashes, and may contain wildcards as
+// understood by filepath.Match.
+func selectiveSyncIncludes(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		pattern = filepath.FromSlash(strings.Trim(pattern, "/"))
+		if pattern == "" {
+			continue
+		}
+		for p := name; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectiveSyncLeadsTo returns true if the name is a directory on the way
+// to something given by one of the patterns.
+func selectiveSyncLeadsTo(patterns []string, name string) bool {
+	parts := strings.Split(name, string(filepath.Separator))
+	for _, pattern := range patterns {
+		patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+		if len(patternParts) <= len(parts) {
+			continue
+		}
+		leads := true
+		for i, part := range parts {
+			if ok, _ := filepath.Match(patternParts[i], part); !ok {
+				leads = false
+				break
+			}
+		}
+		if leads {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSelectiveSyncIncludes(t *testing.T) {
+	patterns := []string{"docs", "/photos/2024/", "*.txt"}
+	cases := []struct {
+		name     string
+		included bool
+	}{
+		{"docs", true},
+		{"docs/a", true},
+		{"docs/sub/a", true},
+		{"docsx", false},
+		{"photos/2024/a.jpg", true},
+		{"photos/2023/a.jpg", false},
+		{"notes.txt", true},
+		{"dir/notes.txt", false},
+		{"other", false},
+	}
+	for _, tc := range cases {
+		if included := selectiveSyncIncludes(patterns, filepath.FromSlash(tc.name)); included != tc.included {
+			t.Errorf("%s: included %v, expected %v", tc.name, included, tc.included)
+		}
+	}
+}
+
+func TestSelectiveSyncLeadsTo(t *testing.T) {
+	patterns := []string{"docs", "/photos/20*/summer/"}
+	cases := []struct {
+		name  string
+		leads bool
+	}{
+		{"docs", false},
+		{"photos", true},
+		{"photos/2024", true},
+		{"photos/2024/summer", false},
+		{"photos/1999", false},
+		{"other", false},
+	}
+	for _, tc := range cases {
+		if leads := selectiveSyncLeadsTo(patterns, filepath.FromSlash(tc.name)); leads != tc.leads {
+			t.Errorf("%s: leads %v, expected %v", tc.name, leads, tc.leads)
+		}
+	}
+}
+
+func TestSelectiveSync(t *testing.T) {
+	w, fcfg, wCancel := newDefaultCfgWrapper()
+	defer wCancel()
+	fcfg.SelectiveSync = true
+	fcfg.SelectiveSyncInclude = []string{"included", "dir/included"}
+	setFolder(t, w, fcfg)
+	m, fc := setupModelWithConnectionFromWrapper(t, w)
+	tfs := fcfg.Filesystem(nil)
+	defer cleanupModelAndRemoveDir(m, tfs.URI())
+
+	included := "included"
+	excluded := "excluded"
+	synced := make(chan string, 10)
+	fc.setIndexFn(func(_ context.Context, _ string, fs []protocol.FileInfo) error {
+		for _, f := range fs {
+			synced <- f.Name
+		}
+		return nil
+	})
+	seen := make(map[string]struct{})
+	waitFor := func(name string) {
+		t.Helper()
+		for {
+			if _, ok := seen[name]; ok {
+				return
+			}
+			select {
+			case got := <-synced:
+				seen[got] = struct{}{}
+				if got != name && (got == excluded || got == "excludeddir" || got == "excludedlink") {
+					t.Fatal("excluded item was synced:", got)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for", name)
+			}
+		}
+	}
+
+	contents := []byte("test file contents\n")
+	fc.addFile(included, 0o644, protocol.FileInfoTypeFile, contents)
+	fc.addFile(excluded, 0o644, protocol.FileInfoTypeFile, contents)
+	fc.addFile("excludeddir", 0o755, protocol.FileInfoTypeDirectory, nil)
+	fc.addFile("excludedlink", 0o644, protocol.FileInfoTypeSymlink, []byte(included))
+	fc.addFile("dir", 0o755, protocol.FileInfoTypeDirectory, nil)
+	fc.addFile(filepath.Join("dir", included), 0o644, protocol.FileInfoTypeFile, contents)
+	fc.sendIndexUpdate()
+	waitFor(included)
+	waitFor("dir")
+	waitFor(filepath.Join("dir", included))
+
+	for _, name := range []string{"excludeddir", "excludedlink"} {
+		if _, err := tfs.Lstat(name); err == nil {
+			t.Fatal(name, "exists")
+		}
+	}
+
+	if _, err := tfs.Lstat(excluded); err == nil {
+		t.Fatal("excluded file exists")
+	}
+	if _, ok := m.testCurrentFolderFile(fcfg.ID, excluded); ok {
+		t.Fatal("excluded file is in the local index")
+	}
+	// The file is still needed, but not announced as invalid.
+	if _, ok, err := m.CurrentGlobalFile(fcfg.ID, excluded); err != nil || !ok {
+		t.Fatal("excluded file is missing from the global index", err)
+	}
+
+	must(t, m.FetchFile(fcfg.ID, excluded))
+	waitFor(excluded)
+	if err := equalContents(tfs, excluded, contents); err != nil {
+		t.Error("Fetched file did not sync correctly:", err)
+	}
+
+	// The fetch is remembered.
+	if ok, _, err := db.NewFolderFetchNamespace(m.db, fcfg.ID).Bool(excluded); err != nil || !ok {
+		t.Error("fetch was not persisted", err)
+	}
+	if err := m.FetchFile(fcfg.ID, "nonexistent"); err != ErrFetchNotFound {
+		t.Errorf("expected %v, got %v", ErrFetchNotFound, err)
+	}
+}
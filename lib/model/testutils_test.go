@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/events"
@@ -435,3 +436,14 @@ func writeFilePerm(t testing.TB, filesystem fs.Filesystem, name string, data []b
 	writeFile(t, filesystem, name, data)
 	must(t, filesystem.Chmod(name, perm))
 }
+
+// protocolModel is the model as connections see it, for tests that connect
+// it directly instead of through the connection service, which handles the
+// direct addresses.
+type protocolModel struct {
+	connections.Model
+}
+
+func (protocolModel) DirectAddresses(protocol.Connection, *protocol.DirectAddresses) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package protocol
+
+import "github.com/syncthing/syncthing/internal/gen/bep"
+
+// DirectAddresses tells the peer at which addresses we might be reachable
+// directly, so that it can try to upgrade a relayed connection.
+type DirectAddresses struct {
+	Addresses []string
+}
+
+func (d *DirectAddresses) toWire() *bep.DirectAddresses {
+	return &bep.DirectAddresses{
+		Addresses: d.Addresses,
+	}
+}
+
+func directAddressesFromWire(w *bep.DirectAddresses) *DirectAddresses {
+	return &DirectAddresses{
+		Addresses: w.Addresses,
+	}
+}
@@ -21,6 +21,7 @@ type TestModel struct {
 	fromTemporary bool
 	indexFn       func(string, []FileInfo)
 	ccFn          func(*ClusterConfig)
+	daFn          func(*DirectAddresses)
 	closedCh      chan struct{}
 	closedErr     error
 }
@@ -71,6 +72,13 @@ func (*TestModel) DownloadProgress(Connection, *DownloadProgress) error {
 	return nil
 }
 
+func (t *TestModel) DirectAddresses(_ Connection, da *DirectAddresses) error {
+	if t.daFn != nil {
+		t.daFn(da)
+	}
+	return nil
+}
+
 func (t *TestModel) closedError() error {
 	select {
 	case <-t.closedCh:
@@ -161,6 +161,10 @@ func (e encryptedModel) ClusterConfig(config *ClusterConfig) error {
 	return e.model.ClusterConfig(config)
 }
 
+func (e encryptedModel) DirectAddresses(da *DirectAddresses) error {
+	return e.model.DirectAddresses(da)
+}
+
 func (e encryptedModel) Closed(err error) {
 	e.model.Closed(err)
 }
@@ -266,6 +270,10 @@ func (e encryptedConnection) ClusterConfig(config *ClusterConfig) {
 	e.conn.ClusterConfig(config)
 }
 
+func (e encryptedConnection) DirectAddresses(ctx context.Context, da *DirectAddresses) {
+	e.conn.DirectAddresses(ctx, da)
+}
+
 func (e encryptedConnection) Close(err error) {
 	e.conn.Close(err)
 }
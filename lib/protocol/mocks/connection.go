@@ -61,6 +61,12 @@ type Connection struct {
 	deviceIDReturnsOnCall map[int]struct {
 		result1 protocol.DeviceID
 	}
+	DirectAddressesStub        func(context.Context, *protocol.DirectAddresses)
+	directAddressesMutex       sync.RWMutex
+	directAddressesArgsForCall []struct {
+		arg1 context.Context
+		arg2 *protocol.DirectAddresses
+	}
 	DownloadProgressStub        func(context.Context, *protocol.DownloadProgress)
 	downloadProgressMutex       sync.RWMutex
 	downloadProgressArgsForCall []struct {
@@ -474,6 +480,39 @@ func (fake *Connection) DeviceIDReturnsOnCall(i int, result1 protocol.DeviceID)
 	}{result1}
 }
 
+func (fake *Connection) DirectAddresses(arg1 context.Context, arg2 *protocol.DirectAddresses) {
+	fake.directAddressesMutex.Lock()
+	fake.directAddressesArgsForCall = append(fake.directAddressesArgsForCall, struct {
+		arg1 context.Context
+		arg2 *protocol.DirectAddresses
+	}{arg1, arg2})
+	stub := fake.DirectAddressesStub
+	fake.recordInvocation("DirectAddresses", []interface{}{arg1, arg2})
+	fake.directAddressesMutex.Unlock()
+	if stub != nil {
+		fake.DirectAddressesStub(arg1, arg2)
+	}
+}
+
+func (fake *Connection) DirectAddressesCallCount() int {
+	fake.directAddressesMutex.RLock()
+	defer fake.directAddressesMutex.RUnlock()
+	return len(fake.directAddressesArgsForCall)
+}
+
+func (fake *Connection) DirectAddressesCalls(stub func(context.Context, *protocol.DirectAddresses)) {
+	fake.directAddressesMutex.Lock()
+	defer fake.directAddressesMutex.Unlock()
+	fake.DirectAddressesStub = stub
+}
+
+func (fake *Connection) DirectAddressesArgsForCall(i int) (context.Context, *protocol.DirectAddresses) {
+	fake.directAddressesMutex.RLock()
+	defer fake.directAddressesMutex.RUnlock()
+	argsForCall := fake.directAddressesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *Connection) DownloadProgress(arg1 context.Context, arg2 *protocol.DownloadProgress) {
 	fake.downloadProgressMutex.Lock()
 	fake.downloadProgressArgsForCall = append(fake.downloadProgressArgsForCall, struct {
@@ -1191,6 +1230,8 @@ func (fake *Connection) Invocations() map[string][][]interface{} {
 	defer fake.cryptoMutex.RUnlock()
 	fake.deviceIDMutex.RLock()
 	defer fake.deviceIDMutex.RUnlock()
+	fake.directAddressesMutex.RLock()
+	defer fake.directAddressesMutex.RUnlock()
 	fake.downloadProgressMutex.RLock()
 	defer fake.downloadProgressMutex.RUnlock()
 	fake.establishedAtMutex.RLock()
@@ -91,6 +91,8 @@ type Model interface {
 	Closed(conn Connection, err error)
 	// The peer device sent progress updates for the files it is currently downloading
 	DownloadProgress(conn Connection, p *DownloadProgress) error
+	// The peer device sent addresses at which it might be reachable directly
+	DirectAddresses(conn Connection, da *DirectAddresses) error
 }
 
 // rawModel is the Model interface, but without the initial Connection
@@ -102,6 +104,7 @@ type rawModel interface {
 	ClusterConfig(*ClusterConfig) error
 	Closed(err error)
 	DownloadProgress(*DownloadProgress) error
+	DirectAddresses(*DirectAddresses) error
 }
 
 type RequestResponse interface {
@@ -136,6 +139,9 @@ type Connection interface {
 	// further by the caller.
 	DownloadProgress(ctx context.Context, dp *DownloadProgress)
 
+	// Send a Direct Addresses message to the peer device.
+	DirectAddresses(ctx context.Context, da *DirectAddresses)
+
 	Start()
 	SetFolderPasswords(passwords map[string]string)
 	Close(err error)
@@ -385,6 +391,11 @@ func (c *rawConnection) DownloadProgress(ctx context.Context, dp *DownloadProgre
 	c.send(ctx, dp.toWire(), nil)
 }
 
+// DirectAddresses sends the addresses at which we might be reachable directly.
+func (c *rawConnection) DirectAddresses(ctx context.Context, da *DirectAddresses) {
+	c.send(ctx, da.toWire(), nil)
+}
+
 func (c *rawConnection) ping() bool {
 	return c.send(context.Background(), &bep.Ping{}, nil)
 }
@@ -480,6 +491,9 @@ func (c *rawConnection) dispatcherLoop() (err error) {
 
 		case *bep.DownloadProgress:
 			err = c.model.DownloadProgress(downloadProgressFromWire(msg))
+
+		case *bep.DirectAddresses:
+			err = c.model.DirectAddresses(directAddressesFromWire(msg))
 		}
 		if err != nil {
 			return newHandleError(err, msgContext)
@@ -875,6 +889,8 @@ func typeOf(msg proto.Message) bep.MessageType {
 		return bep.MessageType_MESSAGE_TYPE_PING
 	case *bep.Close:
 		return bep.MessageType_MESSAGE_TYPE_CLOSE
+	case *bep.DirectAddresses:
+		return bep.MessageType_MESSAGE_TYPE_DIRECT_ADDRESSES
 	default:
 		panic("bug: unknown message type")
 	}
@@ -898,6 +914,8 @@ func newMessage(t bep.MessageType) (proto.Message, error) {
 		return new(bep.Ping), nil
 	case bep.MessageType_MESSAGE_TYPE_CLOSE:
 		return new(bep.Close), nil
+	case bep.MessageType_MESSAGE_TYPE_DIRECT_ADDRESSES:
+		return new(bep.DirectAddresses), nil
 	default:
 		return nil, errUnknownMessage
 	}
@@ -1098,6 +1116,8 @@ func messageContext(msg proto.Message) (string, error) {
 		return "ping", nil
 	case *bep.Close:
 		return "close", nil
+	case *bep.DirectAddresses:
+		return "direct-addresses", nil
 	default:
 		return "", errors.New("unknown or empty message")
 	}
@@ -1134,3 +1154,7 @@ func (c *connectionWrappingModel) Closed(err error) {
 func (c *connectionWrappingModel) DownloadProgress(p *DownloadProgress) error {
 	return c.model.DownloadProgress(c.conn, p)
 }
+
+func (c *connectionWrappingModel) DirectAddresses(da *DirectAddresses) error {
+	return c.model.DirectAddresses(c.conn, da)
+}
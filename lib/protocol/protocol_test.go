@@ -52,6 +52,38 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestDirectAddresses(t *testing.T) {
+	m1 := newTestModel()
+	received := make(chan []string, 1)
+	m1.daFn = func(da *DirectAddresses) {
+		received <- da.Addresses
+	}
+
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, newTestModel(), new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c0.Start()
+	defer closeAndWait(c0, ar, bw)
+	c1 := getRawConnection(NewConnection(c1ID, br, aw, testutil.NoopCloser{}, m1, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c1.Start()
+	defer closeAndWait(c1, ar, bw)
+	c0.ClusterConfig(&ClusterConfig{})
+	c1.ClusterConfig(&ClusterConfig{})
+
+	addrs := []string{"tcp://192.0.2.42:22000", "quic://192.0.2.42:22000"}
+	c0.DirectAddresses(context.Background(), &DirectAddresses{Addresses: addrs})
+
+	select {
+	case got := <-received:
+		if len(got) != len(addrs) || got[0] != addrs[0] || got[1] != addrs[1] {
+			t.Errorf("got %v, expected %v", got, addrs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for direct addresses")
+	}
+}
+
 var errManual = errors.New("manual close")
 
 func TestClose(t *testing.T) {
@@ -7,10 +7,16 @@
 package s3
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -24,12 +30,36 @@ type Session struct {
 
 type Object = s3.Object
 
-func NewSession(endpoint, region, bucket, accessKeyID, secretKey string) (*Session, error) {
-	sess, err := session.NewSession(&aws.Config{
+// ObjectInfo is the result of a Stat call on a single object.
+type ObjectInfo struct {
+	Size         int64
+	LastModified time.Time
+	// Metadata holds the user metadata of the object, with lower case keys.
+	Metadata map[string]string
+}
+
+// An Option modifies the session configuration at creation.
+type Option func(*aws.Config)
+
+// WithPathStyle makes the session address buckets as part of the request
+// path instead of as a subdomain, as required by most S3-compatible
+// servers (MinIO and friends).
+func WithPathStyle() Option {
+	return func(cfg *aws.Config) {
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+}
+
+func NewSession(endpoint, region, bucket, accessKeyID, secretKey string, opts ...Option) (*Session, error) {
+	cfg := &aws.Config{
 		Region:      aws.String(region),
 		Endpoint:    aws.String(endpoint),
 		Credentials: credentials.NewStaticCredentials(accessKeyID, secretKey, ""),
-	})
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	sess, err := session.NewSession(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -49,12 +79,133 @@ func (s *Session) Upload(r io.Reader, key string) error {
 	return err
 }
 
+// Put uploads the contents of r to the given key, with the given user
+// metadata attached to the object.
+func (s *Session) Put(r io.Reader, key string, meta map[string]string) error {
+	uploader := s3manager.NewUploader(s.s3sess)
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Body:     r,
+		Metadata: aws.StringMap(meta),
+	})
+	return err
+}
+
+// Stat returns the size, modification time and user metadata of the
+// given key. The returned error satisfies IsNotFound if the object does
+// not exist.
+func (s *Session) Stat(key string) (*ObjectInfo, error) {
+	svc := s3.New(s.s3sess)
+	resp, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Size:         aws.Int64Value(resp.ContentLength),
+		LastModified: aws.TimeValue(resp.LastModified),
+		Metadata:     make(map[string]string, len(resp.Metadata)),
+	}
+	for k, v := range resp.Metadata {
+		info.Metadata[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return info, nil
+}
+
+// ReadRange returns a reader for length bytes of the object at key,
+// starting at offset. A negative length reads to the end of the object.
+func (s *Session) ReadRange(key string, offset, length int64) (io.ReadCloser, error) {
+	svc := s3.New(s.s3sess)
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		rng = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+	resp, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(rng),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Copy performs a server side copy of the object at src to dst, replacing
+// the user metadata with meta.
+func (s *Session) Copy(src, dst string, meta map[string]string) error {
+	svc := s3.New(s.s3sess)
+	_, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(dst),
+		CopySource:        aws.String((&url.URL{Path: s.bucket + "/" + src}).EscapedPath()),
+		Metadata:          aws.StringMap(meta),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	})
+	return err
+}
+
+// Delete removes the object at key. Deleting an object that does not
+// exist is not an error.
+func (s *Session) Delete(key string) error {
+	svc := s3.New(s.s3sess)
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// ListDir returns the objects and common prefixes directly below the
+// given prefix, using "/" as the delimiter.
+func (s *Session) ListDir(prefix string) ([]*Object, []string, error) {
+	svc := s3.New(s.s3sess)
+
+	var objects []*Object
+	var prefixes []string
+	opts := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+	for {
+		resp, err := svc.ListObjectsV2(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		objects = append(objects, resp.Contents...)
+		for _, p := range resp.CommonPrefixes {
+			prefixes = append(prefixes, aws.StringValue(p.Prefix))
+		}
+
+		if resp.NextContinuationToken == nil || *resp.NextContinuationToken == "" {
+			break
+		}
+		opts.ContinuationToken = resp.NextContinuationToken
+	}
+
+	return objects, prefixes, nil
+}
+
 func (s *Session) List(fn func(*Object) bool) error {
+	return s.ListPrefix("", fn)
+}
+
+// ListPrefix calls fn for every object with a key starting with prefix,
+// until fn returns false.
+func (s *Session) ListPrefix(prefix string, fn func(*Object) bool) error {
 	svc := s3.New(s.s3sess)
 
 	opts := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
 	}
+	if prefix != "" {
+		opts.Prefix = aws.String(prefix)
+	}
 	for {
 		resp, err := svc.ListObjectsV2(opts)
 		if err != nil {
@@ -99,3 +250,20 @@ func (s *Session) Download(w io.WriterAt, key string) error {
 	})
 	return err
 }
+
+// IsNotFound returns true if the error is the result of accessing an
+// object that does not exist.
+func IsNotFound(err error) bool {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
+}
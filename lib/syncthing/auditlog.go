@@ -0,0 +1,544 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package syncthing
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const auditRotatedTimeFormat = "20060102-150405"
+
+// An auditEntry is one line of the audit log. The hash covers the previous
+// entry's hash, the sequence number and the event, so that changing,
+// removing or reordering entries breaks the chain. The chain is not keyed:
+// it shows that entries were lost or changed by accident or by someone
+// unable to rewrite all the entries after them, not more.
+type auditEntry struct {
+	Seq   int64           `json:"seq"`
+	Prev  string          `json:"prev"`
+	Hash  string          `json:"hash"`
+	Event json.RawMessage `json:"event"`
+}
+
+// An auditHeader starts each audit file continuing the chain of a previous
+// one, giving the sequence number and hash of the last entry before it, so
+// that losing entries from the end of the previous file is noticed too.
+type auditHeader struct {
+	PrevSeq  int64  `json:"prevSeq"`
+	PrevHash string `json:"prevHash"`
+}
+
+// An auditLine is either an entry or a header.
+type auditLine struct {
+	Header *auditHeader `json:"header,omitempty"`
+	auditEntry
+}
+
+// The chain starts from an all zero hash.
+var auditGenesis = make([]byte, sha256.Size)
+
+func auditEntryHash(prev []byte, seq int64, event []byte) []byte {
+	h := sha256.New()
+	h.Write(prev)
+	var seqBs [8]byte
+	binary.BigEndian.PutUint64(seqBs[:], uint64(seq))
+	h.Write(seqBs[:])
+	h.Write(event)
+	return h.Sum(nil)
+}
+
+// auditEntryRecorder is implemented by audit destinations that keep track of
+// the chain, to be able to continue it.
+type auditEntryRecorder interface {
+	recordEntry(seq int64, hash []byte)
+}
+
+// auditChain writes events as chained audit entries, one per line and
+// write call.
+type auditChain struct {
+	w    io.Writer
+	seq  int64
+	prev []byte
+}
+
+func newAuditChain(w io.Writer) *auditChain {
+	return &auditChain{w: w, prev: auditGenesis}
+}
+
+func (c *auditChain) write(event []byte) error {
+	seq := c.seq + 1
+	hash := auditEntryHash(c.prev, seq, event)
+	bs, err := json.Marshal(auditEntry{
+		Seq:   seq,
+		Prev:  hex.EncodeToString(c.prev),
+		Hash:  hex.EncodeToString(hash),
+		Event: event,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := c.w.Write(append(bs, '\n')); err != nil {
+		return err
+	}
+	c.seq, c.prev = seq, hash
+	if r, ok := c.w.(auditEntryRecorder); ok {
+		r.recordEntry(seq, hash)
+	}
+	return nil
+}
+
+// An AuditFile is an audit log file that is rotated when it grows beyond
+// maxSize bytes or has been written to for longer than maxAge, whichever
+// limit is set. Rotated files get a timestamp between the file name and the
+// extension, and only the newest maxFiles of them are kept when that is
+// set. The hash chain continues across rotated files, each new file
+// starting with a header linking it to the previous one.
+type AuditFile struct {
+	name     string
+	flags    int
+	maxSize  int64
+	maxAge   time.Duration
+	maxFiles int
+
+	mut     sync.Mutex
+	fd      *os.File
+	size    int64
+	opened  time.Time
+	lastSeq int64
+	last    []byte
+
+	// The size of the header, when the file was started with one, so
+	// that a file isn't rotated before it has any entries.
+	headerSize int64
+}
+
+// OpenAuditFile opens the named audit file with the given flags, which
+// should include os.O_APPEND or os.O_EXCL, continuing the hash chain of the
+// entries already in it, or in the newest file rotated from it. It fails
+// if there are entries to continue from that can't be read.
+func OpenAuditFile(name string, flags int, maxSize int64, maxAge time.Duration, maxFiles int) (*AuditFile, error) {
+	f := &AuditFile{
+		name:     name,
+		flags:    flags | os.O_WRONLY | os.O_CREATE,
+		maxSize:  maxSize,
+		maxAge:   maxAge,
+		maxFiles: maxFiles,
+		last:     auditGenesis,
+	}
+	found, err := f.readLastEntry(name)
+	if err != nil {
+		return nil, fmt.Errorf("continuing the hash chain of %s: %w", name, err)
+	}
+	if !found {
+		rotated, err := RotatedAuditFiles(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(rotated) > 0 {
+			newest := rotated[len(rotated)-1]
+			if _, err := f.readLastEntry(newest); err != nil {
+				return nil, fmt.Errorf("continuing the hash chain of %s: %w", newest, err)
+			}
+		}
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readLastEntry sets the chain to continue from the last valid entry in the
+// named file, or its header if it has no entries, and returns whether there
+// was one. Invalid lines after it, such as an entry only partly written,
+// are skipped, leaving them for verification to point out.
+func (f *AuditFile) readLastEntry(name string) (bool, error) {
+	fd, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+
+	found, invalid := false, false
+	scanner := bufio.NewScanner(fd)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		seq, hash, err := parseAuditLine(line)
+		if err != nil {
+			invalid = true
+			continue
+		}
+		f.lastSeq, f.last = seq, hash
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	if invalid && !found {
+		return false, errors.New("no valid entries")
+	}
+	return found, nil
+}
+
+// parseAuditLine returns the sequence number and hash the chain continues
+// from after the line.
+func parseAuditLine(bs []byte) (int64, []byte, error) {
+	var line auditLine
+	if err := json.Unmarshal(bs, &line); err != nil {
+		return 0, nil, err
+	}
+	seq, hashStr := line.Seq, line.Hash
+	if line.Header != nil {
+		seq, hashStr = line.Header.PrevSeq, line.Header.PrevHash
+	}
+	hash, err := hex.DecodeString(hashStr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(hash) != sha256.Size {
+		return 0, nil, errors.New("invalid hash length")
+	}
+	return seq, hash, nil
+}
+
+func (f *AuditFile) open() error {
+	fd, err := os.OpenFile(f.name, f.flags, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	f.fd = fd
+	f.size = info.Size()
+	f.opened = time.Now()
+	f.headerSize = 0
+	if f.size == 0 && f.lastSeq > 0 {
+		return f.writeHeader()
+	}
+	return nil
+}
+
+func (f *AuditFile) writeHeader() error {
+	bs, err := json.Marshal(auditLine{Header: &auditHeader{
+		PrevSeq:  f.lastSeq,
+		PrevHash: hex.EncodeToString(f.last),
+	}})
+	if err != nil {
+		return err
+	}
+	n, err := f.fd.Write(append(bs, '\n'))
+	f.size += int64(n)
+	f.headerSize = f.size
+	return err
+}
+
+// LastEntry returns the sequence number and hash of the last entry written
+// to the file, for the chain to continue from.
+func (f *AuditFile) LastEntry() (int64, []byte) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.lastSeq, f.last
+}
+
+func (f *AuditFile) recordEntry(seq int64, hash []byte) {
+	f.mut.Lock()
+	f.lastSeq, f.last = seq, hash
+	f.mut.Unlock()
+}
+
+// Write writes bs to the file, rotating it first if needed. Entries should
+// be written one per call, so that they aren't split between files.
+func (f *AuditFile) Write(bs []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if f.fd == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > f.headerSize && (f.maxSize > 0 && f.size+int64(len(bs)) > f.maxSize || f.maxAge > 0 && time.Since(f.opened) > f.maxAge) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.fd.Write(bs)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *AuditFile) rotate() error {
+	if err := f.fd.Close(); err != nil {
+		return err
+	}
+	f.fd = nil
+
+	rotated, err := rotatedAuditFiles(f.name)
+	if err != nil {
+		return err
+	}
+
+	// Files rotated within the same second get a counter, which must sort
+	// after the existing ones even when older ones have been pruned.
+	ext := filepath.Ext(f.name)
+	stamp, counter := time.Now().Truncate(time.Second), 0
+	if len(rotated) > 0 {
+		if newest := rotated[len(rotated)-1]; !newest.stamp.Before(stamp) {
+			stamp, counter = newest.stamp, newest.counter+1
+		}
+	}
+	to := strings.TrimSuffix(f.name, ext) + "." + stamp.Format(auditRotatedTimeFormat)
+	if counter > 0 {
+		to += fmt.Sprintf("-%d", counter)
+	}
+	if err := os.Rename(f.name, to+ext); err != nil {
+		return err
+	}
+	f.prune()
+
+	// The rotated file is out of the way, so the new one can be created
+	// even when the flags are exclusive.
+	return f.open()
+}
+
+// prune removes the oldest rotated files beyond maxFiles.
+func (f *AuditFile) prune() {
+	if f.maxFiles <= 0 {
+		return
+	}
+	rotated, err := RotatedAuditFiles(f.name)
+	if err != nil {
+		l.Warnln("Audit: pruning rotated files:", err)
+		return
+	}
+	for len(rotated) > f.maxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			l.Warnln("Audit: pruning rotated files:", err)
+		}
+		rotated = rotated[1:]
+	}
+}
+
+// Close closes the file.
+func (f *AuditFile) Close() error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.fd == nil {
+		return os.ErrClosed
+	}
+	err := f.fd.Close()
+	f.fd = nil
+	return err
+}
+
+// RotatedAuditFiles returns the files rotated from the named audit file,
+// oldest first.
+func RotatedAuditFiles(name string) ([]string, error) {
+	rotated, err := rotatedAuditFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(rotated))
+	for i, r := range rotated {
+		names[i] = r.name
+	}
+	return names, nil
+}
+
+type rotatedAuditFile struct {
+	name    string
+	stamp   time.Time
+	counter int
+}
+
+func rotatedAuditFiles(name string) ([]rotatedAuditFile, error) {
+	dir := filepath.Dir(name)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(filepath.Base(name), ext) + "."
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var rotated []rotatedAuditFile
+	for _, entry := range entries {
+		// The rotated name is prefix + timestamp + optional "-n" + ext.
+		rest, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || !strings.HasSuffix(rest, ext) {
+			continue
+		}
+		rest = strings.TrimSuffix(rest, ext)
+		if len(rest) < len(auditRotatedTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(auditRotatedTimeFormat, rest[:len(auditRotatedTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		n := 0
+		if counter := rest[len(auditRotatedTimeFormat):]; counter != "" {
+			counter, ok = strings.CutPrefix(counter, "-")
+			if !ok {
+				continue
+			}
+			if n, err = strconv.Atoi(counter); err != nil || n < 1 {
+				continue
+			}
+		}
+		rotated = append(rotated, rotatedAuditFile{filepath.Join(dir, entry.Name()), t, n})
+	}
+	sort.Slice(rotated, func(a, b int) bool {
+		if !rotated[a].stamp.Equal(rotated[b].stamp) {
+			return rotated[a].stamp.Before(rotated[b].stamp)
+		}
+		return rotated[a].counter < rotated[b].counter
+	})
+	return rotated, nil
+}
+
+// An AuditVerifier checks that audit entries hash correctly and form an
+// unbroken chain. Verify may be called with consecutive files of one log to
+// check them as a whole. A log that doesn't start with the first entry,
+// for example as the oldest files have been pruned, can only be checked
+// from where it starts, which FirstSeq tells.
+type AuditVerifier struct {
+	seq      int64
+	prev     []byte
+	firstSeq int64
+}
+
+// An AuditVerifyError is the first problem found by an AuditVerifier.
+type AuditVerifyError struct {
+	Line int
+	Err  error
+}
+
+func (e *AuditVerifyError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *AuditVerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify checks the entries read from r, returning how many were valid
+// before any problem.
+func (v *AuditVerifier) Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	line, n := 0, 0
+	first := true
+	for scanner.Scan() {
+		line++
+		bs := bytes.TrimSpace(scanner.Bytes())
+		if len(bs) == 0 {
+			continue
+		}
+		entry, err := v.verifyLine(bs, first)
+		if err != nil {
+			return n, &AuditVerifyError{Line: line, Err: err}
+		}
+		if entry {
+			n++
+		}
+		first = false
+	}
+	if err := scanner.Err(); err != nil {
+		return n, &AuditVerifyError{Line: line + 1, Err: err}
+	}
+	return n, nil
+}
+
+// FirstSeq returns the sequence number of the first entry checked. When it
+// is more than one, the entries before it couldn't be checked.
+func (v *AuditVerifier) FirstSeq() int64 {
+	return v.firstSeq
+}
+
+// verifyLine checks an entry or header line, returning whether it was an
+// entry.
+func (v *AuditVerifier) verifyLine(bs []byte, first bool) (bool, error) {
+	var line auditLine
+	if err := json.Unmarshal(bs, &line); err != nil {
+		return false, fmt.Errorf("invalid entry: %w", err)
+	}
+	if line.Header != nil {
+		if !first {
+			return false, errors.New("header in the middle of a file")
+		}
+		return false, v.verifyHeader(line.Header)
+	}
+	return true, v.verifyEntry(line.auditEntry)
+}
+
+func (v *AuditVerifier) verifyHeader(header *auditHeader) error {
+	prev, err := hex.DecodeString(header.PrevHash)
+	if err != nil || len(prev) != sha256.Size {
+		return errors.New("invalid header hash")
+	}
+	if header.PrevSeq < 1 {
+		return fmt.Errorf("invalid header sequence %d", header.PrevSeq)
+	}
+	if v.prev == nil {
+		// Starting in the middle of the chain, from what the header
+		// claims came before.
+		v.seq, v.prev = header.PrevSeq, prev
+		return nil
+	}
+	if header.PrevSeq != v.seq || !bytes.Equal(prev, v.prev) {
+		return fmt.Errorf("file continues from sequence %d, not from %d, entries are missing at the end of the previous file", header.PrevSeq, v.seq)
+	}
+	return nil
+}
+
+func (v *AuditVerifier) verifyEntry(entry auditEntry) error {
+	prev, err := hex.DecodeString(entry.Prev)
+	if err != nil {
+		return fmt.Errorf("invalid previous hash: %w", err)
+	}
+	switch {
+	case v.prev != nil && entry.Seq != v.seq+1:
+		return fmt.Errorf("sequence %d follows %d, entries are missing or reordered", entry.Seq, v.seq)
+	case v.prev != nil && !bytes.Equal(prev, v.prev):
+		return fmt.Errorf("sequence %d doesn't chain to the previous entry", entry.Seq)
+	case v.prev == nil && entry.Seq < 1:
+		return fmt.Errorf("invalid sequence %d", entry.Seq)
+	case v.prev == nil && entry.Seq == 1 && !bytes.Equal(prev, auditGenesis):
+		return errors.New("first entry doesn't start the chain")
+	}
+	hash := auditEntryHash(prev, entry.Seq, entry.Event)
+	if hex.EncodeToString(hash) != entry.Hash {
+		return fmt.Errorf("sequence %d has been modified", entry.Seq)
+	}
+	if v.firstSeq == 0 {
+		v.firstSeq = entry.Seq
+	}
+	v.seq, v.prev = entry.Seq, hash
+	return nil
+}
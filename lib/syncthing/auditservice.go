@@ -16,25 +16,44 @@ import (
 )
 
 // The auditService subscribes to events and writes these in JSON format, one
-// event per line, to the specified writer.
+// event per line, to the specified writer. Each line is an audit entry with
+// the event and a hash chaining it to the previous entry, so that edits and
+// removals can be detected by an AuditVerifier.
 type auditService struct {
 	w        io.Writer // audit destination
 	evLogger events.Logger
+	mask     events.EventType
+	chain    *auditChain // kept across restarts of the service
 }
 
-func newAuditService(w io.Writer, evLogger events.Logger) *auditService {
+// auditChainResumer is implemented by audit destinations that already
+// contain entries, to continue their chain.
+type auditChainResumer interface {
+	LastEntry() (seq int64, hash []byte)
+}
+
+func newAuditService(w io.Writer, evLogger events.Logger, mask events.EventType) *auditService {
+	if mask == 0 {
+		mask = events.AllEvents
+	}
 	return &auditService{
 		w:        w,
 		evLogger: evLogger,
+		mask:     mask,
+		chain:    newAuditChain(w),
 	}
 }
 
 // serve runs the audit service.
 func (s *auditService) Serve(ctx context.Context) error {
-	sub := s.evLogger.Subscribe(events.AllEvents)
+	sub := s.evLogger.Subscribe(s.mask)
 	defer sub.Unsubscribe()
 
-	enc := json.NewEncoder(s.w)
+	// The destination knows best where the chain is at, as of the last
+	// entry actually written.
+	if r, ok := s.w.(auditChainResumer); ok {
+		s.chain.seq, s.chain.prev = r.LastEntry()
+	}
 
 	for {
 		select {
@@ -43,7 +62,14 @@ func (s *auditService) Serve(ctx context.Context) error {
 				<-ctx.Done()
 				return ctx.Err()
 			}
-			enc.Encode(ev)
+			bs, err := json.Marshal(ev)
+			if err != nil {
+				l.Warnln("Audit: marshalling event:", err)
+				continue
+			}
+			if err := s.chain.write(bs); err != nil {
+				l.Warnln("Audit:", err)
+			}
 		case <-ctx.Done():
 			return ctx.Err()
 		}
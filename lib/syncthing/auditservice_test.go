@@ -9,6 +9,10 @@ package syncthing
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -31,7 +35,7 @@ func TestAuditService(t *testing.T) {
 	<-sub.C()
 
 	auditCtx, auditCancel := context.WithCancel(context.Background())
-	service := newAuditService(buf, evLogger)
+	service := newAuditService(buf, evLogger, 0)
 	done := make(chan struct{})
 	go func() {
 		service.Serve(auditCtx)
@@ -68,3 +72,202 @@ func TestAuditService(t *testing.T) {
 		t.Error("Missing third event")
 	}
 }
+
+func TestAuditServiceFilter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	evLogger := events.NewLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	go evLogger.Serve(ctx)
+	defer cancel()
+
+	auditCtx, auditCancel := context.WithCancel(context.Background())
+	service := newAuditService(buf, evLogger, events.ConfigSaved)
+	done := make(chan struct{})
+	go func() {
+		service.Serve(auditCtx)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	evLogger.Log(events.Starting, "filtered event")
+	evLogger.Log(events.ConfigSaved, "audited event")
+	time.Sleep(10 * time.Millisecond)
+	auditCancel()
+	<-done
+
+	result := buf.String()
+	if strings.Contains(result, "filtered event") {
+		t.Error("Unexpected filtered event")
+	}
+	if !strings.Contains(result, "audited event") {
+		t.Error("Missing audited event")
+	}
+}
+
+func TestAuditChainVerify(t *testing.T) {
+	buf := new(bytes.Buffer)
+	chain := newAuditChain(buf)
+	for _, ev := range []string{`{"id":1}`, `{"id":2}`, `{"id":3}`} {
+		if err := chain.write([]byte(ev)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")[:3]
+
+	var v AuditVerifier
+	if n, err := v.Verify(strings.NewReader(buf.String())); err != nil || n != 3 {
+		t.Fatalf("expected 3 valid entries, got %d, %v", n, err)
+	}
+
+	cases := map[string]string{
+		"modified":  lines[0] + strings.Replace(lines[1], `"id":2`, `"id":4`, 1) + lines[2],
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[0] + lines[2] + lines[1],
+		"truncated": lines[1] + lines[2][:len(lines[2])-10],
+	}
+	for name, log := range cases {
+		var v AuditVerifier
+		_, err := v.Verify(strings.NewReader(log))
+		var verr *AuditVerifyError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: expected a verification error, got %v", name, err)
+		}
+	}
+
+	// A log that starts later in the chain can be checked from there, but
+	// not if it claims to start the chain.
+	v = AuditVerifier{}
+	if _, err := v.Verify(strings.NewReader(lines[1] + lines[2])); err != nil {
+		t.Error(err)
+	}
+	v = AuditVerifier{}
+	forged := strings.Replace(lines[1], `"seq":2`, `"seq":1`, 1)
+	if _, err := v.Verify(strings.NewReader(forged)); err == nil {
+		t.Error("forged first entry verified")
+	}
+}
+
+func TestAuditFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+
+	af, err := OpenAuditFile(name, os.O_APPEND, 200, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newAuditChain(af)
+	for i := 0; i < 10; i++ {
+		if err := chain.write([]byte(fmt.Sprintf(`{"id":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	af.Close()
+
+	// Reopening continues the chain, which verifies across the kept
+	// files.
+	af, err = OpenAuditFile(name, os.O_APPEND, 200, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain = newAuditChain(af)
+	chain.seq, chain.prev = af.LastEntry()
+	if chain.seq != 10 {
+		t.Errorf("expected to continue after entry 10, got %d", chain.seq)
+	}
+	if err := chain.write([]byte(`{"id":10}`)); err != nil {
+		t.Fatal(err)
+	}
+	af.Close()
+
+	rotated, err := RotatedAuditFiles(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 rotated files to be kept, got %v", rotated)
+	}
+
+	var v AuditVerifier
+	for _, file := range append(rotated, name) {
+		fd, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v.Verify(fd)
+		fd.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+	if v.seq != 11 {
+		t.Errorf("expected to verify through entry 11, got %d", v.seq)
+	}
+	// The oldest files were pruned, which shows.
+	if v.FirstSeq() <= 1 {
+		t.Errorf("expected the chain to start after pruned entries, got %d", v.FirstSeq())
+	}
+
+	// Entries lost from the end of a rotated file are noticed by the
+	// header of the next one.
+	bs, err := os.ReadFile(rotated[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(bs)), "\n")
+	truncated := strings.Join(lines[:len(lines)-1], "")
+	v = AuditVerifier{}
+	if _, err := v.Verify(strings.NewReader(truncated)); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if _, err := v.Verify(fd); err == nil {
+		t.Error("entries missing from the end of a rotated file were not noticed")
+	}
+}
+
+func TestAuditFileContinuesChain(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+
+	af, err := OpenAuditFile(name, os.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newAuditChain(af)
+	for i := 0; i < 3; i++ {
+		if err := chain.write([]byte(fmt.Sprintf(`{"id":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The last entry is kept up to date for restarts of the service.
+	if seq, hash := af.LastEntry(); seq != 3 || !bytes.Equal(hash, chain.prev) {
+		t.Errorf("expected last entry 3, got %d", seq)
+	}
+	// A partly written entry at the end doesn't start the chain over.
+	if _, err := af.Write([]byte(`{"seq":4,"pr`)); err != nil {
+		t.Fatal(err)
+	}
+	af.Close()
+
+	af, err = OpenAuditFile(name, os.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer af.Close()
+	if seq, _ := af.LastEntry(); seq != 3 {
+		t.Errorf("expected to continue after entry 3, got %d", seq)
+	}
+
+	// Nor does a file without valid entries.
+	garbage := filepath.Join(dir, "garbage.log")
+	if err := os.WriteFile(garbage, []byte("not an entry\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditFile(garbage, os.O_APPEND, 0, 0, 0); err == nil {
+		t.Error("expected an error continuing a file without valid entries")
+	}
+}
@@ -53,6 +53,7 @@ const (
 
 type Options struct {
 	AuditWriter    io.Writer
+	AuditEvents    events.EventType // zero means all events
 	NoUpgrade      bool
 	ProfilerAddr   string
 	ResetDeltaIdxs bool
@@ -127,13 +128,15 @@ func (a *App) startup() error {
 	a.mainService.Add(a.ll)
 
 	if a.opts.AuditWriter != nil {
-		a.mainService.Add(newAuditService(a.opts.AuditWriter, a.evLogger))
+		a.mainService.Add(newAuditService(a.opts.AuditWriter, a.evLogger, a.opts.AuditEvents))
 	}
 
 	if a.opts.Verbose {
 		a.mainService.Add(newVerboseService(a.evLogger))
 	}
 
+	a.mainService.Add(newWebhookService(a.cfg, a.evLogger))
+
 	errors := logger.NewRecorder(l, logger.LevelWarn, maxSystemErrors, 0)
 	systemLog := logger.NewRecorder(l, logger.LevelDebug, maxSystemLog, initialSystemLog)
 
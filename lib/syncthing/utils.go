@@ -150,6 +150,9 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
-func OpenDBBackend(path string, tuning config.Tuning) (backend.Backend, error) {
-	return backend.Open(path, backend.Tuning(tuning))
+func OpenDBBackend(path string, tuning config.Tuning, kind config.DatabaseBackend) (backend.Backend, error) {
+	if existing, ok := backend.DetectKind(path); ok && existing != backend.Kind(kind) {
+		l.Warnf(`Database is using the %v backend, not the configured %v backend. Stop Syncthing and run "syncthing cli debug index migrate %v" to convert it.`, existing, kind, kind)
+	}
+	return backend.Open(path, backend.Kind(kind), backend.Tuning(tuning))
 }
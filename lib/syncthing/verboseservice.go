@@ -74,6 +74,10 @@ func (*verboseService) formatEvent(ev events.Event) string {
 		data := ev.Data.(map[string]string)
 		return fmt.Sprintf("Disconnected from device %v", data["id"])
 
+	case events.ConnectionUpgraded:
+		data := ev.Data.(map[string]interface{})
+		return fmt.Sprintf("Upgraded relayed connection to device %v to %v at %v", data["device"], data["type"], data["address"])
+
 	case events.StateChanged:
 		data := ev.Data.(map[string]interface{})
 		return fmt.Sprintf("Folder %q is now %v", data["folder"], data["to"])
@@ -0,0 +1,288 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package syncthing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/api"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const (
+	webhookQueueSize   = 64
+	webhookMaxAttempts = 5
+	webhookTimeout     = 30 * time.Second
+)
+
+// The webhookService subscribes to events and POSTs them in JSON format, one
+// event per request, to the configured webhooks. Failed requests are
+// retried with exponential backoff. Each webhook has a queue of its own, so
+// a slow or failing webhook doesn't hold up the others; when its queue is
+// full, events for it are dropped.
+type webhookService struct {
+	cfg        config.Wrapper
+	evLogger   events.Logger
+	client     *http.Client
+	retryDelay time.Duration // before the first retry, doubling for each following one
+	changed    chan struct{}
+}
+
+func newWebhookService(cfg config.Wrapper, evLogger events.Logger) *webhookService {
+	return &webhookService{
+		cfg:        cfg,
+		evLogger:   evLogger,
+		client:     &http.Client{Timeout: webhookTimeout},
+		retryDelay: time.Second,
+		changed:    make(chan struct{}, 1),
+	}
+}
+
+func (s *webhookService) Serve(ctx context.Context) error {
+	s.cfg.Subscribe(s)
+	defer s.cfg.Unsubscribe(s)
+
+	for {
+		if err := s.serveHooks(ctx, s.cfg.RawCopy().Webhooks); err != nil {
+			return err
+		}
+	}
+}
+
+// serveHooks delivers events to the given webhooks until the context is
+// cancelled, returning its error, or the configuration changes, returning
+// nil.
+func (s *webhookService) serveHooks(ctx context.Context, cfgs []config.WebhookConfiguration) error {
+	if len(cfgs) == 0 {
+		select {
+		case <-s.changed:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	hooksCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hooks := make([]*webhook, len(cfgs))
+	var mask events.EventType
+	for i, cfg := range cfgs {
+		hooks[i] = newWebhook(cfg)
+		mask |= hooks[i].mask
+		go s.deliver(hooksCtx, hooks[i])
+	}
+
+	sub := s.evLogger.Subscribe(mask)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev, ok := <-sub.C():
+			if !ok {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			s.dispatch(hooks, ev)
+		case <-s.changed:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *webhookService) dispatch(hooks []*webhook, ev events.Event) {
+	var body []byte
+	var subject eventSubject
+	for _, hook := range hooks {
+		if hook.mask&ev.Type == 0 {
+			continue
+		}
+		if body == nil {
+			var err error
+			body, err = json.Marshal(ev)
+			if err != nil {
+				l.Debugln("webhook: marshalling event:", err)
+				return
+			}
+			// The folder and device of an event are whatever its data
+			// has in those fields, whether it's a map or a struct.
+			_ = json.Unmarshal(body, &struct {
+				Data *eventSubject `json:"data"`
+			}{&subject})
+		}
+		if !hook.matches(subject) {
+			continue
+		}
+		select {
+		case hook.queue <- webhookRequest{eventType: ev.Type, body: body}:
+		default:
+			l.Debugf("webhook: queue full for %s, dropping %v event", hook.cfg.URL, ev.Type)
+		}
+	}
+}
+
+// deliver sends the queued requests of the webhook, in order.
+func (s *webhookService) deliver(ctx context.Context, hook *webhook) {
+	for {
+		select {
+		case req := <-hook.queue:
+			s.send(ctx, hook, req)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *webhookService) send(ctx context.Context, hook *webhook, req webhookRequest) {
+	delay := s.retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, hook, req)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == webhookMaxAttempts {
+			l.Infof("Webhook %s: giving up on %v event after %d attempt(s): %v", hook.cfg.URL, req.eventType, attempt, err)
+			return
+		}
+		l.Debugf("webhook %s: attempt %d: %v", hook.cfg.URL, attempt, err)
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// post makes a single request, returning whether it's worth retrying if it
+// fails.
+func (s *webhookService) post(ctx context.Context, hook *webhook, req webhookRequest) (bool, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.cfg.URL, bytes.NewReader(req.body))
+	if err != nil {
+		return false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Syncthing-Event", req.eventType.String())
+	if hook.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.cfg.Secret))
+		mac.Write(req.body)
+		httpReq.Header.Set("X-Syncthing-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout:
+		return true, fmt.Errorf("server responded %s", resp.Status)
+	default:
+		return false, fmt.Errorf("server responded %s", resp.Status)
+	}
+}
+
+func (s *webhookService) CommitConfiguration(from, to config.Configuration) bool {
+	if !slices.EqualFunc(from.Webhooks, to.Webhooks, webhookConfigEqual) {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (s *webhookService) String() string {
+	return fmt.Sprintf("webhookService@%p", s)
+}
+
+func webhookConfigEqual(a, b config.WebhookConfiguration) bool {
+	return a.URL == b.URL && a.Secret == b.Secret &&
+		slices.Equal(a.Events, b.Events) &&
+		slices.Equal(a.Folders, b.Folders) &&
+		slices.Equal(a.Devices, b.Devices)
+}
+
+type webhook struct {
+	cfg     config.WebhookConfiguration
+	mask    events.EventType
+	folders map[string]struct{}
+	devices map[string]struct{}
+	queue   chan webhookRequest
+}
+
+type webhookRequest struct {
+	eventType events.EventType
+	body      []byte
+}
+
+type eventSubject struct {
+	Folder string `json:"folder"`
+	Device string `json:"device"`
+}
+
+func newWebhook(cfg config.WebhookConfiguration) *webhook {
+	hook := &webhook{
+		cfg:   cfg,
+		mask:  cfg.EventMask(api.DefaultEventMask),
+		queue: make(chan webhookRequest, webhookQueueSize),
+	}
+	if len(cfg.Folders) > 0 {
+		hook.folders = make(map[string]struct{}, len(cfg.Folders))
+		for _, folder := range cfg.Folders {
+			hook.folders[folder] = struct{}{}
+		}
+	}
+	if len(cfg.Devices) > 0 {
+		hook.devices = make(map[string]struct{}, len(cfg.Devices))
+		for _, dev := range cfg.Devices {
+			hook.devices[dev.String()] = struct{}{}
+		}
+	}
+	return hook
+}
+
+// matches returns true if the event passes the folder and device filters.
+// An event without a folder (device) never passes a folder (device) filter.
+func (h *webhook) matches(subject eventSubject) bool {
+	if h.folders != nil {
+		if _, ok := h.folders[subject.Folder]; !ok {
+			return false
+		}
+	}
+	if h.devices != nil {
+		if _, ok := h.devices[normalizedDeviceID(subject.Device)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizedDeviceID(s string) string {
+	id, err := protocol.DeviceIDFromString(s)
+	if err != nil {
+		return s
+	}
+	return id.String()
+}
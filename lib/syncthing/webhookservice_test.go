@@ -0,0 +1,107 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package syncthing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestWebhookService(t *testing.T) {
+	type received struct {
+		eventType string
+		signature string
+		body      []byte
+	}
+	requests := make(chan received, 10)
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first request, to exercise the retry
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{
+			eventType: r.Header.Get("X-Syncthing-Event"),
+			signature: r.Header.Get("X-Syncthing-Signature"),
+			body:      body,
+		}
+	}))
+	defer srv.Close()
+
+	evLogger := events.NewLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evLogger.Serve(ctx)
+
+	cfg := config.New(protocol.LocalDeviceID)
+	cfg.Webhooks = []config.WebhookConfiguration{{
+		URL:     srv.URL,
+		Events:  []string{"FolderCompletion"},
+		Folders: []string{"default"},
+		Secret:  "s3cr3t",
+	}}
+	w := config.Wrap("/dev/null", cfg, protocol.LocalDeviceID, events.NoopLogger)
+
+	svc := newWebhookService(w, evLogger)
+	svc.retryDelay = time.Millisecond
+	go svc.Serve(ctx)
+
+	// Subscription needs to happen in svc.Serve
+	time.Sleep(10 * time.Millisecond)
+
+	// Neither the wrong event type nor the wrong folder should be sent.
+	evLogger.Log(events.StateChanged, map[string]string{"folder": "default"})
+	evLogger.Log(events.FolderCompletion, map[string]string{"folder": "other"})
+	evLogger.Log(events.FolderCompletion, map[string]interface{}{"folder": "default", "completion": 100})
+
+	var req received
+	select {
+	case req = <-requests:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for webhook request")
+	}
+
+	if req.eventType != "FolderCompletion" {
+		t.Error("unexpected event type header", req.eventType)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(req.body)
+	if expected := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.signature != expected {
+		t.Errorf("signature %q, expected %q", req.signature, expected)
+	}
+	var ev struct {
+		Type string                 `json:"type"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "FolderCompletion" || ev.Data["folder"] != "default" || ev.Data["completion"] != float64(100) {
+		t.Error("unexpected payload", string(req.body))
+	}
+
+	select {
+	case req := <-requests:
+		t.Error("unexpected extra request", string(req.body))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,508 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func init() {
+	// Register the constructor for this type of versioner
+	factories["dedup"] = newDedup
+}
+
+const (
+	dedupBlocksDir = "blocks"
+	dedupIndexDir  = "index"
+)
+
+// The dedup versioner stores each version as a manifest in the index
+// directory, listing the blocks of the file. The blocks themselves are
+// stored once in the blocks directory, named by their hash, and shared
+// between all versions of all files. Blocks are cut and hashed the same way
+// as by the scanner, so a block hash is the same as in the corresponding
+// protocol.FileInfo.Blocks, and those can be used instead of hashing the
+// file again when archiving. Blocks that are no longer referenced by any
+// version are removed by Clean.
+type dedup struct {
+	keep         int
+	cleanoutDays int
+	folderFs     fs.Filesystem
+	versionsFs   fs.Filesystem
+
+	// Archiving and restoring hold the read lock, removing unreferenced
+	// blocks the write lock, so that a block isn't removed between a new
+	// version starting to use it and its manifest being written.
+	blocksMut sync.RWMutex
+}
+
+// dedupManifest describes one version of a file.
+type dedupManifest struct {
+	ModTime     time.Time    `json:"modTime"`
+	Size        int64        `json:"size"`
+	Permissions uint32       `json:"permissions"`
+	Blocks      []dedupBlock `json:"blocks"`
+}
+
+type dedupBlock struct {
+	Hash string `json:"hash"` // hex encoded SHA-256
+	Size int    `json:"size"`
+}
+
+func newDedup(cfg config.FolderConfiguration) Versioner {
+	keep, err := strconv.Atoi(cfg.Versioning.Params["keep"])
+	cleanoutDays, _ := strconv.Atoi(cfg.Versioning.Params["cleanoutDays"])
+	// On error we default to 0, "do not clean out the versioned items"
+
+	if err != nil {
+		keep = 5 // A reasonable default
+	}
+
+	v := &dedup{
+		keep:         keep,
+		cleanoutDays: cleanoutDays,
+		folderFs:     cfg.Filesystem(nil),
+		versionsFs:   versionerFsFromFolderCfg(cfg),
+		blocksMut:    sync.NewRWMutex(),
+	}
+
+	l.Debugf("instantiated %#v", v)
+	return v
+}
+
+func (v *dedup) String() string {
+	return fmt.Sprintf("dedup@%p", v)
+}
+
+// Archive stores the named file as a new version and removes it. If this
+// function returns nil, the named file does not exist any more (has been
+// archived).
+func (v *dedup) Archive(filePath string) error {
+	return v.ArchiveFile(filePath, nil)
+}
+
+// ArchiveFile is like Archive, but uses the blocks of the given file
+// instead of hashing the file again, when it is what's on disk.
+func (v *dedup) ArchiveFile(filePath string, file *protocol.FileInfo) error {
+	v.blocksMut.RLock()
+	err := v.archive(filePath, file, time.Now())
+	v.blocksMut.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	cleanVersions(v.versionsFs, findAllVersions(v.versionsFs, filepath.Join(dedupIndexDir, osutil.NativeFilename(filePath))), v.toRemove)
+	return nil
+}
+
+func (v *dedup) archive(filePath string, file *protocol.FileInfo, now time.Time) error {
+	filePath = osutil.NativeFilename(filePath)
+	info, err := v.folderFs.Lstat(filePath)
+	if fs.IsNotExist(err) {
+		l.Debugln("not archiving nonexistent file", filePath)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsSymlink() {
+		panic("bug: attempting to version a symlink")
+	}
+
+	if _, err := v.versionsFs.Stat("."); fs.IsNotExist(err) {
+		l.Debugln("creating versions dir")
+		if err := v.versionsFs.MkdirAll(".", 0o755); err != nil {
+			return err
+		}
+		_ = v.versionsFs.Hide(".")
+	} else if err != nil {
+		return err
+	}
+
+	var blocks []dedupBlock
+	if file != nil && file.Size == info.Size() && file.ModTime().Equal(info.ModTime()) {
+		blocks, err = v.storeKnownBlocks(filePath, file.Blocks)
+		if err != nil {
+			l.Debugf("not using known blocks for %s: %v", filePath, err)
+			blocks = nil
+		}
+	}
+	if blocks == nil {
+		blocks, err = v.storeBlocks(filePath, info.Size())
+		if err != nil {
+			return err
+		}
+	}
+
+	manifest := dedupManifest{
+		ModTime:     info.ModTime(),
+		Size:        info.Size(),
+		Permissions: uint32(info.Mode() & fs.ModePerm),
+		Blocks:      blocks,
+	}
+	name := filepath.Join(dedupIndexDir, TagFilename(filePath, now.Format(TimeFormat)))
+	l.Debugln("archiving", filePath, "as", name)
+	if err := v.writeManifest(name, manifest); err != nil {
+		return err
+	}
+
+	return v.folderFs.Remove(filePath)
+}
+
+// storeBlocks reads the named file in the folder and stores those of its
+// blocks that aren't already stored.
+func (v *dedup) storeBlocks(filePath string, size int64) ([]dedupBlock, error) {
+	fd, err := v.folderFs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	blockSize := protocol.BlockSize(size)
+	buf := make([]byte, blockSize)
+	var blocks []dedupBlock
+	for {
+		n, err := io.ReadFull(fd, buf)
+		if n > 0 {
+			hash := sha256.Sum256(buf[:n])
+			block := dedupBlock{Hash: hex.EncodeToString(hash[:]), Size: n}
+			if err := v.storeBlock(block, buf[:n]); err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, block)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return blocks, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// storeKnownBlocks stores those of the given blocks of the named file in
+// the folder that aren't already stored. Only those are read, and checked
+// against their hash, so that the file is read in full at most once.
+func (v *dedup) storeKnownBlocks(filePath string, known []protocol.BlockInfo) ([]dedupBlock, error) {
+	fd, err := v.folderFs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	blocks := make([]dedupBlock, 0, len(known))
+	var buf []byte
+	for _, b := range known {
+		block := dedupBlock{Hash: hex.EncodeToString(b.Hash), Size: b.Size}
+		blocks = append(blocks, block)
+		if v.hasBlock(block) {
+			continue
+		}
+		if cap(buf) < b.Size {
+			buf = make([]byte, b.Size)
+		}
+		data := buf[:b.Size]
+		if _, err := fd.ReadAt(data, b.Offset); err != nil {
+			return nil, err
+		}
+		if hash := sha256.Sum256(data); !bytes.Equal(hash[:], b.Hash) {
+			return nil, fmt.Errorf("block at offset %d changed", b.Offset)
+		}
+		if err := v.storeBlock(block, data); err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+func (v *dedup) hasBlock(block dedupBlock) bool {
+	_, err := v.versionsFs.Lstat(blockPath(block.Hash))
+	return err == nil
+}
+
+func (v *dedup) storeBlock(block dedupBlock, data []byte) error {
+	if v.hasBlock(block) {
+		return nil
+	}
+	name := blockPath(block.Hash)
+	if err := v.versionsFs.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(v.versionsFs, name, data, 0o644)
+}
+
+func (v *dedup) writeManifest(name string, manifest dedupManifest) error {
+	bs, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := v.versionsFs.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(v.versionsFs, name, bs, 0o644)
+}
+
+func (v *dedup) readManifest(name string) (dedupManifest, error) {
+	var manifest dedupManifest
+	fd, err := v.versionsFs.Open(name)
+	if err != nil {
+		return manifest, err
+	}
+	defer fd.Close()
+	if err := json.NewDecoder(fd).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("%s: %w", name, err)
+	}
+	return manifest, nil
+}
+
+func (v *dedup) GetVersions() (map[string][]FileVersion, error) {
+	files := make(map[string][]FileVersion)
+	if _, err := v.versionsFs.Lstat(dedupIndexDir); fs.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := v.versionsFs.Walk(dedupIndexDir, func(path string, f fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() || !f.IsRegular() {
+			return nil
+		}
+
+		rel := osutil.NormalizedFilename(strings.TrimPrefix(path, dedupIndexDir+string(fs.PathSeparator)))
+		name, tag := UntagFilename(rel)
+		if name == "" || tag == "" {
+			return nil
+		}
+		versionTime, err := time.ParseInLocation(TimeFormat, tag, time.Local)
+		if err != nil {
+			return nil
+		}
+		manifest, err := v.readManifest(path)
+		if err != nil {
+			l.Debugln("skipping version:", err)
+			return nil
+		}
+
+		files[name] = append(files[name], FileVersion{
+			VersionTime: versionTime,
+			ModTime:     manifest.ModTime.Truncate(time.Second),
+			Size:        manifest.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (v *dedup) Restore(filePath string, versionTime time.Time) error {
+	v.blocksMut.RLock()
+	defer v.blocksMut.RUnlock()
+
+	filePath = osutil.NativeFilename(filePath)
+	tag := versionTime.In(time.Local).Truncate(time.Second).Format(TimeFormat)
+	manifest, err := v.readManifest(filepath.Join(dedupIndexDir, TagFilename(filePath, tag)))
+	if fs.IsNotExist(err) {
+		return errNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// If something already exists where we are restoring to, archive the
+	// existing file, remove it if it's a symlink, or fail if it's a
+	// directory.
+	if info, err := v.folderFs.Lstat(filePath); err == nil {
+		switch {
+		case info.IsDir():
+			return ErrDirectory
+		case info.IsSymlink():
+			if err := v.folderFs.Remove(filePath); err != nil {
+				return fmt.Errorf("removing existing symlink: %w", err)
+			}
+		case info.IsRegular():
+			if err := v.archive(filePath, nil, time.Now()); err != nil {
+				return fmt.Errorf("archiving existing file: %w", err)
+			}
+		default:
+			panic("bug: unknown item type")
+		}
+	} else if !fs.IsNotExist(err) {
+		return err
+	}
+
+	_ = v.folderFs.MkdirAll(filepath.Dir(filePath), 0o755)
+	tempName := fs.TempName(filePath)
+	if err := v.assemble(tempName, manifest); err != nil {
+		_ = v.folderFs.Remove(tempName)
+		return err
+	}
+	_ = v.folderFs.Chtimes(tempName, manifest.ModTime, manifest.ModTime)
+	return v.folderFs.Rename(tempName, filePath)
+}
+
+// assemble writes the blocks of the manifest, in order, to the named file in
+// the folder.
+func (v *dedup) assemble(name string, manifest dedupManifest) error {
+	fd, err := v.folderFs.OpenFile(name, fs.OptWriteOnly|fs.OptCreate|fs.OptExclusive, fs.FileMode(manifest.Permissions))
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	for _, block := range manifest.Blocks {
+		data, err := v.readBlock(block)
+		if err != nil {
+			return err
+		}
+		if _, err := fd.Write(data); err != nil {
+			return err
+		}
+	}
+	return fd.Close()
+}
+
+func (v *dedup) readBlock(block dedupBlock) ([]byte, error) {
+	fd, err := v.versionsFs.Open(blockPath(block.Hash))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	data, err := io.ReadAll(fd)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	if len(data) != block.Size || hex.EncodeToString(hash[:]) != block.Hash {
+		return nil, fmt.Errorf("block %s is corrupt", block.Hash)
+	}
+	return data, nil
+}
+
+// Clean removes expired versions, and then all blocks that are no longer
+// referenced by any remaining version.
+func (v *dedup) Clean(ctx context.Context) error {
+	if _, err := v.versionsFs.Lstat(dedupIndexDir); fs.IsNotExist(err) {
+		return nil
+	}
+	// Block files have no version tag in their names, so this only
+	// expires manifests.
+	if err := clean(ctx, v.versionsFs, v.toRemove); err != nil {
+		return err
+	}
+
+	v.blocksMut.Lock()
+	defer v.blocksMut.Unlock()
+
+	inUse := make(map[string]struct{})
+	err := v.versionsFs.Walk(dedupIndexDir, func(path string, f fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if !f.IsRegular() {
+			return nil
+		}
+		manifest, err := v.readManifest(path)
+		if err != nil {
+			// Be conservative: we don't know which blocks this version
+			// uses, so don't remove any.
+			return err
+		}
+		for _, block := range manifest.Blocks {
+			inUse[block.Hash] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := v.versionsFs.Lstat(dedupBlocksDir); fs.IsNotExist(err) {
+		return nil
+	}
+	dirTracker := make(emptyDirTracker)
+	err = v.versionsFs.Walk(dedupBlocksDir, func(path string, f fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if f.IsDir() {
+			if path != dedupBlocksDir {
+				dirTracker.addDir(path)
+			}
+			return nil
+		}
+		if _, ok := inUse[filepath.Base(path)]; ok {
+			dirTracker.addFile(path)
+			return nil
+		}
+		l.Debugln("removing unreferenced block", path)
+		return v.versionsFs.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+	dirTracker.deleteEmptyDirs(v.versionsFs)
+
+	return nil
+}
+
+func (v *dedup) toRemove(versions []string, now time.Time) []string {
+	return simple{keep: v.keep, cleanoutDays: v.cleanoutDays}.toRemove(versions, now)
+}
+
+// blockPath returns the path of the block with the given hex encoded hash,
+// fanned out over subdirectories by the first bytes of the hash.
+func blockPath(hash string) string {
+	return filepath.Join(dedupBlocksDir, hash[:2], hash[2:4], hash)
+}
+
+// writeFileAtomic writes data to the named file via a temporary file, so
+// that the named file either has the full contents or doesn't exist.
+func writeFileAtomic(filesystem fs.Filesystem, name string, data []byte, mode fs.FileMode) error {
+	tempName := fs.TempName(name)
+	fd, err := filesystem.OpenFile(tempName, fs.OptWriteOnly|fs.OptCreate|fs.OptTruncate, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fd, bytes.NewReader(data)); err != nil {
+		fd.Close()
+		_ = filesystem.Remove(tempName)
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		_ = filesystem.Remove(tempName)
+		return err
+	}
+	return filesystem.Rename(tempName, name)
+}
@@ -0,0 +1,221 @@
+// Copyright (C) 2024 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestDedupVersioning(t *testing.T) {
+	cfg := config.FolderConfiguration{
+		FilesystemType: config.FilesystemTypeBasic,
+		Path:           t.TempDir(),
+		Versioning: config.VersioningConfiguration{
+			Params: map[string]string{
+				"keep": "2",
+			},
+		},
+	}
+	folderFs := cfg.Filesystem(nil)
+	v := newDedup(cfg).(*dedup)
+
+	// Three versions of a four block file, differing only in the last
+	// block.
+	data := make([]byte, 4*protocol.MinBlockSize)
+	rand.New(rand.NewSource(42)).Read(data)
+	versions := make([][]byte, 3)
+	for i := range versions {
+		versions[i] = append([]byte(nil), data...)
+		versions[i][len(data)-1] = byte(i)
+	}
+
+	// Archive them in the past, as restoring archives the current file
+	// with the current time.
+	base := time.Now().Truncate(time.Second)
+	versionTime := func(i int) time.Time {
+		return base.Add(time.Duration(i-3) * time.Minute)
+	}
+	if err := folderFs.MkdirAll("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range versions {
+		writeFile(t, folderFs, "dir/file", string(content))
+		if err := v.archive("dir/file", nil, versionTime(i)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := folderFs.Lstat("dir/file"); !fs.IsNotExist(err) {
+			t.Fatal("file should have been archived, got", err)
+		}
+	}
+
+	// Three shared blocks, plus one distinct last block per version.
+	if n := countBlocks(t, v); n != 6 {
+		t.Errorf("expected 6 stored blocks, got %d", n)
+	}
+
+	vers, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vers["dir/file"]) != 3 {
+		t.Fatalf("expected three versions, got %v", vers)
+	}
+	for _, ver := range vers["dir/file"] {
+		if ver.Size != int64(len(data)) {
+			t.Errorf("unexpected size %d", ver.Size)
+		}
+	}
+
+	// Restoring the middle version archives the current file first.
+	writeFile(t, folderFs, "dir/file", "current")
+	if err := v.Restore("dir/file", versionTime(1)); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := folderFs.Open("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := io.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored, versions[1]) {
+		t.Error("restored content differs from archived version")
+	}
+
+	// Four versions now; cleaning keeps the two newest and removes blocks
+	// that only the expired ones used.
+	if err := v.Clean(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	vers, err = v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vers["dir/file"]) != 2 {
+		t.Errorf("expected two versions after cleaning, got %v", vers)
+	}
+	if n := countBlocks(t, v); n != 5 {
+		t.Errorf("expected 5 stored blocks after cleaning, got %d", n)
+	}
+
+	if err := v.Restore("dir/file", versionTime(0)); err != errNotFound {
+		t.Errorf("expected errNotFound for expired version, got %v", err)
+	}
+}
+
+func countBlocks(t *testing.T, v *dedup) int {
+	t.Helper()
+	n := 0
+	err := v.versionsFs.Walk(dedupBlocksDir, func(_ string, f fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsRegular() {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDedupArchiveKnownBlocks(t *testing.T) {
+	cfg := config.FolderConfiguration{
+		FilesystemType: config.FilesystemTypeBasic,
+		Path:           t.TempDir(),
+		Versioning: config.VersioningConfiguration{
+			Params: map[string]string{
+				"keep": "5",
+			},
+		},
+	}
+	folderFs := cfg.Filesystem(nil)
+	v := newDedup(cfg).(*dedup)
+
+	data := make([]byte, 2*protocol.MinBlockSize)
+	rand.New(rand.NewSource(42)).Read(data)
+	other := make([]byte, protocol.MinBlockSize)
+	rand.New(rand.NewSource(43)).Read(other)
+
+	// The file as the database would know it, given the contents of its
+	// second block.
+	fileInfo := func(second []byte) *protocol.FileInfo {
+		t.Helper()
+		info, err := folderFs.Lstat("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := sha256.Sum256(data[:protocol.MinBlockSize])
+		last := sha256.Sum256(second)
+		return &protocol.FileInfo{
+			Name:       "file",
+			Size:       info.Size(),
+			ModifiedS:  info.ModTime().Unix(),
+			ModifiedNs: int32(info.ModTime().Nanosecond()),
+			Blocks: []protocol.BlockInfo{
+				{Hash: first[:], Size: protocol.MinBlockSize},
+				{Hash: last[:], Offset: protocol.MinBlockSize, Size: protocol.MinBlockSize},
+			},
+		}
+	}
+
+	base := time.Now().Truncate(time.Second)
+	restore := func(i int) []byte {
+		t.Helper()
+		if err := v.Restore("file", base.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		fd, err := folderFs.Open("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fd.Close()
+		bs, err := io.ReadAll(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bs
+	}
+
+	// Blocks matching the contents are used as they are.
+	writeFile(t, folderFs, "file", string(data))
+	if err := v.archive("file", fileInfo(data[protocol.MinBlockSize:]), base); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBlocks(t, v); n != 2 {
+		t.Errorf("expected 2 stored blocks, got %d", n)
+	}
+	if !bytes.Equal(restore(0), data) {
+		t.Error("restored content differs from archived file")
+	}
+
+	// Blocks that don't match the contents are not trusted.
+	if err := folderFs.Remove("file"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, folderFs, "file", string(data))
+	if err := v.archive("file", fileInfo(other), base.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restore(1), data) {
+		t.Error("restored content differs from archived file")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 type Versioner interface {
@@ -24,6 +25,14 @@ type Versioner interface {
 	Clean(context.Context) error
 }
 
+// FileArchiver is implemented by versioners that can make use of what's
+// known about a file, such as its blocks, when archiving it. The file
+// information may be nil, and isn't trusted when it doesn't match what's
+// on disk.
+type FileArchiver interface {
+	ArchiveFile(filePath string, file *protocol.FileInfo) error
+}
+
 type FileVersion struct {
 	VersionTime time.Time `json:"versionTime"`
 	ModTime     time.Time `json:"modTime"`
@@ -69,6 +78,13 @@ func (v *versionerWithErrorContext) Archive(filePath string) error {
 	return v.wrapError(v.Versioner.Archive(filePath), "archive")
 }
 
+func (v *versionerWithErrorContext) ArchiveFile(filePath string, file *protocol.FileInfo) error {
+	if fa, ok := v.Versioner.(FileArchiver); ok {
+		return v.wrapError(fa.ArchiveFile(filePath, file), "archive")
+	}
+	return v.Archive(filePath)
+}
+
 func (v *versionerWithErrorContext) GetVersions() (map[string][]FileVersion, error) {
 	versions, err := v.Versioner.GetVersions()
 	return versions, v.wrapError(err, "get versions")